@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+)
+
+// listBackupsOptions list-backups 命令参数
+type listBackupsOptions struct {
+	json     bool
+	interval time.Duration
+}
+
+// backupCatalog list-backups --json 的输出
+type backupCatalog struct {
+	Pod     string          `json:"pod"`
+	BaseURL string          `json:"base_url"`
+	Backups []catalogBackup `json:"backups"`
+	Gaps    []catalogGap    `json:"gaps"`
+}
+
+type catalogBackup struct {
+	Timestamp  string    `json:"timestamp"`
+	Time       time.Time `json:"time"`
+	Format     string    `json:"format"`
+	Parts      int       `json:"parts,omitempty"`
+	Size       int64     `json:"size"`
+	AgeSeconds int64     `json:"age_seconds"`
+	// Checksum 校验和来源：sidecar、etag 或 none
+	Checksum string `json:"checksum"`
+	URL      string `json:"url"`
+}
+
+type catalogGap struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Missing int       `json:"missing"`
+}
+
+func newListBackupsCmd() *cobra.Command {
+	opts := &listBackupsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list-backups",
+		Short: "列出备份源中当前 Pod 的可用备份",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runListBackups(cmd, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.json, "json", false, "以 JSON 格式输出")
+	flags.DurationVar(&opts.interval, "interval", time.Hour, "期望的备份间隔，用于检测缺失的备份")
+
+	return cmd
+}
+
+func runListBackups(cmd *cobra.Command, opts *listBackupsOptions) error {
+	// 日志输出到 stdout，只保留错误日志，避免混入列表和 JSON 输出
+	cfg, err := loadQuietConfig(nil)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Backup.UsesClusterStream() {
+		return withExitCode(exitConfig, fmt.Errorf("cluster_stream 模式没有备份文件可列出"))
+	}
+	if opts.interval <= 0 {
+		return withExitCode(exitConfig, fmt.Errorf("--interval 必须大于 0: %s", opts.interval))
+	}
+
+	d, err := downloader.NewDownloader(&cfg.Backup)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	filenames, err := cfg.Backup.Filenames()
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	backups, err := downloader.NewDetector(d, filenames).ListBackups(cmd.Context(), cfg.Backup.BaseURL, cfg.Kubernetes.PodName)
+	if err != nil {
+		return withExitCode(exitDetect, err)
+	}
+
+	catalog := buildCatalog(cfg, backups, opts.interval, time.Now())
+	if opts.json {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(catalog)
+	}
+	printCatalog(cmd.OutOrStdout(), catalog)
+	return nil
+}
+
+// buildCatalog 整理备份列表和缺失时段
+func buildCatalog(cfg *config.Config, backups []downloader.BackupObject, interval time.Duration, now time.Time) *backupCatalog {
+	catalog := &backupCatalog{
+		Pod:     cfg.Kubernetes.PodName,
+		BaseURL: cfg.Backup.BaseURL,
+		Backups: []catalogBackup{},
+		Gaps:    []catalogGap{},
+	}
+
+	for _, b := range backups {
+		checksum := b.Checksum
+		if checksum == "" {
+			checksum = "none"
+		}
+		catalog.Backups = append(catalog.Backups, catalogBackup{
+			Timestamp:  b.Timestamp,
+			Time:       b.Time,
+			Format:     b.Ext,
+			Parts:      len(b.Parts),
+			Size:       b.Size,
+			AgeSeconds: int64(now.Sub(b.Time).Seconds()),
+			Checksum:   checksum,
+			URL:        b.URL,
+		})
+	}
+	for _, gap := range downloader.FindGaps(backups, interval, now) {
+		catalog.Gaps = append(catalog.Gaps, catalogGap{
+			From:    gap.From,
+			To:      gap.To,
+			Missing: gap.Missing,
+		})
+	}
+	return catalog
+}
+
+// printCatalog 按时间升序输出备份表格，缺失的时段插入到对应位置
+func printCatalog(out io.Writer, catalog *backupCatalog) {
+	if len(catalog.Backups) == 0 {
+		fmt.Fprintf(out, "未找到 Pod %s 的备份: %s\n", catalog.Pod, catalog.BaseURL)
+		return
+	}
+
+	fmt.Fprintf(out, "Pod %s 的备份（%s，共 %d 个）:\n", catalog.Pod, catalog.BaseURL, len(catalog.Backups))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "时间戳\t时间\t格式\t大小\t距今\t校验和")
+
+	gaps := catalog.Gaps
+	for _, b := range catalog.Backups {
+		for len(gaps) > 0 && gaps[0].From.Before(b.Time) {
+			printGap(w, gaps[0])
+			gaps = gaps[1:]
+		}
+		human, _ := downloader.FormatTimestamp(b.Timestamp)
+		checksum := b.Checksum
+		if checksum == "none" {
+			checksum = "无"
+		}
+		format := b.Format
+		if b.Parts > 0 {
+			format = fmt.Sprintf("%s（%d 卷）", b.Format, b.Parts)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", b.Timestamp, human, format, downloader.FormatBytes(b.Size),
+			formatAge(time.Duration(b.AgeSeconds)*time.Second), checksum)
+	}
+	for _, gap := range gaps {
+		printGap(w, gap)
+	}
+	w.Flush()
+
+	if len(catalog.Gaps) > 0 {
+		missing := 0
+		for _, gap := range catalog.Gaps {
+			missing += gap.Missing
+		}
+		fmt.Fprintf(out, "⚠️  发现 %d 个缺失时段，共缺少 %d 个备份\n", len(catalog.Gaps), missing)
+	}
+}
+
+func printGap(w io.Writer, gap catalogGap) {
+	// 说明放在最后一列之后，不影响前面各列的对齐
+	fmt.Fprintf(w, "⚠️\t\t\t\t\t缺少 %d 个备份: %s ~ %s\n",
+		gap.Missing, gap.From.Format("2006-01-02 15:04"), gap.To.Format("2006-01-02 15:04"))
+}
+
+// formatAge 格式化备份距今时长，如 35m、5h2m、3d4h
+func formatAge(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d >= 24*time.Hour {
+		hours := int(d.Hours())
+		return fmt.Sprintf("%dd%dh", hours/24, hours%24)
+	}
+	return strings.TrimSuffix(d.String(), "0s")
+}
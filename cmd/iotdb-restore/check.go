@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
+	"github.com/vnnox/iotdb-restore-tool/pkg/restorer"
+)
+
+// checkOptions check 命令参数
+type checkOptions struct {
+	timestamp  string
+	before     string
+	atOrBefore string
+	json       bool
+}
+
+func newCheckCmd() *cobra.Command {
+	opts := &checkOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "恢复前预检：检查 Kubernetes 连接、RBAC 权限、Pod、Pod 内命令、磁盘空间和备份",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCheck(cmd.Context(), cmd, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.timestamp, "timestamp", "t", "", "检查指定时间戳的备份（默认按 restore 的规则自动检测）")
+	flags.StringVar(&opts.before, "before", "", "检查早于该时间的最新备份")
+	flags.StringVar(&opts.atOrBefore, "at-or-before", "", "检查不晚于该时间的最新备份")
+	cmd.MarkFlagsMutuallyExclusive("timestamp", "before", "at-or-before")
+	flags.BoolVar(&opts.json, "json", false, "以 JSON 格式输出")
+
+	return cmd
+}
+
+func runCheck(ctx context.Context, cmd *cobra.Command, opts *checkOptions) error {
+	// 只保留错误日志，避免混入检查结果
+	cfg, err := loadQuietConfig(nil)
+	if err != nil {
+		return err
+	}
+
+	clientset, restConfig, err := newKubeClients(cfg)
+	if err != nil {
+		return err
+	}
+
+	// 时间戳检测失败时作为一项失败的检查，其余检查照常执行
+	var detectCheck *restorer.CheckResult
+	timestamp, err := resolveTimestamp(ctx, cfg, &restoreOptions{
+		timestamp:  opts.timestamp,
+		before:     opts.before,
+		atOrBefore: opts.atOrBefore,
+	})
+	if err != nil {
+		detectCheck = &restorer.CheckResult{Name: "备份时间戳", Status: restorer.CheckFail, Detail: err.Error()}
+		timestamp = ""
+	}
+
+	executor := k8s.NewExecutor(clientset, restConfig, cfg.Kubernetes.Namespace, cfg.Kubernetes.PodName, nil)
+	r := restorer.NewRestorer(executor, clientset, restConfig, cfg)
+	result := r.Preflight(ctx, timestamp)
+	if detectCheck != nil {
+		result.Checks = append([]restorer.CheckResult{*detectCheck}, result.Checks...)
+	}
+
+	out := cmd.OutOrStdout()
+	if opts.json {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		printPreflight(out, result)
+	}
+
+	if err := result.Err(); err != nil {
+		return withExitCode(exitPreflight, err)
+	}
+	return nil
+}
+
+// printPreflight 输出预检结果表格
+func printPreflight(out io.Writer, result *restorer.PreflightResult) {
+	if result.Timestamp != "" {
+		fmt.Fprintf(out, "备份时间戳: %s\n", result.Timestamp)
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "检查项\t结果\t详情")
+	for _, check := range result.Checks {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.Name, checkStatusLabel(check.Status), check.Detail)
+	}
+	w.Flush()
+
+	if result.Err() == nil {
+		fmt.Fprintln(out, "✅ 预检通过")
+	} else {
+		fmt.Fprintln(out, "❌ 预检未通过")
+	}
+}
+
+func checkStatusLabel(status string) string {
+	switch status {
+	case restorer.CheckPass:
+		return "通过"
+	case restorer.CheckWarn:
+		return "警告"
+	case restorer.CheckFail:
+		return "失败"
+	default:
+		return "跳过"
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "配置文件相关操作",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "校验配置文件并列出全部问题",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigValidate(cmd)
+		},
+	})
+
+	return cmd
+}
+
+func runConfigValidate(cmd *cobra.Command) error {
+	out := cmd.OutOrStdout()
+
+	_, err := config.LoadWithOverrides(globalOpts.configPath, map[string]interface{}{
+		"namespace": globalOpts.namespace,
+		"pod_name":  globalOpts.podName,
+	})
+	if err == nil {
+		fmt.Fprintf(out, "✅ 配置校验通过: %s\n", globalOpts.configPath)
+		return nil
+	}
+
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) {
+		return withExitCode(exitConfig, err)
+	}
+
+	fmt.Fprintf(out, "❌ 配置校验失败: %s（%d 个问题）\n", globalOpts.configPath, len(validationErr.Errors))
+	for _, fe := range validationErr.Errors {
+		fmt.Fprintf(out, "  - %s: %s\n", fe.Field, fe.Message)
+	}
+	return withExitCode(exitConfig, fmt.Errorf("配置校验失败，共 %d 个问题", len(validationErr.Errors)))
+}
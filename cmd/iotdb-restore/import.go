@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"github.com/vnnox/iotdb-restore-tool/pkg/restorer"
+	"go.uber.org/zap"
+)
+
+// importOptions restore import 命令参数
+type importOptions struct {
+	fromReport string
+}
+
+func newImportCmd() *cobra.Command {
+	opts := &importOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "重新导入导入报告中失败的 tsfile 文件",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cmd.Context(), cmd, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.fromReport, "from-report", "", "导入报告路径（JSON 或 CSV）")
+	_ = cmd.MarkFlagRequired("from-report")
+
+	return cmd
+}
+
+func runImport(ctx context.Context, cmd *cobra.Command, opts *importOptions) error {
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		return err
+	}
+
+	report, err := restorer.ReadImportReport(opts.fromReport)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	failed := restorer.FailedFiles(report.Files)
+	if len(failed) == 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "导入报告 %s 中没有失败的文件\n", opts.fromReport)
+		return nil
+	}
+	files := make([]string, 0, len(failed))
+	for _, record := range failed {
+		files = append(files, record.Path)
+	}
+	logger.Info("从导入报告重新导入失败文件",
+		zap.String("report", opts.fromReport),
+		zap.String("report_run_id", report.RunID),
+		zap.Int("files", len(files)),
+	)
+
+	clientset, restConfig, err := newKubeClients(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, unlock, err := acquireLock(ctx, cfg, clientset, "")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	executor := k8s.NewExecutor(clientset, restConfig, cfg.Kubernetes.Namespace, cfg.Kubernetes.PodName, nil)
+	sqlClient, err := restorer.NewSQLClient(executor, cfg)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	defer sqlClient.Close()
+
+	r := restorer.NewRestorer(executor, clientset, restConfig, cfg)
+	r.SetSQLClient(sqlClient)
+
+	result, err := r.ImportFiles(ctx, files)
+	if result != nil {
+		result.BackupFile = "report:" + opts.fromReport
+	}
+	notify(ctx, cfg, result)
+
+	if err != nil {
+		return withExitCode(phaseExitCode(result), err)
+	}
+	if result.FailedCount > 0 {
+		return withExitCode(exitImportPartial, fmt.Errorf("%d 个 tsfile 文件导入失败，详见 %s", result.FailedCount, result.ImportReport))
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "✅ %d 个文件重新导入成功\n", result.SuccessCount)
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/tsfile"
+)
+
+// inspectOptions inspect-tsfile 命令参数
+type inspectOptions struct {
+	json    bool
+	devices bool
+}
+
+// inspectedFile inspect-tsfile --json 的输出
+type inspectedFile struct {
+	Path string `json:"path"`
+	// Status ok、corrupt（已损坏）或 unsupported（格式不受支持）
+	Status string           `json:"status"`
+	Error  string           `json:"error,omitempty"`
+	Info   *tsfile.FileInfo `json:"info,omitempty"`
+}
+
+func newInspectCmd() *cobra.Command {
+	opts := &inspectOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "inspect-tsfile FILE...",
+		Short: "读取 tsfile 元数据，校验文件完整性并列出设备和时间范围",
+		Long: `读取 tsfile 的文件头和尾部元数据索引，校验文件完整性并列出设备和时间范围。
+FILE 为 - 时从标准输入顺序读取，可用于检查 Pod 内的文件:
+
+  kubectl exec -n iotdb iotdb-datanode-0 -- cat /iotdb/data/.../xxx.tsfile | iotdb-restore inspect-tsfile -`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInspect(cmd, opts, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.json, "json", false, "以 JSON 格式输出")
+	flags.BoolVar(&opts.devices, "devices", false, "列出每个设备的时间范围")
+
+	return cmd
+}
+
+func runInspect(cmd *cobra.Command, opts *inspectOptions, files []string) error {
+	results := make([]inspectedFile, 0, len(files))
+	invalid := 0
+	for _, file := range files {
+		result := inspectFile(cmd.InOrStdin(), file)
+		if result.Status != "ok" {
+			invalid++
+		}
+		results = append(results, result)
+	}
+
+	out := cmd.OutOrStdout()
+	if opts.json {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		printInspected(out, results, opts.devices)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d 个 tsfile 无法通过检查", invalid)
+	}
+	return nil
+}
+
+func inspectFile(stdin io.Reader, file string) inspectedFile {
+	result := inspectedFile{Path: file}
+
+	var info *tsfile.FileInfo
+	var err error
+	if file == "-" {
+		info, err = tsfile.ReadStream(stdin)
+	} else {
+		info, err = readLocalTsFile(file)
+	}
+
+	switch {
+	case err == nil:
+		result.Status = "ok"
+		result.Info = info
+	case errors.Is(err, tsfile.ErrUnsupported):
+		result.Status = "unsupported"
+		result.Error = err.Error()
+	default:
+		result.Status = "corrupt"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func readLocalTsFile(file string) (*tsfile.FileInfo, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return tsfile.Read(f, stat.Size())
+}
+
+func printInspected(out io.Writer, results []inspectedFile, devices bool) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "文件\t状态\t大小\t设备数/序列数\t开始时间\t结束时间")
+	for _, r := range results {
+		if r.Info == nil {
+			fmt.Fprintf(w, "%s\t%s\t\t\t\t%s\n", r.Path, r.Status, r.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", r.Path, r.Status, downloader.FormatBytes(r.Info.Size),
+			len(r.Info.Devices), formatMillis(r.Info.StartTime), formatMillis(r.Info.EndTime))
+		if devices {
+			for _, dev := range r.Info.Devices {
+				fmt.Fprintf(w, "  %s\t\t\t%d\t%s\t%s\n", dev.ID, dev.Series, formatMillis(dev.StartTime), formatMillis(dev.EndTime))
+			}
+		}
+	}
+	w.Flush()
+}
+
+// formatMillis 格式化毫秒时间戳
+func formatMillis(ms int64) string {
+	return time.UnixMilli(ms).Format("2006-01-02 15:04:05.000")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vnnox/iotdb-restore-tool/pkg/lock"
+	"k8s.io/client-go/kubernetes"
+)
+
+func newLockCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "查看或删除恢复任务锁",
+	}
+
+	var jsonOutput bool
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "查看恢复任务锁的持有者和状态",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLockStatus(cmd, jsonOutput)
+		},
+	}
+	statusCmd.Flags().BoolVar(&jsonOutput, "json", false, "以 JSON 格式输出")
+
+	var force bool
+	breakCmd := &cobra.Command{
+		Use:   "break",
+		Short: "删除残留的恢复任务锁",
+		Long: `删除持有者已退出（锁文件残留或 Lease 已过期）的恢复任务锁。
+锁仍被持有时需要 --force，强制删除后原持有者和新任务可能同时操作同一个 Pod。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLockBreak(cmd, force)
+		},
+	}
+	breakCmd.Flags().BoolVar(&force, "force", false, "锁仍被持有时也强制删除")
+
+	cmd.AddCommand(statusCmd, breakCmd)
+	return cmd
+}
+
+func runLockStatus(cmd *cobra.Command, jsonOutput bool) error {
+	locker, err := newLocker()
+	if err != nil {
+		return err
+	}
+	status, err := locker.Status(cmd.Context())
+	if err != nil {
+		return withExitCode(exitLock, err)
+	}
+
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+	printLockStatus(out, status)
+	return nil
+}
+
+func runLockBreak(cmd *cobra.Command, force bool) error {
+	locker, err := newLocker()
+	if err != nil {
+		return err
+	}
+	status, err := locker.Break(cmd.Context(), force)
+	if err != nil {
+		return withExitCode(exitLock, err)
+	}
+
+	out := cmd.OutOrStdout()
+	if status.State == lock.StateFree {
+		fmt.Fprintf(out, "锁未被持有: %s\n", status.Resource)
+		return nil
+	}
+	fmt.Fprintf(out, "✅ 已删除锁: %s\n", status.Resource)
+	printLockStatus(out, status)
+	return nil
+}
+
+// newLocker 按配置创建锁，lease 后端才需要连接 Kubernetes
+func newLocker() (lock.Locker, error) {
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientset kubernetes.Interface
+	if cfg.Lock.Backend == "lease" {
+		if clientset, _, err = newKubeClients(cfg); err != nil {
+			return nil, err
+		}
+	}
+	locker, err := lock.New(cfg, clientset, "")
+	if err != nil {
+		return nil, withExitCode(exitLock, err)
+	}
+	return locker, nil
+}
+
+func printLockStatus(out io.Writer, status *lock.Status) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "后端:\t%s\n", status.Backend)
+	fmt.Fprintf(w, "锁:\t%s\n", status.Resource)
+	if status.Reason != "" {
+		fmt.Fprintf(w, "状态:\t%s（%s）\n", status.State, status.Reason)
+	} else {
+		fmt.Fprintf(w, "状态:\t%s\n", status.State)
+	}
+	if h := status.Holder; h != nil {
+		switch {
+		case h.Identity != "":
+			fmt.Fprintf(w, "持有者:\t%s\n", h.Identity)
+		case h.Host != "":
+			fmt.Fprintf(w, "持有者:\tPID %d@%s\n", h.PID, h.Host)
+		default:
+			fmt.Fprintf(w, "持有者:\tPID %d\n", h.PID)
+		}
+		if h.RunID != "" {
+			fmt.Fprintf(w, "运行 ID:\t%s\n", h.RunID)
+		}
+		if !h.StartedAt.IsZero() {
+			fmt.Fprintf(w, "开始于:\t%s\n", h.StartedAt.Local().Format(time.DateTime))
+		}
+	}
+	if status.ExpiresAt != nil {
+		fmt.Fprintf(w, "过期于:\t%s\n", status.ExpiresAt.Local().Format(time.DateTime))
+	}
+	w.Flush()
+}
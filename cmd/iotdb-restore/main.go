@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// 构建时通过 -ldflags 注入
+var (
+	Version = "dev"
+	Commit  = "none"
+	Date    = "unknown"
+)
+
+// 进程退出码，CronJob 可据此区分失败阶段
+const (
+	exitOK            = 0
+	exitUnknown       = 1
+	exitConfig        = 2
+	exitLock          = 3
+	exitKubernetes    = 4
+	exitDetect        = 5
+	exitPreflight     = 6
+	exitDelete        = 10
+	exitRestart       = 11
+	exitRegion        = 12
+	exitDownload      = 13
+	exitExtract       = 14
+	exitImport        = 15
+	exitProbe         = 16
+	exitImportPartial = 17
+	exitVerify        = 18
+)
+
+// globalOptions 全局命令行参数
+type globalOptions struct {
+	configPath string
+	namespace  string
+	podName    string
+	debug      bool
+}
+
+var globalOpts globalOptions
+
+// exitError 携带退出码的错误
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:])
+	stop()
+	_ = logger.Sync()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string) int {
+	rootCmd := newRootCmd()
+	rootCmd.SetArgs(args)
+
+	err := rootCmd.ExecuteContext(ctx)
+	if err == nil {
+		return exitOK
+	}
+
+	fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitUnknown
+}
+
+func newRootCmd() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:           "iotdb-restore",
+		Short:         "IoTDB 数据库恢复工具",
+		Long:          "从 OSS 备份或同集群源 Pod 恢复 Kubernetes 中的 IoTDB 数据库。",
+		Version:       versionString(),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	rootCmd.SetVersionTemplate("{{.Version}}\n")
+
+	flags := rootCmd.PersistentFlags()
+	flags.StringVarP(&globalOpts.configPath, "config", "c", "configs/config.yaml", "配置文件路径")
+	flags.StringVarP(&globalOpts.namespace, "namespace", "n", "", "Kubernetes 命名空间（覆盖配置文件）")
+	flags.StringVarP(&globalOpts.podName, "pod-name", "p", "", "Pod 名称（覆盖配置文件）")
+	flags.BoolVarP(&globalOpts.debug, "debug", "d", false, "调试模式")
+
+	rootCmd.AddCommand(
+		newRestoreCmd(),
+		newCheckCmd(),
+		newListBackupsCmd(),
+		newInspectCmd(),
+		newLockCmd(),
+		newConfigCmd(),
+		newVersionCmd(),
+	)
+
+	return rootCmd
+}
+
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "显示版本信息",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Fprintln(cmd.OutOrStdout(), versionString())
+		},
+	}
+}
+
+func versionString() string {
+	return fmt.Sprintf("IoTDB Restore Tool %s (commit: %s, built at: %s)", Version, Commit, Date)
+}
+
+// loadConfig 加载配置并初始化日志
+func loadConfig(overrides map[string]interface{}) (*config.Config, error) {
+	if overrides == nil {
+		overrides = map[string]interface{}{}
+	}
+	overrides["namespace"] = globalOpts.namespace
+	overrides["pod_name"] = globalOpts.podName
+
+	cfg, err := config.LoadWithOverrides(globalOpts.configPath, overrides)
+	if err != nil {
+		return nil, withExitCode(exitConfig, err)
+	}
+
+	level := cfg.Log.Level
+	if globalOpts.debug {
+		level = "debug"
+	}
+	if err := logger.Init(level, cfg.Log.Format); err != nil {
+		return nil, withExitCode(exitConfig, fmt.Errorf("初始化日志失败: %w", err))
+	}
+
+	logger.Info("IoTDB Restore Tool 启动",
+		zap.String("version", Version),
+		zap.String("commit", Commit),
+		zap.String("config", globalOpts.configPath),
+		zap.String("namespace", cfg.Kubernetes.Namespace),
+		zap.String("pod", cfg.Kubernetes.PodName),
+	)
+
+	return cfg, nil
+}
+
+// loadQuietConfig 加载配置，日志只保留错误级别，用于输出表格、计划或 JSON 的命令
+func loadQuietConfig(overrides map[string]interface{}) (*config.Config, error) {
+	if overrides == nil {
+		overrides = map[string]interface{}{}
+	}
+	overrides["namespace"] = globalOpts.namespace
+	overrides["pod_name"] = globalOpts.podName
+
+	cfg, err := config.LoadWithOverrides(globalOpts.configPath, overrides)
+	if err != nil {
+		return nil, withExitCode(exitConfig, err)
+	}
+	level := "error"
+	if globalOpts.debug {
+		level = "debug"
+	}
+	if err := logger.Init(level, cfg.Log.Format); err != nil {
+		return nil, withExitCode(exitConfig, fmt.Errorf("初始化日志失败: %w", err))
+	}
+	return cfg, nil
+}
+
+// newKubeClients 创建 Kubernetes 客户端和 REST 配置
+func newKubeClients(cfg *config.Config) (*kubernetes.Clientset, *rest.Config, error) {
+	clientset, err := k8s.NewClient(cfg.Kubernetes.KubeConfig)
+	if err != nil {
+		return nil, nil, withExitCode(exitKubernetes, err)
+	}
+
+	restConfig, err := k8s.NewConfig(cfg.Kubernetes.KubeConfig)
+	if err != nil {
+		return nil, nil, withExitCode(exitKubernetes, err)
+	}
+
+	return clientset, restConfig, nil
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/lock"
+	"github.com/vnnox/iotdb-restore-tool/pkg/restorer"
+	"github.com/vnnox/iotdb-restore-tool/pkg/tsfile/tsfiletest"
+)
+
+func TestPhaseExitCode(t *testing.T) {
+	tests := []struct {
+		phase restorer.Phase
+		want  int
+	}{
+		{phase: restorer.PhasePreflight, want: exitPreflight},
+		{phase: restorer.PhaseDelete, want: exitDelete},
+		{phase: restorer.PhaseSnapshot, want: exitDelete},
+		{phase: restorer.PhaseRestart, want: exitRestart},
+		{phase: restorer.PhaseRegion, want: exitRegion},
+		{phase: restorer.PhaseDownload, want: exitDownload},
+		{phase: restorer.PhaseExtract, want: exitExtract},
+		{phase: restorer.PhaseImport, want: exitImport},
+		{phase: restorer.PhaseProbe, want: exitProbe},
+		{phase: restorer.PhaseVerify, want: exitVerify},
+		{phase: "", want: exitUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.phase), func(t *testing.T) {
+			got := phaseExitCode(&restorer.RestoreResult{FailedPhase: tt.phase})
+			if got != tt.want {
+				t.Fatalf("expected exit code %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExitErrorUnwrap(t *testing.T) {
+	base := errors.New("boom")
+	err := withExitCode(exitDownload, base)
+
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != exitDownload {
+		t.Fatalf("expected exit code %d, got %v", exitDownload, err)
+	}
+	if !errors.Is(err, base) {
+		t.Fatalf("expected wrapped error to match base error")
+	}
+	if withExitCode(exitDownload, nil) != nil {
+		t.Fatalf("expected nil error to stay nil")
+	}
+}
+
+func TestBackupQuery(t *testing.T) {
+	cfg := &config.Config{Backup: config.BackupConfig{MaxAgeHours: 6}}
+	selected := time.Date(2026, 2, 3, 8, 35, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		opts      restoreOptions
+		before    time.Time
+		inclusive bool
+	}{
+		{name: "latest", opts: restoreOptions{}},
+		{name: "before", opts: restoreOptions{before: "2026-02-03 08:35"}, before: selected},
+		{name: "at or before", opts: restoreOptions{atOrBefore: "20260203083500"}, before: selected, inclusive: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := backupQuery(cfg, &tt.opts)
+			if err != nil {
+				t.Fatalf("backup query: %v", err)
+			}
+			if !q.Before.Equal(tt.before) || q.Inclusive != tt.inclusive || q.MaxAge != 6*time.Hour {
+				t.Fatalf("unexpected query %+v", q)
+			}
+		})
+	}
+
+	if _, err := backupQuery(cfg, &restoreOptions{before: "last week"}); err == nil {
+		t.Fatalf("expected error for invalid selector")
+	}
+}
+
+func TestListBackupsCommand(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	latest := now.Add(-time.Hour).Format("20060102150405")
+	for _, ts := range []string{now.Add(-4 * time.Hour).Format("20060102150405"), latest} {
+		name := "emsau_iotdb-datanode-0_" + ts + ".tar.gz"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("backup"), 0644); err != nil {
+			t.Fatalf("write backup: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "emsau_iotdb-datanode-0_"+latest+".tar.gz.sha256"), []byte("sum"), 0644); err != nil {
+		t.Fatalf("write sidecar: %v", err)
+	}
+	t.Setenv("IOTDB_RESTORE_BACKUP_BASE_URL", "file://"+filepath.ToSlash(dir))
+
+	listBackups := func(args ...string) string {
+		var out bytes.Buffer
+		cmd := newRootCmd()
+		cmd.SetOut(&out)
+		cmd.SetArgs(append([]string{"list-backups", "-c", "../../configs/config.example.yaml", "-p", "iotdb-datanode-0"}, args...))
+		if err := cmd.ExecuteContext(context.Background()); err != nil {
+			t.Fatalf("list-backups failed: %v", err)
+		}
+		return out.String()
+	}
+
+	var catalog backupCatalog
+	if err := json.Unmarshal([]byte(listBackups("--json")), &catalog); err != nil {
+		t.Fatalf("decode json output: %v", err)
+	}
+	if len(catalog.Backups) != 2 || catalog.Backups[1].Timestamp != latest || catalog.Backups[1].Checksum != "sidecar" ||
+		catalog.Backups[0].Checksum != "none" {
+		t.Fatalf("unexpected backups %+v", catalog.Backups)
+	}
+	if len(catalog.Gaps) != 1 || catalog.Gaps[0].Missing != 2 {
+		t.Fatalf("expected one gap of 2 missing backups, got %+v", catalog.Gaps)
+	}
+
+	table := listBackups()
+	if !strings.Contains(table, latest) || !strings.Contains(table, "缺少 2 个备份") {
+		t.Fatalf("unexpected table output:\n%s", table)
+	}
+}
+
+func TestRestoreFilter(t *testing.T) {
+	now := time.Date(2026, 2, 10, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name    string
+		opts    restoreOptions
+		since   time.Time
+		until   time.Time
+		wantErr bool
+	}{
+		{name: "relative days", opts: restoreOptions{since: "7d"}, since: now.AddDate(0, 0, -7)},
+		{name: "relative duration", opts: restoreOptions{since: "36h", until: "90m"}, since: now.Add(-36 * time.Hour), until: now.Add(-90 * time.Minute)},
+		{name: "date", opts: restoreOptions{since: "2026-02-01", until: "20260203083500"}, since: time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local), until: time.Date(2026, 2, 3, 8, 35, 0, 0, time.Local)},
+		{name: "invalid", opts: restoreOptions{until: "last week"}, wantErr: true},
+		{name: "reversed", opts: restoreOptions{since: "1d", until: "2d"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := restoreFilter(&tt.opts, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", filter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("restore filter: %v", err)
+			}
+			if !filter.Since.Equal(tt.since) || !filter.Until.Equal(tt.until) {
+				t.Fatalf("unexpected range %s - %s", filter.Since, filter.Until)
+			}
+		})
+	}
+}
+
+func TestRestoreConfigFilterPartialDelete(t *testing.T) {
+	cfg := &config.Config{Databases: []config.DatabaseConfig{{Name: "root.energy"}, {Name: "root.emsplus"}}}
+
+	tests := []struct {
+		name    string
+		opts    restoreOptions
+		wantErr bool
+	}{
+		{name: "whole database", opts: restoreOptions{databases: []string{"root.energy"}}},
+		{name: "device prefix", opts: restoreOptions{pathPrefixes: []string{"root.energy.site42"}}, wantErr: true},
+		{name: "time range", opts: restoreOptions{since: "7d"}, wantErr: true},
+		{name: "skip delete", opts: restoreOptions{since: "7d", skipDelete: true}},
+		{name: "force delete", opts: restoreOptions{pathPrefixes: []string{"root.energy.site42"}, forceDeleteFullDB: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := restoreConfigFilter(cfg, &tt.opts)
+			if tt.wantErr {
+				var exitErr *exitError
+				if !errors.As(err, &exitErr) || exitErr.code != exitConfig {
+					t.Fatalf("expected config error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("restore config filter: %v", err)
+			}
+		})
+	}
+}
+
+func TestInspectCommand(t *testing.T) {
+	dir := t.TempDir()
+	data := tsfiletest.Build(tsfiletest.Device{ID: "root.energy.site42.meter1", Int64: []string{"power"}, StartTime: 1000, EndTime: 5000})
+	valid := filepath.Join(dir, "1770000000000-1-0-0.tsfile")
+	truncated := filepath.Join(dir, "1770000000001-1-0-0.tsfile")
+	if err := os.WriteFile(valid, data, 0644); err != nil {
+		t.Fatalf("write tsfile: %v", err)
+	}
+	if err := os.WriteFile(truncated, data[:len(data)/2], 0644); err != nil {
+		t.Fatalf("write tsfile: %v", err)
+	}
+
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetOut(&out)
+	cmd.SetIn(bytes.NewReader(data))
+	cmd.SetArgs([]string{"inspect-tsfile", "--json", valid, truncated, "-"})
+	if err := cmd.ExecuteContext(context.Background()); err == nil {
+		t.Fatalf("expected error for truncated file")
+	}
+
+	var results []inspectedFile
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatalf("decode json output: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %+v", results)
+	}
+	for i, want := range []string{"ok", "corrupt", "ok"} {
+		if results[i].Status != want {
+			t.Fatalf("expected %s to be %s, got %+v", results[i].Path, want, results[i])
+		}
+	}
+	if info := results[2].Info; len(info.Devices) != 1 || info.StartTime != 1000 || info.EndTime != 5000 {
+		t.Fatalf("unexpected stream result %+v", info)
+	}
+}
+
+func TestLockCommands(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("IOTDB_RESTORE_LOCK_DIR", dir)
+	lockFile := filepath.Join(dir, "iotdb-restore.lock")
+	if err := os.WriteFile(lockFile, []byte(`{"pid": 1, "host": "other-node", "run_id": "20260203-083502-a1b2c3", "started_at": "2026-02-03T08:35:02Z"}`), 0644); err != nil {
+		t.Fatalf("write lock file: %v", err)
+	}
+
+	runLock := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		cmd := newRootCmd()
+		cmd.SetOut(&out)
+		cmd.SetArgs(append([]string{"lock", "-c", "../../configs/config.example.yaml"}, args...))
+		err := cmd.ExecuteContext(context.Background())
+		return out.String(), err
+	}
+
+	out, err := runLock("status", "--json")
+	if err != nil {
+		t.Fatalf("lock status failed: %v", err)
+	}
+	var status lock.Status
+	if err := json.Unmarshal([]byte(out), &status); err != nil {
+		t.Fatalf("decode json output: %v", err)
+	}
+	if status.Resource != lockFile || status.State != lock.StateStale || status.Holder.RunID != "20260203-083502-a1b2c3" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	if out, err = runLock("break"); err != nil {
+		t.Fatalf("lock break failed: %v", err)
+	}
+	if !strings.Contains(out, "已删除锁") {
+		t.Fatalf("unexpected break output:\n%s", out)
+	}
+	if _, err := os.Stat(lockFile); !os.IsNotExist(err) {
+		t.Fatalf("lock file should be removed: %v", err)
+	}
+}
+
+func TestRestoreDryRunFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "json without dry-run", args: []string{"--json"}},
+		{name: "resume with dry-run", args: []string{"--dry-run", "--resume", "20260203-083502-a1b2c3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newRootCmd()
+			cmd.SetOut(&bytes.Buffer{})
+			cmd.SetErr(&bytes.Buffer{})
+			cmd.SetArgs(append([]string{"restore", "-c", "../../configs/config.example.yaml"}, tt.args...))
+			err := cmd.ExecuteContext(context.Background())
+
+			var exitErr *exitError
+			if !errors.As(err, &exitErr) || exitErr.code != exitConfig {
+				t.Fatalf("expected exit code %d, got %v", exitConfig, err)
+			}
+		})
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	plan := &restorer.RestorePlan{
+		Timestamp: "20260203083502",
+		Target:    restorer.PlanPod{Namespace: "iotdb", Name: "iotdb-datanode-0", Ready: true, Databases: []string{"root.energy"}},
+		Input:     restorer.PlanInput{Source: "emsau_iotdb-datanode-0_20260203083502.tar.gz", Size: 3 << 30, Strategy: "pod", Checksum: "none"},
+		Databases: []string{"root.energy", "root.emsplus"},
+		Deletion:  &restorer.PlanDeletion{Databases: []string{"root.energy"}, Paths: []string{"/iotdb/data/datanode/data"}},
+		Steps: []restorer.PlanStep{
+			{Phase: restorer.PhaseDelete, Title: "删除数据库", Commands: []restorer.PlanCommand{{Target: restorer.TargetSQL, Command: "delete database root.energy"}}},
+			{Phase: restorer.PhaseRestart, Title: "重启 Pod", Notes: []string{"删除 Pod 后等待重建就绪"}, EstimatedSeconds: 120},
+		},
+		EstimatedSeconds: 300,
+		Warnings:         []string{"备份大小未知"},
+	}
+
+	var out bytes.Buffer
+	printPlan(&out, plan)
+	for _, want := range []string{
+		"将删除的数据库: root.energy",
+		"大小: 3.00 GB",
+		"1. 删除数据库\n   [sql] delete database root.energy",
+		"2. 重启 Pod（约 2m0s）\n   - 删除 Pod 后等待重建就绪",
+		"预计耗时: 5m0s",
+		"备份大小未知",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in plan output:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestNotifyAfterCancel(t *testing.T) {
+	received := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received <- struct{}{}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{Notification: config.NotificationConfig{
+		Enabled: true,
+		Wechat:  config.WechatConfig{Enabled: true, WebhookURL: srv.URL},
+	}}
+	// 模拟收到 SIGTERM 后的 ctx，失败通知仍需发出
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	now := time.Now()
+	notify(ctx, cfg, &restorer.RestoreResult{StartTime: now, EndTime: now, Error: errors.New("interrupted")})
+
+	select {
+	case <-received:
+	default:
+		t.Fatalf("notification should be sent after the context is cancelled")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/restorer"
+)
+
+// printPlan 输出可读的执行计划
+func printPlan(out io.Writer, plan *restorer.RestorePlan) {
+	fmt.Fprintln(out, "执行计划（干运行，未修改任何数据）")
+	if plan.Timestamp != "" {
+		fmt.Fprintf(out, "备份时间戳: %s\n", plan.Timestamp)
+	}
+
+	target := plan.Target
+	state := "未就绪"
+	if target.Ready {
+		state = "就绪"
+	}
+	fmt.Fprintf(out, "目标 Pod: %s/%s（%s", target.Namespace, target.Name, state)
+	if target.Node != "" {
+		fmt.Fprintf(out, "，节点 %s", target.Node)
+	}
+	fmt.Fprintln(out, "）")
+	if len(target.Databases) > 0 {
+		fmt.Fprintf(out, "  现有数据库: %s\n", strings.Join(target.Databases, ", "))
+	}
+	if target.LiveDataSize > 0 {
+		fmt.Fprintf(out, "  在线数据: %s\n", downloader.FormatBytes(target.LiveDataSize))
+	}
+
+	input := plan.Input
+	fmt.Fprintf(out, "输入: %s（策略 %s，校验 %s）\n", input.Source, input.Strategy, input.Checksum)
+	for _, file := range input.Files {
+		fmt.Fprintf(out, "  %s\n", file)
+	}
+	if input.Size > 0 {
+		fmt.Fprintf(out, "  大小: %s\n", downloader.FormatBytes(input.Size))
+	} else {
+		fmt.Fprintln(out, "  大小: 未知")
+	}
+	fmt.Fprintf(out, "恢复数据库: %s\n", strings.Join(plan.Databases, ", "))
+
+	if d := plan.Deletion; d != nil {
+		if d.Snapshot != "" {
+			fmt.Fprintf(out, "删除前创建快照: %s\n", d.Snapshot)
+		}
+		fmt.Fprintf(out, "将删除的数据库: %s\n", strings.Join(d.Databases, ", "))
+		for _, path := range d.Paths {
+			fmt.Fprintf(out, "  %s\n", path)
+		}
+	}
+
+	if s := plan.Stream; s != nil {
+		fmt.Fprintf(out, "源 Pod: %s/%s（数据目录 %s）\n", s.SourceNamespace, s.SourcePod, s.SourceDataDir)
+		for _, path := range s.Paths {
+			fmt.Fprintf(out, "  %s\n", path)
+		}
+	}
+
+	if len(plan.Disk) > 0 {
+		fmt.Fprintln(out, "磁盘空间:")
+		for _, disk := range plan.Disk {
+			fmt.Fprintf(out, "  %s: 需要 %s，可用 %s（%s）\n", disk.Mount,
+				downloader.FormatBytes(disk.Needed), downloader.FormatBytes(disk.Available), strings.Join(disk.Usages, " + "))
+		}
+	}
+
+	fmt.Fprintln(out, "步骤:")
+	for i, step := range plan.Steps {
+		fmt.Fprintf(out, "%d. %s", i+1, step.Title)
+		if step.EstimatedSeconds > 0 {
+			fmt.Fprintf(out, "（约 %s）", time.Duration(step.EstimatedSeconds)*time.Second)
+		}
+		fmt.Fprintln(out)
+		for _, cmd := range step.Commands {
+			fmt.Fprintf(out, "   [%s] %s\n", cmd.Target, cmd.Command)
+		}
+		for _, note := range step.Notes {
+			fmt.Fprintf(out, "   - %s\n", note)
+		}
+	}
+	fmt.Fprintf(out, "预计耗时: %s\n", time.Duration(plan.EstimatedSeconds)*time.Second)
+
+	if plan.Preflight != nil {
+		fmt.Fprintln(out)
+		printPreflight(out, plan.Preflight)
+	}
+
+	if len(plan.Warnings) > 0 {
+		fmt.Fprintln(out, "警告:")
+		for _, warning := range plan.Warnings {
+			fmt.Fprintf(out, "  ⚠️ %s\n", warning)
+		}
+	}
+}
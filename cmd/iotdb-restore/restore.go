@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/journal"
+	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
+	"github.com/vnnox/iotdb-restore-tool/pkg/lock"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"github.com/vnnox/iotdb-restore-tool/pkg/notifier"
+	"github.com/vnnox/iotdb-restore-tool/pkg/restorer"
+	"github.com/vnnox/iotdb-restore-tool/pkg/selector"
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+)
+
+// restoreOptions restore 命令参数
+type restoreOptions struct {
+	timestamp   string
+	before      string
+	atOrBefore  string
+	concurrency int
+	batchSize   int
+	dryRun      bool
+	// json 干运行时以 JSON 格式输出执行计划
+	json       bool
+	skipDelete bool
+	// forceDeleteFullDB 恢复范围只覆盖部分数据时仍删除整个数据库
+	forceDeleteFullDB bool
+	resume            string
+	// skipPreflight 跳过删除前的预检
+	skipPreflight bool
+	// 选择性恢复条件
+	databases    []string
+	pathPrefixes []string
+	since        string
+	until        string
+}
+
+func newRestoreCmd() *cobra.Command {
+	opts := &restoreOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "执行 IoTDB 数据恢复",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.dryRun {
+				return runDryRun(cmd.Context(), cmd.OutOrStdout(), opts)
+			}
+			if opts.json {
+				return withExitCode(exitConfig, fmt.Errorf("--json 只能与 --dry-run 一起使用"))
+			}
+			return runRestore(cmd.Context(), opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.timestamp, "timestamp", "t", "", "备份文件时间戳（如：20260203083502）")
+	flags.StringVar(&opts.before, "before", "", "选择早于该时间的最新备份（如：20260203083502 或 2026-02-03 08:35）")
+	flags.StringVar(&opts.atOrBefore, "at-or-before", "", "选择不晚于该时间的最新备份")
+	cmd.MarkFlagsMutuallyExclusive("timestamp", "before", "at-or-before")
+	flags.IntVar(&opts.concurrency, "concurrency", 0, "并发数（覆盖配置文件）")
+	flags.IntVar(&opts.batchSize, "batch-size", 0, "批次大小（覆盖配置文件）")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "干运行：生成执行计划，不修改任何数据")
+	flags.BoolVar(&opts.json, "json", false, "以 JSON 格式输出干运行的执行计划")
+	flags.BoolVar(&opts.skipDelete, "skip-delete", false, "跳过删除现有数据库")
+	flags.BoolVar(&opts.forceDeleteFullDB, "force-delete-full-db", false, "按时间范围或设备前缀恢复时仍删除整个数据库（范围外的数据会丢失）")
+	cmd.MarkFlagsMutuallyExclusive("skip-delete", "force-delete-full-db")
+	flags.BoolVar(&opts.skipPreflight, "skip-preflight", false, "跳过恢复前预检（不建议）")
+	flags.StringVar(&opts.resume, "resume", "", "从指定运行 ID 续传（跳过已完成阶段和已导入文件）")
+	flags.StringSliceVar(&opts.databases, "database", nil, "只恢复指定的数据库（可重复或逗号分隔，如 root.energy），其他数据库不删除")
+	flags.StringSliceVar(&opts.pathPrefixes, "path-prefix", nil, "只恢复指定路径前缀所在的数据库（如 root.energy.site42）")
+	flags.StringVar(&opts.since, "since", "", "只导入时间分区晚于该时间的 tsfile（如 2026-02-01、20260201000000 或 7d 表示 7 天前）")
+	flags.StringVar(&opts.until, "until", "", "只导入时间分区早于该时间的 tsfile，格式同 --since")
+
+	cmd.AddCommand(newImportCmd())
+
+	return cmd
+}
+
+func runRestore(ctx context.Context, opts *restoreOptions) error {
+	cfg, err := loadConfig(restoreOverrides(opts))
+	if err != nil {
+		return err
+	}
+
+	filter, err := restoreConfigFilter(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	clientset, restConfig, err := newKubeClients(cfg)
+	if err != nil {
+		return err
+	}
+
+	// 运行 ID 在获取锁之前确定，写入锁的持有者信息
+	runID := opts.resume
+	if runID == "" {
+		runID = journal.NewRunID(time.Now())
+	}
+	ctx, unlock, err := acquireLock(ctx, cfg, clientset, runID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	checker := k8s.NewPodChecker(clientset, cfg.Kubernetes.Namespace)
+	running, err := checker.IsRunning(ctx, cfg.Kubernetes.PodName)
+	if err != nil {
+		return withExitCode(exitKubernetes, err)
+	}
+	if !running {
+		return withExitCode(exitKubernetes, fmt.Errorf("Pod %s/%s 未处于运行状态", cfg.Kubernetes.Namespace, cfg.Kubernetes.PodName))
+	}
+
+	store, err := journal.NewStore(cfg, clientset)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+
+	// 续传时时间戳取自运行日志，不再重新检测
+	timestamp := opts.timestamp
+	if opts.resume == "" {
+		timestamp, err = resolveTimestamp(ctx, cfg, opts)
+	}
+	if err != nil {
+		now := time.Now()
+		notify(ctx, cfg, &restorer.RestoreResult{
+			StartTime: now,
+			EndTime:   now,
+			Error:     err,
+		})
+		return withExitCode(exitDetect, err)
+	}
+
+	executor := k8s.NewExecutor(clientset, restConfig, cfg.Kubernetes.Namespace, cfg.Kubernetes.PodName, nil)
+	sqlClient, err := restorer.NewSQLClient(executor, cfg)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	defer sqlClient.Close()
+
+	r := restorer.NewRestorer(executor, clientset, restConfig, cfg)
+	r.SetSQLClient(sqlClient)
+	r.SetJournalStore(store)
+
+	result, err := r.Restore(ctx, restorer.RestoreOptions{
+		Timestamp:         timestamp,
+		SkipDelete:        opts.skipDelete,
+		SkipPreflight:     opts.skipPreflight,
+		ResumeRunID:       opts.resume,
+		RunID:             runID,
+		Filter:            filter,
+		ForceDeleteFullDB: opts.forceDeleteFullDB,
+	})
+	if result != nil && result.RunID != "" && err != nil {
+		fmt.Fprintf(os.Stderr, "可使用 --resume %s 续传本次恢复\n", result.RunID)
+	}
+	if result != nil && result.Rollback != nil && result.Rollback.Error != "" {
+		fmt.Fprintf(os.Stderr, "未能回滚（%s），恢复前的数据保留在快照 %s 中，请手动还原\n", result.Rollback.Error, result.Rollback.Snapshot)
+	}
+
+	notify(ctx, cfg, result)
+
+	if err != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, lock.ErrLost) {
+			return withExitCode(exitLock, fmt.Errorf("%w: %w", cause, err))
+		}
+		return withExitCode(phaseExitCode(result), err)
+	}
+	if result.FailedCount > 0 {
+		return withExitCode(exitImportPartial, fmt.Errorf("%d 个 tsfile 文件导入失败", result.FailedCount))
+	}
+
+	return nil
+}
+
+// runDryRun 生成并输出执行计划。干运行只读取状态：不获取锁、不写运行日志、不发送通知
+func runDryRun(ctx context.Context, out io.Writer, opts *restoreOptions) error {
+	if opts.resume != "" {
+		return withExitCode(exitConfig, fmt.Errorf("--resume 不能与 --dry-run 一起使用"))
+	}
+
+	// 只保留错误日志，避免混入执行计划
+	cfg, err := loadQuietConfig(restoreOverrides(opts))
+	if err != nil {
+		return err
+	}
+
+	filter, err := restoreConfigFilter(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	clientset, restConfig, err := newKubeClients(cfg)
+	if err != nil {
+		return err
+	}
+
+	timestamp, err := resolveTimestamp(ctx, cfg, opts)
+	if err != nil {
+		return withExitCode(exitDetect, err)
+	}
+
+	executor := k8s.NewExecutor(clientset, restConfig, cfg.Kubernetes.Namespace, cfg.Kubernetes.PodName, nil)
+	r := restorer.NewRestorer(executor, clientset, restConfig, cfg)
+	result, err := r.Restore(ctx, restorer.RestoreOptions{
+		Timestamp:         timestamp,
+		DryRun:            true,
+		SkipDelete:        opts.skipDelete,
+		SkipPreflight:     opts.skipPreflight,
+		Filter:            filter,
+		ForceDeleteFullDB: opts.forceDeleteFullDB,
+	})
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+
+	plan := result.Plan
+	if opts.json {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			return err
+		}
+	} else {
+		printPlan(out, plan)
+	}
+
+	if plan.Preflight != nil {
+		if err := plan.Preflight.Err(); err != nil {
+			return withExitCode(exitPreflight, err)
+		}
+	}
+	return nil
+}
+
+// restoreOverrides restore 命令覆盖配置文件的参数
+func restoreOverrides(opts *restoreOptions) map[string]interface{} {
+	return map[string]interface{}{
+		"concurrency": opts.concurrency,
+		"batch_size":  opts.batchSize,
+		"timestamp":   opts.timestamp,
+	}
+}
+
+// restoreConfigFilter 构建选择性恢复条件，并校验其能匹配配置中的数据库。
+// 恢复范围只覆盖部分数据时须指定 --skip-delete 或 --force-delete-full-db
+func restoreConfigFilter(cfg *config.Config, opts *restoreOptions) (selector.Filter, error) {
+	filter, err := restoreFilter(opts, time.Now())
+	if err != nil {
+		return filter, withExitCode(exitConfig, err)
+	}
+	if !filter.IsZero() {
+		dbNames := make([]string, 0, len(cfg.Databases))
+		for _, db := range cfg.Databases {
+			dbNames = append(dbNames, db.Name)
+		}
+		names, err := filter.SelectDatabases(dbNames)
+		if err != nil {
+			return filter, withExitCode(exitConfig, err)
+		}
+		if !opts.skipDelete && !opts.forceDeleteFullDB {
+			for _, name := range names {
+				if filter.Partial(name) {
+					return filter, withExitCode(exitConfig, fmt.Errorf("恢复范围只覆盖数据库 %s 的部分数据，删除阶段会删除整个数据库；请使用 --skip-delete 保留范围外的数据，或使用 --force-delete-full-db 确认删除", name))
+				}
+			}
+		}
+	}
+	return filter, nil
+}
+
+// acquireLock 获取恢复任务锁，防止多个恢复任务同时操作同一个 Pod。
+// 返回的 ctx 在锁被其他任务接管时取消，原因为 lock.ErrLost
+func acquireLock(ctx context.Context, cfg *config.Config, clientset kubernetes.Interface, runID string) (context.Context, func(), error) {
+	locker, err := lock.New(cfg, clientset, runID)
+	if err != nil {
+		return nil, nil, withExitCode(exitLock, err)
+	}
+	if err := locker.TryLock(ctx); err != nil {
+		logger.Error("无法获取锁，可能已有任务在运行", zap.Error(err))
+		return nil, nil, withExitCode(exitLock, err)
+	}
+	logger.Info("恢复任务锁获取成功", zap.String("backend", cfg.Lock.Backend))
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	if lost := locker.Lost(); lost != nil {
+		go func() {
+			select {
+			case <-lost:
+				cancel(lock.ErrLost)
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	return ctx, func() {
+		cancel(nil)
+		// 恢复被中断时 ctx 已取消，释放锁使用独立的超时
+		unlockCtx, unlockCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer unlockCancel()
+		if err := locker.Unlock(unlockCtx); err != nil {
+			logger.Warn("释放恢复任务锁失败", zap.Error(err))
+		}
+	}, nil
+}
+
+// resolveTimestamp 校验指定的时间戳，或列举备份选择最新（或指定时间点之前）的备份
+func resolveTimestamp(ctx context.Context, cfg *config.Config, opts *restoreOptions) (string, error) {
+	if cfg.Backup.UsesClusterStream() {
+		if opts.timestamp != "" || opts.before != "" || opts.atOrBefore != "" {
+			logger.Warn("cluster_stream 模式忽略 timestamp、before 和 at-or-before 参数")
+		}
+		return "", nil
+	}
+
+	if opts.timestamp != "" {
+		if err := downloader.ValidateTimestamp(opts.timestamp); err != nil {
+			return "", err
+		}
+		return opts.timestamp, nil
+	}
+
+	query, err := backupQuery(cfg, opts)
+	if err != nil {
+		return "", err
+	}
+	if query.Before.IsZero() && !cfg.Backup.AutoDetectTimestamp {
+		return "", fmt.Errorf("未指定时间戳且未启用 auto_detect_timestamp")
+	}
+
+	d, err := downloader.NewDownloader(&cfg.Backup)
+	if err != nil {
+		return "", err
+	}
+	filenames, err := cfg.Backup.Filenames()
+	if err != nil {
+		return "", err
+	}
+	detected, err := downloader.NewDetector(d, filenames).FindBackup(ctx, cfg.Backup.BaseURL, cfg.Kubernetes.PodName, query)
+	if err != nil {
+		return "", err
+	}
+	logger.Info("自动检测到时间戳", zap.String("timestamp", detected))
+	return detected, nil
+}
+
+// backupQuery 根据 --before/--at-or-before 和 max_age_hours 构建备份选择条件
+func backupQuery(cfg *config.Config, opts *restoreOptions) (downloader.BackupQuery, error) {
+	query := downloader.BackupQuery{MaxAge: time.Duration(cfg.Backup.MaxAgeHours) * time.Hour}
+
+	selector := opts.before
+	if opts.atOrBefore != "" {
+		selector = opts.atOrBefore
+		query.Inclusive = true
+	}
+	if selector == "" {
+		return query, nil
+	}
+
+	t, err := downloader.ParseTimeSelector(selector)
+	if err != nil {
+		return query, err
+	}
+	query.Before = t
+	return query, nil
+}
+
+// restoreFilter 根据 --database/--path-prefix/--since/--until 构建选择性恢复条件
+func restoreFilter(opts *restoreOptions, now time.Time) (selector.Filter, error) {
+	filter := selector.Filter{
+		Databases:    opts.databases,
+		PathPrefixes: opts.pathPrefixes,
+	}
+	var err error
+	if opts.since != "" {
+		if filter.Since, err = parseTimeBound(opts.since, now); err != nil {
+			return filter, fmt.Errorf("--since: %w", err)
+		}
+	}
+	if opts.until != "" {
+		if filter.Until, err = parseTimeBound(opts.until, now); err != nil {
+			return filter, fmt.Errorf("--until: %w", err)
+		}
+	}
+	return filter, filter.Validate()
+}
+
+// parseTimeBound 解析时间范围边界：绝对时间（同 --before，另支持 2006-01-02），
+// 或相对当前时间的时长，如 7d、36h、90m
+func parseTimeBound(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return downloader.ParseTimeSelector(value)
+}
+
+// phaseExitCode 根据失败阶段返回退出码
+func phaseExitCode(result *restorer.RestoreResult) int {
+	if result == nil {
+		return exitUnknown
+	}
+
+	switch result.FailedPhase {
+	case restorer.PhasePreflight:
+		return exitPreflight
+	case restorer.PhaseDelete, restorer.PhaseSnapshot:
+		return exitDelete
+	case restorer.PhaseRestart:
+		return exitRestart
+	case restorer.PhaseRegion:
+		return exitRegion
+	case restorer.PhaseDownload:
+		return exitDownload
+	case restorer.PhaseExtract:
+		return exitExtract
+	case restorer.PhaseImport:
+		return exitImport
+	case restorer.PhaseProbe:
+		return exitProbe
+	case restorer.PhaseVerify:
+		return exitVerify
+	default:
+		return exitUnknown
+	}
+}
+
+// notify 发送恢复结果通知，失败仅记录日志
+func notify(ctx context.Context, cfg *config.Config, result *restorer.RestoreResult) {
+	if !cfg.Notification.Enabled || result == nil {
+		return
+	}
+
+	// 收到 SIGTERM 或锁被接管时 ctx 已取消，失败通知仍需发出，使用独立的超时
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	n := notifier.NewWechatNotifier(&cfg.Notification)
+	if err := n.Send(ctx, result); err != nil {
+		logger.Warn("发送通知失败", zap.Error(err))
+	}
+}
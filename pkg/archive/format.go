@@ -0,0 +1,147 @@
+// Package archive 识别备份归档格式（tar、tar.gz、tar.zst、tar.lz4）和分卷文件名
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format 归档格式
+type Format string
+
+const (
+	Unknown Format = ""
+	Tar     Format = "tar"
+	Gzip    Format = "tar.gz"
+	Zstd    Format = "tar.zst"
+	LZ4     Format = "tar.lz4"
+)
+
+// MagicSize 识别格式需要读取的文件头长度（tar 的 ustar 标识位于偏移 257）
+const MagicSize = 262
+
+// extensions 扩展名与格式的对应关系，顺序即同一时间戳存在多种格式时的优先级
+var extensions = []struct {
+	ext    string
+	format Format
+}{
+	{"tar.gz", Gzip},
+	{"tar.zst", Zstd},
+	{"tar.lz4", LZ4},
+	{"tar", Tar},
+	{"tgz", Gzip},
+	{"tzst", Zstd},
+}
+
+// magics 压缩格式的文件头
+var magics = []struct {
+	magic  []byte
+	format Format
+}{
+	{[]byte{0x1f, 0x8b}, Gzip},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, Zstd},
+	{[]byte{0x04, 0x22, 0x4d, 0x18}, LZ4},
+	// lz4 legacy 帧格式（lz4 -l）
+	{[]byte{0x02, 0x21, 0x4c, 0x18}, LZ4},
+}
+
+// PartPattern 分卷后缀的正则，如 .part000
+const PartPattern = `\.part(\d{3,})`
+
+var partSuffix = regexp.MustCompile(PartPattern + `$`)
+
+// Extensions 返回支持的归档扩展名，按优先级排列
+func Extensions() []string {
+	exts := make([]string, 0, len(extensions))
+	for _, e := range extensions {
+		exts = append(exts, e.ext)
+	}
+	return exts
+}
+
+// FromExtension 按文件名扩展名识别格式，分卷文件按去掉 .partNNN 后的文件名识别
+func FromExtension(name string) Format {
+	if base, _, ok := SplitPart(name); ok {
+		name = base
+	}
+	for _, e := range extensions {
+		if strings.HasSuffix(name, "."+e.ext) {
+			return e.format
+		}
+	}
+	return Unknown
+}
+
+// FromMagic 按文件头识别格式，header 至少需要 MagicSize 字节才能识别未压缩的 tar
+func FromMagic(header []byte) Format {
+	for _, m := range magics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.format
+		}
+	}
+	if len(header) >= MagicSize && string(header[257:262]) == "ustar" {
+		return Tar
+	}
+	return Unknown
+}
+
+// Detect 优先按文件头识别格式，无法识别时按文件名扩展名识别
+func Detect(header []byte, name string) Format {
+	if f := FromMagic(header); f != Unknown {
+		return f
+	}
+	return FromExtension(name)
+}
+
+// DetectFile 读取本地文件头识别格式，返回按文件头和按扩展名识别的结果
+func DetectFile(path string) (magic, ext Format, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Unknown, Unknown, fmt.Errorf("打开归档失败: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, MagicSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Unknown, Unknown, fmt.Errorf("读取归档文件头失败: %w", err)
+	}
+	return FromMagic(header[:n]), FromExtension(path), nil
+}
+
+// Decompressor 返回解压该格式需要的外部命令，未压缩的 tar 返回空字符串
+func (f Format) Decompressor() string {
+	switch f {
+	case Gzip:
+		return "gzip"
+	case Zstd:
+		return "zstd"
+	case LZ4:
+		return "lz4"
+	default:
+		return ""
+	}
+}
+
+// SplitPart 解析分卷文件名 <name>.partNNN，返回不含分卷后缀的文件名和分卷序号
+func SplitPart(name string) (base string, part int, ok bool) {
+	m := partSuffix.FindStringSubmatchIndex(name)
+	if m == nil {
+		return name, 0, false
+	}
+	part, err := strconv.Atoi(name[m[2]:m[3]])
+	if err != nil {
+		return name, 0, false
+	}
+	return name[:m[0]], part, true
+}
+
+// PartName 构建第 part 个分卷的文件名
+func PartName(base string, part int) string {
+	return fmt.Sprintf("%s.part%03d", base, part)
+}
@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tarHeader() []byte {
+	header := make([]byte, 512)
+	copy(header, "data/datanode/data/sequence/1.tsfile")
+	copy(header[257:], "ustar\x0000")
+	return header
+}
+
+func TestFromMagic(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   Format
+	}{
+		{name: "gzip", header: []byte{0x1f, 0x8b, 0x08, 0x00}, want: Gzip},
+		{name: "zstd", header: []byte{0x28, 0xb5, 0x2f, 0xfd, 0x24}, want: Zstd},
+		{name: "lz4 frame", header: []byte{0x04, 0x22, 0x4d, 0x18, 0x64}, want: LZ4},
+		{name: "lz4 legacy", header: []byte{0x02, 0x21, 0x4c, 0x18}, want: LZ4},
+		{name: "plain tar", header: tarHeader(), want: Tar},
+		{name: "truncated tar", header: tarHeader()[:200], want: Unknown},
+		{name: "text", header: []byte("<?xml version=\"1.0\"?><Error>AccessDenied</Error>"), want: Unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromMagic(tt.header); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFromExtension(t *testing.T) {
+	tests := []struct {
+		name string
+		want Format
+	}{
+		{name: "emsau_iotdb-datanode-0_20260203083502.tar.gz", want: Gzip},
+		{name: "emsau_iotdb-datanode-0_20260203083502.tgz", want: Gzip},
+		{name: "emsau_iotdb-datanode-0_20260203083502.tar.zst", want: Zstd},
+		{name: "emsau_iotdb-datanode-0_20260203083502.tar.zst.part001", want: Zstd},
+		{name: "emsau_iotdb-datanode-0_20260203083502.tar.lz4", want: LZ4},
+		{name: "emsau_iotdb-datanode-0_20260203083502.tar", want: Tar},
+		{name: "emsau_iotdb-datanode-0_20260203083502.tar.gz.sha256", want: Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := FromExtension(tt.name); got != tt.want {
+			t.Fatalf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSplitPart(t *testing.T) {
+	base, part, ok := SplitPart("backup.tar.zst.part012")
+	if !ok || base != "backup.tar.zst" || part != 12 {
+		t.Fatalf("unexpected split result: %s %d %v", base, part, ok)
+	}
+	if PartName(base, part) != "backup.tar.zst.part012" {
+		t.Fatalf("unexpected part name %s", PartName(base, part))
+	}
+	if _, _, ok := SplitPart("backup.tar.zst.part1"); ok {
+		t.Fatalf("part suffix needs at least 3 digits")
+	}
+}
+
+func TestDetectFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := os.WriteFile(path, []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	magic, ext, err := DetectFile(path)
+	if err != nil {
+		t.Fatalf("detect: %v", err)
+	}
+	if magic != Zstd || ext != Gzip {
+		t.Fatalf("expected magic zstd and extension gzip, got %q %q", magic, ext)
+	}
+}
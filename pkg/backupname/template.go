@@ -0,0 +1,247 @@
+// Package backupname 根据文件名模板构建和解析备份文件名
+package backupname
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/archive"
+)
+
+const (
+	// DefaultTemplate 默认备份文件名模板，对应 emsau_<pod>_<YYYYMMDDHHMMSS>.tar.gz
+	DefaultTemplate = "{prefix}_{pod}_{ts:20060102150405}.{ext}"
+	// DefaultPrefix 默认文件名前缀
+	DefaultPrefix = "emsau"
+	// DefaultExtension 未知归档格式时使用的扩展名
+	DefaultExtension = "tar.gz"
+	// TimestampLayout 工具内部统一使用的时间戳格式（-t 参数、运行日志），与模板中的时间布局无关
+	TimestampLayout = "20060102150405"
+)
+
+// Extensions {ext} 可匹配的归档扩展名
+var Extensions = archive.Extensions()
+
+// safeFilename 生成的文件名只允许这些字符，文件名会直接拼入 Pod 内的 shell 命令
+var safeFilename = regexp.MustCompile(`^[A-Za-z0-9._:+=@-]+$`)
+
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// segment 模板片段：字面量或占位符
+type segment struct {
+	literal     string
+	placeholder string
+}
+
+// Template 备份文件名模板，支持占位符：
+//   - {prefix}: 文件名前缀（backup.filename_prefix）
+//   - {pod}: Pod 名称
+//   - {ts:<Go 时间布局>}: 备份时间，{ts} 等价于 {ts:20060102150405}
+//   - {ext}: 归档扩展名，解析时匹配 Extensions 中的任意一个
+type Template struct {
+	raw      string
+	prefix   string
+	layout   string
+	segments []segment
+}
+
+// Parse 解析文件名模板，模板必须且只能包含一个 {ts}
+func Parse(tmpl, prefix string) (*Template, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return nil, fmt.Errorf("文件名模板不能为空")
+	}
+	if strings.Contains(tmpl, "/") {
+		return nil, fmt.Errorf("文件名模板不能包含目录分隔符: %s", tmpl)
+	}
+
+	t := &Template{raw: tmpl, prefix: prefix}
+	last := 0
+	for _, loc := range placeholderPattern.FindAllStringIndex(tmpl, -1) {
+		if loc[0] > last {
+			t.segments = append(t.segments, segment{literal: tmpl[last:loc[0]]})
+		}
+		name := tmpl[loc[0]+1 : loc[1]-1]
+		switch {
+		case name == "prefix", name == "pod", name == "ext":
+		case name == "ts", strings.HasPrefix(name, "ts:"):
+			if t.layout != "" {
+				return nil, fmt.Errorf("文件名模板只能包含一个 {ts}: %s", tmpl)
+			}
+			t.layout = strings.TrimPrefix(strings.TrimPrefix(name, "ts"), ":")
+			if t.layout == "" {
+				t.layout = TimestampLayout
+			}
+			name = "ts"
+		default:
+			return nil, fmt.Errorf("文件名模板包含未知占位符 {%s}，可用: {prefix}、{pod}、{ts:布局}、{ext}", name)
+		}
+		t.segments = append(t.segments, segment{placeholder: name})
+		last = loc[1]
+	}
+	if last < len(tmpl) {
+		t.segments = append(t.segments, segment{literal: tmpl[last:]})
+	}
+	if strings.ContainsAny(strings.Join(t.literals(), ""), "{}") {
+		return nil, fmt.Errorf("文件名模板的花括号不匹配: %s", tmpl)
+	}
+	if t.layout == "" {
+		return nil, fmt.Errorf("文件名模板缺少 {ts} 时间占位符: %s", tmpl)
+	}
+
+	// 时间布局必须能够往返解析，且生成的文件名只包含安全字符
+	sample := time.Date(2026, 2, 3, 8, 35, 2, 0, time.Local)
+	if parsed, err := time.ParseInLocation(t.layout, sample.Format(t.layout), time.Local); err != nil || parsed.Year() != sample.Year() {
+		return nil, fmt.Errorf("文件名模板的时间布局 %q 无法解析回时间", t.layout)
+	}
+	if name := t.Filename("pod-0", sample.Format(TimestampLayout), ""); !safeFilename.MatchString(name) {
+		return nil, fmt.Errorf("文件名模板生成的文件名 %q 包含不安全字符", name)
+	}
+	return t, nil
+}
+
+// Default 返回默认模板
+func Default() *Template {
+	t, err := Parse(DefaultTemplate, DefaultPrefix)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t *Template) literals() []string {
+	var literals []string
+	for _, s := range t.segments {
+		literals = append(literals, s.literal)
+	}
+	return literals
+}
+
+// String 返回原始模板
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Filename 构建备份文件名。timestamp 为 20060102150405 格式，按模板的时间布局重新格式化；
+// ext 为空时使用 DefaultExtension
+func (t *Template) Filename(pod, timestamp, ext string) string {
+	if ext == "" {
+		ext = DefaultExtension
+	}
+	ts := timestamp
+	if at, err := time.ParseInLocation(TimestampLayout, timestamp, time.Local); err == nil {
+		ts = at.Format(t.layout)
+	}
+
+	var b strings.Builder
+	for _, s := range t.segments {
+		switch s.placeholder {
+		case "":
+			b.WriteString(s.literal)
+		case "prefix":
+			b.WriteString(t.prefix)
+		case "pod":
+			b.WriteString(pod)
+		case "ts":
+			b.WriteString(ts)
+		case "ext":
+			b.WriteString(ext)
+		}
+	}
+	return b.String()
+}
+
+// Matcher 匹配指定 Pod 的备份文件名
+type Matcher struct {
+	template *Template
+	re       *regexp.Regexp
+}
+
+// Matcher 创建文件名匹配器，pod 为空时匹配任意 Pod
+func (t *Template) Matcher(pod string) *Matcher {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, s := range t.segments {
+		switch s.placeholder {
+		case "":
+			b.WriteString(regexp.QuoteMeta(s.literal))
+		case "prefix":
+			b.WriteString(regexp.QuoteMeta(t.prefix))
+		case "pod":
+			if pod == "" {
+				b.WriteString(`.+?`)
+			} else {
+				b.WriteString(regexp.QuoteMeta(pod))
+			}
+		case "ts":
+			b.WriteString(`(?P<ts>` + t.timestampPattern() + `)`)
+		case "ext":
+			exts := make([]string, 0, len(Extensions))
+			for _, ext := range Extensions {
+				exts = append(exts, regexp.QuoteMeta(ext))
+			}
+			b.WriteString(`(?P<ext>` + strings.Join(exts, "|") + `)`)
+		}
+	}
+	// 大备份按 <文件名>.partNNN 分卷上传
+	b.WriteString(`(?:` + archive.PartPattern + `)?$`)
+	return &Matcher{template: t, re: regexp.MustCompile(b.String())}
+}
+
+// timestampPattern 按时间布局生成时间部分的正则：数字串匹配 \d+，字母串匹配 [A-Za-z]+，其余字符原样匹配。
+// 这样 {pod} 任意匹配时也能在 Pod 名称和时间之间正确断开
+func (t *Template) timestampPattern() string {
+	sample := time.Date(2026, 2, 3, 8, 35, 2, 0, time.Local).Format(t.layout)
+
+	var b strings.Builder
+	for i := 0; i < len(sample); {
+		j := i
+		switch c := sample[i]; {
+		case '0' <= c && c <= '9':
+			for j < len(sample) && '0' <= sample[j] && sample[j] <= '9' {
+				j++
+			}
+			b.WriteString(`\d+`)
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z':
+			for j < len(sample) && ('A' <= sample[j] && sample[j] <= 'Z' || 'a' <= sample[j] && sample[j] <= 'z') {
+				j++
+			}
+			b.WriteString(`[A-Za-z]+`)
+		default:
+			j++
+			b.WriteString(regexp.QuoteMeta(sample[i:j]))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// Match 解析文件名，返回 20060102150405 格式的时间戳和扩展名；不匹配模板或是分卷文件时 ok 为 false
+func (m *Matcher) Match(filename string) (timestamp, ext string, ok bool) {
+	timestamp, ext, part, ok := m.MatchVolume(filename)
+	if !ok || part >= 0 {
+		return "", "", false
+	}
+	return timestamp, ext, true
+}
+
+// MatchVolume 与 Match 相同，但同时接受分卷文件 <文件名>.partNNN，part 为分卷序号，非分卷文件为 -1
+func (m *Matcher) MatchVolume(filename string) (timestamp, ext string, part int, ok bool) {
+	matches := m.re.FindStringSubmatch(filename)
+	if matches == nil {
+		return "", "", -1, false
+	}
+	at, err := time.ParseInLocation(m.template.layout, matches[m.re.SubexpIndex("ts")], time.Local)
+	if err != nil {
+		return "", "", -1, false
+	}
+	if i := m.re.SubexpIndex("ext"); i >= 0 {
+		ext = matches[i]
+	}
+	part = -1
+	if _, n, split := archive.SplitPart(filename); split {
+		part = n
+	}
+	return at.Format(TimestampLayout), ext, part, true
+}
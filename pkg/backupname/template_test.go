@@ -0,0 +1,101 @@
+package backupname
+
+import "testing"
+
+func TestTemplateFilenameAndMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		prefix   string
+		want     string
+	}{
+		{name: "default", template: DefaultTemplate, prefix: DefaultPrefix, want: "emsau_iotdb-datanode-0_20260203083502.tar.gz"},
+		{name: "bare ts", template: "{prefix}-{pod}-{ts}.{ext}", prefix: "ems", want: "ems-iotdb-datanode-0-20260203083502.tar.gz"},
+		{name: "custom layout", template: "backup.{pod}.{ts:2006-01-02T15-04-05}.{ext}", want: "backup.iotdb-datanode-0.2026-02-03T08-35-02.tar.gz"},
+		{name: "minute precision", template: "{ts:200601021504}_{pod}.{ext}", want: "202602030835_iotdb-datanode-0.tar.gz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse(tt.template, tt.prefix)
+			if err != nil {
+				t.Fatalf("parse template: %v", err)
+			}
+
+			got := tmpl.Filename("iotdb-datanode-0", "20260203083502", "")
+			if got != tt.want {
+				t.Fatalf("expected filename %s, got %s", tt.want, got)
+			}
+
+			timestamp, ext, ok := tmpl.Matcher("iotdb-datanode-0").Match(got)
+			if !ok || ext != DefaultExtension {
+				t.Fatalf("expected %s to match its own template, got ok=%v ext=%q", got, ok, ext)
+			}
+			// 文件名按模板布局往返后再次构建应得到同一个文件名
+			if rebuilt := tmpl.Filename("iotdb-datanode-0", timestamp, ext); rebuilt != got {
+				t.Fatalf("round trip mismatch: %s != %s", rebuilt, got)
+			}
+			if _, _, ok := tmpl.Matcher("iotdb-datanode-1").Match(got); ok {
+				t.Fatalf("expected %s not to match another pod", got)
+			}
+			if _, _, ok := tmpl.Matcher("").Match(got); !ok {
+				t.Fatalf("expected %s to match any pod", got)
+			}
+			if _, _, ok := tmpl.Matcher("iotdb-datanode-0").Match(got + ".sha256"); ok {
+				t.Fatalf("expected sidecar file not to match")
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidTemplates(t *testing.T) {
+	for _, tmpl := range []string{
+		"",
+		"{prefix}_{pod}.{ext}",
+		"{ts}_{ts}.{ext}",
+		"{prefix}_{host}_{ts}.{ext}",
+		"{prefix}_{pod_{ts}.{ext}",
+		"backups/{pod}_{ts}.{ext}",
+		"{pod} {ts}.{ext}",
+		"{pod}_{ts:Monday}.{ext}",
+	} {
+		if _, err := Parse(tmpl, DefaultPrefix); err == nil {
+			t.Fatalf("expected template %q to be rejected", tmpl)
+		}
+	}
+}
+
+func TestMatchVolume(t *testing.T) {
+	matcher := Default().Matcher("iotdb-datanode-0")
+
+	tests := []struct {
+		filename string
+		ext      string
+		part     int
+		ok       bool
+	}{
+		{filename: "emsau_iotdb-datanode-0_20260203083502.tar.zst", ext: "tar.zst", part: -1, ok: true},
+		{filename: "emsau_iotdb-datanode-0_20260203083502.tar.zst.part000", ext: "tar.zst", part: 0, ok: true},
+		{filename: "emsau_iotdb-datanode-0_20260203083502.tar.part011", ext: "tar", part: 11, ok: true},
+		{filename: "emsau_iotdb-datanode-0_20260203083502.tar.lz4", ext: "tar.lz4", part: -1, ok: true},
+		{filename: "emsau_iotdb-datanode-0_20260203083502.tar.zst.part000.sha256"},
+		{filename: "emsau_iotdb-datanode-0_20260203083502.zip"},
+	}
+
+	for _, tt := range tests {
+		timestamp, ext, part, ok := matcher.MatchVolume(tt.filename)
+		if ok != tt.ok {
+			t.Fatalf("%s: expected ok=%v, got %v", tt.filename, tt.ok, ok)
+		}
+		if !ok {
+			continue
+		}
+		if timestamp != "20260203083502" || ext != tt.ext || part != tt.part {
+			t.Fatalf("%s: unexpected match %s %s %d", tt.filename, timestamp, ext, part)
+		}
+		// Match 只接受完整的归档文件
+		if _, _, whole := matcher.Match(tt.filename); whole != (part < 0) {
+			t.Fatalf("%s: unexpected Match result %v", tt.filename, whole)
+		}
+	}
+}
@@ -1,18 +1,26 @@
 package config
 
 import (
+	"os"
 	"strings"
 	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/backupname"
 )
 
 // Config 是应用程序的完整配置结构
 type Config struct {
 	Kubernetes   KubeConfig         `mapstructure:"kubernetes"`
 	IoTDB        IoTDBConfig        `mapstructure:"iotdb"`
+	Databases    []DatabaseConfig   `mapstructure:"databases"`
 	Backup       BackupConfig       `mapstructure:"backup"`
 	Import       ImportConfig       `mapstructure:"import"`
 	Notification NotificationConfig `mapstructure:"notification"`
 	Log          LogConfig          `mapstructure:"log"`
+	Journal      JournalConfig      `mapstructure:"journal"`
+	Verify       VerifyConfig       `mapstructure:"verify"`
+	Lock         LockConfig         `mapstructure:"lock"`
+	Safety       SafetyConfig       `mapstructure:"safety"`
 }
 
 // KubeConfig Kubernetes 配置
@@ -31,6 +39,26 @@ type IoTDBConfig struct {
 	Port     int    `mapstructure:"port"`
 	Username string `mapstructure:"username"`
 	Password string `mapstructure:"password"`
+	// Client SQL 执行方式: cli（在 Pod 内执行 start-cli.sh）或 session（原生会话协议）
+	Client string `mapstructure:"client"`
+	// TimePartitionInterval 时间分区间隔（毫秒），需与 IoTDB 的 time_partition_interval 一致，
+	// 选择性恢复按 tsfile 所在的时间分区目录判断时间范围
+	TimePartitionInterval int64 `mapstructure:"time_partition_interval"`
+}
+
+// DatabaseConfig 受管数据库配置：恢复前删除并重建，恢复后按探测序列做写读校验
+type DatabaseConfig struct {
+	Name string `mapstructure:"name"`
+	// TTL 数据保留时间（毫秒），0 表示不设置
+	TTL                  int64 `mapstructure:"ttl"`
+	SchemaRegionGroupNum int   `mapstructure:"schema_region_group_num"`
+	DataRegionGroupNum   int   `mapstructure:"data_region_group_num"`
+	// BootstrapSeries 用于触发 Region 创建的序列，默认 <name>.__restore_bootstrap.status
+	BootstrapSeries string `mapstructure:"bootstrap_series"`
+	// ProbeSeries 恢复后写读探测的序列，为空时不探测该数据库
+	ProbeSeries string `mapstructure:"probe_series"`
+	// SampleSeries 数据校验时比对 count(*) 和 max_time 的抽样序列
+	SampleSeries []string `mapstructure:"sample_series"`
 }
 
 // BackupConfig 备份文件配置
@@ -38,14 +66,37 @@ type BackupConfig struct {
 	BaseURL             string `mapstructure:"base_url"`
 	DownloadDir         string `mapstructure:"download_dir"`
 	AutoDetectTimestamp bool   `mapstructure:"auto_detect_timestamp"`
-	TimestampPattern    string `mapstructure:"timestamp_pattern"`
-	SourceType          string `mapstructure:"source_type"` // "oss" 或 "cluster_stream"
+	SourceType          string `mapstructure:"source_type"` // "oss"、"s3" 或 "cluster_stream"
+
+	// FilenameTemplate 备份文件名模板，占位符: {prefix}、{pod}、{ts:Go 时间布局}、{ext}
+	FilenameTemplate string `mapstructure:"filename_template"`
+	// FilenamePrefix 模板中 {prefix} 的取值
+	FilenamePrefix string `mapstructure:"filename_prefix"`
+	// TimestampPattern 已废弃：时间格式由 FilenameTemplate 中的 {ts:布局} 指定
+	TimestampPattern string `mapstructure:"timestamp_pattern"`
+	// MaxAgeHours 自动检测时可接受的最旧备份（小时），相对当前时间或 --before/--at-or-before 指定的时间
+	MaxAgeHours int `mapstructure:"max_age_hours"`
+
+	// 对象存储访问配置：oss 使用 AccessKey/STS 签名，s3 使用 SigV4 签名；未配置凭证时匿名访问
+	Credentials BackupCredentials `mapstructure:"credentials"`
+	// Region S3 SigV4 签名区域，MinIO 默认 us-east-1
+	Region string `mapstructure:"region"`
+	// PresignExpiryMinutes pod 下载策略使用的预签名 URL 有效期（分钟）
+	PresignExpiryMinutes int `mapstructure:"presign_expiry_minutes"`
 
 	// 下载策略配置
-	DownloadStrategy  string `mapstructure:"download_strategy"`   // "local" (本地下载+传输) 或 "pod" (Pod直接下载)
+	DownloadStrategy  string `mapstructure:"download_strategy"`   // "local" (本地下载+传输)、"pod" (Pod直接下载) 或 "stream" (流式解压到 Pod)
+	StreamFallback    string `mapstructure:"stream_fallback"`     // stream 失败时降级的策略: "local"、"pod" 或 "none"
 	LocalTempDir      string `mapstructure:"local_temp_dir"`      // 本地临时目录
 	CleanupLocalFiles bool   `mapstructure:"cleanup_local_files"` // 是否清理本地文件（已废弃，始终清理）
 
+	// 本地下载的分片配置，服务端支持 Range 时按分片并行下载并支持断点续传
+	DownloadConcurrency int `mapstructure:"download_concurrency"`
+	DownloadChunkSizeMB int `mapstructure:"download_chunk_size_mb"`
+
+	// Checksum 备份完整性校验: auto（有 .sha256/Content-MD5/ETag 时校验）、required（缺少校验和时失败）、off
+	Checksum string `mapstructure:"checksum"`
+
 	// 同集群直连恢复配置
 	SourceNamespace string `mapstructure:"source_namespace"`
 	SourcePodName   string `mapstructure:"source_pod_name"`
@@ -54,6 +105,20 @@ type BackupConfig struct {
 	ArchiveDir      string `mapstructure:"archive_dir"`
 }
 
+// BackupCredentials 对象存储访问凭证，建议通过环境变量
+// IOTDB_RESTORE_BACKUP_CREDENTIALS_ACCESS_KEY_ID 等注入，避免写入配置文件
+type BackupCredentials struct {
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	AccessKeySecret string `mapstructure:"access_key_secret"`
+	// SecurityToken STS 临时凭证的安全令牌
+	SecurityToken string `mapstructure:"security_token"`
+}
+
+// Anonymous 未配置 AccessKey 时匿名访问
+func (c BackupCredentials) Anonymous() bool {
+	return c.AccessKeyID == "" && c.AccessKeySecret == ""
+}
+
 // ImportConfig 导入配置
 type ImportConfig struct {
 	Concurrency int  `mapstructure:"concurrency"`
@@ -61,6 +126,38 @@ type ImportConfig struct {
 	RetryCount  int  `mapstructure:"retry_count"`
 	BatchDelay  int  `mapstructure:"batch_delay"`
 	BatchPause  bool `mapstructure:"batch_pause"`
+	// ReportDir 逐文件导入报告的输出目录
+	ReportDir string `mapstructure:"report_dir"`
+	// ReportFormat 导入报告格式: json 或 csv
+	ReportFormat string `mapstructure:"report_format"`
+	// Inspect 导入前读取 tsfile 元数据: auto（选择性恢复需要时）、always（校验所有文件）或 off
+	Inspect string `mapstructure:"inspect"`
+	// Adaptive 按 Pod 可用内存、容器重启和可重试错误率逐批调整并发
+	Adaptive AdaptiveConfig `mapstructure:"adaptive"`
+	// Order 导入顺序: partition（按数据库、时间分区、Region、文件时间戳和版本号）或 none（find 输出顺序）
+	Order string `mapstructure:"order"`
+	// Lanes 同时导入的数据库数，大于 1 时按数据库拆分导入通道，每个通道使用 concurrency 个并发
+	Lanes int `mapstructure:"lanes"`
+}
+
+// AdaptiveConfig 自适应导入并发配置。启用后以 concurrency 为初始并发，
+// 每个批次的文件数不少于当前并发，batch_pause 的固定暂停不再生效
+type AdaptiveConfig struct {
+	Enabled        bool `mapstructure:"enabled"`
+	MinConcurrency int  `mapstructure:"min_concurrency"`
+	// MaxConcurrency 每个导入通道的并发上限，为 0 时取 concurrency 的 2 倍；
+	// 所有通道之和（lanes × max_concurrency）不超过并发上限
+	MaxConcurrency int `mapstructure:"max_concurrency"`
+	// HealthyMemoryMB 可用内存不低于该值且上一批次全部成功时并发加 1
+	HealthyMemoryMB int `mapstructure:"healthy_memory_mb"`
+	// LowMemoryMB 可用内存低于该值时并发减半
+	LowMemoryMB int `mapstructure:"low_memory_mb"`
+	// CriticalMemoryMB 可用内存低于该值时暂停导入，并发降到最小值
+	CriticalMemoryMB int `mapstructure:"critical_memory_mb"`
+	// RetryErrorPercent 上一批次可重试错误占导入尝试的百分比达到该值时并发减半
+	RetryErrorPercent int `mapstructure:"retry_error_percent"`
+	// PauseSeconds 内存不足或容器重启后的暂停时间
+	PauseSeconds int `mapstructure:"pause_seconds"`
 }
 
 // NotificationConfig 通知配置
@@ -76,6 +173,44 @@ type WechatConfig struct {
 	Enabled    bool   `mapstructure:"enabled"`
 }
 
+// JournalConfig 恢复运行日志（断点续传检查点）配置
+type JournalConfig struct {
+	Backend   string `mapstructure:"backend"`   // "file" (本地文件) 或 "configmap"
+	Dir       string `mapstructure:"dir"`       // file 后端的存储目录
+	Namespace string `mapstructure:"namespace"` // configmap 后端的命名空间，默认与 kubernetes.namespace 相同
+}
+
+// LockConfig 恢复任务锁配置
+type LockConfig struct {
+	Backend   string `mapstructure:"backend"`   // "file" (本地锁文件) 或 "lease" (Kubernetes Lease，跨节点互斥)
+	Dir       string `mapstructure:"dir"`       // file 后端的锁目录
+	Namespace string `mapstructure:"namespace"` // lease 后端的命名空间，默认与 kubernetes.namespace 相同
+	// LeaseDurationSeconds 持有者停止续约后，Lease 经过该时间才能被其他任务接管
+	LeaseDurationSeconds int `mapstructure:"lease_duration_seconds"`
+	// RenewIntervalSeconds 恢复期间续约 Lease 的间隔
+	RenewIntervalSeconds int `mapstructure:"renew_interval_seconds"`
+}
+
+// SafetyConfig 恢复前安全快照配置。启用后先确认备份完整存在，再用硬链接为在线数据目录创建快照，
+// 然后才删除数据库；之后任一阶段失败都会清理已导入的数据并从快照重新加载
+type SafetyConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// SnapshotDir Pod 内的快照目录，每次运行一个子目录。须与 iotdb.data_dir 位于同一文件系统，
+	// 快照使用硬链接，不复制数据
+	SnapshotDir string `mapstructure:"snapshot_dir"`
+	// KeepSnapshot 恢复成功后保留快照，默认删除
+	KeepSnapshot bool `mapstructure:"keep_snapshot"`
+}
+
+// VerifyConfig 恢复后数据校验配置
+type VerifyConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// FailOnMismatch 数据不一致时将恢复标记为失败，否则仅在结果和通知中报告
+	FailOnMismatch bool `mapstructure:"fail_on_mismatch"`
+	// ManifestURL oss 模式的比对清单地址，{file} 替换为备份文件名，默认 <base_url>/<备份文件名>.manifest.json
+	ManifestURL string `mapstructure:"manifest_url"`
+}
+
 // LogConfig 日志配置
 type LogConfig struct {
 	Level  string `mapstructure:"level"`
@@ -95,12 +230,6 @@ type ImportStats struct {
 	Timestamp    string
 }
 
-// Validate 验证配置
-func (c *Config) Validate() error {
-	// TODO: 实现配置验证逻辑
-	return nil
-}
-
 // SetDefaults 设置默认值
 func (c *Config) SetDefaults() {
 	if c.Import.Concurrency <= 0 {
@@ -115,12 +244,72 @@ func (c *Config) SetDefaults() {
 	if c.Import.BatchDelay <= 0 {
 		c.Import.BatchDelay = 3
 	}
+	if c.Import.ReportDir == "" {
+		c.Import.ReportDir = "/tmp/iotdb-restore/reports"
+	}
+	if c.Import.ReportFormat == "" {
+		c.Import.ReportFormat = "json"
+	}
+	if c.Import.Inspect == "" {
+		c.Import.Inspect = "auto"
+	}
+	if c.Import.Order == "" {
+		c.Import.Order = "partition"
+	}
+	if c.Import.Lanes <= 0 {
+		c.Import.Lanes = 1
+	}
+	if c.Import.Adaptive.MinConcurrency <= 0 {
+		c.Import.Adaptive.MinConcurrency = 1
+	}
+	if c.Import.Adaptive.HealthyMemoryMB <= 0 {
+		c.Import.Adaptive.HealthyMemoryMB = 4096
+	}
+	if c.Import.Adaptive.LowMemoryMB <= 0 {
+		c.Import.Adaptive.LowMemoryMB = 2048
+	}
+	if c.Import.Adaptive.CriticalMemoryMB <= 0 {
+		c.Import.Adaptive.CriticalMemoryMB = 1024
+	}
+	if c.Import.Adaptive.RetryErrorPercent <= 0 {
+		c.Import.Adaptive.RetryErrorPercent = 20
+	}
+	if c.Import.Adaptive.PauseSeconds <= 0 {
+		c.Import.Adaptive.PauseSeconds = 30
+	}
 	if c.IoTDB.Host == "" {
 		c.IoTDB.Host = "iotdb-datanode"
 	}
 	if c.IoTDB.CLIPath == "" {
 		c.IoTDB.CLIPath = "/iotdb/sbin/start-cli.sh"
 	}
+	if c.IoTDB.Port <= 0 {
+		c.IoTDB.Port = 6667
+	}
+	if c.IoTDB.Username == "" {
+		c.IoTDB.Username = "root"
+	}
+	if c.IoTDB.Password == "" {
+		c.IoTDB.Password = "root"
+	}
+	if c.IoTDB.Client == "" {
+		c.IoTDB.Client = "cli"
+	}
+	if c.IoTDB.TimePartitionInterval <= 0 {
+		c.IoTDB.TimePartitionInterval = 604800000 // IoTDB 默认 7 天
+	}
+	if len(c.Databases) == 0 {
+		// 未配置时沿用 EMS 的数据库布局
+		c.Databases = []DatabaseConfig{
+			{Name: "root.emsplus"},
+			{Name: "root.energy", ProbeSeries: "root.energy.__restore_probe.restore_check"},
+		}
+	}
+	for i := range c.Databases {
+		if c.Databases[i].BootstrapSeries == "" {
+			c.Databases[i].BootstrapSeries = c.Databases[i].Name + ".__restore_bootstrap.status"
+		}
+	}
 	if c.Backup.DownloadDir == "" {
 		c.Backup.DownloadDir = "/tmp"
 	}
@@ -131,9 +320,36 @@ func (c *Config) SetDefaults() {
 	if c.Backup.DownloadStrategy == "" {
 		c.Backup.DownloadStrategy = "local" // 默认使用本地下载+传输策略
 	}
+	if c.Backup.StreamFallback == "" {
+		c.Backup.StreamFallback = "local"
+	}
 	if c.Backup.LocalTempDir == "" {
 		c.Backup.LocalTempDir = "/tmp/iotdb-restore"
 	}
+	if c.Backup.DownloadConcurrency == 0 {
+		c.Backup.DownloadConcurrency = 4
+	}
+	if c.Backup.DownloadChunkSizeMB == 0 {
+		c.Backup.DownloadChunkSizeMB = 16
+	}
+	if c.Backup.Checksum == "" {
+		c.Backup.Checksum = "auto"
+	}
+	if c.Backup.FilenameTemplate == "" {
+		c.Backup.FilenameTemplate = backupname.DefaultTemplate
+	}
+	if c.Backup.FilenamePrefix == "" {
+		c.Backup.FilenamePrefix = backupname.DefaultPrefix
+	}
+	if c.Backup.MaxAgeHours == 0 {
+		c.Backup.MaxAgeHours = 24
+	}
+	if c.Backup.Region == "" {
+		c.Backup.Region = "us-east-1"
+	}
+	if c.Backup.PresignExpiryMinutes == 0 {
+		c.Backup.PresignExpiryMinutes = 60
+	}
 	if c.Backup.SourceNamespace == "" {
 		c.Backup.SourceNamespace = "ems-au"
 	}
@@ -149,8 +365,51 @@ func (c *Config) SetDefaults() {
 	if c.Backup.ArchiveDir == "" {
 		c.Backup.ArchiveDir = "/tmp"
 	}
+	if c.Journal.Backend == "" {
+		c.Journal.Backend = "file"
+	}
+	if c.Journal.Dir == "" {
+		c.Journal.Dir = "/tmp/iotdb-restore/journal"
+	}
+	if c.Safety.SnapshotDir == "" {
+		c.Safety.SnapshotDir = "/iotdb/data/restore_snapshot"
+	}
+	if c.Lock.Backend == "" {
+		c.Lock.Backend = "file"
+	}
+	if c.Lock.Dir == "" {
+		c.Lock.Dir = os.TempDir()
+	}
+	if c.Lock.LeaseDurationSeconds <= 0 {
+		c.Lock.LeaseDurationSeconds = 60
+	}
+	if c.Lock.RenewIntervalSeconds <= 0 {
+		c.Lock.RenewIntervalSeconds = 15
+	}
 }
 
 func (c BackupConfig) UsesClusterStream() bool {
 	return strings.EqualFold(c.SourceType, "cluster_stream")
 }
+
+// UsesLocalDownload 是否可能在本地下载备份：local 策略，或 stream 策略失败后降级为 local
+func (c BackupConfig) UsesLocalDownload() bool {
+	return c.DownloadStrategy == "local" || c.DownloadStrategy == "stream" && c.StreamFallback == "local"
+}
+
+// Filenames 解析备份文件名模板
+func (c BackupConfig) Filenames() (*backupname.Template, error) {
+	return backupname.Parse(c.FilenameTemplate, c.FilenamePrefix)
+}
+
+// MaxAdaptiveConcurrency 每个导入通道可达到的最高并发。未启用自适应并发时为 concurrency；
+// 启用后未配置上限时取 concurrency 的 2 倍，且所有导入通道的并发之和不超过并发上限
+func (c *ImportConfig) MaxAdaptiveConcurrency() int {
+	if !c.Adaptive.Enabled {
+		return c.Concurrency
+	}
+	if c.Adaptive.MaxConcurrency > 0 {
+		return c.Adaptive.MaxConcurrency
+	}
+	return max(c.Concurrency, min(c.Concurrency*2, maxImportConcurrency/max(c.Lanes, 1)))
+}
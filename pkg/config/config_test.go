@@ -57,3 +57,56 @@ func TestBackupConfigUsesClusterStream(t *testing.T) {
 		})
 	}
 }
+
+func TestDatabasesSetDefaults(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetDefaults()
+
+	if len(cfg.Databases) != 2 || cfg.Databases[0].Name != "root.emsplus" || cfg.Databases[1].Name != "root.energy" {
+		t.Fatalf("unexpected default databases: %+v", cfg.Databases)
+	}
+	if cfg.Databases[0].ProbeSeries != "" {
+		t.Fatalf("root.emsplus should not be probed by default, got %q", cfg.Databases[0].ProbeSeries)
+	}
+	if cfg.Databases[1].ProbeSeries != "root.energy.__restore_probe.restore_check" {
+		t.Fatalf("unexpected default probe series: %q", cfg.Databases[1].ProbeSeries)
+	}
+
+	custom := &Config{Databases: []DatabaseConfig{
+		{Name: "root.factory"},
+		{Name: "root.audit", BootstrapSeries: "root.audit.boot.flag"},
+	}}
+	custom.SetDefaults()
+
+	if len(custom.Databases) != 2 {
+		t.Fatalf("configured databases should not be replaced: %+v", custom.Databases)
+	}
+	if got := custom.Databases[0].BootstrapSeries; got != "root.factory.__restore_bootstrap.status" {
+		t.Fatalf("unexpected default bootstrap series: %q", got)
+	}
+	if got := custom.Databases[1].BootstrapSeries; got != "root.audit.boot.flag" {
+		t.Fatalf("configured bootstrap series should be kept, got %q", got)
+	}
+}
+
+func TestImportConfigMaxAdaptiveConcurrency(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ImportConfig
+		want   int
+	}{
+		{name: "adaptive disabled", config: ImportConfig{Concurrency: 4, Lanes: 1}, want: 4},
+		{name: "default doubles concurrency", config: ImportConfig{Concurrency: 4, Lanes: 1, Adaptive: AdaptiveConfig{Enabled: true}}, want: 8},
+		{name: "explicit max", config: ImportConfig{Concurrency: 2, Lanes: 1, Adaptive: AdaptiveConfig{Enabled: true, MaxConcurrency: 6}}, want: 6},
+		{name: "parallel lanes", config: ImportConfig{Concurrency: 3, Lanes: 2}, want: 3},
+		{name: "parallel lanes share the limit", config: ImportConfig{Concurrency: 12, Lanes: 2, Adaptive: AdaptiveConfig{Enabled: true}}, want: 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.MaxAdaptiveConcurrency(); got != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -9,6 +9,21 @@ import (
 
 // Load 从文件加载配置
 func Load(configPath string) (*Config, error) {
+	cfg, err := load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 验证配置
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("配置验证失败: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// load 读取配置文件并设置默认值，不做校验
+func load(configPath string) (*Config, error) {
 	v := viper.New()
 
 	// 设置配置文件
@@ -26,6 +41,16 @@ func Load(configPath string) (*Config, error) {
 	v.SetEnvPrefix("IOTDB_RESTORE")
 	v.AutomaticEnv()
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	// 凭证通常只通过环境变量注入，需要显式绑定才能在配置文件缺省时生效
+	for _, key := range []string{
+		"backup.credentials.access_key_id",
+		"backup.credentials.access_key_secret",
+		"backup.credentials.security_token",
+	} {
+		if err := v.BindEnv(key); err != nil {
+			return nil, fmt.Errorf("绑定环境变量失败: %w", err)
+		}
+	}
 
 	// 读取配置文件
 	if err := v.ReadInConfig(); err != nil {
@@ -43,17 +68,12 @@ func Load(configPath string) (*Config, error) {
 	// 设置默认值
 	cfg.SetDefaults()
 
-	// 验证配置
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("配置验证失败: %w", err)
-	}
-
 	return &cfg, nil
 }
 
-// LoadWithOverrides 从文件加载配置并使用命令行参数覆盖
+// LoadWithOverrides 从文件加载配置并使用命令行参数覆盖，覆盖后再统一校验
 func LoadWithOverrides(configPath string, overrides map[string]interface{}) (*Config, error) {
-	cfg, err := Load(configPath)
+	cfg, err := load(configPath)
 	if err != nil {
 		return nil, err
 	}
@@ -76,5 +96,10 @@ func LoadWithOverrides(configPath string, overrides map[string]interface{}) (*Co
 		_ = timestamp
 	}
 
+	// 验证配置
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("配置验证失败: %w", err)
+	}
+
 	return cfg, nil
 }
@@ -0,0 +1,394 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	// maxImportConcurrency 并发导入上限，过高会压垮单个 DataNode
+	maxImportConcurrency = 32
+	// maxDownloadConcurrency 分片下载并发上限
+	maxDownloadConcurrency = 32
+	// maxDownloadChunkSizeMB 单个分片大小上限
+	maxDownloadChunkSizeMB = 1024
+	// maxPresignExpiryMinutes 预签名 URL 有效期上限，SigV4 最长 7 天
+	maxPresignExpiryMinutes = 7 * 24 * 60
+)
+
+// FieldError 单个配置项的校验错误
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError 汇总配置校验发现的全部问题
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Error())
+	}
+	return fmt.Sprintf("发现 %d 个配置问题: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// validator 收集字段错误
+type validator struct {
+	errors []FieldError
+}
+
+func (v *validator) addf(field, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.addf(field, "不能为空")
+		return false
+	}
+	return true
+}
+
+// absPath 校验绝对路径，且不能是根目录
+func (v *validator) absPath(field, value string) bool {
+	if !v.required(field, value) {
+		return false
+	}
+	if !path.IsAbs(value) {
+		v.addf(field, "必须是绝对路径: %s", value)
+		return false
+	}
+	if path.Clean(value) == "/" {
+		v.addf(field, "不能是根目录")
+		return false
+	}
+	return true
+}
+
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf(field, "无效取值 %q，可选: %s", value, strings.Join(allowed, ", "))
+}
+
+func (v *validator) httpURL(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		v.addf(field, "URL 解析失败: %v", err)
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		v.addf(field, "URL 协议必须是 http 或 https: %s", value)
+		return
+	}
+	if u.Host == "" {
+		v.addf(field, "URL 缺少主机名: %s", value)
+	}
+}
+
+// Validate 验证配置，返回包含全部字段错误的 *ValidationError
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	c.validateKubernetes(v)
+	c.validateIoTDB(v)
+	c.validateDatabases(v)
+	c.validateBackup(v)
+	c.validateImport(v)
+	c.validateNotification(v)
+	c.validateJournal(v)
+	c.validateLock(v)
+	c.validateSafety(v)
+	c.validateVerify(v)
+	c.validateLog(v)
+
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+func (c *Config) validateKubernetes(v *validator) {
+	v.required("kubernetes.namespace", c.Kubernetes.Namespace)
+	v.required("kubernetes.pod_name", c.Kubernetes.PodName)
+}
+
+func (c *Config) validateIoTDB(v *validator) {
+	v.absPath("iotdb.data_dir", c.IoTDB.DataDir)
+	v.required("iotdb.host", c.IoTDB.Host)
+	if c.IoTDB.Port <= 0 || c.IoTDB.Port > 65535 {
+		v.addf("iotdb.port", "端口超出范围: %d", c.IoTDB.Port)
+	}
+	v.oneOf("iotdb.client", strings.ToLower(c.IoTDB.Client), "cli", "session")
+	if strings.EqualFold(c.IoTDB.Client, "cli") {
+		v.absPath("iotdb.cli_path", c.IoTDB.CLIPath)
+	}
+}
+
+func (c *Config) validateDatabases(v *validator) {
+	seen := make(map[string]bool, len(c.Databases))
+	for i, db := range c.Databases {
+		field := fmt.Sprintf("databases[%d]", i)
+		if !v.required(field+".name", db.Name) {
+			continue
+		}
+		if !strings.HasPrefix(db.Name, "root.") || strings.HasSuffix(db.Name, ".") {
+			v.addf(field+".name", "数据库名必须以 root. 开头: %s", db.Name)
+		}
+		if seen[db.Name] {
+			v.addf(field+".name", "数据库重复: %s", db.Name)
+		}
+		seen[db.Name] = true
+
+		if db.TTL < 0 {
+			v.addf(field+".ttl", "不能为负数: %d", db.TTL)
+		}
+		if db.SchemaRegionGroupNum < 0 {
+			v.addf(field+".schema_region_group_num", "不能为负数: %d", db.SchemaRegionGroupNum)
+		}
+		if db.DataRegionGroupNum < 0 {
+			v.addf(field+".data_region_group_num", "不能为负数: %d", db.DataRegionGroupNum)
+		}
+		validateSeries(v, field+".bootstrap_series", db.Name, db.BootstrapSeries)
+		if db.ProbeSeries != "" {
+			validateSeries(v, field+".probe_series", db.Name, db.ProbeSeries)
+		}
+		for j, series := range db.SampleSeries {
+			validateSeries(v, fmt.Sprintf("%s.sample_series[%d]", field, j), db.Name, series)
+		}
+	}
+}
+
+// validateSeries 序列必须位于所属数据库下，且至少包含设备和测点两级
+func validateSeries(v *validator, field, database, series string) {
+	if !v.required(field, series) {
+		return
+	}
+	rest := strings.TrimPrefix(series, database+".")
+	if rest == series {
+		v.addf(field, "序列 %s 不在数据库 %s 下", series, database)
+		return
+	}
+	if !strings.Contains(rest, ".") || strings.HasPrefix(rest, ".") || strings.HasSuffix(rest, ".") {
+		v.addf(field, "序列 %s 缺少设备或测点", series)
+	}
+}
+
+func (c *Config) validateBackup(v *validator) {
+	b := c.Backup
+	v.oneOf("backup.source_type", strings.ToLower(b.SourceType), "oss", "s3", "cluster_stream")
+
+	if b.UsesClusterStream() {
+		v.required("backup.source_namespace", b.SourceNamespace)
+		v.required("backup.source_pod_name", b.SourcePodName)
+		if b.SourceNamespace == c.Kubernetes.Namespace && b.SourcePodName == c.Kubernetes.PodName {
+			v.addf("backup.source_pod_name", "源 Pod 不能与目标 Pod 相同: %s/%s", b.SourceNamespace, b.SourcePodName)
+		}
+		v.absPath("backup.source_data_dir", b.SourceDataDir)
+		v.absPath("backup.archive_dir", b.ArchiveDir)
+		if v.absPath("backup.staging_dir", b.StagingDir) && path.IsAbs(c.IoTDB.DataDir) {
+			c.validateStagingDir(v)
+		}
+		return
+	}
+
+	if strings.HasPrefix(b.BaseURL, "file://") {
+		// 本地备份目录只能由本工具读取后传输到 Pod
+		v.absPath("backup.base_url", strings.TrimPrefix(b.BaseURL, "file://"))
+		if b.DownloadStrategy == "pod" {
+			v.addf("backup.download_strategy", "file:// 备份目录不支持 pod 下载策略")
+		}
+		if b.DownloadStrategy == "stream" && b.StreamFallback == "pod" {
+			v.addf("backup.stream_fallback", "file:// 备份目录不支持降级到 pod 下载策略")
+		}
+	} else {
+		v.httpURL("backup.base_url", b.BaseURL)
+	}
+	c.validateCredentials(v)
+	if _, err := b.Filenames(); err != nil {
+		v.addf("backup.filename_template", "%v", err)
+	}
+	if b.MaxAgeHours < 1 {
+		v.addf("backup.max_age_hours", "必须大于 0: %d", b.MaxAgeHours)
+	}
+	v.oneOf("backup.checksum", b.Checksum, "auto", "required", "off")
+	v.oneOf("backup.download_strategy", b.DownloadStrategy, "local", "pod", "stream")
+	if b.DownloadStrategy == "stream" {
+		v.oneOf("backup.stream_fallback", b.StreamFallback, "local", "pod", "none")
+	}
+	if b.UsesLocalDownload() {
+		v.required("backup.local_temp_dir", b.LocalTempDir)
+		if b.DownloadConcurrency < 1 || b.DownloadConcurrency > maxDownloadConcurrency {
+			v.addf("backup.download_concurrency", "必须在 1-%d 之间: %d", maxDownloadConcurrency, b.DownloadConcurrency)
+		}
+		if b.DownloadChunkSizeMB < 1 || b.DownloadChunkSizeMB > maxDownloadChunkSizeMB {
+			v.addf("backup.download_chunk_size_mb", "必须在 1-%d 之间: %d", maxDownloadChunkSizeMB, b.DownloadChunkSizeMB)
+		}
+	}
+}
+
+// validateCredentials AccessKey 必须成对配置；STS 令牌依赖 AccessKey
+func (c *Config) validateCredentials(v *validator) {
+	b := c.Backup
+	creds := b.Credentials
+	if creds.AccessKeyID != "" && creds.AccessKeySecret == "" {
+		v.addf("backup.credentials.access_key_secret", "配置了 access_key_id 时不能为空")
+	}
+	if creds.AccessKeyID == "" && creds.AccessKeySecret != "" {
+		v.addf("backup.credentials.access_key_id", "配置了 access_key_secret 时不能为空")
+	}
+	if creds.SecurityToken != "" && creds.Anonymous() {
+		v.addf("backup.credentials.security_token", "STS 令牌需要同时配置临时 AccessKey")
+	}
+	if strings.EqualFold(b.SourceType, "s3") {
+		v.required("backup.region", b.Region)
+	}
+	if b.PresignExpiryMinutes < 1 || b.PresignExpiryMinutes > maxPresignExpiryMinutes {
+		v.addf("backup.presign_expiry_minutes", "必须在 1-%d 之间: %d", maxPresignExpiryMinutes, b.PresignExpiryMinutes)
+	}
+}
+
+// validateStagingDir 暂存目录恢复结束后会被 rm -rf，不能与在线数据目录重叠
+func (c *Config) validateStagingDir(v *validator) {
+	staging := path.Clean(c.Backup.StagingDir)
+	dataDir := path.Clean(c.IoTDB.DataDir)
+	liveDir := path.Join(dataDir, "datanode", "data")
+
+	switch {
+	case staging == dataDir:
+		v.addf("backup.staging_dir", "不能与 iotdb.data_dir 相同: %s", staging)
+	case isSubPath(dataDir, staging):
+		v.addf("backup.staging_dir", "不能是 iotdb.data_dir 的上级目录: %s", staging)
+	case staging == liveDir || isSubPath(staging, liveDir) || isSubPath(liveDir, staging):
+		v.addf("backup.staging_dir", "不能与在线数据目录 %s 重叠: %s", liveDir, staging)
+	}
+}
+
+// isSubPath 判断 child 是否位于 parent 之下（不含相等）
+func isSubPath(child, parent string) bool {
+	return strings.HasPrefix(child, strings.TrimSuffix(parent, "/")+"/")
+}
+
+func (c *Config) validateImport(v *validator) {
+	if c.Import.Concurrency < 1 || c.Import.Concurrency > maxImportConcurrency {
+		v.addf("import.concurrency", "必须在 1-%d 之间: %d", maxImportConcurrency, c.Import.Concurrency)
+	}
+	if c.Import.BatchSize < 1 {
+		v.addf("import.batch_size", "必须大于 0: %d", c.Import.BatchSize)
+	}
+	if c.Import.RetryCount < 0 {
+		v.addf("import.retry_count", "不能为负数: %d", c.Import.RetryCount)
+	}
+	if c.Import.BatchDelay < 0 {
+		v.addf("import.batch_delay", "不能为负数: %d", c.Import.BatchDelay)
+	}
+	v.required("import.report_dir", c.Import.ReportDir)
+	v.oneOf("import.report_format", c.Import.ReportFormat, "json", "csv")
+	v.oneOf("import.inspect", c.Import.Inspect, "auto", "always", "off")
+	v.oneOf("import.order", c.Import.Order, "partition", "none")
+	if c.Import.Lanes < 1 {
+		v.addf("import.lanes", "必须大于 0: %d", c.Import.Lanes)
+	} else if c.Import.Lanes > 1 && c.Import.Lanes*c.Import.Concurrency > maxImportConcurrency {
+		v.addf("import.lanes", "lanes × concurrency 不能超过 %d: %d × %d", maxImportConcurrency, c.Import.Lanes, c.Import.Concurrency)
+	}
+	if c.Import.Adaptive.Enabled {
+		c.validateAdaptive(v)
+	}
+}
+
+func (c *Config) validateAdaptive(v *validator) {
+	a := c.Import.Adaptive
+	if a.MinConcurrency < 1 || a.MinConcurrency > c.Import.Concurrency {
+		v.addf("import.adaptive.min_concurrency", "必须在 1-%d（import.concurrency）之间: %d", c.Import.Concurrency, a.MinConcurrency)
+	}
+	// 每个导入通道各自升到 max_concurrency，所有通道之和不能超过并发上限
+	highest := maxImportConcurrency / max(c.Import.Lanes, 1)
+	if a.MaxConcurrency != 0 && (a.MaxConcurrency < c.Import.Concurrency || a.MaxConcurrency > highest) {
+		v.addf("import.adaptive.max_concurrency", "必须在 %d（import.concurrency）-%d（%d / import.lanes）之间: %d",
+			c.Import.Concurrency, highest, maxImportConcurrency, a.MaxConcurrency)
+	}
+	if a.CriticalMemoryMB >= a.LowMemoryMB || a.LowMemoryMB > a.HealthyMemoryMB {
+		v.addf("import.adaptive", "内存阈值必须满足 critical_memory_mb < low_memory_mb <= healthy_memory_mb: %d/%d/%d",
+			a.CriticalMemoryMB, a.LowMemoryMB, a.HealthyMemoryMB)
+	}
+	if a.RetryErrorPercent > 100 {
+		v.addf("import.adaptive.retry_error_percent", "必须在 1-100 之间: %d", a.RetryErrorPercent)
+	}
+}
+
+func (c *Config) validateNotification(v *validator) {
+	if c.Notification.Enabled && c.Notification.Wechat.Enabled {
+		v.httpURL("notification.wechat.webhook_url", c.Notification.Wechat.WebhookURL)
+	}
+}
+
+func (c *Config) validateJournal(v *validator) {
+	v.oneOf("journal.backend", c.Journal.Backend, "file", "configmap")
+	if c.Journal.Backend == "file" {
+		v.required("journal.dir", c.Journal.Dir)
+	}
+}
+
+func (c *Config) validateLock(v *validator) {
+	v.oneOf("lock.backend", c.Lock.Backend, "file", "lease")
+	switch c.Lock.Backend {
+	case "file":
+		v.required("lock.dir", c.Lock.Dir)
+	case "lease":
+		// 续约间隔需明显短于租期，否则一次续约延迟就可能被其他任务接管
+		if c.Lock.RenewIntervalSeconds*2 > c.Lock.LeaseDurationSeconds {
+			v.addf("lock.renew_interval_seconds", "续约间隔 %ds 不能超过租期 %ds 的一半",
+				c.Lock.RenewIntervalSeconds, c.Lock.LeaseDurationSeconds)
+		}
+	}
+}
+
+// validateSafety 快照目录不能与在线数据目录和暂存目录重叠，否则创建快照时会复制到自身之下或被清理
+func (c *Config) validateSafety(v *validator) {
+	if !c.Safety.Enabled || !v.absPath("safety.snapshot_dir", c.Safety.SnapshotDir) || !path.IsAbs(c.IoTDB.DataDir) {
+		return
+	}
+	snapshot := path.Clean(c.Safety.SnapshotDir)
+	dataDir := path.Clean(c.IoTDB.DataDir)
+	liveDir := path.Join(dataDir, "datanode", "data")
+	staging := path.Clean(c.Backup.StagingDir)
+
+	switch {
+	case snapshot == dataDir || isSubPath(dataDir, snapshot):
+		v.addf("safety.snapshot_dir", "不能是 iotdb.data_dir 或其上级目录: %s", snapshot)
+	case snapshot == liveDir || isSubPath(snapshot, liveDir) || isSubPath(liveDir, snapshot):
+		v.addf("safety.snapshot_dir", "不能与在线数据目录 %s 重叠: %s", liveDir, snapshot)
+	case c.Backup.UsesClusterStream() && (snapshot == staging || isSubPath(snapshot, staging) || isSubPath(staging, snapshot)):
+		v.addf("safety.snapshot_dir", "不能与暂存目录 %s 重叠: %s", staging, snapshot)
+	}
+}
+
+func (c *Config) validateVerify(v *validator) {
+	if c.Verify.ManifestURL != "" {
+		v.httpURL("verify.manifest_url", c.Verify.ManifestURL)
+	}
+}
+
+func (c *Config) validateLog(v *validator) {
+	if c.Log.Level != "" {
+		v.oneOf("log.level", c.Log.Level, "debug", "info", "warn", "error")
+	}
+	if c.Log.Format != "" {
+		v.oneOf("log.format", c.Log.Format, "console", "json")
+	}
+}
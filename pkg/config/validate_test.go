@@ -0,0 +1,294 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func validConfig() *Config {
+	cfg := &Config{
+		Kubernetes: KubeConfig{Namespace: "iotdb", PodName: "iotdb-datanode-0"},
+		IoTDB:      IoTDBConfig{DataDir: "/iotdb/data"},
+		Backup:     BackupConfig{BaseURL: "https://bucket.oss-accelerate.aliyuncs.com/ems-au"},
+	}
+	cfg.SetDefaults()
+	return cfg
+}
+
+func TestValidateAcceptsDefaults(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	cfg := validConfig()
+	cfg.Backup.SourceType = "CLUSTER_STREAM"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected cluster stream defaults to be valid, got %v", err)
+	}
+}
+
+func TestValidateFieldErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(cfg *Config)
+		fields []string
+	}{
+		{
+			name:   "unknown source type",
+			mutate: func(cfg *Config) { cfg.Backup.SourceType = "gcs" },
+			fields: []string{"backup.source_type"},
+		},
+		{
+			name:   "oss without base url",
+			mutate: func(cfg *Config) { cfg.Backup.BaseURL = "" },
+			fields: []string{"backup.base_url"},
+		},
+		{
+			name:   "base url without scheme",
+			mutate: func(cfg *Config) { cfg.Backup.BaseURL = "bucket.example.com/ems-au" },
+			fields: []string{"backup.base_url"},
+		},
+		{
+			name: "local backup dir with pod strategy",
+			mutate: func(cfg *Config) {
+				cfg.Backup.BaseURL = "file://backups"
+				cfg.Backup.DownloadStrategy = "pod"
+			},
+			fields: []string{"backup.base_url", "backup.download_strategy"},
+		},
+		{
+			name:   "filename template without timestamp",
+			mutate: func(cfg *Config) { cfg.Backup.FilenameTemplate = "{prefix}_{pod}.{ext}" },
+			fields: []string{"backup.filename_template"},
+		},
+		{
+			name:   "negative max age",
+			mutate: func(cfg *Config) { cfg.Backup.MaxAgeHours = -1 },
+			fields: []string{"backup.max_age_hours"},
+		},
+		{
+			name:   "invalid download strategy",
+			mutate: func(cfg *Config) { cfg.Backup.DownloadStrategy = "stream-ish" },
+			fields: []string{"backup.download_strategy"},
+		},
+		{
+			name: "unknown stream fallback",
+			mutate: func(cfg *Config) {
+				cfg.Backup.DownloadStrategy = "stream"
+				cfg.Backup.StreamFallback = "retry"
+			},
+			fields: []string{"backup.stream_fallback"},
+		},
+		{
+			name: "stream with local fallback checks download settings",
+			mutate: func(cfg *Config) {
+				cfg.Backup.DownloadStrategy = "stream"
+				cfg.Backup.DownloadConcurrency = 0
+			},
+			fields: []string{"backup.download_concurrency"},
+		},
+		{
+			name: "incomplete credentials",
+			mutate: func(cfg *Config) {
+				cfg.Backup.SourceType = "s3"
+				cfg.Backup.Credentials = BackupCredentials{AccessKeyID: "AKID"}
+				cfg.Backup.Region = ""
+				cfg.Backup.PresignExpiryMinutes = maxPresignExpiryMinutes + 1
+			},
+			fields: []string{"backup.credentials.access_key_secret", "backup.region", "backup.presign_expiry_minutes"},
+		},
+		{
+			name:   "sts token without access key",
+			mutate: func(cfg *Config) { cfg.Backup.Credentials = BackupCredentials{SecurityToken: "token"} },
+			fields: []string{"backup.credentials.security_token"},
+		},
+		{
+			name:   "unknown checksum mode",
+			mutate: func(cfg *Config) { cfg.Backup.Checksum = "md5" },
+			fields: []string{"backup.checksum"},
+		},
+		{
+			name: "download part bounds",
+			mutate: func(cfg *Config) {
+				cfg.Backup.DownloadConcurrency = -1
+				cfg.Backup.DownloadChunkSizeMB = maxDownloadChunkSizeMB + 1
+			},
+			fields: []string{"backup.download_concurrency", "backup.download_chunk_size_mb"},
+		},
+		{
+			name: "staging dir equals data dir",
+			mutate: func(cfg *Config) {
+				cfg.Backup.SourceType = "cluster_stream"
+				cfg.Backup.StagingDir = "/iotdb/data/"
+			},
+			fields: []string{"backup.staging_dir"},
+		},
+		{
+			name: "staging dir inside live data dir",
+			mutate: func(cfg *Config) {
+				cfg.Backup.SourceType = "cluster_stream"
+				cfg.Backup.StagingDir = "/iotdb/data/datanode/data/staging"
+			},
+			fields: []string{"backup.staging_dir"},
+		},
+		{
+			name: "staging dir contains data dir",
+			mutate: func(cfg *Config) {
+				cfg.Backup.SourceType = "cluster_stream"
+				cfg.Backup.StagingDir = "/iotdb"
+			},
+			fields: []string{"backup.staging_dir"},
+		},
+		{
+			name: "source pod equals target pod",
+			mutate: func(cfg *Config) {
+				cfg.Backup.SourceType = "cluster_stream"
+				cfg.Backup.SourceNamespace = "iotdb"
+			},
+			fields: []string{"backup.source_pod_name"},
+		},
+		{
+			name: "import bounds",
+			mutate: func(cfg *Config) {
+				cfg.Import.Concurrency = maxImportConcurrency + 1
+				cfg.Import.RetryCount = -1
+			},
+			fields: []string{"import.concurrency", "import.retry_count"},
+		},
+		{
+			name: "import order and lanes",
+			mutate: func(cfg *Config) {
+				cfg.Import.Order = "random"
+				cfg.Import.Concurrency = 8
+				cfg.Import.Lanes = 5
+			},
+			fields: []string{"import.order", "import.lanes"},
+		},
+		{
+			name: "adaptive import bounds",
+			mutate: func(cfg *Config) {
+				cfg.Import.Adaptive.Enabled = true
+				cfg.Import.Adaptive.MinConcurrency = cfg.Import.Concurrency + 1
+				cfg.Import.Adaptive.CriticalMemoryMB = cfg.Import.Adaptive.LowMemoryMB
+			},
+			fields: []string{"import.adaptive.min_concurrency", "import.adaptive"},
+		},
+		{
+			name: "adaptive max concurrency across lanes",
+			mutate: func(cfg *Config) {
+				cfg.Import.Concurrency = 4
+				cfg.Import.Lanes = 4
+				cfg.Import.Adaptive.Enabled = true
+				cfg.Import.Adaptive.MaxConcurrency = 16
+			},
+			fields: []string{"import.adaptive.max_concurrency"},
+		},
+		{
+			name: "databases",
+			mutate: func(cfg *Config) {
+				cfg.Databases = []DatabaseConfig{
+					{Name: "root.energy", BootstrapSeries: "root.energy.status", ProbeSeries: "root.other.d.s"},
+					{Name: "root.energy", BootstrapSeries: "root.energy.d.s", TTL: -1},
+				}
+			},
+			fields: []string{
+				"databases[0].bootstrap_series",
+				"databases[0].probe_series",
+				"databases[1].name",
+				"databases[1].ttl",
+			},
+		},
+		{
+			name: "missing target and relative data dir",
+			mutate: func(cfg *Config) {
+				cfg.Kubernetes.Namespace = ""
+				cfg.IoTDB.DataDir = "iotdb/data"
+				cfg.IoTDB.Client = "jdbc"
+			},
+			fields: []string{"kubernetes.namespace", "iotdb.data_dir", "iotdb.client"},
+		},
+		{
+			name: "webhook required when enabled",
+			mutate: func(cfg *Config) {
+				cfg.Notification.Enabled = true
+				cfg.Notification.Wechat.Enabled = true
+			},
+			fields: []string{"notification.wechat.webhook_url"},
+		},
+		{
+			name:   "unknown journal backend",
+			mutate: func(cfg *Config) { cfg.Journal.Backend = "etcd" },
+			fields: []string{"journal.backend"},
+		},
+		{
+			name: "snapshot inside live data dir",
+			mutate: func(cfg *Config) {
+				cfg.Safety.Enabled = true
+				cfg.Safety.SnapshotDir = cfg.IoTDB.DataDir + "/datanode/data/snapshot"
+			},
+			fields: []string{"safety.snapshot_dir"},
+		},
+		{
+			name: "lease renew interval",
+			mutate: func(cfg *Config) {
+				cfg.Lock.Backend = "lease"
+				cfg.Lock.RenewIntervalSeconds = 40
+			},
+			fields: []string{"lock.renew_interval_seconds"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.mutate(cfg)
+
+			var validationErr *ValidationError
+			if err := cfg.Validate(); !errors.As(err, &validationErr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+
+			got := make([]string, 0, len(validationErr.Errors))
+			for _, fe := range validationErr.Errors {
+				got = append(got, fe.Field)
+			}
+			if len(got) != len(tt.fields) {
+				t.Fatalf("expected fields %v, got %v", tt.fields, validationErr.Errors)
+			}
+			for i := range tt.fields {
+				if got[i] != tt.fields[i] {
+					t.Fatalf("expected fields %v, got %v", tt.fields, validationErr.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadExampleConfig(t *testing.T) {
+	if _, err := Load("../../configs/config.example.yaml"); err != nil {
+		t.Fatalf("example config should be valid: %v", err)
+	}
+}
+
+func TestLoadWithOverridesValidatesAfterOverrides(t *testing.T) {
+	_, err := LoadWithOverrides("../../configs/config.example.yaml", map[string]interface{}{
+		"concurrency": maxImportConcurrency + 1,
+	})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected override to be validated, got %v", err)
+	}
+}
+
+func TestLoadCredentialsFromEnv(t *testing.T) {
+	t.Setenv("IOTDB_RESTORE_BACKUP_CREDENTIALS_ACCESS_KEY_ID", "env-id")
+	t.Setenv("IOTDB_RESTORE_BACKUP_CREDENTIALS_ACCESS_KEY_SECRET", "env-secret")
+
+	cfg, err := Load("../../configs/config.example.yaml")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Backup.Credentials.AccessKeyID != "env-id" || cfg.Backup.Credentials.AccessKeySecret != "env-secret" {
+		t.Fatalf("expected credentials from env, got %+v", cfg.Backup.Credentials)
+	}
+}
@@ -0,0 +1,40 @@
+package downloader
+
+import "time"
+
+// BackupGap 连续缺失备份的时段 [From, To)
+type BackupGap struct {
+	From    time.Time
+	To      time.Time
+	Missing int
+}
+
+// FindGaps 按 interval 划分时段，返回从第一个备份所在时段到 until 之间没有备份的连续时段。
+// until 所在时段尚未结束，不计入缺失；backups 需按时间升序。
+func FindGaps(backups []BackupObject, interval time.Duration, until time.Time) []BackupGap {
+	if len(backups) == 0 || interval <= 0 {
+		return nil
+	}
+
+	present := make(map[int64]bool, len(backups))
+	for _, b := range backups {
+		present[b.Time.Truncate(interval).Unix()] = true
+	}
+
+	var gaps []BackupGap
+	var current *BackupGap
+	end := until.Truncate(interval)
+	for slot := backups[0].Time.Truncate(interval); slot.Before(end); slot = slot.Add(interval) {
+		if present[slot.Unix()] {
+			current = nil
+			continue
+		}
+		if current == nil {
+			gaps = append(gaps, BackupGap{From: slot})
+			current = &gaps[len(gaps)-1]
+		}
+		current.To = slot.Add(interval)
+		current.Missing++
+	}
+	return gaps
+}
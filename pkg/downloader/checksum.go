@@ -0,0 +1,191 @@
+package downloader
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// 校验和算法
+const (
+	AlgorithmSHA256 = "sha256"
+	AlgorithmMD5    = "md5"
+)
+
+// 校验和来源
+const (
+	ChecksumFromSidecar    = "sidecar"
+	ChecksumFromContentMD5 = "content-md5"
+	ChecksumFromETag       = "etag"
+	ChecksumFromDownload   = "download"
+	ChecksumFromTransfer   = "transfer"
+)
+
+// ErrChecksumMismatch 文件内容与期望校验和不一致
+var ErrChecksumMismatch = errors.New("校验和不一致")
+
+// Checksum 文件校验和
+type Checksum struct {
+	Algorithm string
+	// Value 小写十六进制
+	Value string
+	// Source 校验和来源，见 ChecksumFrom*
+	Source string
+}
+
+func (c *Checksum) String() string {
+	return c.Algorithm + ":" + c.Value
+}
+
+// ChecksumError 完整性校验失败
+type ChecksumError struct {
+	Path     string
+	Expected *Checksum
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("完整性校验失败: %s 的 %s 与 %s 不一致（期望 %s，实际 %s）",
+		e.Path, e.Expected.Algorithm, e.Expected.Source, e.Expected.Value, e.Actual)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// Verify 比对实际校验和，不一致时返回 *ChecksumError
+func (c *Checksum) Verify(path, actual string) error {
+	if !strings.EqualFold(c.Value, actual) {
+		return &ChecksumError{Path: path, Expected: c, Actual: strings.ToLower(actual)}
+	}
+	return nil
+}
+
+func newHash(algorithm string) hash.Hash {
+	if algorithm == AlgorithmMD5 {
+		return md5.New()
+	}
+	return sha256.New()
+}
+
+var (
+	sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+	md5Pattern    = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+)
+
+// ResolveChecksum 获取远程文件的期望校验和，依次尝试：
+//  1. <url>.sha256 旁路文件（sha256sum 输出格式）
+//  2. Content-MD5 响应头
+//  3. ETag：OSS 简单上传的 ETag 即内容 MD5；分片上传（带 -N 后缀）的 ETag 不可用
+//
+// 都不可用时返回 nil。
+func (d *OSSDownloader) ResolveChecksum(ctx context.Context, url string) (*Checksum, error) {
+	data, err := d.Fetch(ctx, url+".sha256")
+	switch {
+	case err == nil:
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 || !sha256Pattern.MatchString(fields[0]) {
+			return nil, fmt.Errorf("校验和文件格式错误: %s.sha256", url)
+		}
+		return &Checksum{Algorithm: AlgorithmSHA256, Value: strings.ToLower(fields[0]), Source: ChecksumFromSidecar}, nil
+	case !errors.Is(err, ErrNotFound):
+		return nil, fmt.Errorf("获取校验和文件失败: %w", err)
+	}
+
+	info, err := d.head(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err := base64.StdEncoding.DecodeString(info.contentMD5); err == nil && len(raw) == md5.Size {
+		return &Checksum{Algorithm: AlgorithmMD5, Value: hex.EncodeToString(raw), Source: ChecksumFromContentMD5}, nil
+	}
+	if etag := strings.Trim(info.etag, `"`); md5Pattern.MatchString(etag) {
+		return &Checksum{Algorithm: AlgorithmMD5, Value: strings.ToLower(etag), Source: ChecksumFromETag}, nil
+	}
+	return nil, nil
+}
+
+// DownloadVerified 下载文件并在下载过程中计算校验和。
+// expected 为空时按 sha256 计算并返回，供后续传输校验；不为空时按其算法计算，不一致则删除文件并返回 *ChecksumError。
+func (d *OSSDownloader) DownloadVerified(ctx context.Context, url, destPath string, expected *Checksum) (*Checksum, error) {
+	algorithm := AlgorithmSHA256
+	if expected != nil {
+		algorithm = expected.Algorithm
+	}
+
+	actual, err := d.download(ctx, url, destPath, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if expected != nil {
+		if err := expected.Verify(destPath, actual); err != nil {
+			os.Remove(destPath)
+			os.Remove(progressPath(destPath))
+			return nil, err
+		}
+		logger.Info("下载文件校验通过",
+			zap.String("file", destPath),
+			zap.String("checksum", expected.String()),
+			zap.String("source", expected.Source),
+		)
+	}
+	return &Checksum{Algorithm: algorithm, Value: actual, Source: ChecksumFromDownload}, nil
+}
+
+// hashFile 计算本地文件的校验和
+func hashFile(path, algorithm string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer f.Close()
+
+	h := newHash(algorithm)
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("计算校验和失败: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// chunkHasher 按分片顺序计算校验和：分片乱序完成，连续的前缀一旦就绪就从文件读回并计入哈希
+type chunkHasher struct {
+	h        hash.Hash
+	f        *os.File
+	progress *downloadProgress
+	done     map[int]bool
+	next     int
+}
+
+func newChunkHasher(algorithm string, f *os.File, progress *downloadProgress) *chunkHasher {
+	return &chunkHasher{h: newHash(algorithm), f: f, progress: progress, done: make(map[int]bool)}
+}
+
+// complete 记录分片完成并推进哈希，调用方负责加锁
+func (c *chunkHasher) complete(idx int) error {
+	c.done[idx] = true
+	for c.done[c.next] {
+		start, end := c.progress.chunkRange(c.next)
+		if _, err := io.Copy(c.h, io.NewSectionReader(c.f, start, end-start+1)); err != nil {
+			return fmt.Errorf("计算校验和失败: %w", err)
+		}
+		delete(c.done, c.next)
+		c.next++
+	}
+	return nil
+}
+
+func (c *chunkHasher) sum() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
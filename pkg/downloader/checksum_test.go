@@ -0,0 +1,200 @@
+package downloader
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveChecksum(t *testing.T) {
+	content := []byte("backup")
+	sha := fmt.Sprintf("%x", sha256.Sum256(content))
+	md5sum := md5.Sum(content)
+	md5hex := hex.EncodeToString(md5sum[:])
+
+	tests := []struct {
+		name    string
+		sidecar string
+		headers map[string]string
+		want    *Checksum
+		wantErr bool
+	}{
+		{
+			name:    "sidecar",
+			sidecar: strings.ToUpper(sha) + "  backup.tar.gz\n",
+			headers: map[string]string{"ETag": `"` + md5hex + `"`},
+			want:    &Checksum{Algorithm: AlgorithmSHA256, Value: sha, Source: ChecksumFromSidecar},
+		},
+		{
+			name:    "content md5",
+			headers: map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(md5sum[:])},
+			want:    &Checksum{Algorithm: AlgorithmMD5, Value: md5hex, Source: ChecksumFromContentMD5},
+		},
+		{
+			name:    "simple upload etag",
+			headers: map[string]string{"ETag": `"` + strings.ToUpper(md5hex) + `"`},
+			want:    &Checksum{Algorithm: AlgorithmMD5, Value: md5hex, Source: ChecksumFromETag},
+		},
+		{
+			name:    "multipart upload etag",
+			headers: map[string]string{"ETag": `"` + md5hex + `-3"`},
+		},
+		{
+			name:    "malformed sidecar",
+			sidecar: "not-a-checksum",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if strings.HasSuffix(req.URL.Path, ".sha256") {
+					if tt.sidecar == "" {
+						http.NotFound(w, req)
+						return
+					}
+					w.Write([]byte(tt.sidecar))
+					return
+				}
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+			}))
+			defer srv.Close()
+
+			got, err := NewOSSDownloader().ResolveChecksum(context.Background(), srv.URL+"/backup.tar.gz")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve failed: %v", err)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDownloadVerified(t *testing.T) {
+	content := testContent(5000)
+	srv := newRangeServer(t, content)
+	url := srv.URL + "/backup.tar.gz"
+	sha := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	tests := []struct {
+		name     string
+		expected *Checksum
+		wantErr  bool
+	}{
+		{name: "computed without expected checksum"},
+		{name: "matching checksum", expected: &Checksum{Algorithm: AlgorithmSHA256, Value: sha, Source: ChecksumFromSidecar}},
+		{name: "mismatching checksum", expected: &Checksum{Algorithm: AlgorithmSHA256, Value: strings.Repeat("0", 64), Source: ChecksumFromSidecar}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "backup.tar.gz")
+
+			got, err := newTestDownloader(3, 1024).DownloadVerified(context.Background(), url, dest, tt.expected)
+			if tt.wantErr {
+				var checksumErr *ChecksumError
+				if !errors.As(err, &checksumErr) || !errors.Is(err, ErrChecksumMismatch) {
+					t.Fatalf("expected checksum error, got %v", err)
+				}
+				if _, statErr := os.Stat(dest); !os.IsNotExist(statErr) {
+					t.Fatalf("corrupted download should be removed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("download failed: %v", err)
+			}
+			if got.Algorithm != AlgorithmSHA256 || got.Value != sha {
+				t.Fatalf("expected computed sha256 %s, got %+v", sha, got)
+			}
+		})
+	}
+}
+
+func TestDownloadVerifiedAfterResume(t *testing.T) {
+	content := testContent(4096)
+	srv := newRangeServer(t, content)
+	dest := filepath.Join(t.TempDir(), "backup.tar.gz")
+	url := srv.URL + "/backup.tar.gz"
+
+	srv.failRange = "bytes=1024-2047"
+	if _, err := newTestDownloader(2, 1024).DownloadVerified(context.Background(), url, dest, nil); err == nil {
+		t.Fatalf("expected first download to fail")
+	}
+
+	srv.mu.Lock()
+	srv.failRange = ""
+	srv.mu.Unlock()
+
+	got, err := newTestDownloader(2, 1024).DownloadVerified(context.Background(), url, dest, nil)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if want := fmt.Sprintf("%x", sha256.Sum256(content)); got.Value != want {
+		t.Fatalf("expected sha256 %s after resume, got %s", want, got.Value)
+	}
+}
+
+func TestOpenStreamVerifiesChecksum(t *testing.T) {
+	content := []byte(strings.Repeat("tsfile", 1000))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	sum := fmt.Sprintf("%x", sha256.Sum256(content))
+	tests := []struct {
+		name     string
+		expected *Checksum
+		wantErr  bool
+	}{
+		{name: "computed without expected checksum"},
+		{name: "matching", expected: &Checksum{Algorithm: AlgorithmSHA256, Value: sum, Source: ChecksumFromSidecar}},
+		{name: "mismatch", expected: &Checksum{Algorithm: AlgorithmSHA256, Value: strings.Repeat("0", 64), Source: ChecksumFromSidecar}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := NewOSSDownloader().OpenStream(context.Background(), srv.URL+"/backup.tar.zst", tt.expected)
+			if err != nil {
+				t.Fatalf("open stream: %v", err)
+			}
+			defer stream.Close()
+
+			_, err = io.Copy(io.Discard, stream)
+			if tt.wantErr {
+				if !errors.Is(err, ErrChecksumMismatch) {
+					t.Fatalf("expected checksum mismatch, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read stream: %v", err)
+			}
+			if got := stream.Checksum(); got.Value != sum || got.Algorithm != AlgorithmSHA256 {
+				t.Fatalf("unexpected checksum %+v", got)
+			}
+		})
+	}
+}
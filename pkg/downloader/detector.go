@@ -2,26 +2,317 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/vnnox/iotdb-restore-tool/pkg/archive"
+	"github.com/vnnox/iotdb-restore-tool/pkg/backupname"
 	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
 	"go.uber.org/zap"
 )
 
+// timestampLayout 工具内部统一使用的时间戳格式
+const timestampLayout = backupname.TimestampLayout
+
+// ErrNoBackup 没有满足条件的备份
+var ErrNoBackup = errors.New("未找到满足条件的备份")
+
 // Detector 时间戳检测器
 type Detector struct {
 	downloader *OSSDownloader
+	filenames  *backupname.Template
 }
 
-// NewDetector 创建时间戳检测器
-func NewDetector() *Detector {
+// NewDetector 创建时间戳检测器，d 为空时使用匿名下载器，filenames 为空时使用默认文件名模板
+func NewDetector(d *OSSDownloader, filenames *backupname.Template) *Detector {
+	if d == nil {
+		d = NewOSSDownloader()
+	}
+	if filenames == nil {
+		filenames = backupname.Default()
+	}
 	return &Detector{
-		downloader: NewOSSDownloader(),
+		downloader: d,
+		filenames:  filenames,
+	}
+}
+
+// BackupObject 列举到的备份文件
+type BackupObject struct {
+	// Name 归档文件名，分卷备份为不含 .partNNN 后缀的文件名
+	Name      string
+	URL       string
+	Timestamp string
+	Time      time.Time
+	// Ext 归档扩展名，如 tar.gz、tar.zst
+	Ext string
+	// Parts 分卷文件名，按分卷序号排列；非分卷备份为空
+	Parts []string
+	// Size 归档大小，分卷备份为所有分卷之和
+	Size int64
+	// Checksum 可用的校验和来源：ChecksumFromSidecar、ChecksumFromETag，为空表示没有
+	Checksum string
+}
+
+// Files 返回需要下载的文件名：分卷备份返回全部分卷，否则返回归档文件本身
+func (b *BackupObject) Files() []string {
+	if len(b.Parts) > 0 {
+		return b.Parts
+	}
+	return []string{b.Name}
+}
+
+// BackupQuery 备份选择条件
+type BackupQuery struct {
+	// Before 只选择早于该时间的备份，零值表示当前时间
+	Before time.Time
+	// Inclusive 包含时间恰好等于 Before 的备份（--at-or-before）
+	Inclusive bool
+	// MaxAge 备份相对 Before 的最大年龄，0 表示不限制
+	MaxAge time.Duration
+}
+
+// ListBackups 列举 baseURL 下指定 Pod 的备份，按时间升序返回
+func (d *Detector) ListBackups(ctx context.Context, baseURL, podName string) ([]BackupObject, error) {
+	objects, err := d.downloader.List(ctx, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return GroupBackups(objects, baseURL, d.filenames.Matcher(podName)), nil
+}
+
+// GroupBackups 按文件名模板从列举结果中整理备份，按时间升序返回。
+// 分卷合并为一个备份，缺少中间分卷的备份会被跳过；同一时间戳存在多种格式时按 backupname.Extensions 的顺序取第一个。
+func GroupBackups(objects []ObjectInfo, baseURL string, matcher *backupname.Matcher) []BackupObject {
+	names := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		names[obj.Name] = true
+	}
+
+	type volume struct {
+		obj  ObjectInfo
+		part int
+	}
+	var order []string
+	groups := make(map[string]*BackupObject)
+	whole := make(map[string]bool)
+	volumes := make(map[string][]volume)
+	for _, obj := range objects {
+		// 按文件名模板匹配，跳过其他 Pod 的备份以及 .sha256、.progress 等附属文件
+		timestamp, ext, part, ok := matcher.MatchVolume(obj.Name)
+		if !ok {
+			continue
+		}
+		t, err := time.ParseInLocation(timestampLayout, timestamp, time.Local)
+		if err != nil {
+			continue
+		}
+
+		name := obj.Name
+		if part >= 0 {
+			name, _, _ = archive.SplitPart(obj.Name)
+		}
+		b, ok := groups[name]
+		if !ok {
+			b = &BackupObject{
+				Name:      name,
+				URL:       fmt.Sprintf("%s/%s", strings.TrimSuffix(baseURL, "/"), name),
+				Timestamp: timestamp,
+				Time:      t,
+				Ext:       ext,
+			}
+			groups[name] = b
+			order = append(order, name)
+		}
+		if part < 0 {
+			whole[name] = true
+			b.Size = obj.Size
+			b.Checksum = listedChecksum(obj, names)
+			continue
+		}
+		volumes[name] = append(volumes[name], volume{obj: obj, part: part})
+	}
+
+	best := make(map[string]*BackupObject)
+	for _, name := range order {
+		b := groups[name]
+		if !whole[name] {
+			parts := volumes[name]
+			sort.Slice(parts, func(i, j int) bool { return parts[i].part < parts[j].part })
+			complete := true
+			for i, v := range parts {
+				if v.part != i {
+					complete = false
+					break
+				}
+			}
+			if !complete {
+				logger.Warn("分卷备份不完整，已跳过", zap.String("name", name), zap.Int("parts", len(parts)))
+				continue
+			}
+			b.Checksum = listedChecksum(parts[0].obj, names)
+			for _, v := range parts {
+				b.Parts = append(b.Parts, v.obj.Name)
+				b.Size += v.obj.Size
+				if listedChecksum(v.obj, names) != b.Checksum {
+					b.Checksum = ""
+				}
+			}
+		}
+		if current, ok := best[b.Timestamp]; !ok || extensionRank(b.Ext) < extensionRank(current.Ext) {
+			best[b.Timestamp] = b
+		}
+	}
+
+	backups := make([]BackupObject, 0, len(best))
+	for _, b := range best {
+		backups = append(backups, *b)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Time.Before(backups[j].Time) })
+	return backups
+}
+
+// extensionRank 扩展名在 backupname.Extensions 中的优先级，越小越优先
+func extensionRank(ext string) int {
+	for i, e := range backupname.Extensions {
+		if e == ext {
+			return i
+		}
+	}
+	return len(backupname.Extensions)
+}
+
+// listedChecksum 根据列举结果判断备份的校验和来源，与 ResolveChecksum 的优先级一致（Content-MD5 需要逐个 HEAD，这里不检查）
+func listedChecksum(obj ObjectInfo, names map[string]bool) string {
+	if names[obj.Name+".sha256"] {
+		return ChecksumFromSidecar
+	}
+	if md5Pattern.MatchString(obj.ETag) {
+		return ChecksumFromETag
+	}
+	return ""
+}
+
+// SelectBackup 从按时间升序的备份中选出满足条件的最新一个
+func SelectBackup(backups []BackupObject, q BackupQuery) (*BackupObject, error) {
+	ref := q.Before
+	inclusive := q.Inclusive
+	if ref.IsZero() {
+		ref = time.Now()
+		inclusive = true
+	}
+
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		if b.Time.After(ref) || (!inclusive && b.Time.Equal(ref)) {
+			continue
+		}
+		if q.MaxAge > 0 && ref.Sub(b.Time) > q.MaxAge {
+			return nil, fmt.Errorf("%w: 最近的备份 %s 距 %s 已超过 %s",
+				ErrNoBackup, b.Timestamp, ref.Format(timestampLayout), q.MaxAge)
+		}
+		return &b, nil
+	}
+	return nil, fmt.Errorf("%w: 共 %d 个备份，没有早于 %s 的备份", ErrNoBackup, len(backups), ref.Format(timestampLayout))
+}
+
+// FindBackup 列举备份并按条件选择。列举不可用（如匿名访问禁止 ListObjects）且未指定时间点时，
+// 回退到按当前小时探测的旧规则。
+func (d *Detector) FindBackup(ctx context.Context, baseURL, podName string, q BackupQuery) (string, error) {
+	logger.Info("列举备份文件",
+		zap.String("base_url", baseURL),
+		zap.String("pod_name", podName),
+	)
+
+	backups, err := d.ListBackups(ctx, baseURL, podName)
+	if err != nil {
+		if q.Before.IsZero() && errors.Is(err, ErrAccessDenied) {
+			logger.Warn("列举备份失败，回退到按当前小时探测", zap.Error(err))
+			return d.DetectTimestamp(ctx, baseURL, podName)
+		}
+		return "", err
+	}
+
+	selected, err := SelectBackup(backups, q)
+	if err != nil {
+		return "", err
+	}
+	logger.Info("找到备份文件",
+		zap.String("timestamp", selected.Timestamp),
+		zap.String("filename", selected.Name),
+		zap.Int64("size", selected.Size),
+		zap.Int("candidates", len(backups)),
+	)
+	return selected.Timestamp, nil
+}
+
+// BackupAt 从备份列表中取出指定时间戳的备份
+func BackupAt(backups []BackupObject, timestamp string) (*BackupObject, error) {
+	for i := range backups {
+		if backups[i].Timestamp == timestamp {
+			return &backups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: 没有时间戳为 %s 的备份", ErrNoBackup, timestamp)
+}
+
+// Resolve 定位指定时间戳的备份归档（格式、分卷）。列举被拒绝时逐个探测支持的扩展名及其分卷
+func (d *Detector) Resolve(ctx context.Context, baseURL, podName, timestamp string) (*BackupObject, error) {
+	backups, err := d.ListBackups(ctx, baseURL, podName)
+	if err == nil {
+		return BackupAt(backups, timestamp)
+	}
+	if !errors.Is(err, ErrAccessDenied) {
+		return nil, err
+	}
+	logger.Warn("列举备份失败，逐个探测备份格式", zap.Error(err))
+
+	t, err := time.ParseInLocation(timestampLayout, timestamp, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("时间戳格式错误: %w", err)
+	}
+	base := strings.TrimSuffix(baseURL, "/")
+	for _, ext := range backupname.Extensions {
+		name := d.filenames.Filename(podName, timestamp, ext)
+		b := &BackupObject{
+			Name:      name,
+			URL:       fmt.Sprintf("%s/%s", base, name),
+			Timestamp: timestamp,
+			Time:      t,
+			Ext:       ext,
+		}
+
+		// 没有列举权限时，不存在的对象通常也返回 403，探测失败按不存在处理
+		exists, size, err := d.downloader.Exists(ctx, b.URL)
+		if err != nil {
+			logger.Debug("探测备份失败", zap.String("name", name), zap.Error(err))
+		}
+		if exists {
+			b.Size = size
+			return b, nil
+		}
+
+		for part := 0; ; part++ {
+			partName := archive.PartName(name, part)
+			exists, size, err := d.downloader.Exists(ctx, fmt.Sprintf("%s/%s", base, partName))
+			if err != nil {
+				logger.Debug("探测分卷失败", zap.String("name", partName), zap.Error(err))
+			}
+			if !exists {
+				break
+			}
+			b.Parts = append(b.Parts, partName)
+			b.Size += size
+		}
+		if len(b.Parts) > 0 {
+			return b, nil
+		}
 	}
+	return nil, fmt.Errorf("%w: 没有时间戳为 %s 的备份（已尝试 %s）", ErrNoBackup, timestamp, strings.Join(backupname.Extensions, "、"))
 }
 
 // DetectTimestamp 自动检测备份文件的时间戳
@@ -40,7 +331,7 @@ func (d *Detector) DetectTimestamp(ctx context.Context, baseURL, podName string)
 	for second := 1; second <= 10; second++ {
 		secondStr := fmt.Sprintf("%02d", second)
 		timestamp := baseTimestamp + secondStr
-		filename := fmt.Sprintf("emsau_%s_%s.tar.gz", podName, timestamp)
+		filename := d.filenames.Filename(podName, timestamp, "")
 		url := fmt.Sprintf("%s/%s", baseURL, filename)
 
 		exists, size, err := d.downloader.Exists(ctx, url)
@@ -85,7 +376,7 @@ func (d *Detector) DetectTimestampCustom(ctx context.Context, baseURL, podName,
 		for second := 1; second <= 10; second++ {
 			secondStr := fmt.Sprintf("%02d", second)
 			timestamp := strings.ReplaceAll(searchPattern, "*", secondStr)
-			filename := fmt.Sprintf("emsau_%s_%s.tar.gz", podName, timestamp)
+			filename := d.filenames.Filename(podName, timestamp, "")
 			url := fmt.Sprintf("%s/%s", baseURL, filename)
 
 			exists, _, err := d.downloader.Exists(ctx, url)
@@ -103,7 +394,7 @@ func (d *Detector) DetectTimestampCustom(ctx context.Context, baseURL, podName,
 		}
 	} else {
 		// 直接检查
-		filename := fmt.Sprintf("emsau_%s_%s.tar.gz", podName, searchPattern)
+		filename := d.filenames.Filename(podName, searchPattern, "")
 		url := fmt.Sprintf("%s/%s", baseURL, filename)
 
 		exists, _, err := d.downloader.Exists(ctx, url)
@@ -119,17 +410,13 @@ func (d *Detector) DetectTimestampCustom(ctx context.Context, baseURL, podName,
 	return "", fmt.Errorf("未找到匹配的备份文件")
 }
 
-// ParseTimestamp 从文件名解析时间戳
+// ParseTimestamp 按默认文件名模板从文件名解析时间戳
 func ParseTimestamp(filename string) (string, error) {
-	// 匹配模式：emsau_podname_YYYYMMDDHHMMSS.tar.gz
-	re := regexp.MustCompile(`emsau_\S+_(\d{14})\.tar\.gz`)
-	matches := re.FindStringSubmatch(filename)
-
-	if len(matches) < 2 {
+	timestamp, _, ok := backupname.Default().Matcher("").Match(filename)
+	if !ok {
 		return "", fmt.Errorf("无法从文件名解析时间戳: %s", filename)
 	}
-
-	return matches[1], nil
+	return timestamp, nil
 }
 
 // ValidateTimestamp 验证时间戳格式
@@ -153,6 +440,20 @@ func ValidateTimestamp(timestamp string) error {
 	return nil
 }
 
+// ParseTimeSelector 解析 --before/--at-or-before 的时间，按本地时区解释。
+// 支持 20060102150405、2006-01-02 15:04:05、2006-01-02T15:04 和 RFC3339
+func ParseTimeSelector(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{timestampLayout, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q，支持 20060102150405、2006-01-02 15:04:05 或 RFC3339", value)
+}
+
 // FormatTimestamp 格式化时间戳为可读字符串
 func FormatTimestamp(timestamp string) (string, error) {
 	t, err := time.Parse("20060102150405", timestamp)
@@ -163,13 +464,12 @@ func FormatTimestamp(timestamp string) (string, error) {
 	return t.Format("2006-01-02 15:04:05"), nil
 }
 
-// BuildBackupURL 构建备份文件的完整 URL
+// BuildBackupURL 按默认文件名模板构建备份文件的完整 URL
 func BuildBackupURL(baseURL, podName, timestamp string) string {
-	filename := fmt.Sprintf("emsau_%s_%s.tar.gz", podName, timestamp)
-	return fmt.Sprintf("%s/%s", baseURL, filename)
+	return fmt.Sprintf("%s/%s", baseURL, BuildBackupFilename(podName, timestamp))
 }
 
-// BuildBackupFilename 构建备份文件名
+// BuildBackupFilename 按默认文件名模板构建备份文件名
 func BuildBackupFilename(podName, timestamp string) string {
-	return fmt.Sprintf("emsau_%s_%s.tar.gz", podName, timestamp)
+	return backupname.Default().Filename(podName, timestamp, "")
 }
@@ -0,0 +1,351 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPod = "iotdb-datanode-0"
+
+// newBucketServer 模拟存储空间：根路径（或 /<bucket>）响应 ListObjects，每页 2 个对象且不返回 NextMarker
+func newBucketServer(t *testing.T, bucketPath string, keys []string) *httptest.Server {
+	t.Helper()
+	sort.Strings(keys)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != bucketPath {
+			for _, key := range keys {
+				if req.URL.Path == strings.TrimSuffix(bucketPath, "/")+"/"+key {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+			}
+			http.NotFound(w, req)
+			return
+		}
+
+		query := req.URL.Query()
+		var page listBucketResult
+		for _, key := range keys {
+			if !strings.HasPrefix(key, query.Get("prefix")) || key <= query.Get("marker") {
+				continue
+			}
+			if len(page.Contents) == 2 {
+				page.IsTruncated = true
+				break
+			}
+			page.Contents = append(page.Contents, struct {
+				Key          string `xml:"Key"`
+				Size         int64  `xml:"Size"`
+				LastModified string `xml:"LastModified"`
+				ETag         string `xml:"ETag"`
+			}{Key: key, Size: int64(len(key)), LastModified: "2026-02-03T08:35:11.000Z"})
+		}
+		xml.NewEncoder(w).Encode(page)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func backupTime(t *testing.T, timestamp string) time.Time {
+	t.Helper()
+	tm, err := time.ParseInLocation(timestampLayout, timestamp, time.Local)
+	if err != nil {
+		t.Fatalf("parse %s: %v", timestamp, err)
+	}
+	return tm
+}
+
+func TestListBackups(t *testing.T) {
+	keys := []string{
+		"ems-au/emsau_iotdb-datanode-0_20260203073502.tar.gz",
+		"ems-au/emsau_iotdb-datanode-0_20260203083511.tar.gz",
+		"ems-au/emsau_iotdb-datanode-0_20260203083511.tar.gz.sha256",
+		"ems-au/emsau_iotdb-datanode-1_20260203093502.tar.gz",
+		"ems-au/emsau_iotdb-datanode-0_20260202083505.tar.gz",
+		"other/emsau_iotdb-datanode-0_20260203103502.tar.gz",
+	}
+	want := []string{"20260202083505", "20260203073502", "20260203083511"}
+
+	tests := []struct {
+		name       string
+		bucketPath string
+		baseURL    func(srv *httptest.Server) string
+		pathStyle  bool
+	}{
+		{
+			name:       "virtual hosted",
+			bucketPath: "/",
+			baseURL:    func(srv *httptest.Server) string { return srv.URL + "/ems-au" },
+		},
+		{
+			name:       "path style",
+			bucketPath: "/backups",
+			baseURL:    func(srv *httptest.Server) string { return srv.URL + "/backups/ems-au/" },
+			pathStyle:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newBucketServer(t, tt.bucketPath, keys)
+			d := NewOSSDownloader()
+			d.pathStyle = tt.pathStyle
+
+			backups, err := NewDetector(d, nil).ListBackups(context.Background(), tt.baseURL(srv), testPod)
+			if err != nil {
+				t.Fatalf("list backups: %v", err)
+			}
+			var got []string
+			for _, b := range backups {
+				got = append(got, b.Timestamp)
+			}
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Fatalf("expected backups %v, got %v", want, got)
+			}
+			if last := backups[len(backups)-1]; last.URL != strings.TrimSuffix(tt.baseURL(srv), "/")+"/"+last.Name {
+				t.Fatalf("unexpected backup url %s", last.URL)
+			}
+		})
+	}
+}
+
+func TestSelectBackup(t *testing.T) {
+	var backups []BackupObject
+	for _, ts := range []string{"20260202083505", "20260203073502", "20260203083511"} {
+		backups = append(backups, BackupObject{Timestamp: ts, Time: backupTime(t, ts)})
+	}
+
+	tests := []struct {
+		name    string
+		query   BackupQuery
+		want    string
+		wantErr bool
+	}{
+		{name: "latest", query: BackupQuery{}, want: "20260203083511"},
+		{name: "latest within max age", query: BackupQuery{MaxAge: 24 * time.Hour, Before: backupTime(t, "20260203090000")}, want: "20260203083511"},
+		{name: "before is exclusive", query: BackupQuery{Before: backupTime(t, "20260203083511")}, want: "20260203073502"},
+		{name: "at or before is inclusive", query: BackupQuery{Before: backupTime(t, "20260203083511"), Inclusive: true}, want: "20260203083511"},
+		{name: "previous day", query: BackupQuery{Before: backupTime(t, "20260203000000")}, want: "20260202083505"},
+		{name: "too old", query: BackupQuery{Before: backupTime(t, "20260205000000"), MaxAge: 24 * time.Hour}, wantErr: true},
+		{name: "nothing before", query: BackupQuery{Before: backupTime(t, "20260201000000")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectBackup(backups, tt.query)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNoBackup) {
+					t.Fatalf("expected ErrNoBackup, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("select backup: %v", err)
+			}
+			if got.Timestamp != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got.Timestamp)
+			}
+		})
+	}
+}
+
+func TestFindBackupInLocalDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"emsau_iotdb-datanode-0_20260203083511.tar.gz",
+		"emsau_iotdb-datanode-0_20260203093502.tar.gz",
+		"emsau_iotdb-datanode-0_20260203093502.tar.gz.progress",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("backup "+name), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	baseURL := "file://" + filepath.ToSlash(dir)
+	d := NewOSSDownloader()
+
+	timestamp, err := NewDetector(d, nil).FindBackup(context.Background(), baseURL, testPod,
+		BackupQuery{Before: backupTime(t, "20260203093000")})
+	if err != nil || timestamp != "20260203083511" {
+		t.Fatalf("expected 20260203083511, got %q, %v", timestamp, err)
+	}
+
+	// file:// 地址同样可以下载
+	dest := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := d.DownloadWithProgress(context.Background(), BuildBackupURL(baseURL, testPod, timestamp), dest); err != nil {
+		t.Fatalf("download from local dir: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, []byte("backup emsau_iotdb-datanode-0_20260203083511.tar.gz")) {
+		t.Fatalf("unexpected downloaded content %q", got)
+	}
+}
+
+func TestFindBackupFallsBackToProbe(t *testing.T) {
+	timestamp := time.Now().Format("2006010215") + "3505"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/ems-au/"+BuildBackupFilename(testPod, timestamp) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if req.URL.Path == "/" {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+		http.NotFound(w, req)
+	}))
+	defer srv.Close()
+
+	detector := NewDetector(nil, nil)
+	got, err := detector.FindBackup(context.Background(), srv.URL+"/ems-au", testPod, BackupQuery{})
+	if err != nil || got != timestamp {
+		t.Fatalf("expected fallback to find %s, got %q, %v", timestamp, got, err)
+	}
+
+	// 指定时间点时不回退
+	if _, err := detector.FindBackup(context.Background(), srv.URL+"/ems-au", testPod,
+		BackupQuery{Before: time.Now()}); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expected access denied with a time selector, got %v", err)
+	}
+}
+
+func TestListBackupsGroupsVolumes(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		// 同一时间戳同时存在 tar.gz 和 tar.zst，按扩展名优先级取 tar.gz
+		"emsau_iotdb-datanode-0_20260203073502.tar.gz",
+		"emsau_iotdb-datanode-0_20260203073502.tar.zst",
+		"emsau_iotdb-datanode-0_20260203083511.tar.zst.part000",
+		"emsau_iotdb-datanode-0_20260203083511.tar.zst.part001",
+		"emsau_iotdb-datanode-0_20260203083511.tar.zst.part002",
+		// 缺少 part001，跳过
+		"emsau_iotdb-datanode-0_20260203093502.tar.lz4.part000",
+		"emsau_iotdb-datanode-0_20260203093502.tar.lz4.part002",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("0123456789"), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	baseURL := "file://" + filepath.ToSlash(dir)
+
+	backups, err := NewDetector(nil, nil).ListBackups(context.Background(), baseURL, testPod)
+	if err != nil {
+		t.Fatalf("list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %+v", backups)
+	}
+	if backups[0].Ext != "tar.gz" || len(backups[0].Parts) != 0 {
+		t.Fatalf("expected tar.gz to be preferred, got %+v", backups[0])
+	}
+	split := backups[1]
+	if split.Name != "emsau_iotdb-datanode-0_20260203083511.tar.zst" || split.Ext != "tar.zst" || split.Size != 30 {
+		t.Fatalf("unexpected split backup %+v", split)
+	}
+	if got := strings.Join(split.Files(), ","); got != split.Name+".part000,"+split.Name+".part001,"+split.Name+".part002" {
+		t.Fatalf("unexpected parts %s", got)
+	}
+}
+
+func TestResolveProbesVolumes(t *testing.T) {
+	timestamp := "20260203083511"
+	name := "emsau_iotdb-datanode-0_20260203083511.tar.zst"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/ems-au/" + name + ".part000", "/ems-au/" + name + ".part001":
+			w.Header().Set("Content-Length", "100")
+			w.WriteHeader(http.StatusOK)
+		default:
+			// 禁止列举，且没有列举权限时不存在的对象同样返回 403
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	detector := NewDetector(nil, nil)
+	backup, err := detector.Resolve(context.Background(), srv.URL+"/ems-au", testPod, timestamp)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if backup.Ext != "tar.zst" || len(backup.Parts) != 2 || backup.Size != 200 {
+		t.Fatalf("unexpected backup %+v", backup)
+	}
+
+	if _, err := detector.Resolve(context.Background(), srv.URL+"/ems-au", testPod, "20260203093502"); !errors.Is(err, ErrNoBackup) {
+		t.Fatalf("expected ErrNoBackup, got %v", err)
+	}
+}
+
+func TestParseTimeSelector(t *testing.T) {
+	want := backupTime(t, "20260203083500")
+	for _, value := range []string{"20260203083500", "2026-02-03 08:35:00", "2026-02-03T08:35", want.Format(time.RFC3339)} {
+		got, err := ParseTimeSelector(value)
+		if err != nil || !got.Equal(want) {
+			t.Fatalf("parse %q: expected %s, got %s, %v", value, want, got, err)
+		}
+	}
+	if _, err := ParseTimeSelector("yesterday"); err == nil {
+		t.Fatalf("expected error for invalid selector")
+	}
+}
+
+func TestFindGaps(t *testing.T) {
+	backupsAt := func(timestamps ...string) []BackupObject {
+		var backups []BackupObject
+		for _, ts := range timestamps {
+			backups = append(backups, BackupObject{Timestamp: ts, Time: backupTime(t, ts)})
+		}
+		return backups
+	}
+
+	tests := []struct {
+		name    string
+		backups []BackupObject
+		until   string
+		want    []string
+	}{
+		{
+			name:    "hourly without gaps",
+			backups: backupsAt("20260203073502", "20260203083511", "20260203093502"),
+			until:   "20260203101000",
+		},
+		{
+			name:    "missing hours in the middle",
+			backups: backupsAt("20260203053502", "20260203083502", "20260203093502"),
+			until:   "20260203103000",
+			want:    []string{"20260203060000-20260203080000:2"},
+		},
+		{
+			name:    "trailing gap up to the current hour",
+			backups: backupsAt("20260203053502", "20260203063502"),
+			until:   "20260203093000",
+			want:    []string{"20260203070000-20260203090000:2"},
+		},
+		{
+			name:    "late backup still fills its hour",
+			backups: backupsAt("20260203073502", "20260203085959", "20260203093502"),
+			until:   "20260203100000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, gap := range FindGaps(tt.backups, time.Hour, backupTime(t, tt.until)) {
+				got = append(got, gap.From.Format(timestampLayout)+"-"+gap.To.Format(timestampLayout)+":"+strconv.Itoa(gap.Missing))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("expected gaps %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
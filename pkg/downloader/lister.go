@@ -0,0 +1,163 @@
+package downloader
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// listPageSize 每次 ListObjects 请求返回的最大对象数
+const listPageSize = 1000
+
+// ObjectInfo 列举到的对象，Name 为相对 baseURL 的文件名
+type ObjectInfo struct {
+	Name         string
+	Size         int64
+	LastModified time.Time
+	// ETag 对象存储返回的 ETag（已去掉引号），本地目录为空
+	ETag string
+}
+
+// listBucketResult OSS/S3 ListObjects（V1）响应
+type listBucketResult struct {
+	IsTruncated bool   `xml:"IsTruncated"`
+	NextMarker  string `xml:"NextMarker"`
+	Contents    []struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+	} `xml:"Contents"`
+}
+
+// List 列举 baseURL 下的文件（不含子目录）。
+// http(s) 地址使用 OSS/S3 ListObjects，file:// 地址直接读取本地目录。
+func (d *OSSDownloader) List(ctx context.Context, baseURL string) ([]ObjectInfo, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("解析 URL 失败: %w", err)
+	}
+	if u.Scheme == "file" {
+		return listDir(u.Path)
+	}
+
+	bucketURL, prefix := splitBucketURL(u, d.pathStyle)
+	var objects []ObjectInfo
+	marker := ""
+	for {
+		page, err := d.listPage(ctx, bucketURL, prefix, marker)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range page.Contents {
+			name := strings.TrimPrefix(c.Key, prefix)
+			if name == "" {
+				continue
+			}
+			modified, _ := time.Parse(time.RFC3339, c.LastModified)
+			objects = append(objects, ObjectInfo{Name: name, Size: c.Size, LastModified: modified, ETag: strings.Trim(c.ETag, `"`)})
+		}
+		if !page.IsTruncated || len(page.Contents) == 0 {
+			return objects, nil
+		}
+		// S3 只在指定 delimiter 时返回 NextMarker，兜底使用本页最后一个 Key
+		marker = page.NextMarker
+		if marker == "" {
+			marker = page.Contents[len(page.Contents)-1].Key
+		}
+	}
+}
+
+func (d *OSSDownloader) listPage(ctx context.Context, bucketURL *url.URL, prefix, marker string) (*listBucketResult, error) {
+	query := url.Values{}
+	query.Set("prefix", prefix)
+	query.Set("delimiter", "/")
+	query.Set("max-keys", fmt.Sprint(listPageSize))
+	if marker != "" {
+		query.Set("marker", marker)
+	}
+	listURL := *bucketURL
+	listURL.RawQuery = query.Encode()
+
+	req, err := d.newRequest(ctx, "GET", listURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("列举备份失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("列举备份失败: %w: 状态码 %d", ErrAccessDenied, resp.StatusCode)
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("列举备份失败: %w: %s", ErrNotFound, bucketURL)
+	default:
+		return nil, fmt.Errorf("列举备份失败: 服务器返回错误状态码: %d", resp.StatusCode)
+	}
+
+	var page listBucketResult
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxFetchSize)).Decode(&page); err != nil {
+		return nil, fmt.Errorf("解析列举结果失败: %w", err)
+	}
+	return &page, nil
+}
+
+// splitBucketURL 把 base_url 拆分为存储空间地址和对象前缀。
+// 虚拟主机风格（https://<bucket>.<endpoint>/<prefix>）的存储空间地址为主机根路径；
+// 路径风格（MinIO 等，http://<endpoint>/<bucket>/<prefix>）的第一段路径为存储空间。
+func splitBucketURL(u *url.URL, pathStyle bool) (*url.URL, string) {
+	bucketURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+	rest := strings.Trim(u.Path, "/")
+	if pathStyle {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		bucketURL.Path = "/" + bucket
+		rest = prefix
+	}
+	if rest == "" {
+		return bucketURL, ""
+	}
+	return bucketURL, rest + "/"
+}
+
+// virtualHostedS3 判断 S3 地址是否为虚拟主机风格（<bucket>.s3.<region>.amazonaws.com）
+func virtualHostedS3(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	return strings.Contains(host, ".s3.") || strings.Contains(host, ".s3-")
+}
+
+// listDir 列举本地备份目录
+func listDir(dir string) ([]ObjectInfo, error) {
+	entries, err := os.ReadDir(filepath.FromSlash(path.Clean(dir)))
+	if err != nil {
+		return nil, fmt.Errorf("读取备份目录失败: %w", err)
+	}
+
+	objects := make([]ObjectInfo, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("读取文件信息失败: %w", err)
+		}
+		objects = append(objects, ObjectInfo{Name: entry.Name(), Size: info.Size(), LastModified: info.ModTime()})
+	}
+	return objects, nil
+}
@@ -0,0 +1,439 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultDownloadParts 默认分片下载并发数
+	defaultDownloadParts = 4
+	// defaultChunkSize 默认分片大小
+	defaultChunkSize = 16 << 20
+	// progressSuffix 断点续传进度文件后缀
+	progressSuffix = ".progress"
+)
+
+// remoteInfo HEAD 请求得到的远程文件信息
+type remoteInfo struct {
+	// size 为 -1 表示未知
+	size         int64
+	etag         string
+	contentMD5   string
+	acceptRanges bool
+}
+
+// head 获取远程文件大小、ETag 以及是否支持 Range 请求。
+// 服务端拒绝 HEAD（如只对 GET 签名的地址）时退化为未知大小，由单连接下载处理。
+func (d *OSSDownloader) head(ctx context.Context, url string) (*remoteInfo, error) {
+	req, err := d.newRequest(ctx, "HEAD", url)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return &remoteInfo{
+			size:         resp.ContentLength,
+			etag:         resp.Header.Get("ETag"),
+			contentMD5:   resp.Header.Get("Content-MD5"),
+			acceptRanges: resp.Header.Get("Accept-Ranges") == "bytes",
+		}, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, url)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		// 签名访问时 HEAD 与 GET 使用同一凭证，拒绝即凭证有误；匿名访问时可能只允许 GET
+		if d.signer != nil {
+			return nil, fmt.Errorf("%w: 状态码 %d", ErrAccessDenied, resp.StatusCode)
+		}
+		fallthrough
+	default:
+		logger.Warn("HEAD 请求失败，无法分片下载",
+			zap.String("url", url),
+			zap.Int("status", resp.StatusCode),
+		)
+		return &remoteInfo{size: -1}, nil
+	}
+}
+
+// downloadProgress 分片下载进度，保存在 <dest>.progress 中用于断点续传
+type downloadProgress struct {
+	Size      int64  `json:"size"`
+	ETag      string `json:"etag,omitempty"`
+	ChunkSize int64  `json:"chunk_size"`
+	Completed []int  `json:"completed"`
+}
+
+func (p *downloadProgress) chunkCount() int {
+	return int((p.Size + p.ChunkSize - 1) / p.ChunkSize)
+}
+
+// chunkRange 返回分片的起止偏移（含 end）
+func (p *downloadProgress) chunkRange(idx int) (int64, int64) {
+	start := int64(idx) * p.ChunkSize
+	end := start + p.ChunkSize - 1
+	if end >= p.Size {
+		end = p.Size - 1
+	}
+	return start, end
+}
+
+func progressPath(destPath string) string {
+	return destPath + progressSuffix
+}
+
+// loadProgress 读取进度文件，仅当远程文件大小和 ETag 未变化且本地文件完好时才返回。
+// 远程文件大小未知时只要 ETag 未变化且支持 Range 请求，仍沿用进度文件
+func loadProgress(destPath string, info *remoteInfo) *downloadProgress {
+	data, err := os.ReadFile(progressPath(destPath))
+	if err != nil {
+		return nil
+	}
+
+	var progress downloadProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		logger.Warn("进度文件损坏，重新下载", zap.String("file", destPath), zap.Error(err))
+		return nil
+	}
+	if progress.ChunkSize <= 0 {
+		logger.Warn("进度文件无效，重新下载", zap.String("file", destPath))
+		return nil
+	}
+	if info.size < 0 {
+		if info.etag == "" || progress.ETag != info.etag || !info.acceptRanges {
+			logger.Warn("无法获取远程文件大小，且无法通过 ETag 确认远程文件未变化，丢弃下载进度",
+				zap.String("file", destPath),
+				zap.String("etag", info.etag),
+			)
+			return nil
+		}
+		logger.Info("无法获取远程文件大小，ETag 未变化，沿用下载进度",
+			zap.String("file", destPath),
+			zap.Int64("size", progress.Size),
+			zap.String("etag", info.etag),
+		)
+	} else if progress.Size != info.size || progress.ETag != info.etag {
+		logger.Warn("远程文件已变化，丢弃下载进度",
+			zap.String("file", destPath),
+			zap.Int64("size", info.size),
+			zap.String("etag", info.etag),
+		)
+		return nil
+	}
+	if stat, err := os.Stat(destPath); err != nil || stat.Size() != progress.Size {
+		return nil
+	}
+	return &progress
+}
+
+// saveProgress 先写临时文件再重命名，避免中断时留下半截的进度文件
+func saveProgress(destPath string, progress *downloadProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("序列化下载进度失败: %w", err)
+	}
+	tmp := progressPath(destPath) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入下载进度失败: %w", err)
+	}
+	if err := os.Rename(tmp, progressPath(destPath)); err != nil {
+		return fmt.Errorf("写入下载进度失败: %w", err)
+	}
+	return nil
+}
+
+// downloadChunks 按分片并行下载，每完成一个分片即更新进度文件；失败时保留文件和进度以便续传。
+// algorithm 不为空时按分片顺序计算校验和，续传时已完成的分片从本地文件读回。
+func (d *OSSDownloader) downloadChunks(ctx context.Context, url, destPath string, info *remoteInfo, progress *downloadProgress, algorithm string) (string, error) {
+	resumed := progress != nil
+	if progress == nil {
+		progress = &downloadProgress{Size: info.size, ETag: info.etag, ChunkSize: d.chunkSize}
+	}
+
+	done := make(map[int]bool, len(progress.Completed))
+	var doneBytes int64
+	for _, idx := range progress.Completed {
+		if idx < 0 || idx >= progress.chunkCount() || done[idx] {
+			continue
+		}
+		done[idx] = true
+		start, end := progress.chunkRange(idx)
+		doneBytes += end - start + 1
+	}
+	var pending []int
+	for idx := 0; idx < progress.chunkCount(); idx++ {
+		if !done[idx] {
+			pending = append(pending, idx)
+		}
+	}
+
+	f, err := os.OpenFile(destPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return "", fmt.Errorf("创建目标文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if err := f.Truncate(progress.Size); err != nil {
+		return "", fmt.Errorf("预分配目标文件失败: %w", err)
+	}
+	if err := saveProgress(destPath, progress); err != nil {
+		return "", err
+	}
+
+	var hasher *chunkHasher
+	if algorithm != "" {
+		hasher = newChunkHasher(algorithm, f, progress)
+		for idx := range done {
+			if err := hasher.complete(idx); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	logger.Info("开始分片下载",
+		zap.String("url", url),
+		zap.String("dest", destPath),
+		zap.Int64("size", progress.Size),
+		zap.Int("chunks", progress.chunkCount()),
+		zap.Int("pending", len(pending)),
+		zap.Int("parts", d.parts),
+		zap.Bool("resumed", resumed),
+	)
+
+	tracker := newProgressTracker(url, progress.Size, doneBytes)
+	chunkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	jobs := make(chan int)
+	for i := 0; i < d.parts && i < len(pending); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				start, end := progress.chunkRange(idx)
+				if err := d.downloadChunkWithRetry(chunkCtx, url, f, start, end, info.etag, tracker); err != nil {
+					fail(fmt.Errorf("分片 %d (%d-%d) 下载失败: %w", idx, start, end, err))
+					continue
+				}
+
+				mu.Lock()
+				progress.Completed = append(progress.Completed, idx)
+				sort.Ints(progress.Completed)
+				err := saveProgress(destPath, progress)
+				if err == nil && hasher != nil {
+					err = hasher.complete(idx)
+				}
+				mu.Unlock()
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, idx := range pending {
+		select {
+		case jobs <- idx:
+		case <-chunkCtx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return "", fmt.Errorf("下载中断，进度已保存，重新执行可续传: %w", firstErr)
+	}
+
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("写入目标文件失败: %w", err)
+	}
+	stat, err := os.Stat(destPath)
+	if err != nil {
+		return "", fmt.Errorf("检查目标文件失败: %w", err)
+	}
+	if stat.Size() != info.size {
+		return "", fmt.Errorf("文件大小不一致: 期望 %d 实际 %d", info.size, stat.Size())
+	}
+	os.Remove(progressPath(destPath))
+
+	tracker.finish()
+	if hasher == nil {
+		return "", nil
+	}
+	return hasher.sum(), nil
+}
+
+func (d *OSSDownloader) downloadChunkWithRetry(ctx context.Context, url string, f *os.File, start, end int64, etag string, tracker *progressTracker) error {
+	var lastErr error
+	for attempt := 0; attempt < d.maxRetries; attempt++ {
+		if attempt > 0 {
+			logger.Warn("分片下载重试",
+				zap.Int64("start", start),
+				zap.Int("attempt", attempt+1),
+				zap.Int("max_retries", d.maxRetries),
+				zap.Error(lastErr),
+			)
+			if err := SleepContext(ctx, d.retryDelay); err != nil {
+				return err
+			}
+		}
+
+		written, err := d.downloadChunk(ctx, url, f, start, end, etag, tracker)
+		if err == nil {
+			return nil
+		}
+		// 回退本次失败分片已计入的进度
+		tracker.add(-written)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// downloadChunk 下载 [start, end] 区间并写入文件对应偏移
+func (d *OSSDownloader) downloadChunk(ctx context.Context, url string, f *os.File, start, end int64, etag string, tracker *progressTracker) (int64, error) {
+	req, err := d.newRequest(ctx, "GET", url)
+	if err != nil {
+		return 0, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("服务器未返回分片内容，状态码: %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("ETag"); etag != "" && got != "" && got != etag {
+		return 0, fmt.Errorf("远程文件在下载过程中发生变化: ETag %s -> %s", etag, got)
+	}
+
+	length := end - start + 1
+	writer := &progressWriter{writer: io.NewOffsetWriter(f, start), tracker: tracker}
+	written, err := io.Copy(writer, io.LimitReader(resp.Body, length))
+	if err != nil {
+		return written, fmt.Errorf("写入分片失败: %w", err)
+	}
+	if written != length {
+		return written, fmt.Errorf("分片不完整: 期望 %d 实际 %d", length, written)
+	}
+	return written, nil
+}
+
+// progressTracker 汇总所有分片的下载进度，可并发调用
+type progressTracker struct {
+	mu        sync.Mutex
+	url       string
+	total     int64
+	written   int64
+	resumed   int64
+	startTime time.Time
+	lastLog   time.Time
+	lastTenth int64
+}
+
+// newProgressTracker 创建进度跟踪器，resumed 为续传前已下载的字节数
+func newProgressTracker(url string, total, resumed int64) *progressTracker {
+	now := time.Now()
+	return &progressTracker{
+		url:       url,
+		total:     total,
+		written:   resumed,
+		resumed:   resumed,
+		startTime: now,
+		lastLog:   now,
+	}
+}
+
+func (t *progressTracker) add(n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.written += n
+
+	// 每 5 秒或每 10% 打印一次进度
+	var tenth int64
+	if t.total > 0 {
+		tenth = t.written * 10 / t.total
+	}
+	now := time.Now()
+	if now.Sub(t.lastLog) > 5*time.Second || tenth > t.lastTenth {
+		t.log("下载进度")
+		t.lastLog = now
+		t.lastTenth = tenth
+	}
+}
+
+func (t *progressTracker) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.log("下载完成")
+}
+
+func (t *progressTracker) log(msg string) {
+	fields := []zap.Field{
+		zap.String("url", t.url),
+		zap.String("size", FormatBytes(t.written)),
+		zap.String("total", FormatBytes(t.total)),
+		zap.Duration("duration", time.Since(t.startTime)),
+	}
+	if t.total > 0 {
+		fields = append(fields, zap.Float64("percent", float64(t.written)/float64(t.total)*100))
+	}
+	if elapsed := time.Since(t.startTime).Seconds(); elapsed > 0 {
+		fields = append(fields, zap.Float64("speed", float64(t.written-t.resumed)/elapsed/1024/1024)) // MB/s
+	}
+	logger.Info(msg, fields...)
+}
+
+// SleepContext 等待 d 或 ctx 结束，ctx 先结束时返回 ctx.Err()
+func SleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,259 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rangeServer 支持 Range 的测试文件服务，记录每次 GET 的 Range 头
+type rangeServer struct {
+	*httptest.Server
+	content []byte
+
+	mu     sync.Mutex
+	ranges []string
+	// failRange 命中该 Range 的请求返回 500
+	failRange string
+	// unknownSize HEAD 不返回 Content-Length
+	unknownSize bool
+}
+
+func newRangeServer(t *testing.T, content []byte) *rangeServer {
+	t.Helper()
+	s := &rangeServer{content: content}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			s.mu.Lock()
+			s.ranges = append(s.ranges, req.Header.Get("Range"))
+			fail := s.failRange != "" && req.Header.Get("Range") == s.failRange
+			s.mu.Unlock()
+			if fail {
+				http.Error(w, "boom", http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("ETag", `"v1"`)
+		s.mu.Lock()
+		unknownSize := s.unknownSize
+		s.mu.Unlock()
+		if req.Method == http.MethodHead && unknownSize {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.ServeContent(w, req, "backup.tar.gz", time.Time{}, bytes.NewReader(s.content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *rangeServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+func newTestDownloader(parts int, chunkSize int64) *OSSDownloader {
+	d := NewOSSDownloader()
+	d.retryDelay = time.Millisecond
+	d.SetMultipart(parts, chunkSize)
+	return d
+}
+
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	return content
+}
+
+func TestDownloadInParallelParts(t *testing.T) {
+	content := testContent(10*1024 + 17)
+	srv := newRangeServer(t, content)
+	dest := filepath.Join(t.TempDir(), "backup.tar.gz")
+
+	if err := newTestDownloader(3, 1024).DownloadWithProgress(context.Background(), srv.URL+"/backup.tar.gz", dest); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("read dest: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("downloaded content differs from source")
+	}
+	if n := len(srv.requests()); n != 11 {
+		t.Fatalf("expected 11 ranged requests, got %d", n)
+	}
+	if _, err := os.Stat(progressPath(dest)); !os.IsNotExist(err) {
+		t.Fatalf("expected progress file to be removed, got %v", err)
+	}
+}
+
+func TestDownloadResumesMissingParts(t *testing.T) {
+	content := testContent(4096)
+	srv := newRangeServer(t, content)
+	dest := filepath.Join(t.TempDir(), "backup.tar.gz")
+	url := srv.URL + "/backup.tar.gz"
+
+	srv.failRange = "bytes=2048-3071"
+	err := newTestDownloader(1, 1024).DownloadWithProgress(context.Background(), url, dest)
+	if err == nil {
+		t.Fatalf("expected first download to fail")
+	}
+
+	data, err := os.ReadFile(progressPath(dest))
+	if err != nil {
+		t.Fatalf("expected progress file after failure: %v", err)
+	}
+	var progress downloadProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		t.Fatalf("parse progress: %v", err)
+	}
+	if len(progress.Completed) != 2 || progress.Completed[0] != 0 || progress.Completed[1] != 1 {
+		t.Fatalf("expected chunks 0 and 1 completed, got %v", progress.Completed)
+	}
+
+	srv.mu.Lock()
+	srv.failRange = ""
+	srv.ranges = nil
+	srv.mu.Unlock()
+
+	if err := newTestDownloader(1, 1024).DownloadWithProgress(context.Background(), url, dest); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, content) {
+		t.Fatalf("resumed content differs from source")
+	}
+	want := []string{"bytes=2048-3071", "bytes=3072-4095"}
+	if reqs := srv.requests(); strings.Join(reqs, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected resume to fetch only %v, got %v", want, reqs)
+	}
+}
+
+func TestDownloadResumesWhenSizeUnknown(t *testing.T) {
+	content := testContent(4096)
+	srv := newRangeServer(t, content)
+	dest := filepath.Join(t.TempDir(), "backup.tar.gz")
+	url := srv.URL + "/backup.tar.gz"
+
+	srv.failRange = "bytes=2048-3071"
+	if err := newTestDownloader(1, 1024).DownloadWithProgress(context.Background(), url, dest); err == nil {
+		t.Fatalf("expected first download to fail")
+	}
+
+	// HEAD 不再返回大小，ETag 未变化时沿用进度文件
+	srv.mu.Lock()
+	srv.failRange = ""
+	srv.ranges = nil
+	srv.unknownSize = true
+	srv.mu.Unlock()
+
+	if err := newTestDownloader(1, 1024).DownloadWithProgress(context.Background(), url, dest); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, content) {
+		t.Fatalf("resumed content differs from source")
+	}
+	want := []string{"bytes=2048-3071", "bytes=3072-4095"}
+	if reqs := srv.requests(); strings.Join(reqs, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected resume to fetch only %v, got %v", want, reqs)
+	}
+}
+
+func TestDownloadExistingFile(t *testing.T) {
+	content := testContent(3000)
+
+	tests := []struct {
+		name     string
+		existing []byte
+		requests int
+	}{
+		{name: "complete file is skipped", existing: content, requests: 0},
+		{name: "truncated file is downloaded again", existing: content[:1000], requests: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRangeServer(t, content)
+			dest := filepath.Join(t.TempDir(), "backup.tar.gz")
+			if err := os.WriteFile(dest, tt.existing, 0644); err != nil {
+				t.Fatalf("write existing file: %v", err)
+			}
+
+			if err := newTestDownloader(2, 1024).DownloadWithProgress(context.Background(), srv.URL+"/backup.tar.gz", dest); err != nil {
+				t.Fatalf("download failed: %v", err)
+			}
+
+			got, _ := os.ReadFile(dest)
+			if !bytes.Equal(got, content) {
+				t.Fatalf("dest content differs from source")
+			}
+			if n := len(srv.requests()); n != tt.requests {
+				t.Fatalf("expected %d GET requests, got %d", tt.requests, n)
+			}
+		})
+	}
+}
+
+func TestDownloadExistingFileWithoutHead(t *testing.T) {
+	content := testContent(2048)
+	gets := 0
+	// 只允许 GET 的预签名或匿名地址，HEAD 被拒绝
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodHead {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		gets++
+		w.Write(content)
+	}))
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := os.WriteFile(dest, content[:1000], 0644); err != nil {
+		t.Fatalf("write existing file: %v", err)
+	}
+
+	if err := newTestDownloader(2, 1024).DownloadWithProgress(context.Background(), srv.URL+"/backup.tar.gz", dest); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, content) || gets != 1 {
+		t.Fatalf("truncated file should be downloaded again, got %d bytes after %d GET requests", len(got), gets)
+	}
+}
+
+func TestDownloadWithoutRangeSupport(t *testing.T) {
+	content := testContent(2048)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Length", "2048")
+		if req.Method == http.MethodGet {
+			w.Write(content)
+		}
+	}))
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "backup.tar.gz")
+
+	if err := newTestDownloader(4, 512).DownloadWithProgress(context.Background(), srv.URL+"/backup.tar.gz", dest); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, content) {
+		t.Fatalf("dest content differs from source")
+	}
+}
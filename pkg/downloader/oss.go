@@ -2,6 +2,8 @@ package downloader
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -27,25 +29,74 @@ type OSSDownloader struct {
 	httpClient *http.Client
 	maxRetries int
 	retryDelay time.Duration
+	// parts 分片下载并发数，chunkSize 单个分片大小
+	parts     int
+	chunkSize int64
+	// signer 对象存储签名，为空时匿名访问
+	signer Signer
+	// pathStyle 存储空间在路径第一段（MinIO 等），影响列举时的存储空间地址
+	pathStyle bool
 }
 
 // NewOSSDownloader 创建 OSS 下载器
 func NewOSSDownloader() *OSSDownloader {
+	transport := &http.Transport{
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+		DisableCompression:  true,
+		// 启用 HTTP/2（如果服务器支持）
+		// 注意：oss-accelerate.aliyuncs.com 可能需要 HTTP/1.1
+		ForceAttemptHTTP2: false,
+	}
+	// 支持 file:// 地址，便于从挂载的本地备份目录恢复
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+
 	return &OSSDownloader{
 		httpClient: &http.Client{
-			Timeout: 30 * time.Minute,
-			Transport: &http.Transport{
-				MaxIdleConns:        10,
-				IdleConnTimeout:     90 * time.Second,
-				DisableCompression:  true,
-				// 启用 HTTP/2（如果服务器支持）
-				// 注意：oss-accelerate.aliyuncs.com 可能需要 HTTP/1.1
-				ForceAttemptHTTP2: false,
-			},
+			Timeout:   30 * time.Minute,
+			Transport: transport,
 		},
 		maxRetries: 3,
 		retryDelay: 5 * time.Second,
+		parts:      defaultDownloadParts,
+		chunkSize:  defaultChunkSize,
+	}
+}
+
+// SetMultipart 设置分片下载的并发数和分片大小，非正数保持默认值
+func (d *OSSDownloader) SetMultipart(parts int, chunkSize int64) {
+	if parts > 0 {
+		d.parts = parts
+	}
+	if chunkSize > 0 {
+		d.chunkSize = chunkSize
+	}
+}
+
+// newRequest 创建请求，配置了签名器时为请求签名（本地 file:// 地址不签名）
+func (d *OSSDownloader) newRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if d.signer != nil && req.URL.Scheme != "file" {
+		if err := d.signer.Sign(req); err != nil {
+			return nil, fmt.Errorf("请求签名失败: %w", err)
+		}
+	}
+	return req, nil
+}
+
+// PresignURL 为 Pod 内下载生成预签名地址，匿名访问时原样返回
+func (d *OSSDownloader) PresignURL(url string, expires time.Duration) (string, error) {
+	if d.signer == nil {
+		return url, nil
+	}
+	presigned, err := d.signer.Presign(url, expires)
+	if err != nil {
+		return "", fmt.Errorf("生成预签名地址失败: %w", err)
 	}
+	return presigned, nil
 }
 
 // Download 下载文件到指定路径
@@ -53,23 +104,76 @@ func (d *OSSDownloader) Download(ctx context.Context, url, destPath string) erro
 	return d.DownloadWithProgress(ctx, url, destPath)
 }
 
-// DownloadWithProgress 下载文件并显示进度
+// DownloadWithProgress 下载文件并显示进度。
+// 服务端支持 Range 时按分片并行下载，进度保存在 <dest>.progress 中，中断后重新调用会从断点继续；
+// 目标文件已存在时仅在大小与 HEAD 返回的 Content-Length 一致时才视为已完成，HEAD 失败时重新下载。
 func (d *OSSDownloader) DownloadWithProgress(ctx context.Context, url, destPath string) error {
+	_, err := d.download(ctx, url, destPath, "")
+	return err
+}
+
+// download 下载文件，algorithm 不为空时同时计算并返回文件的校验和
+func (d *OSSDownloader) download(ctx context.Context, url, destPath, algorithm string) (string, error) {
 	// 确保目标目录存在
 	destDir := filepath.Dir(destPath)
 	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("创建目标目录失败: %w", err)
+		return "", fmt.Errorf("创建目标目录失败: %w", err)
 	}
 
-	// 检查文件是否已存在
-	if info, err := os.Stat(destPath); err == nil {
-		logger.Info("文件已存在，跳过下载",
-			zap.String("file", destPath),
-			zap.Int64("size", info.Size()),
-		)
-		return nil
+	info, err := d.head(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	progress := loadProgress(destPath, info)
+	if progress != nil && info.size < 0 {
+		// ETag 未变化，远程文件大小即进度文件记录的大小
+		info.size = progress.Size
 	}
+	if progress == nil {
+		// 检查已存在的文件是否完整
+		if stat, err := os.Stat(destPath); err == nil {
+			if info.size > 0 && stat.Size() == info.size {
+				logger.Info("文件已存在且大小一致，跳过下载",
+					zap.String("file", destPath),
+					zap.Int64("size", stat.Size()),
+				)
+				return hashExisting(destPath, algorithm)
+			}
+			if info.size < 0 {
+				// 大小未知时无法判断已存在的文件是否完整（可能是中断留下的半个文件）
+				logger.Warn("无法获取远程文件大小，重新下载已存在的文件",
+					zap.String("file", destPath),
+					zap.Int64("size", stat.Size()),
+				)
+			} else {
+				logger.Warn("已存在的文件不完整，重新下载",
+					zap.String("file", destPath),
+					zap.Int64("size", stat.Size()),
+					zap.Int64("expected", info.size),
+				)
+			}
+		}
+		os.Remove(destPath)
+		os.Remove(progressPath(destPath))
+	}
+
+	if info.acceptRanges && info.size > 0 {
+		return d.downloadChunks(ctx, url, destPath, info, progress, algorithm)
+	}
+	return d.downloadSingle(ctx, url, destPath, info, algorithm)
+}
+
+// hashExisting 计算已存在文件的校验和，algorithm 为空时不计算
+func hashExisting(path, algorithm string) (string, error) {
+	if algorithm == "" {
+		return "", nil
+	}
+	return hashFile(path, algorithm)
+}
 
+// downloadSingle 服务端不支持 Range 时单连接下载，失败后删除不完整的文件
+func (d *OSSDownloader) downloadSingle(ctx context.Context, url, destPath string, info *remoteInfo, algorithm string) (string, error) {
 	var lastErr error
 	for attempt := 0; attempt < d.maxRetries; attempt++ {
 		if attempt > 0 {
@@ -78,11 +182,13 @@ func (d *OSSDownloader) DownloadWithProgress(ctx context.Context, url, destPath
 				zap.Int("max_retries", d.maxRetries),
 				zap.Error(lastErr),
 			)
-			time.Sleep(d.retryDelay)
+			if err := SleepContext(ctx, d.retryDelay); err != nil {
+				return "", err
+			}
 		}
 
 		// 创建请求
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		req, err := d.newRequest(ctx, "GET", url)
 		if err != nil {
 			lastErr = fmt.Errorf("创建请求失败: %w", err)
 			continue
@@ -95,7 +201,11 @@ func (d *OSSDownloader) DownloadWithProgress(ctx context.Context, url, destPath
 			continue
 		}
 
-		// 检查状态码
+		// 检查状态码，鉴权失败重试无意义
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			resp.Body.Close()
+			return "", fmt.Errorf("%w: 状态码 %d", ErrAccessDenied, resp.StatusCode)
+		}
 		if resp.StatusCode != http.StatusOK {
 			resp.Body.Close()
 			lastErr = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
@@ -114,22 +224,23 @@ func (d *OSSDownloader) DownloadWithProgress(ctx context.Context, url, destPath
 		destFile, err := os.Create(destPath)
 		if err != nil {
 			resp.Body.Close()
-			return fmt.Errorf("创建目标文件失败: %w", err)
+			return "", fmt.Errorf("创建目标文件失败: %w", err)
 		}
 
-		// 使用 TeeWriter 来显示进度
-		progressWriter := &progressWriter{
-			total:     fileSize,
-			writer:    destFile,
-			url:       url,
-			startTime: time.Now(),
+		// 使用 TeeWriter 来显示进度，同时计算校验和
+		var writer io.Writer = destFile
+		h := newHash(algorithm)
+		if algorithm != "" {
+			writer = io.MultiWriter(destFile, h)
 		}
-
-		// 复制数据
-		_, err = io.Copy(progressWriter, resp.Body)
+		tracker := newProgressTracker(url, fileSize, 0)
+		written, err := io.Copy(&progressWriter{writer: writer, tracker: tracker}, resp.Body)
 		resp.Body.Close()
 		destFile.Close()
 
+		if err == nil && info.size > 0 && written != info.size {
+			err = fmt.Errorf("文件大小不一致: 期望 %d 实际 %d", info.size, written)
+		}
 		if err != nil {
 			os.Remove(destPath) // 删除不完整的文件
 			lastErr = fmt.Errorf("下载文件失败: %w", err)
@@ -137,21 +248,19 @@ func (d *OSSDownloader) DownloadWithProgress(ctx context.Context, url, destPath
 		}
 
 		// 下载成功
-		progressWriter.Finish()
-		logger.Info("下载完成",
-			zap.String("file", destPath),
-			zap.Int64("size", fileSize),
-			zap.Duration("duration", time.Since(progressWriter.startTime)),
-		)
-		return nil
+		tracker.finish()
+		if algorithm == "" {
+			return "", nil
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
 	}
 
-	return fmt.Errorf("下载失败，重试 %d 次后放弃: %w", d.maxRetries, lastErr)
+	return "", fmt.Errorf("下载失败，重试 %d 次后放弃: %w", d.maxRetries, lastErr)
 }
 
 // Exists 检查远程文件是否存在，并返回文件大小
 func (d *OSSDownloader) Exists(ctx context.Context, url string) (bool, int64, error) {
-	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	req, err := d.newRequest(ctx, "HEAD", url)
 	if err != nil {
 		return false, 0, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -175,54 +284,59 @@ func (d *OSSDownloader) Exists(ctx context.Context, url string) (bool, int64, er
 	return false, 0, fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
 }
 
-// progressWriter 进度写入器
-type progressWriter struct {
-	total     int64
-	written   int64
-	writer    io.Writer
-	url       string
-	startTime time.Time
-	lastLog   time.Time
-}
+// ErrNotFound 远程文件不存在
+var ErrNotFound = errors.New("远程文件不存在")
 
-func (pw *progressWriter) Write(p []byte) (int, error) {
-	n, err := pw.writer.Write(p)
+// ErrAccessDenied 访问被拒绝，通常是凭证错误或存储空间权限不足
+var ErrAccessDenied = errors.New("访问被拒绝，请检查 backup.credentials 和存储空间权限")
+
+// maxFetchSize Fetch 读取的最大字节数，仅用于清单等小文件
+const maxFetchSize = 16 << 20
+
+// Fetch 读取小文件（如校验清单）的完整内容，文件不存在时返回 ErrNotFound
+func (d *OSSDownloader) Fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := d.newRequest(ctx, "GET", url)
 	if err != nil {
-		return n, err
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
-	pw.written += int64(n)
-
-	// 每 5 秒或每 10% 打印一次进度
-	now := time.Now()
-	if now.Sub(pw.lastLog) > 5*time.Second || pw.total > 0 && pw.written% (pw.total/10) < int64(len(p)) {
-		percent := float64(pw.written) / float64(pw.total) * 100
-		speed := float64(pw.written) / time.Since(pw.startTime).Seconds() / 1024 / 1024 // MB/s
-		logger.Info("下载进度",
-			zap.String("url", pw.url),
-			zap.Float64("percent", percent),
-			zap.String("size", formatBytes(pw.written)),
-			zap.String("total", formatBytes(pw.total)),
-			zap.Float64("speed", speed),
-		)
-		pw.lastLog = now
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if len(data) > maxFetchSize {
+		return nil, fmt.Errorf("文件超过 %d 字节", maxFetchSize)
 	}
+	return data, nil
+}
 
-	return n, nil
+// progressWriter 进度写入器
+type progressWriter struct {
+	writer  io.Writer
+	tracker *progressTracker
 }
 
-func (pw *progressWriter) Finish() {
-	percent := float64(pw.written) / float64(pw.total) * 100
-	logger.Info("下载完成",
-		zap.String("url", pw.url),
-		zap.Float64("percent", percent),
-		zap.String("size", formatBytes(pw.written)),
-		zap.Duration("duration", time.Since(pw.startTime)),
-	)
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.writer.Write(p)
+	pw.tracker.add(int64(n))
+	return n, err
 }
 
-// formatBytes 格式化字节数
-func formatBytes(b int64) string {
+// FormatBytes 格式化字节数
+func FormatBytes(b int64) string {
 	const unit = 1024
 	if b < unit {
 		return fmt.Sprintf("%d B", b)
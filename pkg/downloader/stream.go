@@ -0,0 +1,121 @@
+package downloader
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// StreamReader 远程文件的单连接读取流，读取时计算校验和并记录进度，不落盘。
+// 读到末尾时校验文件大小和期望校验和，不一致时 Read 返回错误而不是 io.EOF。
+type StreamReader struct {
+	url      string
+	body     io.ReadCloser
+	size     int64
+	read     int64
+	h        hash.Hash
+	expected *Checksum
+	checksum *Checksum
+	tracker  *progressTracker
+	done     bool
+	err      error
+}
+
+// OpenStream 打开远程文件的读取流。expected 为空时按 sha256 计算校验和，读完后可通过 Checksum 获取
+func (d *OSSDownloader) OpenStream(ctx context.Context, url string, expected *Checksum) (*StreamReader, error) {
+	algorithm := AlgorithmSHA256
+	if expected != nil {
+		algorithm = expected.Algorithm
+	}
+
+	req, err := d.newRequest(ctx, "GET", url)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, url)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: 状态码 %d", ErrAccessDenied, resp.StatusCode)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
+	}
+
+	logger.Info("开始流式读取",
+		zap.String("url", url),
+		zap.Int64("size", resp.ContentLength),
+	)
+	return &StreamReader{
+		url:      url,
+		body:     resp.Body,
+		size:     resp.ContentLength,
+		h:        newHash(algorithm),
+		expected: expected,
+		checksum: &Checksum{Algorithm: algorithm, Source: ChecksumFromDownload},
+		tracker:  newProgressTracker(url, resp.ContentLength, 0),
+	}, nil
+}
+
+// Read 实现 io.Reader
+func (s *StreamReader) Read(p []byte) (int, error) {
+	n, err := s.body.Read(p)
+	if n > 0 {
+		s.h.Write(p[:n])
+		s.read += int64(n)
+		s.tracker.add(int64(n))
+	}
+	if err == io.EOF {
+		if verifyErr := s.finish(); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+	return n, err
+}
+
+func (s *StreamReader) finish() error {
+	if s.done {
+		return s.err
+	}
+	s.done = true
+	s.err = s.verify()
+	return s.err
+}
+
+func (s *StreamReader) verify() error {
+	if s.size > 0 && s.read != s.size {
+		return fmt.Errorf("文件大小不一致: 期望 %d 实际 %d", s.size, s.read)
+	}
+	s.checksum.Value = hex.EncodeToString(s.h.Sum(nil))
+	if s.expected != nil {
+		if err := s.expected.Verify(s.url, s.checksum.Value); err != nil {
+			return err
+		}
+	}
+	s.tracker.finish()
+	return nil
+}
+
+// Checksum 返回读完后的校验和，未读到末尾时 Value 为空
+func (s *StreamReader) Checksum() *Checksum {
+	return s.checksum
+}
+
+// Close 关闭响应体
+func (s *StreamReader) Close() error {
+	return s.body.Close()
+}
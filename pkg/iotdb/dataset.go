@@ -0,0 +1,211 @@
+package iotdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	timestampColumn = "Time"
+	nullValue       = "null"
+)
+
+// queryDataSet TSQueryDataSet：按列存储的一批结果
+type queryDataSet struct {
+	time    []byte
+	values  [][]byte
+	bitmaps [][]byte
+}
+
+func readQueryDataSet(r *thriftReader) (*queryDataSet, error) {
+	ds := &queryDataSet{}
+	err := r.readStruct(func(id int16, fieldType byte) error {
+		var err error
+		switch {
+		case id == 1 && fieldType == thriftString:
+			ds.time, err = r.readBinary()
+		case id == 2 && fieldType == thriftList:
+			ds.values, err = r.readBinaryList()
+		case id == 3 && fieldType == thriftList:
+			ds.bitmaps, err = r.readBinaryList()
+		default:
+			err = r.skip(fieldType)
+		}
+		return err
+	})
+	return ds, err
+}
+
+// dataSet 将服务端返回的列式数据转换为字符串行
+type dataSet struct {
+	columns         []string
+	ignoreTimestamp bool
+	// valueIndex 每个输出列在去重后的值列表中的位置
+	valueIndex []int
+	// types 去重后每个值列的数据类型
+	types []string
+	rows  [][]string
+}
+
+func newDataSet(columns, dataTypes []string, columnIndex map[string]int32, ignoreTimestamp bool) (*dataSet, error) {
+	if len(columns) != len(dataTypes) {
+		return nil, fmt.Errorf("列数(%d)与类型数(%d)不一致", len(columns), len(dataTypes))
+	}
+
+	ds := &dataSet{
+		columns:         columns,
+		ignoreTimestamp: ignoreTimestamp,
+		valueIndex:      make([]int, len(columns)),
+	}
+
+	// 服务端对同名列去重，columnNameIndexMap 给出列在去重后列表中的位置
+	seen := make(map[string]int, len(columns))
+	for i, name := range columns {
+		if idx, ok := seen[name]; ok {
+			ds.valueIndex[i] = idx
+			continue
+		}
+		idx := len(ds.types)
+		if columnIndex != nil {
+			pos, ok := columnIndex[name]
+			if !ok {
+				return nil, fmt.Errorf("列 %s 缺少位置信息", name)
+			}
+			idx = int(pos)
+		}
+		for len(ds.types) <= idx {
+			ds.types = append(ds.types, "")
+		}
+		ds.types[idx] = dataTypes[i]
+		seen[name] = idx
+		ds.valueIndex[i] = idx
+	}
+	return ds, nil
+}
+
+// append 解码一批数据
+func (ds *dataSet) append(batch *queryDataSet) error {
+	if len(batch.time)%8 != 0 {
+		return fmt.Errorf("时间列长度非法: %d", len(batch.time))
+	}
+	rowCount := len(batch.time) / 8
+	if len(batch.values) < len(ds.types) || len(batch.bitmaps) < len(ds.types) {
+		return fmt.Errorf("值列数量不足: %d/%d", len(batch.values), len(ds.types))
+	}
+
+	// 按列解码后再转置为行
+	columns := make([][]string, len(ds.types))
+	for col, dataType := range ds.types {
+		values, err := decodeColumn(dataType, batch.values[col], batch.bitmaps[col], rowCount)
+		if err != nil {
+			return fmt.Errorf("解码第 %d 列失败: %w", col, err)
+		}
+		columns[col] = values
+	}
+
+	for row := 0; row < rowCount; row++ {
+		values := make([]string, 0, len(ds.columns)+1)
+		if !ds.ignoreTimestamp {
+			ts := int64(binary.BigEndian.Uint64(batch.time[row*8:]))
+			values = append(values, strconv.FormatInt(ts, 10))
+		}
+		for _, idx := range ds.valueIndex {
+			values = append(values, columns[idx][row])
+		}
+		ds.rows = append(ds.rows, values)
+	}
+	return nil
+}
+
+func (ds *dataSet) result() *Result {
+	columns := ds.columns
+	if !ds.ignoreTimestamp {
+		columns = append([]string{timestampColumn}, columns...)
+	}
+	return &Result{Columns: columns, Rows: ds.rows}
+}
+
+// decodeColumn 按数据类型解码一列，空值以 "null" 表示（与 start-cli 输出一致）
+func decodeColumn(dataType string, data, bitmap []byte, rowCount int) ([]string, error) {
+	values := make([]string, rowCount)
+	pos := 0
+	next := func(n int) ([]byte, error) {
+		if pos+n > len(data) {
+			return nil, fmt.Errorf("数据长度不足")
+		}
+		b := data[pos : pos+n]
+		pos += n
+		return b, nil
+	}
+
+	for row := 0; row < rowCount; row++ {
+		if row/8 >= len(bitmap) || bitmap[row/8]&(0x80>>(row%8)) == 0 {
+			values[row] = nullValue
+			continue
+		}
+
+		switch dataType {
+		case "BOOLEAN":
+			b, err := next(1)
+			if err != nil {
+				return nil, err
+			}
+			values[row] = strconv.FormatBool(b[0] != 0)
+		case "INT32":
+			b, err := next(4)
+			if err != nil {
+				return nil, err
+			}
+			values[row] = strconv.FormatInt(int64(int32(binary.BigEndian.Uint32(b))), 10)
+		case "DATE":
+			b, err := next(4)
+			if err != nil {
+				return nil, err
+			}
+			// DATE 以 yyyyMMdd 整数存储
+			v := int(int32(binary.BigEndian.Uint32(b)))
+			values[row] = time.Date(v/10000, time.Month(v/100%100), v%100, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+		case "INT64", "TIMESTAMP":
+			b, err := next(8)
+			if err != nil {
+				return nil, err
+			}
+			values[row] = strconv.FormatInt(int64(binary.BigEndian.Uint64(b)), 10)
+		case "FLOAT":
+			b, err := next(4)
+			if err != nil {
+				return nil, err
+			}
+			values[row] = strconv.FormatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(b))), 'f', -1, 32)
+		case "DOUBLE":
+			b, err := next(8)
+			if err != nil {
+				return nil, err
+			}
+			values[row] = strconv.FormatFloat(math.Float64frombits(binary.BigEndian.Uint64(b)), 'f', -1, 64)
+		case "TEXT", "STRING", "BLOB":
+			b, err := next(4)
+			if err != nil {
+				return nil, err
+			}
+			size := int(int32(binary.BigEndian.Uint32(b)))
+			if size < 0 {
+				return nil, fmt.Errorf("文本长度非法: %d", size)
+			}
+			if b, err = next(size); err != nil {
+				return nil, err
+			}
+			if dataType == "BLOB" {
+				values[row] = fmt.Sprintf("0x%x", b)
+			} else {
+				values[row] = string(b)
+			}
+		default:
+			return nil, fmt.Errorf("不支持的数据类型: %s", dataType)
+		}
+	}
+	return values, nil
+}
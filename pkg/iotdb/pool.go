@@ -0,0 +1,103 @@
+package iotdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Pool 会话池，按需建立会话，最多同时持有 size 个
+type Pool struct {
+	opts Options
+	sem  chan struct{}
+
+	mu     sync.Mutex
+	idle   []*Session
+	closed bool
+}
+
+// NewPool 创建会话池
+func NewPool(opts Options, size int) *Pool {
+	if size <= 0 {
+		size = 1
+	}
+	return &Pool{
+		opts: opts,
+		sem:  make(chan struct{}, size),
+	}
+}
+
+// Execute 从池中取出会话执行 SQL，会话失效时自动丢弃
+func (p *Pool) Execute(ctx context.Context, sql string) (*Result, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-p.sem }()
+
+	s, reused, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.Execute(ctx, sql)
+	// 空闲会话的连接可能已被服务端关闭（如 Pod 重启），换新会话重试一次
+	if err != nil && reused && s.isBroken() && ctx.Err() == nil {
+		s.Close()
+		if s, err = Open(ctx, p.opts); err != nil {
+			return nil, err
+		}
+		result, err = s.Execute(ctx, sql)
+	}
+	p.put(s)
+	return result, err
+}
+
+func (p *Pool) get(ctx context.Context) (*Session, bool, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, false, fmt.Errorf("IoTDB 会话池已关闭")
+	}
+	if n := len(p.idle); n > 0 {
+		s := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return s, true, nil
+	}
+	p.mu.Unlock()
+
+	s, err := Open(ctx, p.opts)
+	return s, false, err
+}
+
+func (p *Pool) put(s *Session) {
+	broken := s.isBroken()
+
+	p.mu.Lock()
+	if broken || p.closed {
+		p.mu.Unlock()
+		s.Close()
+		return
+	}
+	p.idle = append(p.idle, s)
+	p.mu.Unlock()
+}
+
+// Close 关闭池中所有空闲会话
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	var firstErr error
+	for _, s := range idle {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,401 @@
+package iotdb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// protocolVersionV3 对应 TSProtocolVersion.IOTDB_SERVICE_PROTOCOL_V3
+	protocolVersionV3 int32 = 2
+	defaultFetchSize  int32 = 1024
+	defaultZoneID           = "UTC"
+)
+
+// Options 会话连接参数
+type Options struct {
+	Host           string
+	Port           int
+	Username       string
+	Password       string
+	ConnectTimeout time.Duration
+}
+
+// Result SQL 执行结果，非查询语句的 Columns 和 Rows 为空
+type Result struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// Session 单个 IoTDB 会话，同一时间只能执行一条语句
+type Session struct {
+	mu          sync.Mutex
+	conn        net.Conn
+	seqID       int32
+	sessionID   int64
+	statementID int64
+	broken      bool
+}
+
+// Open 建立连接并打开会话
+func Open(ctx context.Context, opts Options) (*Session, error) {
+	timeout := opts.ConnectTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("连接 IoTDB %s 失败: %w", addr, err)
+	}
+
+	s := &Session{conn: conn}
+	if err := s.openSession(ctx, opts); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Session) openSession(ctx context.Context, opts Options) error {
+	w := &thriftWriter{}
+	w.writeFieldBegin(thriftI32, 1)
+	w.writeI32(protocolVersionV3)
+	w.writeFieldBegin(thriftString, 2)
+	w.writeString(defaultZoneID)
+	w.writeFieldBegin(thriftString, 3)
+	w.writeString(opts.Username)
+	w.writeFieldBegin(thriftString, 4)
+	w.writeString(opts.Password)
+	w.writeFieldBegin(thriftMap, 5)
+	w.writeMapBegin(thriftString, thriftString, 1)
+	w.writeString("version")
+	w.writeString("V_1_0")
+	w.writeFieldStop()
+
+	var status *StatusError
+	err := s.call(ctx, "openSession", requestArgs(w), func(r *thriftReader) error {
+		return r.readStruct(func(id int16, fieldType byte) error {
+			var err error
+			switch {
+			case id == 1 && fieldType == thriftStruct:
+				status, err = readStatus(r)
+			case id == 3 && fieldType == thriftI64:
+				s.sessionID, err = r.readI64()
+			default:
+				err = r.skip(fieldType)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("打开 IoTDB 会话失败: %w", err)
+	}
+	if status != nil {
+		return fmt.Errorf("打开 IoTDB 会话失败: %w", status)
+	}
+
+	if err := s.requestStatementID(ctx); err != nil {
+		return fmt.Errorf("申请语句 ID 失败: %w", err)
+	}
+	return nil
+}
+
+func (s *Session) requestStatementID(ctx context.Context) error {
+	w := &thriftWriter{}
+	w.writeFieldBegin(thriftI64, 1)
+	w.writeI64(s.sessionID)
+
+	return s.call(ctx, "requestStatementId", w, func(r *thriftReader) error {
+		return r.readI64Result(&s.statementID)
+	})
+}
+
+// Execute 执行 SQL 语句，查询语句返回全部结果行
+func (s *Session) Execute(ctx context.Context, sql string) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.broken {
+		return nil, fmt.Errorf("IoTDB 会话已失效")
+	}
+
+	w := &thriftWriter{}
+	w.writeFieldBegin(thriftI64, 1)
+	w.writeI64(s.sessionID)
+	w.writeFieldBegin(thriftString, 2)
+	w.writeString(sql)
+	w.writeFieldBegin(thriftI64, 3)
+	w.writeI64(s.statementID)
+	w.writeFieldBegin(thriftI32, 4)
+	w.writeI32(defaultFetchSize)
+	w.writeFieldBegin(thriftBool, 6)
+	w.writeBool(false)
+	w.writeFieldBegin(thriftBool, 7)
+	w.writeBool(true)
+	w.writeFieldStop()
+
+	var resp executeResponse
+	if err := s.call(ctx, "executeStatement", requestArgs(w), resp.read); err != nil {
+		return nil, err
+	}
+	if resp.status != nil {
+		return nil, resp.status
+	}
+	if !resp.hasQueryID {
+		return &Result{}, nil
+	}
+	defer s.closeOperation(ctx, resp.queryID)
+
+	ds, err := newDataSet(resp.columns, resp.dataTypes, resp.columnIndex, resp.ignoreTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	data, moreData := resp.data, resp.moreData
+	for {
+		if data != nil {
+			if err := ds.append(data); err != nil {
+				return nil, err
+			}
+		}
+		if !moreData {
+			break
+		}
+		if data, moreData, err = s.fetchResults(ctx, sql, resp.queryID); err != nil {
+			return nil, err
+		}
+		if data == nil {
+			break
+		}
+	}
+	return ds.result(), nil
+}
+
+func (s *Session) fetchResults(ctx context.Context, sql string, queryID int64) (*queryDataSet, bool, error) {
+	w := &thriftWriter{}
+	w.writeFieldBegin(thriftI64, 1)
+	w.writeI64(s.sessionID)
+	w.writeFieldBegin(thriftString, 2)
+	w.writeString(sql)
+	w.writeFieldBegin(thriftI32, 3)
+	w.writeI32(defaultFetchSize)
+	w.writeFieldBegin(thriftI64, 4)
+	w.writeI64(queryID)
+	w.writeFieldBegin(thriftBool, 5)
+	w.writeBool(true)
+	w.writeFieldStop()
+
+	var (
+		status    *StatusError
+		hasResult bool
+		moreData  bool
+		data      *queryDataSet
+	)
+	err := s.call(ctx, "fetchResults", requestArgs(w), func(r *thriftReader) error {
+		return r.readStruct(func(id int16, fieldType byte) error {
+			var err error
+			switch {
+			case id == 1 && fieldType == thriftStruct:
+				status, err = readStatus(r)
+			case id == 2 && fieldType == thriftBool:
+				hasResult, err = r.readBool()
+			case id == 4 && fieldType == thriftStruct:
+				data, err = readQueryDataSet(r)
+			case id == 7 && fieldType == thriftBool:
+				moreData, err = r.readBool()
+			default:
+				err = r.skip(fieldType)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if status != nil {
+		return nil, false, status
+	}
+	if !hasResult {
+		return nil, false, nil
+	}
+	return data, moreData, nil
+}
+
+func (s *Session) closeOperation(ctx context.Context, queryID int64) {
+	w := &thriftWriter{}
+	w.writeFieldBegin(thriftI64, 1)
+	w.writeI64(s.sessionID)
+	w.writeFieldBegin(thriftI64, 2)
+	w.writeI64(queryID)
+	w.writeFieldBegin(thriftI64, 3)
+	w.writeI64(s.statementID)
+	w.writeFieldStop()
+
+	_ = s.call(ctx, "closeOperation", requestArgs(w), func(r *thriftReader) error {
+		return r.skip(thriftStruct)
+	})
+}
+
+func (s *Session) isBroken() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.broken
+}
+
+// Close 关闭会话和连接
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.broken {
+		w := &thriftWriter{}
+		w.writeFieldBegin(thriftI64, 1)
+		w.writeI64(s.sessionID)
+		w.writeFieldStop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = s.call(ctx, "closeSession", requestArgs(w), func(r *thriftReader) error {
+			return r.skip(thriftStruct)
+		})
+		cancel()
+	}
+	s.broken = true
+	return s.conn.Close()
+}
+
+// call 发送一次 RPC 请求并读取应答。args 为参数结构体的字段（不含结束标记），
+// result 读取应答结构体中的返回值（第 0 个字段）。
+func (s *Session) call(ctx context.Context, method string, args *thriftWriter, result func(r *thriftReader) error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetDeadline(deadline)
+	} else {
+		s.conn.SetDeadline(time.Time{})
+	}
+	stop := context.AfterFunc(ctx, func() {
+		s.conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	s.seqID++
+	w := &thriftWriter{}
+	w.writeMessageBegin(method, messageCall, s.seqID)
+	w.buf.Write(args.buf.Bytes())
+	w.writeFieldStop()
+
+	if err := w.writeFrame(s.conn); err != nil {
+		s.broken = true
+		return s.wrapIOError(ctx, method, err)
+	}
+
+	r, err := readFrame(s.conn)
+	if err != nil {
+		s.broken = true
+		return s.wrapIOError(ctx, method, err)
+	}
+
+	name, messageType, seqID, err := r.readMessageBegin()
+	if err != nil {
+		s.broken = true
+		return err
+	}
+	if messageType == messageException {
+		return r.readApplicationException()
+	}
+	if messageType != messageReply || name != method || seqID != s.seqID {
+		s.broken = true
+		return fmt.Errorf("收到非预期的 thrift 应答: %s(type=%d, seq=%d)", name, messageType, seqID)
+	}
+
+	return r.readStruct(func(id int16, fieldType byte) error {
+		if id == 0 {
+			return result(r)
+		}
+		return r.skip(fieldType)
+	})
+}
+
+func (s *Session) wrapIOError(ctx context.Context, method string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("调用 %s 中断: %w", method, ctxErr)
+	}
+	return fmt.Errorf("调用 %s 失败: %w", method, err)
+}
+
+// requestArgs 将请求结构体包装为参数的第 1 个字段（1: req）
+func requestArgs(req *thriftWriter) *thriftWriter {
+	w := &thriftWriter{}
+	w.writeFieldBegin(thriftStruct, 1)
+	w.buf.Write(req.buf.Bytes())
+	return w
+}
+
+// readI64Result 读取 i64 类型的返回值
+func (r *thriftReader) readI64Result(v *int64) error {
+	var err error
+	*v, err = r.readI64()
+	return err
+}
+
+// executeResponse TSExecuteStatementResp 中用到的字段
+type executeResponse struct {
+	status          *StatusError
+	hasQueryID      bool
+	queryID         int64
+	columns         []string
+	dataTypes       []string
+	columnIndex     map[string]int32
+	ignoreTimestamp bool
+	data            *queryDataSet
+	moreData        bool
+}
+
+func (resp *executeResponse) read(r *thriftReader) error {
+	return r.readStruct(func(id int16, fieldType byte) error {
+		var err error
+		switch {
+		case id == 1 && fieldType == thriftStruct:
+			resp.status, err = readStatus(r)
+		case id == 2 && fieldType == thriftI64:
+			resp.hasQueryID = true
+			resp.queryID, err = r.readI64()
+		case id == 3 && fieldType == thriftList:
+			resp.columns, err = r.readStringList()
+		case id == 5 && fieldType == thriftBool:
+			resp.ignoreTimestamp, err = r.readBool()
+		case id == 6 && fieldType == thriftList:
+			resp.dataTypes, err = r.readStringList()
+		case id == 7 && fieldType == thriftStruct:
+			resp.data, err = readQueryDataSet(r)
+		case id == 9 && fieldType == thriftMap:
+			resp.columnIndex, err = readColumnIndex(r)
+		case id == 14 && fieldType == thriftBool:
+			resp.moreData, err = r.readBool()
+		default:
+			err = r.skip(fieldType)
+		}
+		return err
+	})
+}
+
+func readColumnIndex(r *thriftReader) (map[string]int32, error) {
+	_, _, size, err := r.readMapBegin()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int32, size)
+	for i := 0; i < size; i++ {
+		name, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		if index[name], err = r.readI32(); err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
@@ -0,0 +1,238 @@
+package iotdb
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"testing"
+)
+
+// fakeServer 以 thrift 协议模拟 IoTDB 会话服务
+type fakeServer struct {
+	listener net.Listener
+	calls    chan string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &fakeServer{listener: listener, calls: make(chan string, 64)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (srv *fakeServer) options() Options {
+	addr := srv.listener.Addr().(*net.TCPAddr)
+	return Options{Host: addr.IP.String(), Port: addr.Port, Username: "root", Password: "root"}
+}
+
+func (srv *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		r, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		method, _, seqID, err := r.readMessageBegin()
+		if err != nil {
+			return
+		}
+		statement := readStatement(r)
+		srv.calls <- method
+
+		w := &thriftWriter{}
+		w.writeMessageBegin(method, messageReply, seqID)
+		switch method {
+		case "openSession":
+			w.writeFieldBegin(thriftStruct, 0)
+			writeTestStatus(w, 1, CodeSuccess, "")
+			w.writeFieldBegin(thriftI64, 3)
+			w.writeI64(42)
+			w.writeFieldStop()
+		case "requestStatementId":
+			w.writeFieldBegin(thriftI64, 0)
+			w.writeI64(7)
+		case "executeStatement":
+			w.writeFieldBegin(thriftStruct, 0)
+			writeExecuteResponse(w, statement)
+		default:
+			w.writeFieldBegin(thriftStruct, 0)
+			w.writeFieldBegin(thriftI32, 1)
+			w.writeI32(CodeSuccess)
+			w.writeFieldStop()
+		}
+		w.writeFieldStop()
+		if err := w.writeFrame(conn); err != nil {
+			return
+		}
+	}
+}
+
+// readStatement 从 executeStatement 参数中读取 SQL，其他方法返回空串
+func readStatement(r *thriftReader) string {
+	var statement string
+	r.readStruct(func(id int16, fieldType byte) error {
+		if fieldType != thriftStruct {
+			return r.skip(fieldType)
+		}
+		return r.readStruct(func(id int16, fieldType byte) error {
+			if id == 2 && fieldType == thriftString {
+				var err error
+				statement, err = r.readString()
+				return err
+			}
+			return r.skip(fieldType)
+		})
+	})
+	return statement
+}
+
+func writeTestStatus(w *thriftWriter, id int16, code int32, message string) {
+	w.writeFieldBegin(thriftStruct, id)
+	w.writeFieldBegin(thriftI32, 1)
+	w.writeI32(code)
+	if message != "" {
+		w.writeFieldBegin(thriftString, 2)
+		w.writeString(message)
+	}
+	w.writeFieldStop()
+}
+
+func writeExecuteResponse(w *thriftWriter, statement string) {
+	switch statement {
+	case "show databases":
+		writeTestStatus(w, 1, CodeSuccess, "")
+		w.writeFieldBegin(thriftI64, 2)
+		w.writeI64(1)
+		w.writeFieldBegin(thriftList, 3)
+		w.writeListBegin(thriftString, 2)
+		w.writeString("Database")
+		w.writeString("TTL(ms)")
+		w.writeFieldBegin(thriftBool, 5)
+		w.writeBool(true)
+		w.writeFieldBegin(thriftList, 6)
+		w.writeListBegin(thriftString, 2)
+		w.writeString("TEXT")
+		w.writeString("INT64")
+
+		// 两行：root.energy/null, root.emsplus/86400000
+		var text, ttl []byte
+		for _, db := range []string{"root.energy", "root.emsplus"} {
+			text = binary.BigEndian.AppendUint32(text, uint32(len(db)))
+			text = append(text, db...)
+		}
+		ttl = binary.BigEndian.AppendUint64(ttl, 86400000)
+
+		w.writeFieldBegin(thriftStruct, 7)
+		w.writeFieldBegin(thriftString, 1)
+		w.writeBinary(make([]byte, 16))
+		w.writeFieldBegin(thriftList, 2)
+		w.writeListBegin(thriftString, 2)
+		w.writeBinary(text)
+		w.writeBinary(ttl)
+		w.writeFieldBegin(thriftList, 3)
+		w.writeListBegin(thriftString, 2)
+		w.writeBinary([]byte{0xC0})
+		w.writeBinary([]byte{0x40})
+		w.writeFieldStop()
+	case "create database root.energy":
+		writeTestStatus(w, 1, 501, "root.energy has already been created as database")
+	default:
+		writeTestStatus(w, 1, CodeSuccess, "")
+	}
+	w.writeFieldStop()
+}
+
+func TestSessionExecuteQuery(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	s, err := Open(ctx, srv.options())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	res, err := s.Execute(ctx, "show databases")
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	if len(res.Columns) != 2 || res.Columns[0] != "Database" {
+		t.Fatalf("unexpected columns: %v", res.Columns)
+	}
+	want := [][]string{{"root.energy", "null"}, {"root.emsplus", "86400000"}}
+	if len(res.Rows) != len(want) {
+		t.Fatalf("unexpected rows: %v", res.Rows)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if res.Rows[i][j] != want[i][j] {
+				t.Fatalf("row %d: expected %v, got %v", i, want[i], res.Rows[i])
+			}
+		}
+	}
+}
+
+func TestSessionExecuteStatusError(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	s, err := Open(ctx, srv.options())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	_, err = s.Execute(ctx, "create database root.energy")
+	code, ok := StatusCode(err)
+	if !ok || code != 501 {
+		t.Fatalf("expected status 501, got %v", err)
+	}
+
+	// 状态错误不影响会话继续使用
+	if _, err := s.Execute(ctx, "flush"); err != nil {
+		t.Fatalf("execute after status error: %v", err)
+	}
+}
+
+func TestPoolReusesSessions(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	pool := NewPool(srv.options(), 2)
+	for i := 0; i < 3; i++ {
+		if _, err := pool.Execute(ctx, "insert into root.energy.d(time,s) values("+strconv.Itoa(i)+",1)"); err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+	}
+	if err := pool.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	opened := 0
+	close(srv.calls)
+	for method := range srv.calls {
+		if method == "openSession" {
+			opened++
+		}
+	}
+	if opened != 1 {
+		t.Fatalf("expected 1 session, got %d", opened)
+	}
+}
@@ -0,0 +1,87 @@
+package iotdb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// IoTDB TSStatusCode 中会话客户端需要区分的状态码
+const (
+	CodeSuccess              int32 = 200
+	CodeMultipleError        int32 = 302
+	CodeRedirectionRecommend int32 = 400
+)
+
+// StatusError IoTDB 返回的非成功状态
+type StatusError struct {
+	Code    int32
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+// StatusCode 返回错误链中的 IoTDB 状态码
+func StatusCode(err error) (int32, bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code, true
+	}
+	return 0, false
+}
+
+// readStatus 读取 TSStatus，成功状态返回 nil
+func readStatus(r *thriftReader) (*StatusError, error) {
+	var (
+		code      int32
+		message   string
+		subStatus []*StatusError
+	)
+	err := r.readStruct(func(id int16, fieldType byte) error {
+		var err error
+		switch {
+		case id == 1 && fieldType == thriftI32:
+			code, err = r.readI32()
+		case id == 2 && fieldType == thriftString:
+			message, err = r.readString()
+		case id == 3 && fieldType == thriftList:
+			_, size, listErr := r.readListBegin()
+			if listErr != nil {
+				return listErr
+			}
+			for i := 0; i < size; i++ {
+				sub, subErr := readStatus(r)
+				if subErr != nil {
+					return subErr
+				}
+				if sub != nil {
+					subStatus = append(subStatus, sub)
+				}
+			}
+		default:
+			err = r.skip(fieldType)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch code {
+	case CodeSuccess, CodeRedirectionRecommend:
+		return nil, nil
+	case CodeMultipleError:
+		if len(subStatus) == 0 {
+			break
+		}
+		messages := make([]string, 0, len(subStatus))
+		for _, sub := range subStatus {
+			messages = append(messages, sub.Error())
+		}
+		// 多条错误时以第一条子状态码为准，便于调用方按状态码判断
+		return &StatusError{Code: subStatus[0].Code, Message: strings.Join(messages, "; ")}, nil
+	}
+	return &StatusError{Code: code, Message: message}, nil
+}
@@ -0,0 +1,350 @@
+package iotdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Thrift 二进制协议的最小实现，仅覆盖会话协议需要的类型。
+// IoTDB 默认使用 TFramedTransport + TBinaryProtocol（rpc_thrift_compression_enable=false）。
+
+const (
+	thriftStop   byte = 0
+	thriftBool   byte = 2
+	thriftByte   byte = 3
+	thriftDouble byte = 4
+	thriftI16    byte = 6
+	thriftI32    byte = 8
+	thriftI64    byte = 10
+	thriftString byte = 11
+	thriftStruct byte = 12
+	thriftMap    byte = 13
+	thriftSet    byte = 14
+	thriftList   byte = 15
+
+	messageCall      byte = 1
+	messageReply     byte = 2
+	messageException byte = 3
+
+	thriftVersion1    uint32 = 0x80010000
+	thriftVersionMask uint32 = 0xffff0000
+
+	maxFrameSize = 256 * 1024 * 1024
+)
+
+// thriftWriter 写入 Thrift 二进制协议数据
+type thriftWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *thriftWriter) writeMessageBegin(name string, messageType byte, seqID int32) {
+	w.writeI32(int32(thriftVersion1 | uint32(messageType)))
+	w.writeString(name)
+	w.writeI32(seqID)
+}
+
+func (w *thriftWriter) writeFieldBegin(fieldType byte, id int16) {
+	w.buf.WriteByte(fieldType)
+	w.writeI16(id)
+}
+
+func (w *thriftWriter) writeFieldStop() {
+	w.buf.WriteByte(thriftStop)
+}
+
+func (w *thriftWriter) writeBool(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+func (w *thriftWriter) writeI16(v int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	w.buf.Write(b[:])
+}
+
+func (w *thriftWriter) writeI32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	w.buf.Write(b[:])
+}
+
+func (w *thriftWriter) writeI64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	w.buf.Write(b[:])
+}
+
+func (w *thriftWriter) writeBinary(v []byte) {
+	w.writeI32(int32(len(v)))
+	w.buf.Write(v)
+}
+
+func (w *thriftWriter) writeString(v string) {
+	w.writeI32(int32(len(v)))
+	w.buf.WriteString(v)
+}
+
+func (w *thriftWriter) writeListBegin(elemType byte, size int) {
+	w.buf.WriteByte(elemType)
+	w.writeI32(int32(size))
+}
+
+func (w *thriftWriter) writeMapBegin(keyType, valueType byte, size int) {
+	w.buf.WriteByte(keyType)
+	w.buf.WriteByte(valueType)
+	w.writeI32(int32(size))
+}
+
+// writeFrame 以 TFramedTransport 格式写出缓冲区内容
+func (w *thriftWriter) writeFrame(out io.Writer) error {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(w.buf.Len()))
+	if _, err := out.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := out.Write(w.buf.Bytes())
+	return err
+}
+
+// thriftReader 读取 Thrift 二进制协议数据
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+// readFrame 读取一个 TFramedTransport 帧
+func readFrame(in io.Reader) (*thriftReader, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(in, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("thrift 帧过大: %d", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(in, data); err != nil {
+		return nil, err
+	}
+	return &thriftReader{data: data}, nil
+}
+
+func (r *thriftReader) next(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *thriftReader) readByte() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *thriftReader) readBool() (bool, error) {
+	b, err := r.readByte()
+	return b != 0, err
+}
+
+func (r *thriftReader) readI16() (int16, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+func (r *thriftReader) readI32() (int32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (r *thriftReader) readI64() (int64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+func (r *thriftReader) readBinary() ([]byte, error) {
+	size, err := r.readI32()
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.next(int(size))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(b))
+	copy(out, b)
+	return out, nil
+}
+
+func (r *thriftReader) readString() (string, error) {
+	b, err := r.readBinary()
+	return string(b), err
+}
+
+func (r *thriftReader) readMessageBegin() (name string, messageType byte, seqID int32, err error) {
+	header, err := r.readI32()
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if uint32(header)&thriftVersionMask != thriftVersion1 {
+		return "", 0, 0, fmt.Errorf("不支持的 thrift 协议版本: %#x", uint32(header))
+	}
+	messageType = byte(uint32(header) & 0xff)
+	if name, err = r.readString(); err != nil {
+		return "", 0, 0, err
+	}
+	seqID, err = r.readI32()
+	return name, messageType, seqID, err
+}
+
+func (r *thriftReader) readListBegin() (elemType byte, size int, err error) {
+	if elemType, err = r.readByte(); err != nil {
+		return 0, 0, err
+	}
+	n, err := r.readI32()
+	return elemType, int(n), err
+}
+
+func (r *thriftReader) readMapBegin() (keyType, valueType byte, size int, err error) {
+	if keyType, err = r.readByte(); err != nil {
+		return 0, 0, 0, err
+	}
+	if valueType, err = r.readByte(); err != nil {
+		return 0, 0, 0, err
+	}
+	n, err := r.readI32()
+	return keyType, valueType, int(n), err
+}
+
+// readStruct 依次回调结构体中的字段，未处理的字段需由回调调用 skip
+func (r *thriftReader) readStruct(field func(id int16, fieldType byte) error) error {
+	for {
+		fieldType, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		if fieldType == thriftStop {
+			return nil
+		}
+		id, err := r.readI16()
+		if err != nil {
+			return err
+		}
+		if err := field(id, fieldType); err != nil {
+			return err
+		}
+	}
+}
+
+// skip 跳过指定类型的值
+func (r *thriftReader) skip(fieldType byte) error {
+	var err error
+	switch fieldType {
+	case thriftBool, thriftByte:
+		_, err = r.next(1)
+	case thriftI16:
+		_, err = r.next(2)
+	case thriftI32:
+		_, err = r.next(4)
+	case thriftI64, thriftDouble:
+		_, err = r.next(8)
+	case thriftString:
+		_, err = r.readBinary()
+	case thriftStruct:
+		err = r.readStruct(func(_ int16, t byte) error { return r.skip(t) })
+	case thriftMap:
+		keyType, valueType, size, mapErr := r.readMapBegin()
+		if mapErr != nil {
+			return mapErr
+		}
+		for i := 0; i < size && err == nil; i++ {
+			if err = r.skip(keyType); err == nil {
+				err = r.skip(valueType)
+			}
+		}
+	case thriftSet, thriftList:
+		elemType, size, listErr := r.readListBegin()
+		if listErr != nil {
+			return listErr
+		}
+		for i := 0; i < size && err == nil; i++ {
+			err = r.skip(elemType)
+		}
+	default:
+		err = fmt.Errorf("未知的 thrift 类型: %d", fieldType)
+	}
+	return err
+}
+
+func (r *thriftReader) readStringList() ([]string, error) {
+	_, size, err := r.readListBegin()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, size)
+	for i := 0; i < size; i++ {
+		v, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (r *thriftReader) readBinaryList() ([][]byte, error) {
+	_, size, err := r.readListBegin()
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, 0, size)
+	for i := 0; i < size; i++ {
+		v, err := r.readBinary()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// readApplicationException 读取 TApplicationException
+func (r *thriftReader) readApplicationException() error {
+	var message string
+	var exceptionType int32
+	err := r.readStruct(func(id int16, fieldType byte) error {
+		var err error
+		switch {
+		case id == 1 && fieldType == thriftString:
+			message, err = r.readString()
+		case id == 2 && fieldType == thriftI32:
+			exceptionType, err = r.readI32()
+		default:
+			err = r.skip(fieldType)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("thrift 服务端异常(type=%d): %s", exceptionType, message)
+}
@@ -0,0 +1,234 @@
+package journal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/selector"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrNotFound 运行日志不存在
+var ErrNotFound = errors.New("运行日志不存在")
+
+const (
+	// saveEveryFiles 每记录这么多个已导入文件保存一次运行日志
+	saveEveryFiles = 50
+	// saveInterval 距上次保存超过该时间时，记录已导入文件后立即保存
+	saveInterval = 10 * time.Second
+)
+
+// Journal 一次恢复运行的检查点，记录已完成阶段和已导入文件
+type Journal struct {
+	RunID           string           `json:"run_id"`
+	Timestamp       string           `json:"timestamp"`
+	SourceType      string           `json:"source_type"`
+	Namespace       string           `json:"namespace"`
+	PodName         string           `json:"pod_name"`
+	BackupFiles     []string         `json:"backup_files,omitempty"`
+	Filter          *selector.Filter `json:"filter,omitempty"` // 选择性恢复条件，续传时沿用
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	CompletedPhases []string         `json:"completed_phases"`
+	ImportedFiles   []string         `json:"imported_files"`
+	Finished        bool             `json:"finished"`
+}
+
+// Store 运行日志存储接口
+type Store interface {
+	Load(ctx context.Context, runID string) (*Journal, error)
+	Save(ctx context.Context, j *Journal) error
+}
+
+// NewStore 根据配置创建运行日志存储
+func NewStore(cfg *config.Config, clientset kubernetes.Interface) (Store, error) {
+	switch cfg.Journal.Backend {
+	case "", "file":
+		return NewFileStore(cfg.Journal.Dir), nil
+	case "configmap":
+		if clientset == nil {
+			return nil, fmt.Errorf("configmap 运行日志需要 Kubernetes 客户端")
+		}
+		namespace := cfg.Journal.Namespace
+		if namespace == "" {
+			namespace = cfg.Kubernetes.Namespace
+		}
+		return NewConfigMapStore(clientset, namespace), nil
+	default:
+		return nil, fmt.Errorf("未知的运行日志后端: %s", cfg.Journal.Backend)
+	}
+}
+
+// NewRunID 生成运行 ID（同时满足文件名和 ConfigMap 名称规则）
+func NewRunID(now time.Time) string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return now.Format("20060102-150405")
+	}
+	return fmt.Sprintf("%s-%s", now.Format("20060102-150405"), hex.EncodeToString(suffix))
+}
+
+// Recorder 在恢复过程中更新并持久化运行日志，方法并发安全。
+// 已导入文件按批保存（每 saveEveryFiles 个文件或每 saveInterval），阶段完成、Save 和 Finish 时保存全部记录。
+// nil Recorder 的所有方法均为空操作。
+type Recorder struct {
+	mu       sync.Mutex
+	store    Store
+	journal  *Journal
+	phases   map[string]bool
+	imported map[string]bool
+	// unsaved 上次保存之后新记录的已导入文件数
+	unsaved int
+	savedAt time.Time
+}
+
+// NewRecorder 为运行日志创建记录器，store 为 nil 时仅在内存中记录
+func NewRecorder(store Store, j *Journal) *Recorder {
+	rec := &Recorder{
+		store:    store,
+		journal:  j,
+		phases:   make(map[string]bool, len(j.CompletedPhases)),
+		imported: make(map[string]bool, len(j.ImportedFiles)),
+	}
+	for _, phase := range j.CompletedPhases {
+		rec.phases[phase] = true
+	}
+	for _, file := range j.ImportedFiles {
+		rec.imported[file] = true
+	}
+	return rec
+}
+
+// RunID 返回运行 ID
+func (r *Recorder) RunID() string {
+	if r == nil {
+		return ""
+	}
+	return r.journal.RunID
+}
+
+// Persistent 运行日志是否会持久化
+func (r *Recorder) Persistent() bool {
+	return r != nil && r.store != nil
+}
+
+// PhaseDone 阶段是否已完成
+func (r *Recorder) PhaseDone(phase string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.phases[phase]
+}
+
+// MarkPhase 记录阶段完成
+func (r *Recorder) MarkPhase(ctx context.Context, phase string) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.phases[phase] {
+		return nil
+	}
+	r.phases[phase] = true
+	r.journal.CompletedPhases = append(r.journal.CompletedPhases, phase)
+	return r.saveLocked(ctx)
+}
+
+// BackupFiles 返回已定位的备份文件（分卷备份为全部分卷），尚未定位时为空
+func (r *Recorder) BackupFiles() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.journal.BackupFiles
+}
+
+// SetBackupFiles 记录定位到的备份文件，续传时直接使用，不再重新定位
+func (r *Recorder) SetBackupFiles(ctx context.Context, files []string) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.journal.BackupFiles = files
+	return r.saveLocked(ctx)
+}
+
+// FileImported 文件是否已在之前的运行中导入
+func (r *Recorder) FileImported(file string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.imported[file]
+}
+
+// MarkFileImported 记录文件已导入。记录按批保存，导入结束时须调用 Save 保存剩余的记录
+func (r *Recorder) MarkFileImported(ctx context.Context, file string) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.imported[file] {
+		return nil
+	}
+	r.imported[file] = true
+	r.journal.ImportedFiles = append(r.journal.ImportedFiles, file)
+	r.unsaved++
+	if r.unsaved < saveEveryFiles && time.Since(r.savedAt) < saveInterval {
+		return nil
+	}
+	return r.saveLocked(ctx)
+}
+
+// Finish 标记运行完成
+func (r *Recorder) Finish(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.journal.Finished = true
+	return r.saveLocked(ctx)
+}
+
+// Save 立即持久化运行日志
+func (r *Recorder) Save(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.saveLocked(ctx)
+}
+
+func (r *Recorder) saveLocked(ctx context.Context) error {
+	now := time.Now()
+	r.journal.UpdatedAt = now
+	if r.store != nil {
+		sort.Strings(r.journal.ImportedFiles)
+		if err := r.store.Save(ctx, r.journal); err != nil {
+			return err
+		}
+	}
+	r.unsaved = 0
+	r.savedAt = now
+	return nil
+}
@@ -0,0 +1,126 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir())
+
+	j := &Journal{
+		RunID:     "20260323-103502-abcdef",
+		Timestamp: "20260323103502",
+		PodName:   "iotdb-datanode-0",
+		CreatedAt: time.Now(),
+	}
+	rec := NewRecorder(store, j)
+	if err := rec.MarkPhase(ctx, "delete"); err != nil {
+		t.Fatalf("mark phase: %v", err)
+	}
+	if err := rec.MarkFileImported(ctx, "/data/b.tsfile"); err != nil {
+		t.Fatalf("mark file: %v", err)
+	}
+	if err := rec.MarkFileImported(ctx, "/data/a.tsfile"); err != nil {
+		t.Fatalf("mark file: %v", err)
+	}
+	if err := rec.Save(ctx); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	loaded, err := store.Load(ctx, j.RunID)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if loaded.Timestamp != "20260323103502" {
+		t.Fatalf("unexpected timestamp: %q", loaded.Timestamp)
+	}
+	if len(loaded.CompletedPhases) != 1 || loaded.CompletedPhases[0] != "delete" {
+		t.Fatalf("unexpected phases: %v", loaded.CompletedPhases)
+	}
+	if len(loaded.ImportedFiles) != 2 || loaded.ImportedFiles[0] != "/data/a.tsfile" {
+		t.Fatalf("unexpected imported files: %v", loaded.ImportedFiles)
+	}
+
+	resumed := NewRecorder(store, loaded)
+	if !resumed.PhaseDone("delete") || resumed.PhaseDone("restart") {
+		t.Fatalf("unexpected phase state after resume")
+	}
+	if !resumed.FileImported("/data/b.tsfile") || resumed.FileImported("/data/c.tsfile") {
+		t.Fatalf("unexpected file state after resume")
+	}
+}
+
+// countingStore 记录保存次数和最近一次保存的已导入文件数
+type countingStore struct {
+	saves    int
+	imported int
+}
+
+func (s *countingStore) Load(ctx context.Context, runID string) (*Journal, error) {
+	return nil, ErrNotFound
+}
+
+func (s *countingStore) Save(ctx context.Context, j *Journal) error {
+	s.saves++
+	s.imported = len(j.ImportedFiles)
+	return nil
+}
+
+func TestRecorderBatchesImportedFiles(t *testing.T) {
+	ctx := context.Background()
+	store := &countingStore{}
+	rec := NewRecorder(store, &Journal{RunID: "20260323-103502-abcdef"})
+	if err := rec.Save(ctx); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	for i := 0; i < saveEveryFiles+1; i++ {
+		if err := rec.MarkFileImported(ctx, fmt.Sprintf("/data/%d.tsfile", i)); err != nil {
+			t.Fatalf("mark file: %v", err)
+		}
+	}
+	if store.saves != 2 || store.imported != saveEveryFiles {
+		t.Fatalf("expected one batch save of %d files, got %d saves with %d files", saveEveryFiles, store.saves, store.imported)
+	}
+
+	// 距上次保存超过 saveInterval 时不等批次满
+	rec.savedAt = time.Now().Add(-saveInterval)
+	if err := rec.MarkFileImported(ctx, "/data/late.tsfile"); err != nil {
+		t.Fatalf("mark file: %v", err)
+	}
+	if store.saves != 3 || store.imported != saveEveryFiles+2 {
+		t.Fatalf("expected save after interval, got %d saves with %d files", store.saves, store.imported)
+	}
+
+	if err := rec.MarkFileImported(ctx, "/data/last.tsfile"); err != nil {
+		t.Fatalf("mark file: %v", err)
+	}
+	if err := rec.MarkPhase(ctx, "import"); err != nil {
+		t.Fatalf("mark phase: %v", err)
+	}
+	if store.saves != 4 || store.imported != saveEveryFiles+3 {
+		t.Fatalf("expected phase end to save remaining files, got %d saves with %d files", store.saves, store.imported)
+	}
+}
+
+func TestFileStoreLoadMissing(t *testing.T) {
+	_, err := NewFileStore(t.TempDir()).Load(context.Background(), "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNilRecorderIsNoop(t *testing.T) {
+	var rec *Recorder
+	if rec.PhaseDone("delete") || rec.FileImported("a") || rec.Persistent() {
+		t.Fatalf("nil recorder should report nothing done")
+	}
+	if err := rec.MarkPhase(context.Background(), "delete"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	configMapPrefix = "iotdb-restore-journal-"
+	configMapKey    = "journal.json"
+)
+
+// FileStore 将运行日志保存为本地 JSON 文件
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore 创建本地文件存储
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+// Load 读取运行日志
+func (s *FileStore) Load(ctx context.Context, runID string) (*Journal, error) {
+	data, err := os.ReadFile(s.path(runID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, runID)
+		}
+		return nil, fmt.Errorf("读取运行日志失败: %w", err)
+	}
+
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("解析运行日志失败: %w", err)
+	}
+	return &j, nil
+}
+
+// Save 原子写入运行日志
+func (s *FileStore) Save(ctx context.Context, j *Journal) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("创建运行日志目录失败: %w", err)
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化运行日志失败: %w", err)
+	}
+
+	target := s.path(j.RunID)
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入运行日志失败: %w", err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return fmt.Errorf("写入运行日志失败: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) path(runID string) string {
+	return filepath.Join(s.dir, runID+".json")
+}
+
+// ConfigMapStore 将运行日志保存到 ConfigMap，Job Pod 被重建后仍可续传
+type ConfigMapStore struct {
+	clientset kubernetes.Interface
+	namespace string
+}
+
+// NewConfigMapStore 创建 ConfigMap 存储
+func NewConfigMapStore(clientset kubernetes.Interface, namespace string) *ConfigMapStore {
+	return &ConfigMapStore{
+		clientset: clientset,
+		namespace: namespace,
+	}
+}
+
+// Load 读取运行日志
+func (s *ConfigMapStore) Load(ctx context.Context, runID string) (*Journal, error) {
+	cm, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Get(ctx, configMapPrefix+runID, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, runID)
+		}
+		return nil, fmt.Errorf("读取运行日志 ConfigMap 失败: %w", err)
+	}
+
+	var j Journal
+	if err := json.Unmarshal([]byte(cm.Data[configMapKey]), &j); err != nil {
+		return nil, fmt.Errorf("解析运行日志失败: %w", err)
+	}
+	return &j, nil
+}
+
+// Save 创建或更新运行日志 ConfigMap
+func (s *ConfigMapStore) Save(ctx context.Context, j *Journal) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("序列化运行日志失败: %w", err)
+	}
+
+	client := s.clientset.CoreV1().ConfigMaps(s.namespace)
+	name := configMapPrefix + j.RunID
+
+	cm, err := client.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: s.namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/name":      "iotdb-restore",
+					"app.kubernetes.io/component": "journal",
+				},
+			},
+			Data: map[string]string{configMapKey: string(data)},
+		}
+		if _, err := client.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("创建运行日志 ConfigMap 失败: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取运行日志 ConfigMap 失败: %w", err)
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[configMapKey] = string(data)
+	if _, err := client.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("更新运行日志 ConfigMap 失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission 一项需要的 RBAC 权限
+type Permission struct {
+	Namespace   string
+	Group       string
+	Resource    string
+	Subresource string
+	Verb        string
+}
+
+// String 返回 kubectl auth can-i 风格的描述，如 "create pods/exec (iotdb)"
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	return fmt.Sprintf("%s %s (%s)", p.Verb, resource, p.Namespace)
+}
+
+// CanI 通过 SelfSubjectAccessReview 检查当前身份是否拥有权限
+func CanI(ctx context.Context, clientset kubernetes.Interface, perm Permission) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   perm.Namespace,
+				Group:       perm.Group,
+				Resource:    perm.Resource,
+				Subresource: perm.Subresource,
+				Verb:        perm.Verb,
+			},
+		},
+	}
+	result, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("检查权限 %s 失败: %w", perm, err)
+	}
+	return result.Status.Allowed, nil
+}
@@ -11,7 +11,6 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // expandPath 扩展路径中的 ~ 为用户主目录
@@ -114,9 +113,10 @@ func NewConfig(kubeconfigPath string) (*rest.Config, error) {
 	return config, nil
 }
 
-// TestConnection 测试与 Kubernetes API 的连接
-func TestConnection(ctx context.Context, clientset *kubernetes.Clientset) error {
-	_, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+// TestConnection 测试与 Kubernetes API 的连接。读取服务端版本不需要额外授权，
+// 只授予命名空间内 Role 的 ServiceAccount 也能通过
+func TestConnection(ctx context.Context, clientset kubernetes.Interface) error {
+	_, err := clientset.Discovery().ServerVersion()
 	if err != nil {
 		return fmt.Errorf("连接 Kubernetes API 失败: %w", err)
 	}
@@ -14,9 +14,20 @@ import (
 	"k8s.io/client-go/tools/remotecommand"
 )
 
+// CommandExecutor Pod 命令执行接口，恢复流程依赖该接口以便在测试中替换
+type CommandExecutor interface {
+	Exec(ctx context.Context, command []string) (string, string, error)
+	ExecStream(ctx context.Context, command []string, stdin io.Reader, stdout, stderr io.Writer) error
+	ExecSimple(ctx context.Context, command string) (string, error)
+	FileExists(ctx context.Context, filePath string) (bool, error)
+	FileSize(ctx context.Context, filePath string) (int64, error)
+}
+
+var _ CommandExecutor = (*Executor)(nil)
+
 // Executor Pod 命令执行器
 type Executor struct {
-	Clientset    *kubernetes.Clientset
+	Clientset    kubernetes.Interface
 	RestConfig   *rest.Config
 	Namespace    string
 	PodName      string
@@ -31,7 +42,7 @@ type ExecutorConfig struct {
 }
 
 // NewExecutor 创建命令执行器
-func NewExecutor(clientset *kubernetes.Clientset, restConfig *rest.Config, namespace, podName string, config *ExecutorConfig) *Executor {
+func NewExecutor(clientset kubernetes.Interface, restConfig *rest.Config, namespace, podName string, config *ExecutorConfig) *Executor {
 	if config == nil {
 		config = &ExecutorConfig{
 			Timeout:     30 * time.Minute,
@@ -125,8 +136,8 @@ func (e *Executor) Exec(ctx context.Context, command []string) (string, string,
 	return stdout.String(), stderr.String(), nil
 }
 
-// ExecStream 在 Pod 中执行命令（流式输出）
-func (e *Executor) ExecStream(ctx context.Context, command []string, stdout, stderr io.Writer) error {
+// ExecStream 在 Pod 中执行命令（流式输出），stdin 不为空时作为命令的标准输入
+func (e *Executor) ExecStream(ctx context.Context, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	// 获取容器名称
 	containerName, err := e.getContainerName(ctx)
 	if err != nil {
@@ -151,6 +162,9 @@ func (e *Executor) ExecStream(ctx context.Context, command []string, stdout, std
 	for _, cmd := range command[1:] {
 		req.Param("command", cmd)
 	}
+	if stdin != nil {
+		req.Param("stdin", "true")
+	}
 
 	executor, err := remotecommand.NewSPDYExecutor(e.RestConfig, "POST", req.URL())
 	if err != nil {
@@ -158,6 +172,7 @@ func (e *Executor) ExecStream(ctx context.Context, command []string, stdout, std
 	}
 
 	err = executor.StreamWithContext(execCtx, remotecommand.StreamOptions{
+		Stdin:  stdin,
 		Stdout: stdout,
 		Stderr: stderr,
 	})
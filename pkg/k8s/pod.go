@@ -13,12 +13,12 @@ import (
 
 // PodChecker Pod 检查器接口
 type PodChecker struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
 	namespace string
 }
 
 // NewPodChecker 创建 Pod 检查器
-func NewPodChecker(clientset *kubernetes.Clientset, namespace string) *PodChecker {
+func NewPodChecker(clientset kubernetes.Interface, namespace string) *PodChecker {
 	return &PodChecker{
 		clientset: clientset,
 		namespace: namespace,
@@ -154,3 +154,17 @@ func (p *PodChecker) GetPodInfo(ctx context.Context, podName string) (map[string
 
 	return info, nil
 }
+
+// RestartCount 返回 Pod 中所有容器的重启次数之和
+func (p *PodChecker) RestartCount(ctx context.Context, podName string) (int32, error) {
+	pod, err := p.GetPod(ctx, podName)
+	if err != nil {
+		return 0, err
+	}
+
+	var restarts int32
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return restarts, nil
+}
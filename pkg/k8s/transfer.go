@@ -3,6 +3,8 @@ package k8s
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -21,7 +23,7 @@ import (
 
 // Transfer 文件传输器
 type Transfer struct {
-	clientset  *kubernetes.Clientset
+	clientset  kubernetes.Interface
 	restConfig *rest.Config
 	namespace  string
 	podName    string
@@ -29,7 +31,7 @@ type Transfer struct {
 }
 
 // NewTransfer 创建文件传输器
-func NewTransfer(clientset *kubernetes.Clientset, restConfig *rest.Config, namespace, podName string) *Transfer {
+func NewTransfer(clientset kubernetes.Interface, restConfig *rest.Config, namespace, podName string) *Transfer {
 	return &Transfer{
 		clientset:  clientset,
 		restConfig: restConfig,
@@ -64,10 +66,11 @@ func (t *Transfer) getContainerNameForPod(ctx context.Context, namespace, podNam
 	return containerName, nil
 }
 
-// CopyDirectoryAsArchiveFromPod 流式将源 Pod 中的目录打包写入目标 Pod 的归档文件。
-func (t *Transfer) CopyDirectoryAsArchiveFromPod(ctx context.Context, sourceNamespace, sourcePod, sourceBaseDir string, sourcePaths []string, targetArchivePath string) error {
+// CopyDirectoryAsArchiveFromPod 流式将源 Pod 中的目录打包写入目标 Pod 的归档文件，
+// 返回传输过程中计算的归档 sha256，供目标 Pod 落盘后复核。
+func (t *Transfer) CopyDirectoryAsArchiveFromPod(ctx context.Context, sourceNamespace, sourcePod, sourceBaseDir string, sourcePaths []string, targetArchivePath string) (string, error) {
 	if len(sourcePaths) == 0 {
-		return fmt.Errorf("sourcePaths 不能为空")
+		return "", fmt.Errorf("sourcePaths 不能为空")
 	}
 
 	logger.Info("开始从源 Pod 拉取目录归档到目标 Pod",
@@ -98,6 +101,7 @@ func (t *Transfer) CopyDirectoryAsArchiveFromPod(ctx context.Context, sourceName
 	defer cancel()
 
 	pipeReader, pipeWriter := io.Pipe()
+	hasher := sha256.New()
 	var sourceStderr bytes.Buffer
 	var targetStderr bytes.Buffer
 	var wg sync.WaitGroup
@@ -111,7 +115,7 @@ func (t *Transfer) CopyDirectoryAsArchiveFromPod(ctx context.Context, sourceName
 		defer wg.Done()
 		defer pipeWriter.Close()
 
-		sourceErr = t.execPodCommand(streamCtx, sourceNamespace, sourcePod, sourceCmd, nil, pipeWriter, &sourceStderr)
+		sourceErr = t.execPodCommand(streamCtx, sourceNamespace, sourcePod, sourceCmd, nil, io.MultiWriter(pipeWriter, hasher), &sourceStderr)
 		if sourceErr != nil {
 			_ = pipeWriter.CloseWithError(sourceErr)
 			cancel()
@@ -132,11 +136,12 @@ func (t *Transfer) CopyDirectoryAsArchiveFromPod(ctx context.Context, sourceName
 	wg.Wait()
 
 	if sourceErr != nil {
-		return fmt.Errorf("源 Pod 打包失败: %w: %s", sourceErr, strings.TrimSpace(sourceStderr.String()))
+		return "", fmt.Errorf("源 Pod 打包失败: %w: %s", sourceErr, strings.TrimSpace(sourceStderr.String()))
 	}
 	if targetErr != nil {
-		return fmt.Errorf("目标 Pod 写入归档失败: %w: %s", targetErr, strings.TrimSpace(targetStderr.String()))
+		return "", fmt.Errorf("目标 Pod 写入归档失败: %w: %s", targetErr, strings.TrimSpace(targetStderr.String()))
 	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
 
 	logger.Info("源 Pod 目录归档传输完成",
 		zap.String("source_namespace", sourceNamespace),
@@ -144,9 +149,10 @@ func (t *Transfer) CopyDirectoryAsArchiveFromPod(ctx context.Context, sourceName
 		zap.String("target_namespace", t.namespace),
 		zap.String("target_pod", t.podName),
 		zap.String("target_archive_path", targetArchivePath),
+		zap.String("sha256", checksum),
 	)
 
-	return nil
+	return checksum, nil
 }
 
 // CopyDirectoryFromPod 流式复制源 Pod 中的目录到目标 Pod 目录。
@@ -1,17 +1,32 @@
 package lock
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
 )
 
-// FileLock 文件锁
+// maxHolderSize 读取锁文件内容的上限
+const maxHolderSize = 64 << 10
+
+// FileLock 基于 flock(2) 的本地文件锁。持有锁的进程退出时由内核释放，
+// 锁文件中以 JSON 记录持有者的 PID、主机、运行 ID 和开始时间
 type FileLock struct {
 	lockFile string
+	runID    string
 	file     *os.File
 	mu       sync.Mutex
 }
@@ -29,99 +44,282 @@ func NewFileLock(lockDir string, name string) (*FileLock, error) {
 	}, nil
 }
 
-// TryLock 尝试获取锁（非阻塞）
-func (fl *FileLock) TryLock() error {
+// SetRunID 设置写入锁文件的运行 ID
+func (fl *FileLock) SetRunID(runID string) {
+	fl.runID = runID
+}
+
+// Path 返回锁文件路径
+func (fl *FileLock) Path() string {
+	return fl.lockFile
+}
+
+// TryLock 尝试获取锁（非阻塞）。锁被同一主机上已退出的进程遗留时删除锁文件后重试一次
+func (fl *FileLock) TryLock(ctx context.Context) error {
 	fl.mu.Lock()
 	defer fl.mu.Unlock()
 
-	// 尝试创建并打开文件
-	file, err := os.OpenFile(fl.lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if fl.file != nil {
+		return nil
+	}
+
+	err := fl.tryLock()
+	if !errors.Is(err, ErrLocked) {
+		return err
+	}
+	status := fl.status()
+	if status.State != StateStale {
+		return err
+	}
+	logger.Warn("锁文件的持有进程已退出，删除后重新获取锁",
+		zap.String("lock_file", fl.lockFile),
+		zap.String("reason", status.Reason),
+	)
+	if err := os.Remove(fl.lockFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除残留的锁文件失败: %w", err)
+	}
+	return fl.tryLock()
+}
+
+func (fl *FileLock) tryLock() error {
+	file, err := os.OpenFile(fl.lockFile, os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
-		if os.IsExist(err) {
-			// 锁文件已存在，检查是否过期
-			if info, err := os.Stat(fl.lockFile); err == nil {
-				// 如果锁文件超过 3 小时，认为是死锁
-				if time.Since(info.ModTime()) > 3*time.Hour {
-					os.Remove(fl.lockFile)
-					// 重试
-					file, err = os.OpenFile(fl.lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
-					if err != nil {
-						return fmt.Errorf("获取锁失败（重试后）: %w", err)
-					}
-				} else {
-					// 锁文件未过期，返回错误
-					pid := fl.readPID()
-					return fmt.Errorf("任务正在运行中（PID: %s，锁文件: %s）", pid, fl.lockFile)
-				}
-			} else {
-				return fmt.Errorf("获取锁失败: %w", err)
-			}
-		} else {
-			return fmt.Errorf("获取锁失败: %w", err)
+		return fmt.Errorf("打开锁文件失败: %w", err)
+	}
+	if err := flock(file); err != nil {
+		file.Close()
+		if errors.Is(err, errWouldBlock) {
+			return fl.lockedError()
 		}
+		return fmt.Errorf("获取锁失败: %w", err)
+	}
+
+	// 加锁前锁文件可能已被释放它的进程删除，此时锁住的是已删除的文件
+	if !sameFile(file, fl.lockFile) {
+		file.Close()
+		return fmt.Errorf("%w: 锁文件 %s 已被替换", ErrLocked, fl.lockFile)
+	}
+
+	if previous, err := readHolder(file); err == nil && previous != nil {
+		logger.Info("锁文件由上次未正常退出的任务遗留，已由内核释放",
+			zap.Int("previous_pid", previous.PID),
+			zap.String("previous_run_id", previous.RunID),
+		)
 	}
 
-	// 写入当前 PID
-	pid := os.Getpid()
-	fmt.Fprintf(file, "%d\n", pid)
-	fmt.Fprintf(file, "started_at: %s\n", time.Now().Format(time.RFC3339))
+	holder := Holder{
+		PID:       os.Getpid(),
+		Host:      hostname(),
+		RunID:     fl.runID,
+		StartedAt: time.Now(),
+	}
+	if err := writeHolder(file, holder); err != nil {
+		funlock(file)
+		file.Close()
+		return fmt.Errorf("写入锁文件失败: %w", err)
+	}
 
 	fl.file = file
 	return nil
 }
 
-// Lock 阻塞式获取锁
+func (fl *FileLock) lockedError() error {
+	holder, err := fl.readHolder()
+	if err != nil || holder == nil {
+		return fmt.Errorf("%w（锁文件: %s）", ErrLocked, fl.lockFile)
+	}
+	return fmt.Errorf("%w: 任务正在运行中（PID: %d，主机: %s，运行 ID: %s，开始于: %s，锁文件: %s）",
+		ErrLocked, holder.PID, holder.Host, holder.RunID, holder.StartedAt.Format(time.RFC3339), fl.lockFile)
+}
+
+// Lock 阻塞式获取锁，每 5 秒重试一次，直到成功、遇到非锁冲突的错误或 ctx 结束
 func (fl *FileLock) Lock(ctx context.Context) error {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
 	for {
+		err := fl.TryLock(ctx)
+		if !errors.Is(err, ErrLocked) {
+			return err
+		}
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return fmt.Errorf("等待锁失败: %w（%w）", ctx.Err(), err)
 		case <-ticker.C:
-			if err := fl.TryLock(); err == nil {
-				return nil
-			}
 		}
 	}
 }
 
-// Unlock 释放锁
-func (fl *FileLock) Unlock() error {
+// Unlock 释放锁并删除锁文件。锁文件已被 lock break 替换时不删除新的锁文件
+func (fl *FileLock) Unlock(ctx context.Context) error {
 	fl.mu.Lock()
 	defer fl.mu.Unlock()
 
-	if fl.file != nil {
-		fl.file.Close()
-		fl.file = nil
+	if fl.file == nil {
+		return nil
 	}
+	file := fl.file
+	fl.file = nil
 
-	if err := os.Remove(fl.lockFile); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("释放锁失败: %w", err)
+	// 先删除再解锁，避免其他进程锁住即将被删除的文件
+	var removeErr error
+	if sameFile(file, fl.lockFile) {
+		if err := os.Remove(fl.lockFile); err != nil && !os.IsNotExist(err) {
+			removeErr = fmt.Errorf("释放锁失败: %w", err)
+		}
 	}
+	funlock(file)
+	file.Close()
+	return removeErr
+}
 
+// Lost 本地文件锁不会被其他任务接管，返回 nil
+func (fl *FileLock) Lost() <-chan struct{} {
 	return nil
 }
 
-// readPID 读取锁文件中的 PID
-func (fl *FileLock) readPID() string {
-	data, err := os.ReadFile(fl.lockFile)
+// Status 查看锁文件的持有情况
+func (fl *FileLock) Status(ctx context.Context) (*Status, error) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return fl.status(), nil
+}
+
+// Break 删除锁文件。持有进程仍在运行，或在其他主机上无法确认时需要 force
+func (fl *FileLock) Break(ctx context.Context, force bool) (*Status, error) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	status := fl.status()
+	switch {
+	case status.State == StateFree:
+		return status, nil
+	case status.State == StateHeld && !force:
+		return status, fmt.Errorf("锁仍被持有（%s），确认持有者已停止后使用 --force 强制删除", status.Reason)
+	}
+	if err := os.Remove(fl.lockFile); err != nil && !os.IsNotExist(err) {
+		return status, fmt.Errorf("删除锁文件失败: %w", err)
+	}
+	logger.Warn("已删除锁文件",
+		zap.String("lock_file", fl.lockFile),
+		zap.String("state", status.State),
+		zap.Bool("force", force),
+	)
+	return status, nil
+}
+
+// status 根据 flock 和记录的持有进程判断锁的状态
+func (fl *FileLock) status() *Status {
+	status := &Status{Backend: "file", Resource: fl.lockFile}
+
+	file, err := os.Open(fl.lockFile)
+	if os.IsNotExist(err) {
+		status.State = StateFree
+		return status
+	}
 	if err != nil {
-		return "unknown"
+		status.State = StateHeld
+		status.Reason = fmt.Sprintf("无法打开锁文件: %v", err)
+		return status
 	}
+	defer file.Close()
+
+	holder, readErr := readHolder(file)
+	status.Holder = holder
 
-	// 第一行是 PID
-	lines := string(data)
-	if len(lines) > 0 {
-		return lines
+	if err := flock(file); err == nil {
+		funlock(file)
+		if holder == nil {
+			status.State = StateFree
+			return status
+		}
+		status.State = StateStale
+		status.Reason = "锁文件残留，没有进程持有锁"
+		return status
 	}
 
-	return "unknown"
+	status.State = StateHeld
+	switch {
+	case readErr != nil || holder == nil:
+		status.Reason = "锁被持有，锁文件中没有可识别的持有者信息"
+	case holder.Host != hostname():
+		status.Reason = fmt.Sprintf("持有者在主机 %s 上，无法确认进程是否存活", holder.Host)
+	case processAlive(holder.PID):
+		status.Reason = fmt.Sprintf("持有进程 %d 正在运行", holder.PID)
+	default:
+		// 锁目录位于网络文件系统等场景下，进程退出后 flock 可能不会被释放
+		status.State = StateStale
+		status.Reason = fmt.Sprintf("持有进程 %d 已退出", holder.PID)
+	}
+	return status
 }
 
-// IsLocked 检查是否已锁定
+func (fl *FileLock) readHolder() (*Holder, error) {
+	file, err := os.Open(fl.lockFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readHolder(file)
+}
+
+// IsLocked 检查锁是否被持有
 func (fl *FileLock) IsLocked() bool {
-	_, err := os.Stat(fl.lockFile)
-	return !os.IsNotExist(err)
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return fl.file != nil || fl.status().State == StateHeld
+}
+
+// readHolder 读取锁文件中的持有者信息，空文件返回 nil。
+// 兼容旧版本第一行为 PID 的锁文件
+func readHolder(file *os.File) (*Holder, error) {
+	// 从已打开的文件读取，锁文件被替换时仍读取加锁的那个文件
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, maxHolderSize))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var holder Holder
+	if err := json.Unmarshal(data, &holder); err == nil {
+		return &holder, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Scan()
+	pid, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil {
+		return nil, fmt.Errorf("无法解析锁文件: %s", file.Name())
+	}
+	return &Holder{PID: pid, Host: hostname()}, nil
+}
+
+func writeHolder(file *os.File, holder Holder) error {
+	data, err := json.MarshalIndent(holder, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt(append(data, '\n'), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// sameFile 打开的文件是否仍是 path 指向的文件
+func sameFile(file *os.File, path string) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(opened, current)
 }
@@ -0,0 +1,155 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exitedPID 返回一个已退出进程的 PID
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("run true: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func writeLockFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write lock file: %v", err)
+	}
+}
+
+func TestFileLockExclusive(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	first, _ := NewFileLock(dir, "iotdb-restore")
+	first.SetRunID("20260203-083502-a1b2c3")
+	second, _ := NewFileLock(dir, "iotdb-restore")
+
+	if err := first.TryLock(ctx); err != nil {
+		t.Fatalf("first lock: %v", err)
+	}
+	err := second.TryLock(ctx)
+	if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), fmt.Sprintf("PID: %d", os.Getpid())) ||
+		!strings.Contains(err.Error(), "20260203-083502-a1b2c3") {
+		t.Fatalf("expected lock held by current process, got %v", err)
+	}
+
+	status, err := second.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.State != StateHeld || status.Holder == nil || status.Holder.PID != os.Getpid() || status.Holder.Host != hostname() {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if _, err := second.Break(ctx, false); err == nil {
+		t.Fatalf("break without force should fail while lock is held")
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := second.Lock(waitCtx); !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected lock wait to report timeout and holder, got %v", err)
+	}
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if _, err := os.Stat(first.Path()); !os.IsNotExist(err) {
+		t.Fatalf("lock file should be removed after unlock: %v", err)
+	}
+	if err := second.TryLock(ctx); err != nil {
+		t.Fatalf("second lock after release: %v", err)
+	}
+	second.Unlock(ctx)
+}
+
+func TestFileLockStale(t *testing.T) {
+	dead := exitedPID(t)
+	tests := []struct {
+		name    string
+		content string
+		// hold 另一个打开的文件持有 flock，模拟锁未被内核释放
+		hold bool
+	}{
+		{name: "left by exited process", content: fmt.Sprintf(`{"pid": %d, "host": %q, "started_at": "2026-02-03T08:35:02Z"}`, dead, hostname())},
+		{name: "legacy pid file", content: fmt.Sprintf("%d\nstarted_at: 2026-02-03T08:35:02+08:00\n", dead)},
+		{name: "held by exited process", content: fmt.Sprintf(`{"pid": %d, "host": %q, "started_at": "2026-02-03T08:35:02Z"}`, dead, hostname()), hold: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fl, _ := NewFileLock(t.TempDir(), "iotdb-restore")
+			writeLockFile(t, fl.Path(), tt.content)
+			if tt.hold {
+				file, err := os.Open(fl.Path())
+				if err != nil {
+					t.Fatalf("open lock file: %v", err)
+				}
+				defer file.Close()
+				if err := flock(file); err != nil {
+					t.Fatalf("flock: %v", err)
+				}
+			}
+
+			status, err := fl.Status(ctx)
+			if err != nil {
+				t.Fatalf("status: %v", err)
+			}
+			if status.State != StateStale || status.Holder == nil || status.Holder.PID != dead {
+				t.Fatalf("expected stale lock of pid %d, got %+v", dead, status)
+			}
+
+			if err := fl.TryLock(ctx); err != nil {
+				t.Fatalf("lock stale file: %v", err)
+			}
+			defer fl.Unlock(ctx)
+			holder, err := fl.readHolder()
+			if err != nil || holder.PID != os.Getpid() {
+				t.Fatalf("expected lock file rewritten by current process, got %+v (%v)", holder, err)
+			}
+		})
+	}
+}
+
+func TestFileLockBreak(t *testing.T) {
+	ctx := context.Background()
+	fl, _ := NewFileLock(t.TempDir(), "iotdb-restore")
+
+	status, err := fl.Break(ctx, false)
+	if err != nil || status.State != StateFree {
+		t.Fatalf("break free lock: %+v (%v)", status, err)
+	}
+
+	writeLockFile(t, fl.Path(), fmt.Sprintf(`{"pid": %d, "host": "other-node", "started_at": "2026-02-03T08:35:02Z"}`, os.Getpid()))
+	file, err := os.Open(fl.Path())
+	if err != nil {
+		t.Fatalf("open lock file: %v", err)
+	}
+	defer file.Close()
+	if err := flock(file); err != nil {
+		t.Fatalf("flock: %v", err)
+	}
+
+	// 其他主机上的持有者无法确认是否存活，需要 force
+	if status, err := fl.Break(ctx, false); err == nil || status.State != StateHeld {
+		t.Fatalf("expected break to be refused, got %+v (%v)", status, err)
+	}
+	if _, err := fl.Break(ctx, true); err != nil {
+		t.Fatalf("force break: %v", err)
+	}
+	if err := fl.TryLock(ctx); err != nil {
+		t.Fatalf("lock after break: %v", err)
+	}
+	fl.Unlock(ctx)
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package lock
+
+import (
+	"errors"
+	"os"
+)
+
+// errWouldBlock 锁已被其他打开的文件持有
+var errWouldBlock = errors.New("锁已被持有")
+
+func flock(file *os.File) error {
+	return errors.New("当前平台不支持文件锁，请使用 lease 锁")
+}
+
+func funlock(file *os.File) {}
+
+// processAlive 无法检查时按进程存在处理，避免误删锁
+func processAlive(pid int) bool {
+	return true
+}
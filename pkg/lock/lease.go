@@ -0,0 +1,321 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	coordinationclient "k8s.io/client-go/kubernetes/typed/coordination/v1"
+)
+
+// runIDAnnotation 记录持有者运行 ID 的 Lease 注解
+const runIDAnnotation = "iotdb-restore/run-id"
+
+// errLeaseLost Lease 已被其他任务接管
+var errLeaseLost = errors.New("Lease 已被其他任务接管")
+
+// LeaseLock 基于 coordination.k8s.io Lease 的分布式锁，运行在不同节点上的恢复任务之间也能互斥。
+// 持有期间按 renewInterval 续约，其他任务只能在持有者停止续约超过租期后接管
+type LeaseLock struct {
+	client        coordinationclient.LeaseInterface
+	namespace     string
+	name          string
+	identity      string
+	runID         string
+	duration      time.Duration
+	renewInterval time.Duration
+	now           func() time.Time
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+	lost chan struct{}
+}
+
+// NewLeaseLock 创建 Lease 锁，identity 为持有者标识
+func NewLeaseLock(clientset kubernetes.Interface, namespace, name, identity string, duration, renewInterval time.Duration) *LeaseLock {
+	return &LeaseLock{
+		client:        clientset.CoordinationV1().Leases(namespace),
+		namespace:     namespace,
+		name:          name,
+		identity:      identity,
+		duration:      duration,
+		renewInterval: renewInterval,
+		now:           time.Now,
+	}
+}
+
+// SetRunID 设置记录到 Lease 注解中的运行 ID
+func (l *LeaseLock) SetRunID(runID string) {
+	l.runID = runID
+}
+
+// TryLock 尝试获取锁（非阻塞）。Lease 不存在、由本进程持有或已过期时获取成功，并开始续约
+func (l *LeaseLock) TryLock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stop != nil {
+		return nil
+	}
+
+	now := l.now()
+	lease, err := l.client.Get(ctx, l.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      l.name,
+				Namespace: l.namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/name":      "iotdb-restore",
+					"app.kubernetes.io/component": "lock",
+				},
+			},
+		}
+		l.hold(lease, now)
+		if _, err := l.client.Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return fmt.Errorf("%w: Lease %s/%s 已被其他任务创建", ErrLocked, l.namespace, l.name)
+			}
+			return fmt.Errorf("创建 Lease 失败: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("读取 Lease 失败: %w", err)
+	default:
+		holder := holderOf(lease)
+		if holder != "" && holder != l.identity {
+			expiry := leaseExpiry(lease)
+			if now.Before(expiry) {
+				return fmt.Errorf("%w: 任务正在运行中（持有者: %s，Lease: %s/%s，%s 后过期）",
+					ErrLocked, holder, l.namespace, l.name, expiry.Sub(now).Round(time.Second))
+			}
+			logger.Warn("Lease 已过期，接管恢复任务锁",
+				zap.String("lease", l.name),
+				zap.String("previous_holder", holder),
+				zap.Time("expired_at", expiry),
+			)
+			transitions := int32(0)
+			if lease.Spec.LeaseTransitions != nil {
+				transitions = *lease.Spec.LeaseTransitions
+			}
+			transitions++
+			lease.Spec.LeaseTransitions = &transitions
+		}
+		l.hold(lease, now)
+		// Update 带 resourceVersion，两个任务同时接管时只有一个能成功
+		if _, err := l.client.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+			if apierrors.IsConflict(err) {
+				return fmt.Errorf("%w: Lease %s/%s 已被其他任务更新", ErrLocked, l.namespace, l.name)
+			}
+			return fmt.Errorf("更新 Lease 失败: %w", err)
+		}
+	}
+
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	l.lost = make(chan struct{})
+	go l.keepAlive(l.stop, l.done, l.lost)
+	return nil
+}
+
+// Unlock 停止续约并删除 Lease。Lease 已被其他任务接管时不做修改
+func (l *LeaseLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stop == nil {
+		return nil
+	}
+	close(l.stop)
+	<-l.done
+	l.stop, l.done = nil, nil
+
+	lease, err := l.client.Get(ctx, l.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("释放锁失败: %w", err)
+	}
+	if holderOf(lease) != l.identity {
+		return nil
+	}
+	err = l.client.Delete(ctx, l.name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("释放锁失败: %w", err)
+	}
+	return nil
+}
+
+// Lost 续约时发现 Lease 被其他任务接管，或超过租期仍未续约成功时关闭
+func (l *LeaseLock) Lost() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lost
+}
+
+// Status 查看 Lease 的持有情况，持有者超过租期未续约时为 stale
+func (l *LeaseLock) Status(ctx context.Context) (*Status, error) {
+	status := &Status{Backend: "lease", Resource: l.namespace + "/" + l.name}
+
+	lease, err := l.client.Get(ctx, l.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		status.State = StateFree
+		return status, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 Lease 失败: %w", err)
+	}
+	holder := holderOf(lease)
+	if holder == "" {
+		status.State = StateFree
+		return status, nil
+	}
+
+	status.Holder = &Holder{Identity: holder, RunID: lease.Annotations[runIDAnnotation]}
+	if lease.Spec.AcquireTime != nil {
+		status.Holder.StartedAt = lease.Spec.AcquireTime.Time
+	}
+	expiry := leaseExpiry(lease)
+	status.ExpiresAt = &expiry
+	if l.now().Before(expiry) {
+		status.State = StateHeld
+		status.Reason = fmt.Sprintf("持有者 %s 仍在续约", holder)
+	} else {
+		status.State = StateStale
+		status.Reason = fmt.Sprintf("持有者 %s 已停止续约，Lease 已过期", holder)
+	}
+	return status, nil
+}
+
+// Break 删除 Lease。Lease 未过期时需要 force
+func (l *LeaseLock) Break(ctx context.Context, force bool) (*Status, error) {
+	status, err := l.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case status.State == StateFree:
+		return status, nil
+	case status.State == StateHeld && !force:
+		return status, fmt.Errorf("锁仍被持有（%s），确认持有者已停止后使用 --force 强制删除", status.Reason)
+	}
+	if err := l.client.Delete(ctx, l.name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return status, fmt.Errorf("删除 Lease 失败: %w", err)
+	}
+	logger.Warn("已删除恢复任务锁 Lease",
+		zap.String("lease", status.Resource),
+		zap.String("state", status.State),
+		zap.Bool("force", force),
+	)
+	return status, nil
+}
+
+// keepAlive 定期续约，直到 stop 关闭或锁丢失
+func (l *LeaseLock) keepAlive(stop, done, lost chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(l.renewInterval)
+	defer ticker.Stop()
+
+	renewed := l.now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), l.renewInterval)
+		err := l.renew(ctx)
+		cancel()
+
+		switch {
+		case err == nil:
+			renewed = l.now()
+		case errors.Is(err, errLeaseLost):
+			logger.Error("恢复任务锁已被其他任务接管", zap.String("lease", l.name), zap.Error(err))
+			close(lost)
+			return
+		case l.now().Sub(renewed) >= l.duration:
+			logger.Error("超过租期仍未续约成功，恢复任务锁可能已被接管",
+				zap.String("lease", l.name),
+				zap.Duration("since_last_renew", l.now().Sub(renewed)),
+				zap.Error(err),
+			)
+			close(lost)
+			return
+		default:
+			logger.Warn("续约 Lease 失败，稍后重试", zap.String("lease", l.name), zap.Error(err))
+		}
+	}
+}
+
+func (l *LeaseLock) renew(ctx context.Context) error {
+	lease, err := l.client.Get(ctx, l.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("%w: Lease 已被删除", errLeaseLost)
+	}
+	if err != nil {
+		return fmt.Errorf("读取 Lease 失败: %w", err)
+	}
+	if holder := holderOf(lease); holder != l.identity {
+		return fmt.Errorf("%w: 当前持有者 %s", errLeaseLost, holder)
+	}
+
+	renewTime := metav1.NewMicroTime(l.now())
+	lease.Spec.RenewTime = &renewTime
+	if _, err := l.client.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("更新 Lease 失败: %w", err)
+	}
+	return nil
+}
+
+// hold 将 lease 的持有者设为当前进程
+func (l *LeaseLock) hold(lease *coordinationv1.Lease, now time.Time) {
+	identity := l.identity
+	seconds := int32(l.duration / time.Second)
+	acquireTime := metav1.NewMicroTime(now)
+	lease.Spec.HolderIdentity = &identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.AcquireTime = &acquireTime
+	lease.Spec.RenewTime = &acquireTime
+	if l.runID != "" {
+		if lease.Annotations == nil {
+			lease.Annotations = map[string]string{}
+		}
+		lease.Annotations[runIDAnnotation] = l.runID
+	} else {
+		delete(lease.Annotations, runIDAnnotation)
+	}
+}
+
+func holderOf(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// leaseExpiry 持有者最后一次续约时间加租期，没有续约记录的 Lease 视为已过期
+func leaseExpiry(lease *coordinationv1.Lease) time.Time {
+	renewed := lease.Spec.RenewTime
+	if renewed == nil {
+		renewed = lease.Spec.AcquireTime
+	}
+	if renewed == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return time.Time{}
+	}
+	return renewed.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+}
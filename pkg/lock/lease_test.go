@@ -0,0 +1,188 @@
+package lock
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	testNamespace = "iotdb"
+	testLease     = "iotdb-restore-iotdb-datanode-0"
+)
+
+func getLease(t *testing.T, clientset *fake.Clientset) *coordinationv1.Lease {
+	t.Helper()
+	lease, err := clientset.CoordinationV1().Leases(testNamespace).Get(context.Background(), testLease, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get lease: %v", err)
+	}
+	return lease
+}
+
+func createLease(t *testing.T, clientset *fake.Clientset, holder string, renewed time.Time) {
+	t.Helper()
+	seconds := int32(60)
+	renewTime := metav1.NewMicroTime(renewed)
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: testLease, Namespace: testNamespace},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &seconds,
+			RenewTime:            &renewTime,
+		},
+	}
+	if _, err := clientset.CoordinationV1().Leases(testNamespace).Create(context.Background(), lease, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create lease: %v", err)
+	}
+}
+
+func TestLeaseLockExclusive(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+	first := NewLeaseLock(clientset, testNamespace, testLease, "job-a_1", time.Minute, time.Hour)
+	second := NewLeaseLock(clientset, testNamespace, testLease, "job-b_1", time.Minute, time.Hour)
+
+	if err := first.TryLock(ctx); err != nil {
+		t.Fatalf("first lock: %v", err)
+	}
+	if holder := holderOf(getLease(t, clientset)); holder != "job-a_1" {
+		t.Fatalf("unexpected holder %q", holder)
+	}
+	err := second.TryLock(ctx)
+	if err == nil || !strings.Contains(err.Error(), "job-a_1") {
+		t.Fatalf("expected lock held by job-a_1, got %v", err)
+	}
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if err := second.TryLock(ctx); err != nil {
+		t.Fatalf("second lock after release: %v", err)
+	}
+	if err := second.Unlock(ctx); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+}
+
+func TestLeaseLockTakeover(t *testing.T) {
+	now := time.Date(2026, 2, 3, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		renewed  time.Time
+		acquired bool
+	}{
+		{name: "lease still valid", renewed: now.Add(-30 * time.Second), acquired: false},
+		{name: "lease expired", renewed: now.Add(-2 * time.Minute), acquired: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			clientset := fake.NewSimpleClientset()
+			createLease(t, clientset, "job-a_1", tt.renewed)
+
+			l := NewLeaseLock(clientset, testNamespace, testLease, "job-b_1", time.Minute, time.Hour)
+			l.now = func() time.Time { return now }
+			err := l.TryLock(ctx)
+			if (err == nil) != tt.acquired {
+				t.Fatalf("expected acquired=%v, got %v", tt.acquired, err)
+			}
+			if !tt.acquired {
+				return
+			}
+			defer l.Unlock(ctx)
+
+			lease := getLease(t, clientset)
+			if holderOf(lease) != "job-b_1" || lease.Spec.LeaseTransitions == nil || *lease.Spec.LeaseTransitions != 1 {
+				t.Fatalf("unexpected lease after takeover: %+v", lease.Spec)
+			}
+		})
+	}
+}
+
+func TestLeaseLockRenewAndLost(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+	l := NewLeaseLock(clientset, testNamespace, testLease, "job-a_1", time.Minute, 5*time.Millisecond)
+	if err := l.TryLock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer l.Unlock(ctx)
+
+	acquired := getLease(t, clientset).Spec.RenewTime.Time
+	deadline := time.Now().Add(time.Second)
+	for !getLease(t, clientset).Spec.RenewTime.After(acquired) {
+		if time.Now().After(deadline) {
+			t.Fatalf("lease was not renewed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 其他任务接管后，续约时发现持有者变化
+	lease := getLease(t, clientset)
+	other := "job-b_1"
+	lease.Spec.HolderIdentity = &other
+	if _, err := clientset.CoordinationV1().Leases(testNamespace).Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update lease: %v", err)
+	}
+	select {
+	case <-l.Lost():
+	case <-time.After(time.Second):
+		t.Fatalf("lost channel was not closed")
+	}
+
+	if err := l.Unlock(ctx); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if holderOf(getLease(t, clientset)) != other {
+		t.Fatalf("unlock should not release a lease held by another job")
+	}
+}
+
+func TestLeaseLockStatusAndBreak(t *testing.T) {
+	now := time.Date(2026, 2, 3, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		renewed time.Time
+		state   string
+	}{
+		{name: "held", renewed: now.Add(-30 * time.Second), state: StateHeld},
+		{name: "expired", renewed: now.Add(-2 * time.Minute), state: StateStale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			clientset := fake.NewSimpleClientset()
+			createLease(t, clientset, "job-a_1", tt.renewed)
+
+			l := NewLeaseLock(clientset, testNamespace, testLease, "job-b_1", time.Minute, time.Hour)
+			l.now = func() time.Time { return now }
+			status, err := l.Status(ctx)
+			if err != nil {
+				t.Fatalf("status: %v", err)
+			}
+			if status.State != tt.state || status.Holder == nil || status.Holder.Identity != "job-a_1" ||
+				!status.ExpiresAt.Equal(tt.renewed.Add(time.Minute)) {
+				t.Fatalf("unexpected status %+v", status)
+			}
+
+			_, err = l.Break(ctx, false)
+			if (err == nil) != (tt.state == StateStale) {
+				t.Fatalf("unexpected break result for %s lease: %v", tt.state, err)
+			}
+			if _, err := l.Break(ctx, true); err != nil {
+				t.Fatalf("force break: %v", err)
+			}
+			if status, _ := l.Status(ctx); status.State != StateFree {
+				t.Fatalf("expected lease to be deleted, got %+v", status)
+			}
+		})
+	}
+}
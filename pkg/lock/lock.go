@@ -0,0 +1,111 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"k8s.io/client-go/kubernetes"
+)
+
+// lockName 锁文件名和 Lease 名称的前缀
+const lockName = "iotdb-restore"
+
+// 锁状态
+const (
+	StateFree  = "free"
+	StateHeld  = "held"
+	StateStale = "stale"
+)
+
+// ErrLocked 锁被其他任务持有
+var ErrLocked = errors.New("锁已被其他任务持有")
+
+// ErrLost 持有期间锁被其他任务接管，作为恢复 ctx 的取消原因
+var ErrLost = errors.New("恢复任务锁已被其他任务接管")
+
+// Locker 恢复任务锁，防止多个恢复任务同时操作同一个 Pod
+type Locker interface {
+	// TryLock 非阻塞获取锁，锁被其他任务持有时返回包装 ErrLocked 的错误
+	TryLock(ctx context.Context) error
+	// Unlock 释放锁
+	Unlock(ctx context.Context) error
+	// Lost 持有期间锁被其他任务接管时关闭，不会丢失的锁返回 nil
+	Lost() <-chan struct{}
+	// Status 查看锁的持有情况
+	Status(ctx context.Context) (*Status, error)
+	// Break 强制删除锁。锁仍被有效持有时，只有 force 为 true 才会删除
+	Break(ctx context.Context, force bool) (*Status, error)
+}
+
+// Status 锁的持有情况
+type Status struct {
+	Backend string `json:"backend"`
+	// Resource 锁文件路径或 Lease 的 namespace/name
+	Resource string `json:"resource"`
+	// State free（未被持有）、held（被持有）或 stale（残留，持有者已不存在）
+	State string `json:"state"`
+	// Reason 判断状态的依据
+	Reason string `json:"reason,omitempty"`
+	// Holder 持有者信息，锁未被持有时为空
+	Holder *Holder `json:"holder,omitempty"`
+	// ExpiresAt Lease 的过期时间
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Holder 锁持有者信息，file 后端以 JSON 写入锁文件
+type Holder struct {
+	// Identity Lease 的持有者标识
+	Identity  string    `json:"identity,omitempty"`
+	PID       int       `json:"pid,omitempty"`
+	Host      string    `json:"host,omitempty"`
+	RunID     string    `json:"run_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// New 根据配置创建恢复任务锁，runID 记录到持有者信息中，可为空
+func New(cfg *config.Config, clientset kubernetes.Interface, runID string) (Locker, error) {
+	switch cfg.Lock.Backend {
+	case "", "file":
+		fileLock, err := NewFileLock(cfg.Lock.Dir, lockName)
+		if err != nil {
+			return nil, err
+		}
+		fileLock.SetRunID(runID)
+		return fileLock, nil
+	case "lease":
+		if clientset == nil {
+			return nil, fmt.Errorf("lease 锁需要 Kubernetes 客户端")
+		}
+		namespace := cfg.Lock.Namespace
+		if namespace == "" {
+			namespace = cfg.Kubernetes.Namespace
+		}
+		// 按目标 Pod 命名，恢复不同 Pod 的任务互不影响
+		name := lockName + "-" + cfg.Kubernetes.PodName
+		leaseLock := NewLeaseLock(clientset, namespace, name, Identity(),
+			time.Duration(cfg.Lock.LeaseDurationSeconds)*time.Second,
+			time.Duration(cfg.Lock.RenewIntervalSeconds)*time.Second,
+		)
+		leaseLock.SetRunID(runID)
+		return leaseLock, nil
+	default:
+		return nil, fmt.Errorf("未知的锁后端: %s", cfg.Lock.Backend)
+	}
+}
+
+// Identity 当前进程的持有者标识：主机名（在 Kubernetes 中即 Pod 名）和 PID
+func Identity() string {
+	return fmt.Sprintf("%s_%d", hostname(), os.Getpid())
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
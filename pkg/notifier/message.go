@@ -2,11 +2,15 @@ package notifier
 
 import (
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/vnnox/iotdb-restore-tool/pkg/restorer"
 )
 
+// maxFailedFilesInMessage 通知中最多列出的失败文件数，避免超过企微消息长度限制
+const maxFailedFilesInMessage = 20
+
 // MessageTemplate 消息模板
 type MessageTemplate struct {
 	Title       string
@@ -36,7 +40,15 @@ func BuildMessage(result *restorer.RestoreResult, environment string) string {
 
 	message := fmt.Sprintf("## IoTDB 数据恢复通知\n\n")
 	message += fmt.Sprintf("%s **环境**: %s\n", status, environment)
-	message += fmt.Sprintf("> **备份文件**: `%s`\n\n", result.BackupFile)
+	message += fmt.Sprintf("> **备份文件**: `%s`\n", result.BackupFile)
+	if result.RunID != "" {
+		runID := result.RunID
+		if result.Resumed {
+			runID += "（续传）"
+		}
+		message += fmt.Sprintf("> **运行 ID**: `%s`\n", runID)
+	}
+	message += "\n"
 
 	message += "---\n\n"
 	message += "### 📊 恢复统计\n\n"
@@ -48,32 +60,91 @@ func BuildMessage(result *restorer.RestoreResult, environment string) string {
 	message += fmt.Sprintf("| **总文件数** | %d 个 |\n", result.TotalFiles)
 	message += fmt.Sprintf("| **成功导入** | %d 个 |\n", result.SuccessCount)
 	message += fmt.Sprintf("| **失败数量** | %d 个 |\n", result.FailedCount)
+	if result.SkippedCount > 0 {
+		message += fmt.Sprintf("| **续传跳过** | %d 个 |\n", result.SkippedCount)
+	}
+	if c := result.Concurrency; c != nil && c.Adaptive {
+		message += fmt.Sprintf("| **导入并发** | %s |\n", c)
+	}
+	if result.ImportReport != "" {
+		message += fmt.Sprintf("| **导入报告** | `%s` |\n", result.ImportReport)
+	}
+
+	if len(result.FailedFiles) > 0 {
+		message += "\n### ⚠️ 导入失败文件\n\n"
+		message += "| 文件 | 原因 | 尝试次数 |\n"
+		message += "|------|------|------|\n"
+		for i, file := range result.FailedFiles {
+			if i == maxFailedFilesInMessage {
+				message += fmt.Sprintf("\n其余 %d 个失败文件见导入报告\n", len(result.FailedFiles)-i)
+				break
+			}
+			message += fmt.Sprintf("| %s | %s | %d |\n", filepath.Base(file.Path), file.ErrorClass, file.Attempts)
+		}
+		message += "\n可使用 `iotdb-restore restore import --from-report <报告路径>` 重新导入\n"
+	}
 
 	message += "\n---\n\n"
 
-	if result.Probe != nil && result.Probe.Executed {
+	for _, probe := range result.Probes {
+		if probe == nil || !probe.Executed {
+			continue
+		}
 		message += "### 🩺 数据库自检\n\n"
 		message += "| 项目 | 详情 |\n"
 		message += "|------|------|\n"
-		message += fmt.Sprintf("| **数据库** | %s |\n", result.Probe.Database)
-		message += fmt.Sprintf("| **探测序列** | `%s` |\n", result.Probe.SeriesPath)
-		message += fmt.Sprintf("| **写入时间戳** | %d |\n", result.Probe.Timestamp)
-		message += fmt.Sprintf("| **写入值** | %d |\n", result.Probe.Value)
-		if result.Probe.QueryResult != "" {
-			message += fmt.Sprintf("| **查询结果** | %s |\n", result.Probe.QueryResult)
+		message += fmt.Sprintf("| **数据库** | %s |\n", probe.Database)
+		message += fmt.Sprintf("| **探测序列** | `%s` |\n", probe.SeriesPath)
+		message += fmt.Sprintf("| **写入时间戳** | %d |\n", probe.Timestamp)
+		message += fmt.Sprintf("| **写入值** | %d |\n", probe.Value)
+		if probe.QueryResult != "" {
+			message += fmt.Sprintf("| **查询结果** | %s |\n", probe.QueryResult)
 		}
-		if result.Probe.Error != "" {
+		if probe.Error != "" {
 			message += fmt.Sprintf("| **自检状态** | 失败 |\n")
-			message += fmt.Sprintf("| **自检错误** | %s |\n", result.Probe.Error)
+			message += fmt.Sprintf("| **自检错误** | %s |\n", probe.Error)
 		} else {
 			message += fmt.Sprintf("| **自检状态** | 成功 |\n")
 		}
 		message += "\n---\n\n"
 	}
 
+	if v := result.Verification; v != nil {
+		message += "### 🔍 数据校验\n\n"
+		message += "| 项目 | 详情 |\n"
+		message += "|------|------|\n"
+		if v.Reference != "" {
+			message += fmt.Sprintf("| **比对基准** | `%s` |\n", v.Reference)
+		}
+		switch {
+		case v.Error != "":
+			message += fmt.Sprintf("| **校验状态** | 失败 |\n")
+			message += fmt.Sprintf("| **校验错误** | %s |\n", v.Error)
+		case !v.Executed:
+			message += fmt.Sprintf("| **校验状态** | 跳过（%s） |\n", v.Skipped)
+		case len(v.Mismatches) > 0:
+			message += fmt.Sprintf("| **校验状态** | %d/%d 项不一致 |\n", len(v.Mismatches), v.Checks)
+		default:
+			message += fmt.Sprintf("| **校验状态** | 通过（%d 项） |\n", v.Checks)
+		}
+		for _, m := range v.Mismatches {
+			message += fmt.Sprintf("| **%s** | %s 期望≥%d，实际 %d |\n", m.Database, m.Item, m.Expected, m.Actual)
+		}
+		message += "\n---\n\n"
+	}
+
 	if result.Error != nil {
 		message += "### ❌ 恢复失败\n\n"
 		message += fmt.Sprintf("错误信息: %s\n", result.Error.Error())
+		if rb := result.Rollback; rb != nil {
+			switch {
+			case rb.RolledBack:
+				message += "\n已回滚（rolled back）：恢复前的数据已还原，Pod 已重启\n"
+			case rb.Error != "":
+				message += fmt.Sprintf("\n回滚失败: %s\n", rb.Error)
+				message += fmt.Sprintf("恢复前的数据保留在快照 `%s` 中，请手动还原\n", rb.Snapshot)
+			}
+		}
 	} else {
 		message += "### ✅ 恢复操作已完成\n\n"
 	}
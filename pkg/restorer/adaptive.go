@@ -0,0 +1,250 @@
+package restorer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// adaptivePauseUnit pause_seconds 的单位，测试中会缩短
+var adaptivePauseUnit = time.Second
+
+// 并发调整动作
+const (
+	ConcurrencyIncrease = "increase"
+	ConcurrencyDecrease = "decrease"
+	ConcurrencyPause    = "pause"
+)
+
+// ConcurrencyDecision 自适应并发在某个批次开始前的一次调整
+type ConcurrencyDecision struct {
+	// Lane 按数据库并行导入时触发调整的通道
+	Lane         string `json:"lane,omitempty"`
+	Batch        int    `json:"batch"`
+	Action       string `json:"action"`
+	From         int    `json:"from"`
+	To           int    `json:"to"`
+	Reason       string `json:"reason"`
+	PauseSeconds int    `json:"pause_seconds,omitempty"`
+}
+
+// ConcurrencySummary 导入并发汇总，未启用自适应时只有初始并发
+type ConcurrencySummary struct {
+	Adaptive bool `json:"adaptive"`
+	Initial  int  `json:"initial"`
+	Final    int  `json:"final"`
+	// Lowest、Highest 导入过程中使用过的最小、最大并发
+	Lowest        int                   `json:"lowest"`
+	Highest       int                   `json:"highest"`
+	Increases     int                   `json:"increases"`
+	Decreases     int                   `json:"decreases"`
+	Pauses        int                   `json:"pauses"`
+	PausedSeconds int                   `json:"paused_seconds"`
+	Decisions     []ConcurrencyDecision `json:"decisions,omitempty"`
+}
+
+// String 返回并发调整的简要描述，如 "4 → 6（范围 2-6，加 3 次，减 1 次，暂停 0 次共 0s）"
+func (s *ConcurrencySummary) String() string {
+	if s == nil {
+		return ""
+	}
+	if !s.Adaptive {
+		return fmt.Sprintf("%d（固定）", s.Initial)
+	}
+	return fmt.Sprintf("%d → %d（范围 %d-%d，加 %d 次，减 %d 次，暂停 %d 次共 %ds）",
+		s.Initial, s.Final, s.Lowest, s.Highest, s.Increases, s.Decreases, s.Pauses, s.PausedSeconds)
+}
+
+// batchStats 一个批次的导入情况，作为下一批次并发调整的输入
+type batchStats struct {
+	files  int
+	failed int
+	// attempts 导入尝试次数，retries 其中遇到可重试错误的次数
+	attempts int
+	retries  int
+	// availableMB 批次结束后 Pod 的可用内存，-1 表示未知
+	availableMB int
+	// restarted 批次期间容器发生过重启
+	restarted bool
+}
+
+// newBatchStats 汇总已完成批次的导入记录。不可重试的错误会立即返回，
+// 因此除最后一次失败外的每次尝试都遇到了可重试错误
+func newBatchStats(records []*FileRecord) batchStats {
+	stats := batchStats{files: len(records), availableMB: -1}
+	for _, record := range records {
+		stats.attempts += record.Attempts
+		if record.Attempts > 1 {
+			stats.retries += record.Attempts - 1
+		}
+		if record.Status == FileFailed {
+			stats.failed++
+			if record.ErrorClass == ErrorClassRegionNotReady {
+				stats.retries++
+			}
+		}
+	}
+	return stats
+}
+
+// concurrencyController 逐批调整导入并发：内存和成功率健康时增加，
+// 内存不足、容器重启或可重试错误激增时减少或暂停。多个导入通道由同一个控制器调整，
+// 但每个通道各自按该值并发导入，总并发为通道数 × 并发值
+type concurrencyController struct {
+	mu        sync.Mutex
+	cfg       config.AdaptiveConfig
+	batchSize int
+	current   int
+	highest   int
+	// restarts 最近一次观察到的容器重启次数，-1 表示未知
+	restarts int32
+	summary  *ConcurrencySummary
+}
+
+func newConcurrencyController(cfg *config.ImportConfig) *concurrencyController {
+	return &concurrencyController{
+		cfg:       cfg.Adaptive,
+		batchSize: cfg.BatchSize,
+		current:   cfg.Concurrency,
+		highest:   cfg.MaxAdaptiveConcurrency(),
+		restarts:  -1,
+		summary: &ConcurrencySummary{
+			Adaptive: cfg.Adaptive.Enabled,
+			Initial:  cfg.Concurrency,
+			Final:    cfg.Concurrency,
+			Lowest:   cfg.Concurrency,
+			Highest:  cfg.Concurrency,
+		},
+	}
+}
+
+func (c *concurrencyController) adaptive() bool {
+	return c.cfg.Enabled
+}
+
+func (c *concurrencyController) concurrency() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+// restarted 记录当前的容器重启次数，返回与上次观察相比是否发生了重启。
+// 多个通道中只有第一个观察到变化的通道会得到 true
+func (c *concurrencyController) restarted(current int32) bool {
+	if current < 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.restarts
+	c.restarts = current
+	return previous >= 0 && current != previous
+}
+
+// nextBatchSize 下一批次的文件数，自适应时不少于当前并发，否则增加的并发用不上
+func (c *concurrencyController) nextBatchSize() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cfg.Enabled {
+		return max(c.batchSize, c.current)
+	}
+	return c.batchSize
+}
+
+// decide 根据通道 lane 上一批次的情况决定批次 batch 的并发，返回开始前需要暂停的时间
+func (c *concurrencyController) decide(lane string, batch int, stats batchStats) time.Duration {
+	if !c.cfg.Enabled {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from := c.current
+	pause := time.Duration(c.cfg.PauseSeconds) * adaptivePauseUnit
+	decision := ConcurrencyDecision{Lane: lane, Batch: batch, From: from}
+	memoryKnown := stats.availableMB >= 0
+
+	switch {
+	case stats.restarted:
+		c.current = c.cfg.MinConcurrency
+		decision.Action = ConcurrencyPause
+		decision.Reason = "容器发生重启"
+	case memoryKnown && stats.availableMB < c.cfg.CriticalMemoryMB:
+		c.current = c.cfg.MinConcurrency
+		decision.Action = ConcurrencyPause
+		decision.Reason = fmt.Sprintf("可用内存 %dMB 低于 %dMB", stats.availableMB, c.cfg.CriticalMemoryMB)
+	case memoryKnown && stats.availableMB < c.cfg.LowMemoryMB:
+		c.current = max(c.cfg.MinConcurrency, from/2)
+		decision.Action = ConcurrencyDecrease
+		decision.Reason = fmt.Sprintf("可用内存 %dMB 低于 %dMB", stats.availableMB, c.cfg.LowMemoryMB)
+	case stats.attempts > 0 && stats.retries*100 >= c.cfg.RetryErrorPercent*stats.attempts:
+		c.current = max(c.cfg.MinConcurrency, from/2)
+		decision.Action = ConcurrencyDecrease
+		decision.Reason = fmt.Sprintf("可重试错误 %d 次，占导入尝试 %d 次的 %d%% 以上", stats.retries, stats.attempts, c.cfg.RetryErrorPercent)
+	case memoryKnown && stats.availableMB >= c.cfg.HealthyMemoryMB && stats.failed == 0 && stats.files > 0:
+		c.current = min(c.highest, from+1)
+		decision.Action = ConcurrencyIncrease
+		decision.Reason = fmt.Sprintf("可用内存 %dMB，上一批次 %d 个文件全部成功", stats.availableMB, stats.files)
+	default:
+		return 0
+	}
+
+	if decision.Action != ConcurrencyPause {
+		pause = 0
+		if c.current == from {
+			return 0
+		}
+	}
+	decision.To = c.current
+	if pause > 0 {
+		decision.PauseSeconds = c.cfg.PauseSeconds
+	}
+	c.record(decision)
+	return pause
+}
+
+func (c *concurrencyController) record(decision ConcurrencyDecision) {
+	s := c.summary
+	switch decision.Action {
+	case ConcurrencyIncrease:
+		s.Increases++
+	case ConcurrencyDecrease:
+		s.Decreases++
+	case ConcurrencyPause:
+		s.Pauses++
+		s.PausedSeconds += decision.PauseSeconds
+		if decision.To < decision.From {
+			s.Decreases++
+		}
+	}
+	s.Final = decision.To
+	s.Lowest = min(s.Lowest, decision.To)
+	s.Highest = max(s.Highest, decision.To)
+	s.Decisions = append(s.Decisions, decision)
+
+	fields := []zap.Field{
+		zap.Int("batch", decision.Batch),
+		zap.String("action", decision.Action),
+		zap.Int("from", decision.From),
+		zap.Int("to", decision.To),
+		zap.String("reason", decision.Reason),
+	}
+	if decision.Lane != "" {
+		fields = append(fields, zap.String("lane", decision.Lane))
+	}
+	if decision.PauseSeconds > 0 {
+		fields = append(fields, zap.Int("pause_seconds", decision.PauseSeconds))
+	}
+	switch decision.Action {
+	case ConcurrencyIncrease:
+		logger.Info("提高导入并发", fields...)
+	case ConcurrencyDecrease:
+		logger.Warn("降低导入并发", fields...)
+	default:
+		logger.Warn("暂停导入", fields...)
+	}
+}
@@ -0,0 +1,138 @@
+package restorer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+)
+
+func newAdaptiveImportConfig() *config.ImportConfig {
+	return &config.ImportConfig{
+		Concurrency: 4,
+		BatchSize:   3,
+		Adaptive: config.AdaptiveConfig{
+			Enabled:           true,
+			MinConcurrency:    1,
+			MaxConcurrency:    6,
+			HealthyMemoryMB:   4096,
+			LowMemoryMB:       2048,
+			CriticalMemoryMB:  1024,
+			RetryErrorPercent: 20,
+			PauseSeconds:      30,
+		},
+	}
+}
+
+func TestConcurrencyControllerDecide(t *testing.T) {
+	tests := []struct {
+		name   string
+		stats  batchStats
+		want   int
+		action string
+		pause  bool
+	}{
+		{name: "healthy", stats: batchStats{files: 4, attempts: 4, availableMB: 8192}, want: 5, action: ConcurrencyIncrease},
+		{name: "failed file holds", stats: batchStats{files: 4, failed: 1, attempts: 4, availableMB: 8192}, want: 4},
+		{name: "unknown memory holds", stats: batchStats{files: 4, attempts: 4, availableMB: -1}, want: 4},
+		{name: "between thresholds holds", stats: batchStats{files: 4, attempts: 4, availableMB: 3000}, want: 4},
+		{name: "low memory", stats: batchStats{files: 4, attempts: 4, availableMB: 1500}, want: 2, action: ConcurrencyDecrease},
+		{name: "critical memory", stats: batchStats{files: 4, attempts: 4, availableMB: 512}, want: 1, action: ConcurrencyPause, pause: true},
+		{name: "retry spike", stats: batchStats{files: 4, attempts: 10, retries: 2, availableMB: 8192}, want: 2, action: ConcurrencyDecrease},
+		{name: "container restart", stats: batchStats{files: 4, attempts: 4, availableMB: 8192, restarted: true}, want: 1, action: ConcurrencyPause, pause: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConcurrencyController(newAdaptiveImportConfig())
+			pause := c.decide("", 2, tt.stats)
+			if c.concurrency() != tt.want || (pause > 0) != tt.pause {
+				t.Fatalf("expected concurrency %d (pause %v), got %d (pause %s)", tt.want, tt.pause, c.concurrency(), pause)
+			}
+			if tt.action == "" {
+				if len(c.summary.Decisions) != 0 {
+					t.Fatalf("expected no decision, got %+v", c.summary.Decisions)
+				}
+				return
+			}
+			if len(c.summary.Decisions) != 1 || c.summary.Decisions[0].Action != tt.action || c.summary.Decisions[0].Reason == "" {
+				t.Fatalf("unexpected decisions %+v", c.summary.Decisions)
+			}
+		})
+	}
+}
+
+func TestConcurrencyControllerBounds(t *testing.T) {
+	c := newConcurrencyController(newAdaptiveImportConfig())
+	for batch := 2; batch < 10; batch++ {
+		c.decide("", batch, batchStats{files: 6, attempts: 6, availableMB: 8192})
+	}
+	if c.concurrency() != 6 || c.nextBatchSize() != 6 {
+		t.Fatalf("expected concurrency capped at 6 with matching batch size, got %d/%d", c.concurrency(), c.nextBatchSize())
+	}
+
+	for batch := 10; batch < 15; batch++ {
+		c.decide("", batch, batchStats{files: 6, attempts: 6, availableMB: 1500})
+	}
+	s := c.summary
+	if c.concurrency() != 1 || s.Lowest != 1 || s.Highest != 6 || s.Increases != 2 || s.Decreases != 2 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+
+	cfg := newAdaptiveImportConfig()
+	cfg.Adaptive.Enabled = false
+	fixed := newConcurrencyController(cfg)
+	if pause := fixed.decide("", 2, batchStats{availableMB: 100, restarted: true}); pause != 0 || fixed.concurrency() != 4 || fixed.nextBatchSize() != 3 {
+		t.Fatalf("disabled controller should keep fixed concurrency")
+	}
+}
+
+func TestImportAdaptiveConcurrency(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+	r.config.Import.Concurrency = 1
+	r.config.Import.BatchSize = 1
+	r.config.Import.Adaptive = newAdaptiveImportConfig().Adaptive
+	r.config.Import.Adaptive.MaxConcurrency = 3
+	pod.availableMB = 8192
+
+	var files []string
+	for i := 0; i < 5; i++ {
+		file := fmt.Sprintf("%s/sequence/root.energy/1/2920/177000000000%d-%d-0-0.tsfile", r.liveDataDir(), i, i+1)
+		pod.files[file] = true
+		files = append(files, file)
+	}
+
+	// 第 3 个批次开始前发现容器重启
+	restarts := []int32{0, 0, 1}
+	importer := r.newImporter()
+	importer.SetRestartCounter(func(ctx context.Context) (int32, error) {
+		n := restarts[0]
+		if len(restarts) > 1 {
+			restarts = restarts[1:]
+		}
+		return n, nil
+	})
+
+	result, err := importer.Import(context.Background(), files)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if result.SuccessCount != len(files) {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+
+	s := result.Concurrency
+	want := []string{ConcurrencyIncrease, ConcurrencyPause, ConcurrencyIncrease}
+	if s == nil || len(s.Decisions) != len(want) {
+		t.Fatalf("unexpected concurrency decisions %+v", s.Decisions)
+	}
+	for i, action := range want {
+		if s.Decisions[i].Action != action {
+			t.Fatalf("expected decision %d to be %s, got %+v", i, action, s.Decisions[i])
+		}
+	}
+	if s.Initial != 1 || s.Final != 2 || s.Highest != 2 || s.Pauses != 1 || s.PausedSeconds != 30 {
+		t.Fatalf("unexpected concurrency summary %+v", s)
+	}
+}
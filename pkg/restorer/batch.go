@@ -24,14 +24,14 @@ type Batch struct {
 
 // Batcher 批次处理器
 type Batcher struct {
-	executor    *k8s.Executor
+	executor    k8s.CommandExecutor
 	config      *config.Config
 	batchSize   int
 	concurrency int
 }
 
 // NewBatcher 创建批次处理器
-func NewBatcher(executor *k8s.Executor, cfg *config.Config) *Batcher {
+func NewBatcher(executor k8s.CommandExecutor, cfg *config.Config) *Batcher {
 	return &Batcher{
 		executor:    executor,
 		config:      cfg,
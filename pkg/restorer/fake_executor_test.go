@@ -0,0 +1,676 @@
+package restorer
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
+)
+
+var _ k8s.CommandExecutor = (*fakePod)(nil)
+
+// errExit 模拟命令以非 0 状态退出
+var errExit = errors.New("command terminated with exit code 1")
+
+// sqlReply 预设的 SQL 应答，按顺序消费
+type sqlReply struct {
+	prefix string
+	stdout string
+	err    error
+}
+
+// fakePod 模拟目标 Pod：内存文件系统、备份下载/解压以及 start-cli.sh 的 SQL 应答
+type fakePod struct {
+	mu sync.Mutex
+
+	// files Pod 中的文件路径
+	files map[string]bool
+	// backups 可通过 wget 下载的备份 URL -> 归档内的相对路径
+	backups map[string][]string
+	// archives 已下载的归档路径 -> 归档内的相对路径
+	archives map[string][]string
+	// contents 文件路径 -> 内容，sha256sum/md5sum 按内容计算
+	contents map[string][]byte
+	// backupContents 备份 URL -> 下载后的文件内容，未设置时由归档条目生成
+	backupContents map[string][]byte
+	// missingTools command -v 检查不到的命令，pigz 默认不可用
+	missingTools map[string]bool
+	// hasPigz 为 true 时 command -v pigz 检查通过
+	hasPigz bool
+	// extractErrors tar 参数 -> 解压失败时 tar 的输出，模拟损坏或被截断的归档
+	extractErrors map[string]string
+	// streamErr 不为空时流式解压失败
+	streamErr error
+	// availableMB free -m 返回的可用内存，为 0 时返回 2048
+	availableMB int
+	// diskKB df 返回的可用空间（KB），为 0 时返回 100GB
+	diskKB int64
+
+	databases map[string]bool
+	series    map[string]map[string]string
+	// regionWarmup 前 N 次查询 Region 时返回空结果，模拟 Region 尚未就绪
+	regionWarmup int
+	replies      []sqlReply
+
+	commands []string
+	sqls     []string
+	// extractFlags 每次解压使用的 tar 参数
+	extractFlags []string
+	// onSQL 执行每条 SQL 前调用，用于在恢复过程中注入事件
+	onSQL func(sql string)
+	// liveDir DataNode 的在线数据目录：删除数据库时删除其中该数据库的文件，load 把 tsfile 加载到其中
+	liveDir string
+}
+
+func newFakePod() *fakePod {
+	return &fakePod{
+		files:          make(map[string]bool),
+		backups:        make(map[string][]string),
+		archives:       make(map[string][]string),
+		contents:       make(map[string][]byte),
+		backupContents: make(map[string][]byte),
+		missingTools:   make(map[string]bool),
+		extractErrors:  make(map[string]string),
+		databases:      make(map[string]bool),
+		series:         make(map[string]map[string]string),
+	}
+}
+
+// addBackup 注册可下载的备份归档
+func (p *fakePod) addBackup(url string, entries ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.backups[url] = entries
+}
+
+// replySQL 为下一条以 prefix 开头的 SQL 预设应答
+func (p *fakePod) replySQL(prefix, stdout string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.replies = append(p.replies, sqlReply{prefix: prefix, stdout: stdout, err: err})
+}
+
+// executedSQL 返回以 prefix 开头的已执行 SQL
+func (p *fakePod) executedSQL(prefix string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var matched []string
+	for _, sql := range p.sqls {
+		if strings.HasPrefix(sql, prefix) {
+			matched = append(matched, sql)
+		}
+	}
+	return matched
+}
+
+func (p *fakePod) hasFile(filePath string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.files[filePath]
+}
+
+func (p *fakePod) Exec(ctx context.Context, command []string) (string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+	if len(command) != 3 || command[0] != "sh" || command[1] != "-c" {
+		return "", "", fmt.Errorf("unexpected command: %q", command)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.commands = append(p.commands, command[2])
+	return p.shell(command[2])
+}
+
+func (p *fakePod) ExecStream(ctx context.Context, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if stdin == nil {
+		out, errOut, err := p.Exec(ctx, command)
+		io.WriteString(stdout, out)
+		io.WriteString(stderr, errOut)
+		return err
+	}
+
+	// 只模拟流式解压：tar 从 stdin 读取归档，归档内容为逐行的条目
+	data, readErr := io.ReadAll(stdin)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.commands = append(p.commands, command[2])
+	m := streamTarPattern.FindStringSubmatch(command[2])
+	if m == nil {
+		io.WriteString(stderr, "sh: unexpected command: "+command[2])
+		return errExit
+	}
+	if readErr != nil || p.streamErr != nil {
+		io.WriteString(stderr, "tar: Unexpected EOF in archive")
+		return errExit
+	}
+	p.extractFlags = append(p.extractFlags, strings.TrimSpace(m[1]))
+	for _, entry := range strings.Fields(string(data)) {
+		p.files[path.Join(m[2], entry)] = true
+	}
+	return nil
+}
+
+func (p *fakePod) ExecSimple(ctx context.Context, command string) (string, error) {
+	stdout, stderr, err := p.Exec(ctx, []string{"sh", "-c", command})
+	if err != nil {
+		return stdout, fmt.Errorf("%w: %s", err, stderr)
+	}
+	return stdout, nil
+}
+
+func (p *fakePod) FileExists(ctx context.Context, filePath string) (bool, error) {
+	return p.hasFile(filePath), nil
+}
+
+func (p *fakePod) FileSize(ctx context.Context, filePath string) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.files[filePath] {
+		return 0, nil
+	}
+	if content, ok := p.contents[filePath]; ok {
+		return int64(len(content)), nil
+	}
+	return fakeFileSize, nil
+}
+
+var (
+	cliCommandPattern = regexp.MustCompile(`(?s)^\S*start-cli\.sh .* -e "(.*)"$`)
+	wgetPattern       = regexp.MustCompile(`^wget -q -O '([^']+)' '([^']+)'$`)
+	tarPattern        = regexp.MustCompile(`^cd (\S+) && (?:if \(set -o pipefail\) 2>/dev/null; then set -o pipefail; fi && cat ([^|]+) \| )?tar --overwrite (-I 'pigz -p 4' -xf|-xzf|-I zstd -xf|-I lz4 -xf|-xf) (\S+) -C (\S+) 2>&1$`)
+	streamTarPattern  = regexp.MustCompile(`^tar --overwrite (.*)-xf - -C (\S+)$`)
+	odPattern         = regexp.MustCompile(`^od -An -tx1 (?:-v -j (\d+) )?-N (\d+) '([^']+)'$`)
+	findTsfilePattern = regexp.MustCompile(`^find (\S+) -name '\*\.tsfile' -type f$`)
+	findPattern       = regexp.MustCompile(`^find (\S+) -type f \| `)
+	cleanDirPattern   = regexp.MustCompile(`^mkdir -p (\S+) && rm -rf `)
+	rmTreePattern     = regexp.MustCompile(`^rm -rf ('?/[^' ]+'?(?: '?/[^' ]+'?)*)$`)
+	linkPattern       = regexp.MustCompile(`^if \[ -e '([^']+)' \] && \[ ! -e '([^']+)' \]; then rm -rf '[^']+' && mkdir -p '[^']+' && cp -al '[^']+' '[^']+' && mv '[^']+' '[^']+'; fi$`)
+	rmFilePattern     = regexp.MustCompile(`^rm -f ('?[^' ]+'?(?: '?[^' ]+'?)*)$`)
+	checksumPattern   = regexp.MustCompile(`^(sha256|md5)sum '([^']+)'$`)
+	statPattern       = regexp.MustCompile(`^stat -c '%s %n' (.+)$`)
+	testExecPattern   = regexp.MustCompile(`^test -x '([^']+)'$`)
+	whichPattern      = regexp.MustCompile(`^for t in ([^;]+); do command -v "\$t" >/dev/null 2>&1 \|\| echo "\$t"; done$`)
+	dfPattern         = regexp.MustCompile(`^d='([^']+)'; while .* df -Pk "\$d"$`)
+)
+
+// fakeFileSize fakePod 中所有文件的大小
+const fakeFileSize = 1024
+
+// shell 解释恢复流程会用到的 shell 命令，未知命令返回错误以暴露行为变化
+func (p *fakePod) shell(cmd string) (string, string, error) {
+	if m := cliCommandPattern.FindStringSubmatch(cmd); m != nil {
+		return p.cli(strings.ReplaceAll(m[1], `\"`, `"`))
+	}
+
+	switch {
+	case strings.HasPrefix(cmd, "rm -rf /iotdb/data/backup_before_restore"):
+		return "", "", nil
+	case strings.HasPrefix(cmd, "command -v pigz"):
+		if !p.hasPigz {
+			return "", "", errExit
+		}
+		return "", "", nil
+	case strings.HasPrefix(cmd, "command -v "):
+		if p.missingTools[strings.Fields(cmd)[2]] {
+			return "", "", errExit
+		}
+		return "", "", nil
+	case strings.HasPrefix(cmd, "ls -lh "):
+		return "1.0G\n", "", nil
+	case strings.HasPrefix(cmd, "free -m"):
+		if p.availableMB > 0 {
+			return fmt.Sprintf("%d\n", p.availableMB), "", nil
+		}
+		return "2048\n", "", nil
+	}
+
+	if m := wgetPattern.FindStringSubmatch(cmd); m != nil {
+		// 预签名地址按去掉查询参数后的地址匹配
+		entries, ok := p.backups[strings.SplitN(m[2], "?", 2)[0]]
+		if !ok {
+			return "", "wget: server returned error: HTTP/1.1 404 Not Found", errExit
+		}
+		p.files[m[1]] = true
+		p.archives[m[1]] = entries
+		p.contents[m[1]] = fakeArchiveContent(entries)
+		if content, ok := p.backupContents[strings.SplitN(m[2], "?", 2)[0]]; ok {
+			p.contents[m[1]] = content
+		}
+		return "", "", nil
+	}
+	if m := tarPattern.FindStringSubmatch(cmd); m != nil {
+		// 分卷通过 cat 拼接，解压结果为所有分卷条目的并集
+		sources := []string{m[4]}
+		if m[2] != "" {
+			sources = strings.Fields(m[2])
+		}
+		p.extractFlags = append(p.extractFlags, m[3])
+		for _, source := range sources {
+			archive := path.Join(m[1], source)
+			if _, ok := p.archives[archive]; !ok || !p.files[archive] {
+				if m[2] != "" {
+					return "cat: " + source + ": No such file or directory", "", errExit
+				}
+				return "tar: " + source + ": Cannot open: No such file or directory", "", errExit
+			}
+		}
+		if output, ok := p.extractErrors[m[3]]; ok {
+			return output, "", errExit
+		}
+		for _, source := range sources {
+			for _, entry := range p.archives[path.Join(m[1], source)] {
+				p.files[path.Join(m[5], entry)] = true
+			}
+		}
+		return "", "", nil
+	}
+	if m := odPattern.FindStringSubmatch(cmd); m != nil {
+		if !p.files[m[3]] {
+			return "", "od: " + m[3] + ": No such file or directory", errExit
+		}
+		offset, _ := strconv.Atoi(m[1])
+		n, _ := strconv.Atoi(m[2])
+		content := p.contents[m[3]]
+		content = content[min(offset, len(content)):]
+		if len(content) > n {
+			content = content[:n]
+		}
+		var out strings.Builder
+		for i, b := range content {
+			if i%16 == 0 && i > 0 {
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, " %02x", b)
+		}
+		return out.String() + "\n", "", nil
+	}
+	if m := findTsfilePattern.FindStringSubmatch(cmd); m != nil {
+		return p.listFiles(m[1], ".tsfile"), "", nil
+	}
+	if m := findPattern.FindStringSubmatch(cmd); m != nil {
+		return p.listFiles(m[1], ""), "", nil
+	}
+	if m := cleanDirPattern.FindStringSubmatch(cmd); m != nil {
+		p.removeTree(m[1])
+		return "", "", nil
+	}
+	if m := rmTreePattern.FindStringSubmatch(cmd); m != nil {
+		for _, dir := range strings.Fields(m[1]) {
+			p.removeTree(strings.Trim(dir, "'"))
+		}
+		return "", "", nil
+	}
+	if m := whichPattern.FindStringSubmatch(cmd); m != nil {
+		var out strings.Builder
+		for _, tool := range strings.Fields(m[1]) {
+			if tool == "pigz" || p.missingTools[tool] {
+				out.WriteString(tool + "\n")
+			}
+		}
+		return out.String(), "", nil
+	}
+	if m := testExecPattern.FindStringSubmatch(cmd); m != nil {
+		if p.missingTools[m[1]] {
+			return "", "", errExit
+		}
+		return "", "", nil
+	}
+	if dfPattern.MatchString(cmd) {
+		available := p.diskKB
+		if available == 0 {
+			available = 100 << 20
+		}
+		return fmt.Sprintf("Filesystem 1024-blocks Used Available Capacity Mounted on\noverlay 209715200 1048576 %d 1%% /\n", available), "", nil
+	}
+	if m := linkPattern.FindStringSubmatch(cmd); m != nil {
+		if p.listFiles(m[2], "") == "" {
+			p.copyTree(m[1], m[2])
+		}
+		return "", "", nil
+	}
+	if m := statPattern.FindStringSubmatch(cmd); m != nil {
+		var out strings.Builder
+		var err error
+		for _, quoted := range strings.Fields(m[1]) {
+			file := strings.Trim(quoted, "'")
+			if !p.files[file] {
+				err = errExit
+				continue
+			}
+			fmt.Fprintf(&out, "%d %s\n", fakeFileSize, file)
+		}
+		return out.String(), "", err
+	}
+	if m := rmFilePattern.FindStringSubmatch(cmd); m != nil {
+		for _, quoted := range strings.Fields(m[1]) {
+			file := strings.Trim(quoted, "'")
+			delete(p.files, file)
+			delete(p.archives, file)
+			delete(p.contents, file)
+		}
+		return "", "", nil
+	}
+	if m := checksumPattern.FindStringSubmatch(cmd); m != nil {
+		if !p.files[m[2]] {
+			return "", m[1] + "sum: " + m[2] + ": No such file or directory", errExit
+		}
+		var sum []byte
+		if m[1] == "md5" {
+			digest := md5.Sum(p.contents[m[2]])
+			sum = digest[:]
+		} else {
+			digest := sha256.Sum256(p.contents[m[2]])
+			sum = digest[:]
+		}
+		return fmt.Sprintf("%x  %s\n", sum, m[2]), "", nil
+	}
+
+	return "", "sh: unexpected command: " + cmd, errExit
+}
+
+// Exists 模拟对备份源的 HEAD 请求
+func (p *fakePod) Exists(ctx context.Context, url string) (bool, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	url = strings.SplitN(url, "?", 2)[0]
+	entries, ok := p.backups[url]
+	if !ok {
+		return false, 0, nil
+	}
+	if content, ok := p.backupContents[url]; ok {
+		return true, int64(len(content)), nil
+	}
+	return true, int64(len(fakeArchiveContent(entries))), nil
+}
+
+// fakeResolver 按 fakePod 中注册的备份 URL 模拟列举备份源
+type fakeResolver struct {
+	pod    *fakePod
+	config *config.BackupConfig
+}
+
+func (f *fakeResolver) Resolve(ctx context.Context, baseURL, podName, timestamp string) (*downloader.BackupObject, error) {
+	filenames, err := f.config.Filenames()
+	if err != nil {
+		return nil, err
+	}
+
+	f.pod.mu.Lock()
+	defer f.pod.mu.Unlock()
+	prefix := strings.TrimSuffix(baseURL, "/") + "/"
+	var objects []downloader.ObjectInfo
+	for url := range f.pod.backups {
+		if strings.HasPrefix(url, prefix) {
+			objects = append(objects, downloader.ObjectInfo{Name: strings.TrimPrefix(url, prefix), Size: fakeFileSize})
+		}
+	}
+	return downloader.BackupAt(downloader.GroupBackups(objects, baseURL, filenames.Matcher(podName)), timestamp)
+}
+
+// fakeArchiveContent fakePod 中下载的归档内容，由归档条目决定
+func fakeArchiveContent(entries []string) []byte {
+	return []byte(strings.Join(entries, "\n"))
+}
+
+func (p *fakePod) listFiles(dir, suffix string) string {
+	var matched []string
+	for file := range p.files {
+		if strings.HasPrefix(file, dir+"/") && strings.HasSuffix(file, suffix) {
+			matched = append(matched, file)
+		}
+	}
+	sort.Strings(matched)
+	if len(matched) == 0 {
+		return ""
+	}
+	return strings.Join(matched, "\n") + "\n"
+}
+
+// copyTree 模拟 cp -al：src 下的文件连同内容复制到 dst 下
+func (p *fakePod) copyTree(src, dst string) {
+	for file := range p.files {
+		if !strings.HasPrefix(file, src+"/") {
+			continue
+		}
+		copied := dst + strings.TrimPrefix(file, src)
+		p.files[copied] = true
+		if content, ok := p.contents[file]; ok {
+			p.contents[copied] = content
+		}
+		if entries, ok := p.archives[file]; ok {
+			p.archives[copied] = entries
+		}
+	}
+}
+
+func (p *fakePod) removeTree(dir string) {
+	for file := range p.files {
+		if strings.HasPrefix(file, dir+"/") {
+			delete(p.files, file)
+		}
+	}
+}
+
+var (
+	insertPattern = regexp.MustCompile(`^insert into (\S+?)\s*\(\s*time\s*,\s*(\w+)\s*\) values\s*\(\s*(\d+)\s*,\s*(\d+)\s*\)$`)
+	selectPattern = regexp.MustCompile(`^select (\w+) from (\S+) where time = (\d+)$`)
+	countPattern  = regexp.MustCompile(`^count (timeseries|devices) (\S+)\.\*\*$`)
+	statsPattern  = regexp.MustCompile(`^select count\((\w+)\), max_time\((\w+)\) from (\S+)$`)
+	loadPattern   = regexp.MustCompile(`^load '([^']+)'`)
+)
+
+const cliSuccess = "Msg: The statement is executed successfully.\n"
+
+// cli 模拟 start-cli.sh -e 的输出
+func (p *fakePod) cli(sql string) (string, string, error) {
+	p.sqls = append(p.sqls, sql)
+	if p.onSQL != nil {
+		p.onSQL(sql)
+	}
+
+	for i, reply := range p.replies {
+		if strings.HasPrefix(sql, reply.prefix) {
+			p.replies = append(p.replies[:i], p.replies[i+1:]...)
+			return reply.stdout, "", reply.err
+		}
+	}
+
+	fields := strings.Fields(sql)
+	switch {
+	case sql == "flush":
+		return cliSuccess, "", nil
+	case sql == "show databases":
+		var rows [][]string
+		for _, db := range sortedKeys(p.databases) {
+			rows = append(rows, []string{db})
+		}
+		return renderCLITable([]string{"Database"}, rows), "", nil
+	case sql == "show schema regions" || sql == "show data regions":
+		var rows [][]string
+		if p.regionWarmup > 0 {
+			if sql == "show data regions" {
+				p.regionWarmup--
+			}
+		} else {
+			for i, db := range sortedKeys(p.databases) {
+				rows = append(rows, []string{fmt.Sprint(i + 1), db, "Running"})
+			}
+		}
+		return renderCLITable([]string{"RegionId", "Database", "Status"}, rows), "", nil
+	case strings.HasPrefix(sql, "delete database ") && len(fields) == 3:
+		if !p.databases[fields[2]] {
+			return "Msg: 508: Path [" + fields[2] + "] does not exist\n", "", errExit
+		}
+		delete(p.databases, fields[2])
+		if p.liveDir != "" {
+			p.removeTree(p.liveDir + "/sequence/" + fields[2])
+			p.removeTree(p.liveDir + "/unsequence/" + fields[2])
+		}
+		for series := range p.series {
+			if strings.HasPrefix(series, fields[2]+".") {
+				delete(p.series, series)
+			}
+		}
+		return cliSuccess, "", nil
+	case strings.HasPrefix(sql, "create database ") && (len(fields) == 3 || fields[3] == "with"):
+		if p.databases[fields[2]] {
+			return "Msg: 501: " + fields[2] + " has already been created as database\n", "", errExit
+		}
+		p.databases[fields[2]] = true
+		return cliSuccess, "", nil
+	case strings.HasPrefix(sql, "set ttl to ") && len(fields) == 5:
+		if !p.databases[fields[3]] {
+			return "Msg: 508: Path [" + fields[3] + "] does not exist\n", "", errExit
+		}
+		return cliSuccess, "", nil
+	case strings.HasPrefix(sql, "create timeseries ") && len(fields) >= 3:
+		if _, ok := p.series[fields[2]]; ok {
+			return "Msg: 508: Path [" + fields[2] + "] already exists\n", "", errExit
+		}
+		if p.databaseOf(fields[2]) == "" {
+			return "Msg: 509: " + fields[2] + " is not a legal path\n", "", errExit
+		}
+		p.series[fields[2]] = make(map[string]string)
+		return cliSuccess, "", nil
+	}
+
+	if m := insertPattern.FindStringSubmatch(sql); m != nil {
+		series := m[1] + "." + m[2]
+		values, ok := p.series[series]
+		if !ok {
+			if p.databaseOf(series) == "" {
+				return "Msg: 509: " + series + " is not a legal path\n", "", errExit
+			}
+			values = make(map[string]string)
+			p.series[series] = values
+		}
+		values[m[3]] = m[4]
+		return cliSuccess, "", nil
+	}
+	if m := selectPattern.FindStringSubmatch(sql); m != nil {
+		series := m[2] + "." + m[1]
+		value, ok := p.series[series][m[3]]
+		if !ok {
+			return renderCLITable([]string{"Time", series}, nil), "", nil
+		}
+		return renderCLITable([]string{"Time", series}, [][]string{{m[3], value}}), "", nil
+	}
+	if m := countPattern.FindStringSubmatch(sql); m != nil {
+		matched := make(map[string]bool)
+		for series := range p.series {
+			if !strings.HasPrefix(series, m[2]+".") {
+				continue
+			}
+			if m[1] == "devices" {
+				series = series[:strings.LastIndex(series, ".")]
+			}
+			matched[series] = true
+		}
+		return renderCLITable([]string{"count(" + m[1] + ")"}, [][]string{{fmt.Sprint(len(matched))}}), "", nil
+	}
+	if m := statsPattern.FindStringSubmatch(sql); m != nil {
+		series := m[3] + "." + m[1]
+		count, maxTime := 0, "null"
+		for ts := range p.series[series] {
+			count++
+			if maxTime == "null" || len(ts) > len(maxTime) || (len(ts) == len(maxTime) && ts > maxTime) {
+				maxTime = ts
+			}
+		}
+		return renderCLITable(
+			[]string{"count(" + series + ")", "max_time(" + series + ")"},
+			[][]string{{fmt.Sprint(count), maxTime}},
+		), "", nil
+	}
+	if m := loadPattern.FindStringSubmatch(sql); m != nil {
+		if !p.files[m[1]] {
+			return "Msg: 305: TsFile " + m[1] + " does not exist\n", "", errExit
+		}
+		// 按 sequence/unsequence 之后的相对路径放入在线数据目录
+		if p.liveDir != "" && !strings.HasPrefix(m[1], p.liveDir+"/") {
+			for _, dir := range []string{"/sequence/", "/unsequence/"} {
+				if i := strings.Index(m[1], dir); i >= 0 {
+					p.files[p.liveDir+m[1][i:]] = true
+					break
+				}
+			}
+		}
+		return cliSuccess, "", nil
+	}
+
+	return "Msg: 700: unsupported statement in fake: " + sql + "\n", "", errExit
+}
+
+func (p *fakePod) databaseOf(series string) string {
+	for db := range p.databases {
+		if strings.HasPrefix(series, db+".") {
+			return db
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renderCLITable 按 start-cli.sh 的格式输出查询结果
+func renderCLITable(headers []string, rows [][]string) string {
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		widths[i] = len(header)
+	}
+	for _, row := range rows {
+		for i, value := range row {
+			if len(value) > widths[i] {
+				widths[i] = len(value)
+			}
+		}
+	}
+
+	var b strings.Builder
+	border := func() {
+		b.WriteString("+")
+		for _, width := range widths {
+			b.WriteString(strings.Repeat("-", width) + "+")
+		}
+		b.WriteString("\n")
+	}
+	line := func(values []string) {
+		b.WriteString("|")
+		for i, value := range values {
+			b.WriteString(fmt.Sprintf("%*s|", widths[i], value))
+		}
+		b.WriteString("\n")
+	}
+
+	border()
+	line(headers)
+	border()
+	for _, row := range rows {
+		line(row)
+	}
+	border()
+	fmt.Fprintf(&b, "Total line number = %d\n", len(rows))
+	return b.String()
+}
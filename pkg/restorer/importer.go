@@ -2,136 +2,337 @@ package restorer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/iotdb"
 	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
 	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
 	"go.uber.org/zap"
 )
 
-const importRetryBaseDelay = 10 * time.Second
+// importRetryBaseDelay 导入重试的基础等待时间，测试中会缩短
+var importRetryBaseDelay = 10 * time.Second
 
 // ImportResult 导入结果
 type ImportResult struct {
 	TotalFiles   int
-	SuccessCount int
+	SuccessCount int // 包含续传时跳过的已导入文件
 	FailedCount  int
+	SkippedCount int
 	Duration     time.Duration
+	// Files 每个文件的导入记录，顺序与输入一致
+	Files []*FileRecord
+	// Concurrency 导入并发及自适应调整的汇总
+	Concurrency *ConcurrencySummary
+}
+
+// FileStatus 文件导入状态
+type FileStatus string
+
+const (
+	FileImported FileStatus = "success"
+	FileFailed   FileStatus = "failed"
+	FileSkipped  FileStatus = "skipped" // 续传时跳过的已导入文件
+)
+
+// 导入失败的错误分类
+const (
+	ErrorClassRegionNotReady = "region_not_ready"
+	ErrorClassReadOnly       = "readonly"
+	ErrorClassFileMissing    = "file_missing"
+	ErrorClassFileCorrupted  = "file_corrupted"
+	ErrorClassTimeout        = "timeout"
+	ErrorClassCanceled       = "canceled"
+	ErrorClassOther          = "other"
+)
+
+// FileRecord 单个文件的导入记录
+type FileRecord struct {
+	Path       string        `json:"path"`
+	Size       int64         `json:"size"`
+	Attempts   int           `json:"attempts"`
+	Duration   time.Duration `json:"-"`
+	Status     FileStatus    `json:"status"`
+	ErrorClass string        `json:"error_class,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	// Devices、StartTime、EndTime 导入前读取的元数据摘要，未检查的文件为空
+	Devices   int   `json:"devices,omitempty"`
+	StartTime int64 `json:"start_time,omitempty"`
+	EndTime   int64 `json:"end_time,omitempty"`
+}
+
+// FailedFiles 返回导入失败的记录
+func FailedFiles(records []*FileRecord) []*FileRecord {
+	var failed []*FileRecord
+	for _, record := range records {
+		if record.Status == FileFailed {
+			failed = append(failed, record)
+		}
+	}
+	return failed
 }
 
 // RegionReadyFunc 在导入重试前确认 Region 已就绪。
 type RegionReadyFunc func(context.Context) error
 
+// ImportCheckpoint 记录已导入文件，用于断点续传。
+type ImportCheckpoint interface {
+	FileImported(file string) bool
+	MarkFileImported(ctx context.Context, file string) error
+}
+
+// RestartCountFunc 返回目标 Pod 容器的重启次数，用于自适应并发发现导入期间的重启
+type RestartCountFunc func(context.Context) (int32, error)
+
 // Importer tsfile 导入器
 type Importer struct {
-	executor    *k8s.Executor
-	config      *config.Config
-	regionReady RegionReadyFunc
+	executor     k8s.CommandExecutor
+	sql          SQLClient
+	config       *config.Config
+	regionReady  RegionReadyFunc
+	checkpoint   ImportCheckpoint
+	restartCount RestartCountFunc
 }
 
 // NewImporter 创建导入器
-func NewImporter(executor *k8s.Executor, cfg *config.Config, regionReady RegionReadyFunc) *Importer {
+func NewImporter(executor k8s.CommandExecutor, sql SQLClient, cfg *config.Config, regionReady RegionReadyFunc) *Importer {
 	return &Importer{
 		executor:    executor,
+		sql:         sql,
 		config:      cfg,
 		regionReady: regionReady,
 	}
 }
 
+// SetCheckpoint 设置导入检查点，已导入的文件会被跳过，新导入成功的文件会被记录
+func (im *Importer) SetCheckpoint(checkpoint ImportCheckpoint) {
+	im.checkpoint = checkpoint
+}
+
+// SetRestartCounter 设置容器重启次数的查询函数，自适应并发在重启后暂停并降到最小并发
+func (im *Importer) SetRestartCounter(restartCount RestartCountFunc) {
+	im.restartCount = restartCount
+}
+
 // Import 导入文件列表
 func (im *Importer) Import(ctx context.Context, files []string) (*ImportResult, error) {
 	startTime := time.Now()
-	totalFiles := len(files)
+	allFiles := len(files)
+
+	records := make([]*FileRecord, len(files))
+	for i, file := range files {
+		records[i] = &FileRecord{Path: file}
+	}
+
+	var skippedCount int
+	pending := records
+	if im.checkpoint != nil {
+		pending = make([]*FileRecord, 0, len(records))
+		for _, record := range records {
+			if im.checkpoint.FileImported(record.Path) {
+				record.Status = FileSkipped
+				skippedCount++
+				continue
+			}
+			pending = append(pending, record)
+		}
+		if skippedCount > 0 {
+			logger.Info("跳过之前已导入的文件",
+				zap.Int("skipped", skippedCount),
+				zap.Int("pending", len(pending)),
+			)
+		}
+	}
+	totalFiles := len(pending)
+
+	if im.config.Import.Order == "partition" {
+		pending = orderRecords(pending)
+	}
+	lanes := []importLane{{records: pending}}
+	if im.config.Import.Lanes > 1 {
+		lanes = splitLanes(pending)
+	}
 
 	logger.Info("开始导入 tsfile 文件",
 		zap.Int("total_files", totalFiles),
 		zap.Int("concurrency", im.config.Import.Concurrency),
 		zap.Int("batch_size", im.config.Import.BatchSize),
 		zap.Int("retry_count", im.config.Import.RetryCount),
+		zap.String("order", im.config.Import.Order),
+		zap.Int("lanes", len(lanes)),
 	)
 
-	sem := make(chan struct{}, im.config.Import.Concurrency)
-	var successCount int64
-	var failedCount int64
+	controller := newConcurrencyController(&im.config.Import)
+	if controller.adaptive() {
+		controller.restarted(im.currentRestarts(ctx))
+	}
+
+	// 每个通道内分批导入，最多 lanes 个数据库同时导入
+	laneSem := make(chan struct{}, max(im.config.Import.Lanes, 1))
+	laneErrs := make([]error, len(lanes))
 	var wg sync.WaitGroup
+	for i, lane := range lanes {
+		wg.Add(1)
+		go func(i int, lane importLane) {
+			defer wg.Done()
+			laneSem <- struct{}{}
+			defer func() { <-laneSem }()
+			laneErrs[i] = im.importLane(ctx, lane, controller)
+		}(i, lane)
+	}
+	wg.Wait()
+	if err := errors.Join(laneErrs...); err != nil {
+		return nil, err
+	}
 
-	batchSize := im.config.Import.BatchSize
-	for i := 0; i < totalFiles; i += batchSize {
-		end := i + batchSize
-		if end > totalFiles {
-			end = totalFiles
+	var successCount, failedCount int
+	for _, record := range pending {
+		switch record.Status {
+		case FileImported:
+			successCount++
+		case FileFailed:
+			failedCount++
 		}
+	}
+
+	duration := time.Since(startTime)
+	result := &ImportResult{
+		TotalFiles:   allFiles,
+		SuccessCount: successCount + skippedCount,
+		FailedCount:  failedCount,
+		SkippedCount: skippedCount,
+		Duration:     duration,
+		Files:        records,
+		Concurrency:  controller.summary,
+	}
 
-		batch := files[i:end]
-		batchNum := i/batchSize + 1
-		totalBatches := (totalFiles + batchSize - 1) / batchSize
+	logger.Info("所有文件导入完成",
+		zap.Int("total_files", result.TotalFiles),
+		zap.Int("success_count", result.SuccessCount),
+		zap.Int("failed_count", result.FailedCount),
+		zap.Int("skipped_count", result.SkippedCount),
+		zap.String("concurrency", result.Concurrency.String()),
+		zap.Duration("duration", duration),
+	)
 
-		logger.Info("处理批次",
+	return result, nil
+}
+
+// importLane 按顺序分批导入一个通道的文件，批次之间由并发控制调整并发或暂停
+func (im *Importer) importLane(ctx context.Context, lane importLane, controller *concurrencyController) error {
+	var stats batchStats
+	total := len(lane.records)
+	for i, batchNum := 0, 1; i < total; batchNum++ {
+		availableMB := im.availableMemory(ctx)
+		if controller.adaptive() && batchNum > 1 {
+			stats.availableMB = availableMB
+			stats.restarted = controller.restarted(im.currentRestarts(ctx))
+			if pause := controller.decide(lane.database, batchNum, stats); pause > 0 {
+				if err := downloader.SleepContext(ctx, pause); err != nil {
+					return err
+				}
+				if stats.restarted && im.regionReady != nil {
+					if err := im.regionReady(ctx); err != nil {
+						logger.Warn("容器重启后等待 Region 就绪失败，继续导入", zap.Error(err))
+					}
+				}
+			}
+		}
+
+		concurrency := controller.concurrency()
+		batchSize := controller.nextBatchSize()
+		end := min(i+batchSize, total)
+		batch := lane.records[i:end]
+		// 自适应时批次大小会变化，总批次数为按当前批次大小的估算
+		totalBatches := batchNum - 1 + (total-i+batchSize-1)/batchSize
+
+		fields := []zap.Field{
 			zap.Int("batch", batchNum),
 			zap.Int("total_batches", totalBatches),
 			zap.Int("batch_size", len(batch)),
+			zap.Int("concurrency", concurrency),
 			zap.Int("progress", i),
-		)
-
-		im.logMemoryUsage(ctx)
-
-		for _, file := range batch {
-			wg.Add(1)
-			go func(f string) {
-				defer wg.Done()
-
-				sem <- struct{}{}
-				defer func() { <-sem }()
-
-				if err := im.importSingleFile(ctx, f); err != nil {
-					atomic.AddInt64(&failedCount, 1)
-					logger.Error("导入失败",
-						zap.String("file", filepath.Base(f)),
-						zap.Error(err),
-					)
-				} else {
-					atomic.AddInt64(&successCount, 1)
-					logger.Debug("导入成功", zap.String("file", filepath.Base(f)))
-				}
-			}(file)
 		}
+		if lane.database != "" {
+			fields = append(fields, zap.String("lane", lane.database))
+		}
+		logger.Info("处理批次", fields...)
 
-		wg.Wait()
+		im.statFileSizes(ctx, batch)
+		im.importBatch(ctx, batch, concurrency)
+		stats = newBatchStats(batch)
+		i = end
 
-		if i+batchSize < totalFiles && im.config.Import.BatchPause {
+		// 自适应时由并发控制决定是否暂停
+		if i < total && im.config.Import.BatchPause && !controller.adaptive() {
 			logger.Info("等待系统释放内存...",
 				zap.Int("pause_seconds", im.config.Import.BatchDelay),
 			)
 			time.Sleep(time.Duration(im.config.Import.BatchDelay) * time.Second)
 		}
 	}
+	return nil
+}
 
-	duration := time.Since(startTime)
-	result := &ImportResult{
-		TotalFiles:   totalFiles,
-		SuccessCount: int(successCount),
-		FailedCount:  int(failedCount),
-		Duration:     duration,
+// importBatch 以 concurrency 个并发导入一个批次，结果写入各文件的记录
+func (im *Importer) importBatch(ctx context.Context, batch []*FileRecord, concurrency int) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, record := range batch {
+		// 按批次内的顺序获取并发名额，保证文件按排好的顺序开始导入
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(record *FileRecord) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fileStart := time.Now()
+			attempts, err := im.importSingleFile(ctx, record.Path)
+			record.Attempts = attempts
+			record.Duration = time.Since(fileStart)
+
+			if err != nil {
+				record.Status = FileFailed
+				record.ErrorClass = classifyImportError(ctx, err)
+				record.Error = err.Error()
+				logger.Error("导入失败",
+					zap.String("file", filepath.Base(record.Path)),
+					zap.String("error_class", record.ErrorClass),
+					zap.Int("attempts", attempts),
+					zap.Error(err),
+				)
+			} else {
+				record.Status = FileImported
+				logger.Debug("导入成功", zap.String("file", filepath.Base(record.Path)))
+				im.markImported(ctx, record.Path)
+			}
+		}(record)
 	}
+	wg.Wait()
+}
 
-	logger.Info("所有文件导入完成",
-		zap.Int("total_files", result.TotalFiles),
-		zap.Int("success_count", result.SuccessCount),
-		zap.Int("failed_count", result.FailedCount),
-		zap.Duration("duration", duration),
-	)
-
-	return result, nil
+func (im *Importer) markImported(ctx context.Context, file string) {
+	if im.checkpoint == nil {
+		return
+	}
+	if err := im.checkpoint.MarkFileImported(ctx, file); err != nil {
+		logger.Warn("记录导入检查点失败",
+			zap.String("file", filepath.Base(file)),
+			zap.Error(err),
+		)
+	}
 }
 
-// importSingleFile 导入单个文件，并对 Region 未就绪问题重试。
-func (im *Importer) importSingleFile(ctx context.Context, filePath string) error {
+// importSingleFile 导入单个文件，并对 Region 未就绪问题重试，返回尝试次数。
+func (im *Importer) importSingleFile(ctx context.Context, filePath string) (int, error) {
 	filename := filepath.Base(filePath)
 	maxAttempts := im.config.Import.RetryCount
 	if maxAttempts <= 0 {
@@ -149,7 +350,7 @@ func (im *Importer) importSingleFile(ctx context.Context, filePath string) error
 		if err := im.runLoadCommand(ctx, filePath); err != nil {
 			lastErr = err
 			if !isRetryableImportError(err) || attempt == maxAttempts {
-				return err
+				return attempt, err
 			}
 
 			logger.Warn("检测到 Region 未就绪，准备重试导入",
@@ -162,7 +363,7 @@ func (im *Importer) importSingleFile(ctx context.Context, filePath string) error
 				if readyErr := im.regionReady(ctx); readyErr != nil {
 					lastErr = fmt.Errorf("等待 Region 就绪失败: %w", readyErr)
 					if attempt == maxAttempts {
-						return lastErr
+						return attempt, lastErr
 					}
 				}
 			}
@@ -171,30 +372,21 @@ func (im *Importer) importSingleFile(ctx context.Context, filePath string) error
 			continue
 		}
 
-		return nil
+		return attempt, nil
 	}
 
-	return lastErr
+	return maxAttempts, lastErr
 }
 
 func (im *Importer) runLoadCommand(ctx context.Context, filePath string) error {
-	cmd := fmt.Sprintf("%s -h %s -e \"load '%s' verify=false\"",
-		im.config.IoTDB.CLIPath,
-		im.config.IoTDB.Host,
-		filePath,
-	)
-
-	stdout, stderr, err := im.executor.Exec(ctx, []string{"sh", "-c", cmd})
+	res, err := im.sql.Exec(ctx, fmt.Sprintf("load '%s' verify=false", filePath))
 	if err != nil {
-		return fmt.Errorf("执行命令失败: %w: %s", err, strings.TrimSpace(stderr))
+		return err
 	}
 
-	if !containsSuccess(stdout) && !containsSuccess(stderr) {
-		msg := strings.TrimSpace(stderr)
-		if msg == "" {
-			msg = strings.TrimSpace(stdout)
-		}
-		return fmt.Errorf("导入失败: %s", msg)
+	// cli 后端执行成功时仍需确认输出中包含成功标记
+	if res.Output != "" && !containsSuccess(res.Output) {
+		return fmt.Errorf("导入失败: %s", res.Output)
 	}
 
 	return nil
@@ -224,14 +416,41 @@ func isRetryableImportError(err error) bool {
 		}
 	}
 
-	if strings.Contains(message, "status code: 701") &&
-		(strings.Contains(message, "schema") || strings.Contains(message, "region")) {
+	is701 := strings.Contains(message, "status code: 701")
+	if code, ok := iotdb.StatusCode(err); ok && code == 701 {
+		is701 = true
+	}
+	if is701 && (strings.Contains(message, "schema") || strings.Contains(message, "region")) {
 		return true
 	}
 
 	return false
 }
 
+// classifyImportError 将导入失败归类，便于在报告中按原因筛选
+func classifyImportError(ctx context.Context, err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, context.Canceled) || ctx.Err() != nil:
+		return ErrorClassCanceled
+	case isRetryableImportError(err):
+		return ErrorClassRegionNotReady
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "readonly") || strings.Contains(message, "read-only"):
+		return ErrorClassReadOnly
+	case strings.Contains(message, "does not exist") || strings.Contains(message, "no such file"):
+		return ErrorClassFileMissing
+	case strings.Contains(message, "broken") || strings.Contains(message, "corrupt") ||
+		strings.Contains(message, "incompatible") || strings.Contains(message, "magic string"):
+		return ErrorClassFileCorrupted
+	}
+	return ErrorClassOther
+}
+
 // containsSuccess 检查输出是否包含成功标记。
 func containsSuccess(output string) bool {
 	message := strings.ToLower(output)
@@ -239,17 +458,56 @@ func containsSuccess(output string) bool {
 		strings.Contains(message, "success")
 }
 
-// logMemoryUsage 记录内存使用情况
-func (im *Importer) logMemoryUsage(ctx context.Context) {
+// statFileSizes 批量获取文件大小，失败时大小保持为 0
+func (im *Importer) statFileSizes(ctx context.Context, records []*FileRecord) {
+	paths := make([]string, 0, len(records))
+	byPath := make(map[string]*FileRecord, len(records))
+	for _, record := range records {
+		paths = append(paths, shellQuote(record.Path))
+		byPath[record.Path] = record
+	}
+
+	// 部分文件不存在时 stat 返回非 0，但其余文件的输出仍然有效
+	output, _, _ := im.executor.Exec(ctx, []string{"sh", "-c", "stat -c '%s %n' " + strings.Join(paths, " ")})
+	for _, line := range splitLines(output) {
+		sizeText, path, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		size, err := strconv.ParseInt(sizeText, 10, 64)
+		if record := byPath[path]; record != nil && err == nil {
+			record.Size = size
+		}
+	}
+}
+
+// availableMemory 返回 Pod 的可用内存（MB），获取失败时返回 -1
+func (im *Importer) availableMemory(ctx context.Context) int {
 	cmd := "free -m | grep Mem | awk '{print $7}'"
 	output, err := im.executor.ExecSimple(ctx, cmd)
 	if err != nil {
 		logger.Debug("获取内存使用失败", zap.Error(err))
-		return
+		return -1
 	}
 
 	var freeMem int
-	if _, err := fmt.Sscanf(output, "%d", &freeMem); err == nil {
-		logger.Debug("当前可用内存", zap.Int("mb", freeMem))
+	if _, err := fmt.Sscanf(output, "%d", &freeMem); err != nil {
+		logger.Debug("解析可用内存失败", zap.String("output", output))
+		return -1
+	}
+	logger.Debug("当前可用内存", zap.Int("mb", freeMem))
+	return freeMem
+}
+
+// currentRestarts 返回容器重启次数，无法获取时返回 -1
+func (im *Importer) currentRestarts(ctx context.Context) int32 {
+	if im.restartCount == nil {
+		return -1
+	}
+	restarts, err := im.restartCount(ctx)
+	if err != nil {
+		logger.Debug("获取容器重启次数失败", zap.Error(err))
+		return -1
 	}
+	return restarts
 }
@@ -0,0 +1,191 @@
+package restorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"github.com/vnnox/iotdb-restore-tool/pkg/selector"
+	"github.com/vnnox/iotdb-restore-tool/pkg/tsfile"
+	"go.uber.org/zap"
+)
+
+// inspectResult 导入前检查的结果
+type inspectResult struct {
+	// selected 需要导入的文件，顺序与输入一致
+	selected []string
+	// corrupt 已损坏、不导入的文件
+	corrupt []*FileRecord
+	// infos 已读取元数据的文件
+	infos map[string]*tsfile.FileInfo
+}
+
+// inspectTsFiles 在导入前读取 tsfile 元数据：损坏的文件不导入并记录原因，
+// 按设备和时间范围跳过恢复范围之外的文件。无法判断的文件保守地保留导入。
+func (r *IoTDBRestorer) inspectTsFiles(ctx context.Context, files []string) (*inspectResult, error) {
+	result := &inspectResult{infos: make(map[string]*tsfile.FileInfo)}
+
+	var pending []string
+	for _, file := range files {
+		if r.needsInspection(file) {
+			pending = append(pending, file)
+		}
+	}
+	if len(pending) == 0 {
+		result.selected = files
+		return result, nil
+	}
+	logger.Info("导入前检查 tsfile 元数据",
+		zap.String("mode", r.config.Import.Inspect),
+		zap.Int("files", len(pending)),
+	)
+
+	infos := make([]*tsfile.FileInfo, len(pending))
+	errs := make([]error, len(pending))
+	sem := make(chan struct{}, r.config.Import.Concurrency)
+	var wg sync.WaitGroup
+	for i, file := range pending {
+		wg.Add(1)
+		go func(i int, file string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			infos[i], errs[i] = r.readPodTsFile(ctx, file)
+		}(i, file)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]bool)
+	var unreadable, outOfRange int
+	for i, file := range pending {
+		err := errs[i]
+		switch {
+		case errors.Is(err, tsfile.ErrCorrupt):
+			excluded[file] = true
+			result.corrupt = append(result.corrupt, &FileRecord{
+				Path:       file,
+				Size:       r.podFileSize(ctx, file),
+				Status:     FileFailed,
+				ErrorClass: ErrorClassFileCorrupted,
+				Error:      err.Error(),
+			})
+			logger.Error("tsfile 已损坏，跳过导入", zap.String("file", file), zap.Error(err))
+		case err != nil:
+			unreadable++
+			logger.Warn("无法读取 tsfile 元数据，保留导入", zap.String("file", file), zap.Error(err))
+		default:
+			result.infos[file] = infos[i]
+			if !r.matchFileInfo(infos[i]) {
+				excluded[file] = true
+				outOfRange++
+			}
+		}
+	}
+
+	for _, file := range files {
+		if !excluded[file] {
+			result.selected = append(result.selected, file)
+		}
+	}
+
+	logger.Info("tsfile 元数据检查完成",
+		zap.Int("inspected", len(pending)),
+		zap.Int("corrupt", len(result.corrupt)),
+		zap.Int("out_of_range", outOfRange),
+		zap.Int("unreadable", unreadable),
+	)
+	return result, nil
+}
+
+// needsInspection 文件是否需要在导入前读取元数据，续传时已导入的文件不再检查
+func (r *IoTDBRestorer) needsInspection(file string) bool {
+	if r.journal.FileImported(file) {
+		return false
+	}
+	switch r.config.Import.Inspect {
+	case "always":
+		return true
+	case "auto":
+		// 只在目录结构无法确定文件是否在恢复范围内时读取
+		loc, err := selector.ParsePath(file)
+		if err != nil {
+			return len(r.filter.PathPrefixes) > 0 || r.filter.HasTimeRange()
+		}
+		return r.filter.NeedsMetadata(loc, r.config.IoTDB.TimePartitionInterval)
+	}
+	return false
+}
+
+// matchFileInfo 文件中是否有设备的数据符合恢复范围
+func (r *IoTDBRestorer) matchFileInfo(info *tsfile.FileInfo) bool {
+	if r.filter.IsZero() {
+		return true
+	}
+	for _, dev := range info.Devices {
+		if r.filter.MatchDevice(dev.ID, time.UnixMilli(dev.StartTime), time.UnixMilli(dev.EndTime)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readPodTsFile 按区间读取 Pod 内 tsfile 的文件头和尾部元数据
+func (r *IoTDBRestorer) readPodTsFile(ctx context.Context, file string) (*tsfile.FileInfo, error) {
+	size, err := r.executor.FileSize(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("获取文件大小失败: %w", err)
+	}
+	return tsfile.Read(&podFile{ctx: ctx, executor: r.executor, path: file}, size)
+}
+
+func (r *IoTDBRestorer) podFileSize(ctx context.Context, file string) int64 {
+	size, err := r.executor.FileSize(ctx, file)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// apply 将损坏文件的记录和元数据摘要合入导入结果
+func (res *inspectResult) apply(result *ImportResult) {
+	for _, record := range result.Files {
+		if info, ok := res.infos[record.Path]; ok {
+			record.Devices = len(info.Devices)
+			record.StartTime = info.StartTime
+			record.EndTime = info.EndTime
+		}
+	}
+	result.Files = append(result.Files, res.corrupt...)
+	result.TotalFiles += len(res.corrupt)
+	result.FailedCount += len(res.corrupt)
+}
+
+// podFile 通过 od 按区间读取 Pod 内的文件，实现 io.ReaderAt
+type podFile struct {
+	ctx      context.Context
+	executor k8s.CommandExecutor
+	path     string
+}
+
+func (f *podFile) ReadAt(p []byte, off int64) (int, error) {
+	// -v 输出所有行，否则 od 会把重复的行折叠为 *
+	cmd := fmt.Sprintf("od -An -tx1 -v -j %d -N %d '%s'", off, len(p), f.path)
+	output, err := f.executor.ExecSimple(f.ctx, cmd)
+	if err != nil {
+		return 0, fmt.Errorf("读取 %s 失败: %w", filepath.Base(f.path), err)
+	}
+	n := copy(p, parseHexDump(output))
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
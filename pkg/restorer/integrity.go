@@ -0,0 +1,79 @@
+package restorer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// resolveChecksum 获取备份文件的期望校验和；auto 模式下获取失败只记录警告，required 模式下返回错误
+func (r *IoTDBRestorer) resolveChecksum(ctx context.Context, backupURL string) (*downloader.Checksum, error) {
+	mode := r.config.Backup.Checksum
+	if mode == "off" {
+		return nil, nil
+	}
+
+	d, err := downloader.NewDownloader(&r.config.Backup)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := d.ResolveChecksum(ctx, backupURL)
+	if err != nil {
+		if mode == "required" {
+			return nil, fmt.Errorf("获取备份校验和失败: %w", err)
+		}
+		logger.Warn("获取备份校验和失败，跳过完整性校验", zap.String("url", backupURL), zap.Error(err))
+		return nil, nil
+	}
+	if sum == nil {
+		if mode == "required" {
+			return nil, fmt.Errorf("备份文件缺少校验和（.sha256、Content-MD5 或 ETag）: %s", backupURL)
+		}
+		logger.Warn("备份文件没有可用的校验和，跳过完整性校验", zap.String("url", backupURL))
+		return nil, nil
+	}
+
+	logger.Info("已获取备份校验和",
+		zap.String("checksum", sum.String()),
+		zap.String("source", sum.Source),
+	)
+	return sum, nil
+}
+
+// podChecksum 在 Pod 内计算文件校验和
+func (r *IoTDBRestorer) podChecksum(ctx context.Context, remotePath, algorithm string) (string, error) {
+	output, err := r.executor.ExecSimple(ctx, fmt.Sprintf("%ssum '%s'", algorithm, remotePath))
+	if err != nil {
+		return "", fmt.Errorf("Pod 内计算校验和失败: %w", err)
+	}
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("Pod 内计算校验和无输出: %s", remotePath)
+	}
+	return fields[0], nil
+}
+
+// verifyPodChecksum 在 Pod 内复核文件校验和，不一致时删除文件并返回 *downloader.ChecksumError
+func (r *IoTDBRestorer) verifyPodChecksum(ctx context.Context, remotePath string, expected *downloader.Checksum) error {
+	actual, err := r.podChecksum(ctx, remotePath, expected.Algorithm)
+	if err != nil {
+		return err
+	}
+	if err := expected.Verify(remotePath, actual); err != nil {
+		if _, _, rmErr := r.executor.Exec(ctx, []string{"sh", "-c", fmt.Sprintf("rm -f '%s'", remotePath)}); rmErr != nil {
+			logger.Warn("删除校验失败的文件失败", zap.String("path", remotePath), zap.Error(rmErr))
+		}
+		return err
+	}
+
+	logger.Info("Pod 内文件校验通过",
+		zap.String("path", remotePath),
+		zap.String("checksum", expected.String()),
+		zap.String("source", expected.Source),
+	)
+	return nil
+}
@@ -0,0 +1,138 @@
+package restorer
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+)
+
+// newChecksumRestorer 返回从测试服务器获取备份校验和的恢复器，sidecar 为空时 .sha256 返回 404
+func newChecksumRestorer(t *testing.T, mode, sidecar string) (*IoTDBRestorer, *fakePod) {
+	t.Helper()
+
+	r, pod, _, url := newTestRestorerWithServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, ".sha256") {
+			if sidecar == "" {
+				http.NotFound(w, req)
+				return
+			}
+			w.Write([]byte(sidecar))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	r.config.Backup.BaseURL = url
+	r.config.Backup.Checksum = mode
+	pod.addBackup(url+"/"+testBackupFile(), testTsFiles...)
+	return r, pod
+}
+
+func testBackupFile() string {
+	return fmt.Sprintf("emsau_%s_%s.tar.gz", testPodName, testTimestamp)
+}
+
+func testBackupSidecar() string {
+	return fmt.Sprintf("%x  %s\n", sha256.Sum256(fakeArchiveContent(testTsFiles)), testBackupFile())
+}
+
+func TestRestoreVerifiesBackupChecksum(t *testing.T) {
+	r, pod := newChecksumRestorer(t, "required", testBackupSidecar())
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if result.SuccessCount != len(testTsFiles) {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+
+	checked := false
+	for _, cmd := range pod.commands {
+		if cmd == fmt.Sprintf("sha256sum '%s/%s'", podBackupPath, testBackupFile()) {
+			checked = true
+		}
+	}
+	if !checked {
+		t.Fatalf("expected backup checksum to be verified in pod, commands: %v", pod.commands)
+	}
+}
+
+func TestRestoreBackupChecksumMismatch(t *testing.T) {
+	r, pod := newChecksumRestorer(t, "auto", strings.Repeat("0", 64)+"  "+testBackupFile()+"\n")
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if !errors.Is(err, downloader.ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if result.FailedPhase != PhaseDownload {
+		t.Fatalf("expected failed phase %s, got %s", PhaseDownload, result.FailedPhase)
+	}
+	if pod.hasFile(podBackupPath + "/" + testBackupFile()) {
+		t.Fatalf("corrupted backup should be removed from pod")
+	}
+	if len(loadedFiles(pod)) != 0 {
+		t.Fatalf("no file should be loaded after integrity failure")
+	}
+}
+
+func TestRestoreChecksumModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		wantErr bool
+	}{
+		{name: "auto proceeds without checksum", mode: "auto"},
+		{name: "off skips checksum", mode: "off"},
+		{name: "required fails without checksum", mode: "required", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, pod := newChecksumRestorer(t, tt.mode, "")
+
+			result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+			if tt.wantErr {
+				if err == nil || result.FailedPhase != PhaseDownload {
+					t.Fatalf("expected download failure, got %v (phase %s)", err, result.FailedPhase)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("restore failed: %v", err)
+			}
+			for _, cmd := range pod.commands {
+				if strings.HasPrefix(cmd, "sha256sum ") || strings.HasPrefix(cmd, "md5sum ") {
+					t.Fatalf("no checksum available, unexpected command: %s", cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreRedownloadsCorruptedExistingBackup(t *testing.T) {
+	r, pod := newChecksumRestorer(t, "auto", testBackupSidecar())
+
+	remotePath := podBackupPath + "/" + testBackupFile()
+	pod.files[remotePath] = true
+	pod.contents[remotePath] = []byte("truncated")
+
+	if _, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp}); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	downloaded := false
+	for _, cmd := range pod.commands {
+		if strings.HasPrefix(cmd, "wget ") {
+			downloaded = true
+		}
+	}
+	if !downloaded {
+		t.Fatalf("expected corrupted backup to be downloaded again")
+	}
+}
@@ -0,0 +1,102 @@
+package restorer
+
+import (
+	"sort"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/selector"
+)
+
+// importLane 导入通道：通道内的文件按顺序分批导入，不同通道之间并行
+type importLane struct {
+	// database 通道对应的数据库，不按数据库拆分时为空
+	database string
+	records  []*FileRecord
+}
+
+// orderedRecord 排序用的文件位置，无法解析路径的文件 ok 为 false
+type orderedRecord struct {
+	record *FileRecord
+	loc    selector.Location
+	ok     bool
+}
+
+func locateRecords(records []*FileRecord) []orderedRecord {
+	located := make([]orderedRecord, len(records))
+	for i, record := range records {
+		loc, err := selector.ParsePath(record.Path)
+		located[i] = orderedRecord{record: record, loc: loc, ok: err == nil}
+	}
+	return located
+}
+
+// less 按数据库、时间分区、Region 排序，同一分区内先顺序文件后乱序文件，
+// 再按文件名中的时间戳和版本号排序；无法解析路径的文件按路径排在最后
+func (a orderedRecord) less(b orderedRecord) bool {
+	if a.ok != b.ok {
+		return a.ok
+	}
+	if !a.ok {
+		return a.record.Path < b.record.Path
+	}
+	x, y := a.loc, b.loc
+	switch {
+	case x.Database != y.Database:
+		return x.Database < y.Database
+	case x.Partition != y.Partition:
+		return x.Partition < y.Partition
+	case x.Region != y.Region:
+		return x.Region < y.Region
+	case x.Sequence != y.Sequence:
+		return x.Sequence
+	case x.Time != y.Time:
+		return x.Time < y.Time
+	case x.Version != y.Version:
+		return x.Version < y.Version
+	}
+	return a.record.Path < b.record.Path
+}
+
+// orderRecords 返回按时间先后排列的导入顺序，不修改 records
+func orderRecords(records []*FileRecord) []*FileRecord {
+	located := locateRecords(records)
+	sort.SliceStable(located, func(i, j int) bool {
+		return located[i].less(located[j])
+	})
+
+	ordered := make([]*FileRecord, len(located))
+	for i, l := range located {
+		ordered[i] = l.record
+	}
+	return ordered
+}
+
+// splitLanes 按数据库拆分导入通道，通道内保持 records 的顺序。
+// 通道按数据库名排列，无法解析路径的文件单独一个通道排在最后
+func splitLanes(records []*FileRecord) []importLane {
+	byDatabase := make(map[string]*importLane)
+	var names []string
+	var unparsed []*FileRecord
+	for _, l := range locateRecords(records) {
+		if !l.ok {
+			unparsed = append(unparsed, l.record)
+			continue
+		}
+		lane, ok := byDatabase[l.loc.Database]
+		if !ok {
+			lane = &importLane{database: l.loc.Database}
+			byDatabase[l.loc.Database] = lane
+			names = append(names, l.loc.Database)
+		}
+		lane.records = append(lane.records, l.record)
+	}
+
+	sort.Strings(names)
+	lanes := make([]importLane, 0, len(names)+1)
+	for _, name := range names {
+		lanes = append(lanes, *byDatabase[name])
+	}
+	if len(unparsed) > 0 {
+		lanes = append(lanes, importLane{records: unparsed})
+	}
+	return lanes
+}
@@ -0,0 +1,139 @@
+package restorer
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func newRecords(paths ...string) []*FileRecord {
+	records := make([]*FileRecord, len(paths))
+	for i, path := range paths {
+		records[i] = &FileRecord{Path: path}
+	}
+	return records
+}
+
+func recordPaths(records []*FileRecord) []string {
+	paths := make([]string, len(records))
+	for i, record := range records {
+		paths[i] = record.Path
+	}
+	return paths
+}
+
+func TestOrderRecords(t *testing.T) {
+	records := newRecords(
+		"/data/unsequence/root.energy/1/2920/1766100000000-9-0-0.tsfile",
+		"/data/broken.tsfile",
+		"/data/sequence/root.energy/1/2921/1766700000000-3-0-0.tsfile",
+		"/data/sequence/root.emsplus/2/2921/1766700000000-1-0-0.tsfile",
+		"/data/sequence/root.energy/1/2920/1766100000000-2-0-0.tsfile",
+		"/data/sequence/root.energy/3/2920/1766000000000-1-0-0.tsfile",
+		"/data/sequence/root.energy/1/2920/1766100000000-1-0-0.tsfile",
+		"/data/sequence/root.energy/1/2920/1766050000000-7-0-0.tsfile",
+	)
+	want := []string{
+		"/data/sequence/root.emsplus/2/2921/1766700000000-1-0-0.tsfile",
+		"/data/sequence/root.energy/1/2920/1766050000000-7-0-0.tsfile",
+		"/data/sequence/root.energy/1/2920/1766100000000-1-0-0.tsfile",
+		"/data/sequence/root.energy/1/2920/1766100000000-2-0-0.tsfile",
+		"/data/unsequence/root.energy/1/2920/1766100000000-9-0-0.tsfile",
+		"/data/sequence/root.energy/3/2920/1766000000000-1-0-0.tsfile",
+		"/data/sequence/root.energy/1/2921/1766700000000-3-0-0.tsfile",
+		"/data/broken.tsfile",
+	}
+
+	got := recordPaths(orderRecords(records))
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected order:\n%s", strings.Join(got, "\n"))
+	}
+	if records[0].Path != "/data/unsequence/root.energy/1/2920/1766100000000-9-0-0.tsfile" {
+		t.Fatalf("orderRecords should not modify its input")
+	}
+}
+
+func TestSplitLanes(t *testing.T) {
+	records := newRecords(
+		"/data/sequence/root.energy/1/2920/1766100000000-1-0-0.tsfile",
+		"/data/broken.tsfile",
+		"/data/sequence/root.emsplus/2/2920/1766100000000-1-0-0.tsfile",
+		"/data/sequence/root.energy/1/2921/1766700000000-2-0-0.tsfile",
+	)
+
+	lanes := splitLanes(records)
+	if len(lanes) != 3 {
+		t.Fatalf("expected 3 lanes, got %+v", lanes)
+	}
+	want := []struct {
+		database string
+		files    int
+	}{{"root.emsplus", 1}, {"root.energy", 2}, {"", 1}}
+	for i, w := range want {
+		if lanes[i].database != w.database || len(lanes[i].records) != w.files {
+			t.Fatalf("lane %d: expected %s with %d files, got %s with %v", i, w.database, w.files, lanes[i].database, recordPaths(lanes[i].records))
+		}
+	}
+	if lanes[1].records[0] != records[0] || lanes[1].records[1] != records[3] {
+		t.Fatalf("lane should keep the input order")
+	}
+}
+
+func TestImportOrderAndLanes(t *testing.T) {
+	tests := []struct {
+		name  string
+		order string
+		lanes int
+	}{
+		{name: "partition order", order: "partition", lanes: 1},
+		{name: "database lanes", order: "partition", lanes: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, pod, _ := newTestRestorer(t)
+			r.config.Import.Order = tt.order
+			r.config.Import.Lanes = tt.lanes
+			// 单个通道内逐个导入，加载顺序即导入顺序
+			r.config.Import.Concurrency = 1
+
+			live := r.liveDataDir()
+			files := []string{
+				live + "/sequence/root.energy/1/2921/1766700000000-3-0-0.tsfile",
+				live + "/sequence/root.emsplus/2/2920/1766100000000-1-0-0.tsfile",
+				live + "/sequence/root.energy/1/2920/1766100000000-2-0-0.tsfile",
+				live + "/sequence/root.emsplus/2/2921/1766700000000-2-0-0.tsfile",
+			}
+			for _, file := range files {
+				pod.files[file] = true
+			}
+
+			result, err := r.newImporter().Import(context.Background(), files)
+			if err != nil {
+				t.Fatalf("import failed: %v", err)
+			}
+			if result.SuccessCount != len(files) {
+				t.Fatalf("unexpected import counts: %+v", result)
+			}
+			if strings.Join(recordPaths(result.Files), "\n") != strings.Join(files, "\n") {
+				t.Fatalf("records should keep the input order")
+			}
+
+			// 每个数据库内按时间分区先后导入
+			seen := make(map[string]string)
+			for _, file := range loadedFiles(pod) {
+				loc := strings.Split(strings.TrimPrefix(file, live+"/sequence/"), "/")
+				if previous, ok := seen[loc[0]]; ok && previous > loc[2] {
+					t.Fatalf("partition %s of %s loaded after %s: %v", loc[2], loc[0], previous, loadedFiles(pod))
+				}
+				seen[loc[0]] = loc[2]
+			}
+			if tt.lanes == 1 {
+				loaded := loadedFiles(pod)
+				if !strings.Contains(loaded[0], "root.emsplus") || !strings.Contains(loaded[3], "root.energy") {
+					t.Fatalf("expected databases to be imported one after another, got %v", loaded)
+				}
+			}
+		})
+	}
+}
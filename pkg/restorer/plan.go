@@ -0,0 +1,565 @@
+package restorer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/archive"
+	"github.com/vnnox/iotdb-restore-tool/pkg/journal"
+	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// 估算耗时使用的经验吞吐量，实际耗时取决于网络、磁盘和 IoTDB 的负载
+const (
+	planTransferRate = 50 << 20
+	planExtractRate  = 100 << 20
+	planImportRate   = 20 << 20
+	planRestartTime  = 2 * time.Minute
+)
+
+// 计划中命令的执行位置
+const (
+	TargetPod        = "pod"
+	TargetSourcePod  = "source"
+	TargetSQL        = "sql"
+	TargetLocal      = "local"
+	TargetKubernetes = "kubernetes"
+)
+
+// RestorePlan 干运行生成的执行计划。生成计划只读取状态，不修改任何数据
+type RestorePlan struct {
+	Timestamp string    `json:"timestamp,omitempty"`
+	Target    PlanPod   `json:"target"`
+	Input     PlanInput `json:"input"`
+	// Databases 本次恢复的数据库
+	Databases []string `json:"databases"`
+	// Deletion 删除阶段会删除的数据（安全模式下先创建快照），跳过删除时为空
+	Deletion *PlanDeletion `json:"deletion,omitempty"`
+	// Stream 直连恢复从源 Pod 拉取的数据
+	Stream *PlanStream `json:"stream,omitempty"`
+	// Disk Pod 内各挂载点的可用空间和需要的空间，备份大小未知或 Pod 未就绪时为空
+	Disk  []DiskUsage `json:"disk,omitempty"`
+	Steps []PlanStep  `json:"steps"`
+	// EstimatedSeconds 按经验吞吐量估算的总耗时，备份大小未知时不含下载、解压和导入
+	EstimatedSeconds int64            `json:"estimated_seconds"`
+	Preflight        *PreflightResult `json:"preflight,omitempty"`
+	Warnings         []string         `json:"warnings,omitempty"`
+}
+
+// PlanPod 目标 Pod 的当前状态
+type PlanPod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Node      string `json:"node,omitempty"`
+	Phase     string `json:"phase,omitempty"`
+	Ready     bool   `json:"ready"`
+	// Databases Pod 中当前存在的数据库，Pod 未就绪时为空
+	Databases []string `json:"databases,omitempty"`
+	// LiveDataSize 在线数据目录的大小，0 表示未知
+	LiveDataSize int64 `json:"live_data_size,omitempty"`
+}
+
+// PlanInput 恢复输入
+type PlanInput struct {
+	// Source 备份归档名，直连恢复为 cluster_stream:<namespace>/<pod>
+	Source string   `json:"source,omitempty"`
+	Files  []string `json:"files,omitempty"`
+	Format string   `json:"format,omitempty"`
+	// Size 备份大小（直连恢复为源数据目录大小），0 表示未知
+	Size     int64  `json:"size,omitempty"`
+	Strategy string `json:"strategy"`
+	Checksum string `json:"checksum"`
+}
+
+// PlanDeletion 删除阶段影响的数据
+type PlanDeletion struct {
+	Databases []string `json:"databases,omitempty"`
+	Paths     []string `json:"paths"`
+	// Snapshot 安全模式下删除前创建的快照目录，为空时直接删除
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// PlanStream 直连恢复的源 Pod 和拉取的目录
+type PlanStream struct {
+	SourceNamespace string   `json:"source_namespace"`
+	SourcePod       string   `json:"source_pod"`
+	SourceDataDir   string   `json:"source_data_dir"`
+	Paths           []string `json:"paths"`
+	ArchivePath     string   `json:"archive_path"`
+	StagingDir      string   `json:"staging_dir"`
+}
+
+// PlanStep 计划中的一个阶段
+type PlanStep struct {
+	Phase            Phase         `json:"phase,omitempty"`
+	Title            string        `json:"title"`
+	Commands         []PlanCommand `json:"commands,omitempty"`
+	Notes            []string      `json:"notes,omitempty"`
+	EstimatedSeconds int64         `json:"estimated_seconds,omitempty"`
+}
+
+// PlanCommand 计划执行的命令，Target 为执行位置
+type PlanCommand struct {
+	Target  string `json:"target"`
+	Command string `json:"command"`
+}
+
+// Plan 生成恢复的执行计划：定位备份并通过 HEAD 获取大小，读取目标 Pod 的状态，
+// 列出各阶段将执行的命令、删除的数据、磁盘占用和预计耗时，不修改任何数据
+func (r *IoTDBRestorer) Plan(ctx context.Context, opts RestoreOptions) (*RestorePlan, error) {
+	runID := opts.RunID
+	if runID == "" {
+		runID = journal.NewRunID(time.Now())
+	}
+	// 只在内存中记录，不写入运行日志存储
+	r.journal = journal.NewRecorder(nil, &journal.Journal{RunID: runID, Timestamp: opts.Timestamp})
+
+	if err := r.applyFilter(opts); err != nil {
+		return nil, err
+	}
+
+	plan := &RestorePlan{
+		Timestamp: opts.Timestamp,
+		Databases: r.databaseNames(),
+	}
+	preflight, backup := r.preflight(ctx, opts.Timestamp)
+	plan.Preflight = preflight
+	plan.Target = r.planTarget(ctx)
+	plan.Input = r.planInput(backup)
+
+	if plan.Target.Ready && plan.Input.Size > 0 {
+		disk, err := r.diskUsage(ctx, plan.Input.Size)
+		if err != nil {
+			plan.Warnings = append(plan.Warnings, err.Error())
+		}
+		plan.Disk = disk
+	}
+	if plan.Input.Size == 0 {
+		plan.Warnings = append(plan.Warnings, "备份大小未知，预计耗时不含下载、解压和导入")
+	}
+	if !r.filter.IsZero() && !opts.SkipDelete {
+		// 未加 --force-delete-full-db 时 applyFilter 已拒绝此类恢复
+		for _, name := range plan.Databases {
+			if r.filter.Partial(name) {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("恢复范围只覆盖 %s 的部分数据，按 --force-delete-full-db 删除整个数据库", name))
+			}
+		}
+	}
+
+	safety := r.safetyEnabled(opts.SkipDelete)
+	if !opts.SkipPreflight {
+		plan.addStep(PlanStep{
+			Phase: PhasePreflight,
+			Title: "恢复前预检",
+			Notes: []string{fmt.Sprintf("%d 项检查，任一项失败时不改动任何数据", len(preflight.Checks))},
+		})
+	}
+	if !opts.SkipDelete {
+		plan.Deletion = r.planDeletion(plan.Target, safety)
+		if safety {
+			plan.addStep(r.planSnapshotStep(plan.Deletion.Databases))
+		} else {
+			plan.addStep(r.planDeleteStep(plan.Deletion.Databases))
+		}
+		plan.addStep(r.planRestartStep())
+	}
+	plan.addStep(r.planRegionStep(plan.Target.Databases, opts.SkipDelete))
+
+	if r.config.Backup.UsesClusterStream() {
+		plan.Stream = r.planStream()
+		plan.addStep(r.planClusterStreamStep(plan.Input.Size))
+	} else {
+		plan.addStep(r.planDownloadStep(ctx, backup))
+		if plan.Input.Strategy != "stream" {
+			plan.addStep(r.planExtractStep(backup))
+		}
+	}
+	plan.addStep(r.planImportStep(plan.Input.Size))
+	plan.addStep(r.planProbeStep())
+	if r.config.Verify.Enabled {
+		plan.addStep(r.planVerifyStep())
+	}
+	plan.addStep(r.planCleanupStep(backup))
+
+	return plan, nil
+}
+
+func (p *RestorePlan) addStep(step PlanStep) {
+	p.Steps = append(p.Steps, step)
+	p.EstimatedSeconds += step.EstimatedSeconds
+}
+
+// planTarget 读取目标 Pod 的状态、现有数据库和在线数据目录大小
+func (r *IoTDBRestorer) planTarget(ctx context.Context) PlanPod {
+	target := PlanPod{Namespace: r.config.Kubernetes.Namespace, Name: r.config.Kubernetes.PodName}
+
+	checker := k8s.NewPodChecker(r.clientset, r.config.Kubernetes.Namespace)
+	ready, pod, err := checker.IsReady(ctx, r.config.Kubernetes.PodName)
+	if err != nil || pod == nil {
+		return target
+	}
+	target.Node = pod.Spec.NodeName
+	target.Phase = string(pod.Status.Phase)
+	target.Ready = ready
+	if !ready {
+		return target
+	}
+
+	if result, err := r.execSQL(ctx, "show databases"); err != nil {
+		logger.Warn("查询现有数据库失败", zap.Error(err))
+	} else {
+		for db := range databaseSet(result.Rows) {
+			target.Databases = append(target.Databases, db)
+		}
+		sort.Strings(target.Databases)
+	}
+
+	if output, err := r.executor.ExecSimple(ctx, fmt.Sprintf("du -sk '%s'", r.liveDataDir())); err == nil {
+		if kb, err := strconv.ParseInt(firstField(output), 10, 64); err == nil {
+			target.LiveDataSize = kb << 10
+		}
+	}
+	return target
+}
+
+func (r *IoTDBRestorer) planInput(backup *preflightBackup) PlanInput {
+	b := r.config.Backup
+	input := PlanInput{Strategy: b.DownloadStrategy, Checksum: b.Checksum}
+	if b.UsesClusterStream() {
+		input.Source = fmt.Sprintf("cluster_stream:%s/%s", b.SourceNamespace, b.SourcePodName)
+		input.Strategy = "cluster_stream"
+	}
+	if input.Strategy == "" {
+		input.Strategy = "local"
+	}
+	if backup == nil {
+		return input
+	}
+	if len(backup.files) > 0 {
+		input.Source, _, _ = archive.SplitPart(backup.files[0])
+		input.Files = backup.files
+		input.Format = string(backup.format)
+	}
+	input.Size = backup.size
+	return input
+}
+
+// planDeletion 删除阶段影响的数据库和目录
+func (r *IoTDBRestorer) planDeletion(target PlanPod, safety bool) *PlanDeletion {
+	deletion := &PlanDeletion{}
+	present := make(map[string]bool, len(target.Databases))
+	for _, db := range target.Databases {
+		present[db] = true
+	}
+	for _, db := range r.databaseNames() {
+		// Pod 未就绪时无法确认数据库是否存在，按全部删除列出
+		if !target.Ready || present[db] {
+			deletion.Databases = append(deletion.Databases, db)
+		}
+	}
+
+	if safety {
+		deletion.Snapshot = r.snapshotDir()
+		for _, move := range r.snapshotMoves() {
+			deletion.Paths = append(deletion.Paths, move.live)
+		}
+		return deletion
+	}
+
+	liveDataRoot := r.liveDataDir()
+	deletion.Paths = []string{"/iotdb/data/backup_before_restore", "/iotdb/data/backup_before_restore_old_*"}
+	if r.selective() {
+		for _, db := range r.databaseNames() {
+			deletion.Paths = append(deletion.Paths,
+				filepath.Join(liveDataRoot, "sequence", db),
+				filepath.Join(liveDataRoot, "unsequence", db),
+			)
+		}
+	} else {
+		deletion.Paths = append(deletion.Paths, liveDataRoot+"/*")
+	}
+	return deletion
+}
+
+func (r *IoTDBRestorer) planSnapshotStep(databases []string) PlanStep {
+	step := PlanStep{
+		Phase: PhaseSnapshot,
+		Title: "安全模式：确认备份完整后为在线数据创建快照，再删除数据库",
+		Commands: []PlanCommand{
+			{Target: TargetSQL, Command: "flush"},
+		},
+		Notes: []string{"后续任一阶段失败时删除数据库和已导入的数据，重启 Pod 后从快照重新加载恢复前的 tsfile"},
+	}
+	for _, move := range r.snapshotMoves() {
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: linkCommand(move.live, move.saved)})
+	}
+	for _, db := range databases {
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetSQL, Command: fmt.Sprintf("delete database %s", db)})
+	}
+	step.Commands = append(step.Commands, PlanCommand{Target: TargetSQL, Command: "flush"})
+	for _, cmd := range r.liveDataCleanupCommands() {
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: cmd})
+	}
+	if !r.config.Safety.KeepSnapshot {
+		step.Notes = append(step.Notes, "恢复成功后删除快照")
+	}
+	return step
+}
+
+func (r *IoTDBRestorer) planDeleteStep(databases []string) PlanStep {
+	step := PlanStep{Phase: PhaseDelete, Title: "删除现有数据库并清理旧数据"}
+	for _, db := range databases {
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetSQL, Command: fmt.Sprintf("delete database %s", db)})
+	}
+	step.Commands = append(step.Commands, PlanCommand{Target: TargetSQL, Command: "flush"})
+	for _, cmd := range r.cleanupCommands() {
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: cmd})
+	}
+	return step
+}
+
+func (r *IoTDBRestorer) planRestartStep() PlanStep {
+	return PlanStep{
+		Phase: PhaseRestart,
+		Title: "重启 Pod 并等待 Ready",
+		Commands: []PlanCommand{
+			{Target: TargetKubernetes, Command: fmt.Sprintf("delete pod %s/%s", r.config.Kubernetes.Namespace, r.config.Kubernetes.PodName)},
+		},
+		Notes:            []string{fmt.Sprintf("等待重建的 Pod Ready，最长 %s", regionReadyTimeout)},
+		EstimatedSeconds: int64(planRestartTime.Seconds()),
+	}
+}
+
+// planRegionStep 创建缺少的数据库并等待 Region 就绪。删除阶段之后所有恢复的数据库都需要重建
+func (r *IoTDBRestorer) planRegionStep(existing []string, skipDelete bool) PlanStep {
+	present := make(map[string]bool, len(existing))
+	for _, db := range existing {
+		present[db] = true
+	}
+
+	step := PlanStep{
+		Phase:    PhaseRegion,
+		Title:    "创建数据库并等待 Schema/Data Region 就绪",
+		Commands: []PlanCommand{{Target: TargetSQL, Command: "show databases"}},
+	}
+	for _, db := range r.managedDatabases() {
+		if skipDelete && present[db.Name] {
+			continue
+		}
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetSQL, Command: createDatabaseSQL(db)})
+		if db.TTL > 0 {
+			step.Commands = append(step.Commands, PlanCommand{Target: TargetSQL, Command: fmt.Sprintf("set ttl to %s %d", db.Name, db.TTL)})
+		}
+	}
+	for _, db := range r.managedDatabases() {
+		device, measurement := splitSeriesPath(db.BootstrapSeries)
+		step.Commands = append(step.Commands,
+			PlanCommand{Target: TargetSQL, Command: fmt.Sprintf("create timeseries %s with datatype=INT64, encoding=RLE, compressor=SNAPPY", db.BootstrapSeries)},
+			PlanCommand{Target: TargetSQL, Command: fmt.Sprintf("insert into %s(time,%s) values(<now>,1)", device, measurement)},
+		)
+	}
+	step.Commands = append(step.Commands,
+		PlanCommand{Target: TargetSQL, Command: "show schema regions"},
+		PlanCommand{Target: TargetSQL, Command: "show data regions"},
+	)
+	return step
+}
+
+func (r *IoTDBRestorer) planStream() *PlanStream {
+	b := r.config.Backup
+	return &PlanStream{
+		SourceNamespace: b.SourceNamespace,
+		SourcePod:       b.SourcePodName,
+		SourceDataDir:   b.SourceDataDir,
+		Paths:           []string{b.SourceDataDir + "/data/sequence", b.SourceDataDir + "/data/unsequence"},
+		ArchivePath:     r.clusterStreamArchivePath(),
+		StagingDir:      b.StagingDir,
+	}
+}
+
+func (r *IoTDBRestorer) planClusterStreamStep(size int64) PlanStep {
+	b := r.config.Backup
+	archivePath := r.clusterStreamArchivePath()
+	step := PlanStep{
+		Phase: PhaseDownload,
+		Title: fmt.Sprintf("从同集群源 Pod %s/%s 拉取 tsfile 数据", b.SourceNamespace, b.SourcePodName),
+		Commands: []PlanCommand{
+			{Target: TargetSourcePod, Command: fmt.Sprintf("test -d '%s/data/sequence' -a -d '%s/data/unsequence'", b.SourceDataDir, b.SourceDataDir)},
+			{Target: TargetSourcePod, Command: fmt.Sprintf("%s -h %s -e \"flush on cluster\"", r.config.IoTDB.CLIPath, r.config.IoTDB.Host)},
+			{Target: TargetPod, Command: r.clusterStreamCleanupCommand()},
+			{Target: TargetKubernetes, Command: fmt.Sprintf("tar -C %s data/sequence data/unsequence（源 Pod）→ %s（目标 Pod）", b.SourceDataDir, archivePath)},
+		},
+		EstimatedSeconds: estimateSeconds(size, planTransferRate) + estimateSeconds(size, planExtractRate),
+	}
+	if b.Checksum != "off" {
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: fmt.Sprintf("sha256sum '%s'", archivePath)})
+	}
+	step.Commands = append(step.Commands,
+		PlanCommand{Target: TargetPod, Command: fmt.Sprintf("tar -tf '%s' >/dev/null", archivePath)},
+		PlanCommand{Target: TargetPod, Command: fmt.Sprintf("mkdir -p '%s' && tar -xf '%s' -C '%s'", b.StagingDir, archivePath, b.StagingDir)},
+		PlanCommand{Target: TargetPod, Command: fmt.Sprintf("rm -f '%s'", archivePath)},
+	)
+	return step
+}
+
+func (r *IoTDBRestorer) planDownloadStep(ctx context.Context, backup *preflightBackup) PlanStep {
+	b := r.config.Backup
+	step := PlanStep{Phase: PhaseDownload, Title: "下载备份文件"}
+	if backup == nil || len(backup.files) == 0 {
+		step.Notes = append(step.Notes, "未定位到备份文件")
+		return step
+	}
+
+	strategy := b.DownloadStrategy
+	if strategy == "" {
+		strategy = "local"
+	}
+	if strategy == "stream" {
+		step.Title = "流式下载并解压备份文件"
+		for _, file := range backup.files {
+			step.Commands = append(step.Commands, PlanCommand{Target: TargetLocal, Command: fmt.Sprintf("GET %s/%s", b.BaseURL, file)})
+		}
+		flags, err := r.streamTarFlags(ctx, backup.format)
+		if err != nil {
+			step.Notes = append(step.Notes, err.Error())
+		}
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: fmt.Sprintf("tar --overwrite %s-xf - -C %s", flags, r.config.IoTDB.DataDir)})
+		if b.StreamFallback != "none" {
+			step.Notes = append(step.Notes, fmt.Sprintf("流式恢复失败时降级为 %s 下载策略", b.StreamFallback))
+		}
+		step.EstimatedSeconds = estimateSeconds(backup.size, planTransferRate)
+		return step
+	}
+
+	for _, file := range backup.files {
+		url := fmt.Sprintf("%s/%s", b.BaseURL, file)
+		remotePath := filepath.Join(podBackupPath, file)
+		if strategy == "pod" {
+			step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: podDownloadCommand(remotePath, url)})
+		} else {
+			localTempDir := b.LocalTempDir
+			if localTempDir == "" {
+				localTempDir = "$TMPDIR"
+			}
+			step.Commands = append(step.Commands,
+				PlanCommand{Target: TargetLocal, Command: fmt.Sprintf("GET %s → %s", url, filepath.Join(localTempDir, file))},
+				PlanCommand{Target: TargetKubernetes, Command: fmt.Sprintf("copy %s → %s", filepath.Join(localTempDir, file), remotePath)},
+			)
+		}
+		if b.Checksum != "off" {
+			step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: fmt.Sprintf("sha256sum '%s'", remotePath)})
+		}
+	}
+	if strategy == "pod" && !b.Credentials.Anonymous() {
+		step.Notes = append(step.Notes, fmt.Sprintf("Pod 内使用有效期 %d 分钟的预签名地址下载", b.PresignExpiryMinutes))
+	}
+	if b.Checksum != "off" {
+		step.Notes = append(step.Notes, fmt.Sprintf("校验和模式 %s，期望值取自 .sha256、Content-MD5 或 ETag", b.Checksum))
+	}
+	step.EstimatedSeconds = estimateSeconds(backup.size, planTransferRate)
+	return step
+}
+
+func (r *IoTDBRestorer) planExtractStep(backup *preflightBackup) PlanStep {
+	step := PlanStep{Phase: PhaseExtract, Title: "解压备份文件到数据目录"}
+	if backup == nil || len(backup.files) == 0 {
+		step.Notes = append(step.Notes, "未定位到备份文件")
+		return step
+	}
+
+	files := backup.files
+	step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: fmt.Sprintf("od -An -tx1 -N %d '%s'", archive.MagicSize, filepath.Join(podBackupPath, files[0]))})
+	switch backup.format {
+	case archive.Gzip:
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: r.tarCommand(files, "-I 'pigz -p 4' -xf")})
+		step.Notes = append(step.Notes, "pigz 不可用或失败时改用: "+r.tarCommand(files, "-xzf"))
+	case archive.Zstd, archive.LZ4:
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: r.tarCommand(files, "-I "+backup.format.Decompressor()+" -xf")})
+	default:
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: r.tarCommand(files, "-xf")})
+	}
+	step.EstimatedSeconds = estimateSeconds(backup.size, planExtractRate)
+	return step
+}
+
+func (r *IoTDBRestorer) planImportStep(size int64) PlanStep {
+	imp := r.config.Import
+	step := PlanStep{
+		Phase: PhaseImport,
+		Title: "导入 tsfile 文件",
+		Commands: []PlanCommand{
+			{Target: TargetPod, Command: fmt.Sprintf("find %s -name '*.tsfile' -type f", r.restoreScanRoot())},
+			{Target: TargetSQL, Command: "load '<tsfile>' verify=false"},
+		},
+		Notes: []string{fmt.Sprintf("并发 %d，批次大小 %d，失败重试 %d 次", imp.Concurrency, imp.BatchSize, imp.RetryCount)},
+		// 导入量按备份大小估算，解压后的 tsfile 至少与归档一样大
+		EstimatedSeconds: estimateSeconds(size, planImportRate),
+	}
+	if imp.Adaptive.Enabled {
+		step.Notes = append(step.Notes, "按 Pod 可用内存、容器重启和可重试错误率调整并发")
+	}
+	if imp.Lanes > 1 {
+		step.Notes = append(step.Notes, fmt.Sprintf("按数据库拆分为 %d 个导入通道", imp.Lanes))
+	}
+	if !r.filter.IsZero() {
+		step.Notes = append(step.Notes, fmt.Sprintf("只导入恢复范围 %s 内的 tsfile", r.filter))
+	}
+	return step
+}
+
+func (r *IoTDBRestorer) planProbeStep() PlanStep {
+	step := PlanStep{Phase: PhaseProbe, Title: "数据库写入和查询探测"}
+	for _, db := range r.managedDatabases() {
+		if db.ProbeSeries == "" {
+			continue
+		}
+		device, measurement := splitSeriesPath(db.ProbeSeries)
+		step.Commands = append(step.Commands,
+			PlanCommand{Target: TargetSQL, Command: fmt.Sprintf("create timeseries %s with datatype=INT64, encoding=RLE, compressor=SNAPPY", db.ProbeSeries)},
+			PlanCommand{Target: TargetSQL, Command: fmt.Sprintf("insert into %s(time, %s) values(<now>, <now>)", device, measurement)},
+			PlanCommand{Target: TargetSQL, Command: fmt.Sprintf("select %s from %s where time = <now>", measurement, device)},
+		)
+	}
+	if len(step.Commands) == 0 {
+		step.Notes = append(step.Notes, "未配置探测序列，跳过写读探测")
+	}
+	return step
+}
+
+func (r *IoTDBRestorer) planVerifyStep() PlanStep {
+	step := PlanStep{Phase: PhaseVerify, Title: "恢复后数据校验"}
+	for _, db := range r.managedDatabases() {
+		step.Commands = append(step.Commands,
+			PlanCommand{Target: TargetSQL, Command: fmt.Sprintf("count timeseries %s.**", db.Name)},
+			PlanCommand{Target: TargetSQL, Command: fmt.Sprintf("count devices %s.**", db.Name)},
+		)
+	}
+	return step
+}
+
+// planCleanupStep 恢复成功后清理临时文件，不属于可续传的阶段
+func (r *IoTDBRestorer) planCleanupStep(backup *preflightBackup) PlanStep {
+	step := PlanStep{Title: "清理临时文件"}
+	switch {
+	case r.config.Backup.UsesClusterStream():
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: r.clusterStreamCleanupCommand()})
+		step.Notes = append(step.Notes, "存在导入失败的文件时保留暂存目录")
+	case backup != nil && len(backup.files) > 0 && r.config.Backup.DownloadStrategy != "stream":
+		step.Commands = append(step.Commands, PlanCommand{Target: TargetPod, Command: backupCleanupCommand(backup.files)})
+	}
+	return step
+}
+
+// estimateSeconds 按吞吐量估算处理 size 字节的秒数，至少为 1 秒
+func estimateSeconds(size int64, rate int64) int64 {
+	if size <= 0 {
+		return 0
+	}
+	return size/rate + 1
+}
@@ -0,0 +1,152 @@
+package restorer
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// mutatingCommands 干运行不能在 Pod 内执行的命令前缀
+var mutatingCommands = []string{"rm ", "mkdir ", "mv ", "wget ", "tar ", "if [ -e"}
+
+func planPhases(plan *RestorePlan) []string {
+	var phases []string
+	for _, step := range plan.Steps {
+		phases = append(phases, string(step.Phase))
+	}
+	return phases
+}
+
+func TestRestoreDryRunPlan(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(r *IoTDBRestorer)
+		opts   RestoreOptions
+		phases string
+		check  func(t *testing.T, r *IoTDBRestorer, plan *RestorePlan)
+	}{
+		{
+			name:   "full restore",
+			phases: "preflight,delete,restart,region,download,extract,import,probe,",
+			check: func(t *testing.T, r *IoTDBRestorer, plan *RestorePlan) {
+				if got := strings.Join(plan.Deletion.Databases, ","); got != "root.energy" {
+					t.Fatalf("only existing databases should be deleted, got %s", got)
+				}
+				if plan.Deletion.Snapshot != "" {
+					t.Fatalf("unexpected snapshot: %+v", plan.Deletion)
+				}
+				if len(plan.Disk) != 1 || plan.Disk[0].Needed != 2*plan.Input.Size {
+					t.Fatalf("unexpected disk usage: %+v", plan.Disk)
+				}
+				download := plan.Steps[4]
+				if len(download.Commands) == 0 || !strings.HasPrefix(download.Commands[0].Command, "wget -q -O '/tmp/emsau_") {
+					t.Fatalf("unexpected download commands: %+v", download.Commands)
+				}
+			},
+		},
+		{
+			name:   "safety mode",
+			setup:  func(r *IoTDBRestorer) { r.config.Safety.Enabled = true },
+			phases: "preflight,snapshot,restart,region,download,extract,import,probe,",
+			check: func(t *testing.T, r *IoTDBRestorer, plan *RestorePlan) {
+				if !strings.HasSuffix(plan.Deletion.Snapshot, "/dry-run") || strings.Join(plan.Deletion.Databases, ",") != "root.energy" {
+					t.Fatalf("unexpected deletion: %+v", plan.Deletion)
+				}
+			},
+		},
+		{
+			name:   "skip delete",
+			opts:   RestoreOptions{SkipDelete: true, SkipPreflight: true},
+			phases: "region,download,extract,import,probe,",
+			check: func(t *testing.T, r *IoTDBRestorer, plan *RestorePlan) {
+				if plan.Deletion != nil {
+					t.Fatalf("nothing should be deleted: %+v", plan.Deletion)
+				}
+				for _, cmd := range plan.Steps[0].Commands {
+					if cmd.Command == "create database root.energy" {
+						t.Fatalf("existing database should not be recreated: %+v", plan.Steps[0].Commands)
+					}
+				}
+			},
+		},
+		{
+			name: "stream strategy",
+			setup: func(r *IoTDBRestorer) {
+				r.config.Backup.DownloadStrategy = "stream"
+			},
+			phases: "preflight,delete,restart,region,download,import,probe,",
+		},
+		{
+			name:   "verify enabled",
+			setup:  func(r *IoTDBRestorer) { r.config.Verify.Enabled = true },
+			phases: "preflight,delete,restart,region,download,extract,import,probe,verify,",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, pod, clientset := newTestRestorer(t)
+			if tt.setup != nil {
+				tt.setup(r)
+			}
+			opts := tt.opts
+			opts.Timestamp = testTimestamp
+			opts.RunID = "dry-run"
+			opts.DryRun = true
+
+			result, err := r.Restore(context.Background(), opts)
+			if err != nil {
+				t.Fatalf("dry run failed: %v", err)
+			}
+			plan := result.Plan
+			if plan == nil {
+				t.Fatalf("expected plan")
+			}
+			if got := strings.Join(planPhases(plan), ","); got != tt.phases {
+				t.Fatalf("expected phases %s, got %s", tt.phases, got)
+			}
+			if plan.Input.Size <= 0 || len(plan.Input.Files) != 1 || plan.EstimatedSeconds <= 0 {
+				t.Fatalf("unexpected plan input: %+v, estimate %d", plan.Input, plan.EstimatedSeconds)
+			}
+			if !plan.Target.Ready || strings.Join(plan.Target.Databases, ",") != "root.energy" {
+				t.Fatalf("unexpected target: %+v", plan.Target)
+			}
+			if tt.check != nil {
+				tt.check(t, r, plan)
+			}
+
+			if got := pod.executedSQL("delete database "); len(got) != 0 {
+				t.Fatalf("dry run should not delete databases, got %v", got)
+			}
+			if got := pod.executedSQL("create "); len(got) != 0 {
+				t.Fatalf("dry run should not create anything, got %v", got)
+			}
+			if n := podRestarts(clientset); n != 0 {
+				t.Fatalf("dry run should not restart the pod, got %d restarts", n)
+			}
+			for _, cmd := range pod.commands {
+				for _, prefix := range mutatingCommands {
+					if strings.HasPrefix(cmd, prefix) {
+						t.Fatalf("dry run executed %q", cmd)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreDryRunMissingBackup(t *testing.T) {
+	r, _, _ := newTestRestorer(t)
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: "20260101000000", DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	plan := result.Plan
+	if plan.Preflight.Err() == nil {
+		t.Fatalf("expected preflight failure for missing backup")
+	}
+	if plan.Input.Size != 0 || len(plan.Warnings) == 0 {
+		t.Fatalf("expected unknown size warning, got %+v", plan)
+	}
+}
@@ -0,0 +1,494 @@
+package restorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/archive"
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// 预检项状态
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+	CheckSkip = "skip"
+)
+
+// CheckResult 一项预检的结果
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// PreflightResult 恢复前的依赖检查结果，warn 不影响恢复
+type PreflightResult struct {
+	Timestamp string        `json:"timestamp,omitempty"`
+	Checks    []CheckResult `json:"checks"`
+}
+
+// Add 追加一项检查结果
+func (p *PreflightResult) Add(check CheckResult) {
+	p.Checks = append(p.Checks, check)
+}
+
+// Err 存在失败的检查项时返回汇总的错误
+func (p *PreflightResult) Err() error {
+	var failed []string
+	for _, check := range p.Checks {
+		if check.Status == CheckFail {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Detail))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("预检未通过: %s", strings.Join(failed, "；"))
+}
+
+// remoteSource 通过 HEAD 查询备份源中的文件，OSSDownloader 实现该接口
+type remoteSource interface {
+	Exists(ctx context.Context, url string) (bool, int64, error)
+}
+
+// preflightBackup 预检定位到的恢复输入
+type preflightBackup struct {
+	files  []string
+	format archive.Format
+	// size 备份大小（直连恢复为源数据目录大小），0 表示未知
+	size int64
+}
+
+// 预检项名称
+const (
+	checkAPI    = "Kubernetes API"
+	checkRBAC   = "RBAC 权限"
+	checkPod    = "目标 Pod"
+	checkCLI    = "IoTDB CLI"
+	checkBackup = "备份"
+	checkTools  = "Pod 内命令"
+	checkDisk   = "磁盘空间"
+)
+
+// Preflight 检查恢复依赖的 Kubernetes 连接、RBAC 权限、Pod 状态、Pod 内命令、磁盘空间和备份可用性，
+// 不修改任何数据。timestamp 为空时（非直连恢复）跳过备份相关的检查
+func (r *IoTDBRestorer) Preflight(ctx context.Context, timestamp string) *PreflightResult {
+	result, _ := r.preflight(ctx, timestamp)
+	return result
+}
+
+// preflight 执行预检，同时返回定位到的恢复输入，未定位到时为 nil
+func (r *IoTDBRestorer) preflight(ctx context.Context, timestamp string) (*PreflightResult, *preflightBackup) {
+	result := &PreflightResult{Timestamp: timestamp}
+
+	api := r.checkAPI(ctx)
+	result.Add(api)
+	podReady := false
+	if api.Status == CheckFail {
+		result.Add(CheckResult{Name: checkRBAC, Status: CheckSkip, Detail: "Kubernetes API 不可用"})
+		result.Add(CheckResult{Name: checkPod, Status: CheckSkip, Detail: "Kubernetes API 不可用"})
+	} else {
+		result.Add(r.checkRBAC(ctx))
+		pod := r.checkPod(ctx)
+		result.Add(pod)
+		podReady = pod.Status == CheckPass
+	}
+
+	if podReady {
+		result.Add(r.checkCLI(ctx))
+	} else {
+		result.Add(CheckResult{Name: checkCLI, Status: CheckSkip, Detail: "目标 Pod 未就绪"})
+	}
+
+	backup, check := r.checkBackup(ctx, timestamp)
+	result.Add(check)
+
+	if podReady {
+		result.Add(r.checkTools(ctx, backup))
+		result.Add(r.checkDisk(ctx, backup))
+	} else {
+		result.Add(CheckResult{Name: checkTools, Status: CheckSkip, Detail: "目标 Pod 未就绪"})
+		result.Add(CheckResult{Name: checkDisk, Status: CheckSkip, Detail: "目标 Pod 未就绪"})
+	}
+	return result, backup
+}
+
+// runPreflight 在删除阶段之前执行预检，失败时不改动任何数据
+func (r *IoTDBRestorer) runPreflight(ctx context.Context) error {
+	logger.Info("步骤 0: 恢复前预检")
+
+	preflight := r.Preflight(ctx, r.result.Timestamp)
+	r.result.Preflight = preflight
+	for _, check := range preflight.Checks {
+		fields := []zap.Field{
+			zap.String("check", check.Name),
+			zap.String("status", check.Status),
+			zap.String("detail", check.Detail),
+		}
+		switch check.Status {
+		case CheckFail:
+			logger.Error("预检未通过", fields...)
+		case CheckWarn:
+			logger.Warn("预检警告", fields...)
+		default:
+			logger.Info("预检", fields...)
+		}
+	}
+
+	if err := preflight.Err(); err != nil {
+		r.result.FailedPhase = PhasePreflight
+		return err
+	}
+	return nil
+}
+
+func (r *IoTDBRestorer) checkAPI(ctx context.Context) CheckResult {
+	if err := k8s.TestConnection(ctx, r.clientset); err != nil {
+		return CheckResult{Name: checkAPI, Status: CheckFail, Detail: err.Error()}
+	}
+	return CheckResult{Name: checkAPI, Status: CheckPass, Detail: "连接正常"}
+}
+
+// requiredPermissions 恢复流程需要的 RBAC 权限
+func (r *IoTDBRestorer) requiredPermissions() []k8s.Permission {
+	namespace := r.config.Kubernetes.Namespace
+	perms := []k8s.Permission{
+		{Namespace: namespace, Resource: "pods", Verb: "get"},
+		{Namespace: namespace, Resource: "pods", Verb: "delete"},
+		{Namespace: namespace, Resource: "pods", Subresource: "exec", Verb: "create"},
+	}
+	if r.config.Backup.UsesClusterStream() {
+		source := r.config.Backup.SourceNamespace
+		perms = append(perms,
+			k8s.Permission{Namespace: source, Resource: "pods", Verb: "get"},
+			k8s.Permission{Namespace: source, Resource: "pods", Subresource: "exec", Verb: "create"},
+		)
+	}
+	if r.config.Journal.Backend == "configmap" {
+		ns := r.config.Journal.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		for _, verb := range []string{"get", "create", "update"} {
+			perms = append(perms, k8s.Permission{Namespace: ns, Resource: "configmaps", Verb: verb})
+		}
+	}
+	if r.config.Lock.Backend == "lease" {
+		ns := r.config.Lock.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		for _, verb := range []string{"get", "create", "update", "delete"} {
+			perms = append(perms, k8s.Permission{Namespace: ns, Group: "coordination.k8s.io", Resource: "leases", Verb: verb})
+		}
+	}
+	return perms
+}
+
+func (r *IoTDBRestorer) checkRBAC(ctx context.Context) CheckResult {
+	perms := r.requiredPermissions()
+	var denied []string
+	for _, perm := range perms {
+		allowed, err := k8s.CanI(ctx, r.clientset, perm)
+		if err != nil {
+			return CheckResult{Name: checkRBAC, Status: CheckFail, Detail: err.Error()}
+		}
+		if !allowed {
+			denied = append(denied, perm.String())
+		}
+	}
+	if len(denied) > 0 {
+		return CheckResult{Name: checkRBAC, Status: CheckFail, Detail: "缺少权限: " + strings.Join(denied, ", ")}
+	}
+	return CheckResult{Name: checkRBAC, Status: CheckPass, Detail: fmt.Sprintf("%d 项权限均已授予", len(perms))}
+}
+
+func (r *IoTDBRestorer) checkPod(ctx context.Context) CheckResult {
+	name := fmt.Sprintf("%s/%s", r.config.Kubernetes.Namespace, r.config.Kubernetes.PodName)
+	checker := k8s.NewPodChecker(r.clientset, r.config.Kubernetes.Namespace)
+	ready, pod, err := checker.IsReady(ctx, r.config.Kubernetes.PodName)
+	if err != nil {
+		return CheckResult{Name: checkPod, Status: CheckFail, Detail: err.Error()}
+	}
+	if pod == nil {
+		return CheckResult{Name: checkPod, Status: CheckFail, Detail: fmt.Sprintf("%s 不存在", name)}
+	}
+	if !ready {
+		return CheckResult{Name: checkPod, Status: CheckFail, Detail: fmt.Sprintf("%s 未就绪（%s）", name, pod.Status.Phase)}
+	}
+	detail := fmt.Sprintf("%s 已就绪", name)
+	if pod.Spec.NodeName != "" {
+		detail += fmt.Sprintf("（节点 %s）", pod.Spec.NodeName)
+	}
+	return CheckResult{Name: checkPod, Status: CheckPass, Detail: detail}
+}
+
+func (r *IoTDBRestorer) checkCLI(ctx context.Context) CheckResult {
+	if !strings.EqualFold(r.config.IoTDB.Client, "cli") {
+		return CheckResult{Name: checkCLI, Status: CheckSkip, Detail: fmt.Sprintf("使用 %s 客户端", r.config.IoTDB.Client)}
+	}
+	cli := r.config.IoTDB.CLIPath
+	if _, err := r.executor.ExecSimple(ctx, fmt.Sprintf("test -x '%s'", cli)); err != nil {
+		return CheckResult{Name: checkCLI, Status: CheckFail, Detail: fmt.Sprintf("%s 不存在或不可执行", cli)}
+	}
+	return CheckResult{Name: checkCLI, Status: CheckPass, Detail: cli}
+}
+
+// checkBackup 确认恢复输入可用，并通过 HEAD 获取备份大小
+func (r *IoTDBRestorer) checkBackup(ctx context.Context, timestamp string) (*preflightBackup, CheckResult) {
+	if r.config.Backup.UsesClusterStream() {
+		return r.checkSourceData(ctx)
+	}
+	if timestamp == "" {
+		return nil, CheckResult{Name: checkBackup, Status: CheckSkip, Detail: "未指定备份时间戳"}
+	}
+
+	backup, err := r.locateBackup(ctx, timestamp)
+	switch {
+	case errors.Is(err, downloader.ErrNoBackup):
+		return nil, CheckResult{Name: checkBackup, Status: CheckFail, Detail: err.Error()}
+	case err != nil && r.config.Safety.Enabled:
+		return nil, CheckResult{Name: checkBackup, Status: CheckFail, Detail: fmt.Sprintf("安全模式要求能在备份源中定位到备份: %v", err)}
+	case err != nil:
+		return nil, CheckResult{Name: checkBackup, Status: CheckWarn, Detail: fmt.Sprintf("无法列举备份源，恢复时将按默认文件名下载: %v", err)}
+	}
+	if err := checkBackupComplete(backup); err != nil {
+		return nil, CheckResult{Name: checkBackup, Status: CheckFail, Detail: err.Error()}
+	}
+
+	name, _, _ := archive.SplitPart(backup.Files()[0])
+	result := &preflightBackup{files: backup.Files(), format: archive.FromExtension(name)}
+	for _, file := range result.files {
+		exists, size, err := r.headBackup(ctx, fmt.Sprintf("%s/%s", r.config.Backup.BaseURL, file))
+		if err != nil {
+			result.size = backup.Size
+			return result, CheckResult{Name: checkBackup, Status: CheckWarn, Detail: fmt.Sprintf("HEAD %s 失败，按列举的大小 %s 估算: %v", file, downloader.FormatBytes(backup.Size), err)}
+		}
+		if !exists {
+			return nil, CheckResult{Name: checkBackup, Status: CheckFail, Detail: fmt.Sprintf("HEAD %s 返回不存在", file)}
+		}
+		result.size += size
+	}
+	return result, CheckResult{Name: checkBackup, Status: CheckPass, Detail: fmt.Sprintf("%s（%s，%d 个文件）", backup.Name, downloader.FormatBytes(result.size), len(result.files))}
+}
+
+// checkSourceData 直连恢复检查源 Pod 正在运行，并统计源数据目录的大小
+func (r *IoTDBRestorer) checkSourceData(ctx context.Context) (*preflightBackup, CheckResult) {
+	if err := r.checkSourcePod(ctx); err != nil {
+		return nil, CheckResult{Name: checkBackup, Status: CheckFail, Detail: err.Error()}
+	}
+
+	source := fmt.Sprintf("%s/%s", r.config.Backup.SourceNamespace, r.config.Backup.SourcePodName)
+	dataDir := r.config.Backup.SourceDataDir + "/data"
+	sourceExecutor := k8s.NewExecutor(r.clientset, r.restConfig, r.config.Backup.SourceNamespace, r.config.Backup.SourcePodName, nil)
+	output, err := sourceExecutor.ExecSimple(ctx, fmt.Sprintf("du -sk '%s'", dataDir))
+	if err != nil {
+		return nil, CheckResult{Name: checkBackup, Status: CheckFail, Detail: fmt.Sprintf("源 Pod %s 的数据目录 %s 不可读: %v", source, dataDir, err)}
+	}
+	kb, err := strconv.ParseInt(firstField(output), 10, 64)
+	if err != nil {
+		return nil, CheckResult{Name: checkBackup, Status: CheckWarn, Detail: fmt.Sprintf("源 Pod %s 正在运行，无法解析数据目录大小: %s", source, strings.TrimSpace(output))}
+	}
+	result := &preflightBackup{size: kb << 10}
+	return result, CheckResult{Name: checkBackup, Status: CheckPass, Detail: fmt.Sprintf("源 Pod %s 正在运行，数据目录 %s", source, downloader.FormatBytes(result.size))}
+}
+
+func (r *IoTDBRestorer) headBackup(ctx context.Context, url string) (bool, int64, error) {
+	remote := r.remote
+	if remote == nil {
+		d, err := downloader.NewDownloader(&r.config.Backup)
+		if err != nil {
+			return false, 0, err
+		}
+		remote = d
+	}
+	return remote.Exists(ctx, url)
+}
+
+// podTool Pod 内需要的命令，optional 的命令缺失时只给出警告
+type podTool struct {
+	name     string
+	optional bool
+}
+
+// requiredTools 恢复流程在目标 Pod 内执行的命令
+func (r *IoTDBRestorer) requiredTools(backup *preflightBackup) []podTool {
+	tools := []podTool{{name: "tar"}, {name: "find"}, {name: "df"}}
+	if r.config.Backup.UsesClusterStream() {
+		return tools
+	}
+
+	b := r.config.Backup
+	if b.DownloadStrategy == "pod" || b.DownloadStrategy == "stream" && b.StreamFallback == "pod" {
+		tools = append(tools, podTool{name: "wget"})
+	}
+	if b.Checksum != "off" {
+		tools = append(tools, podTool{name: "sha256sum"}, podTool{name: "md5sum", optional: true})
+	}
+	tools = append(tools, podTool{name: "od", optional: r.config.Import.Inspect == "off"})
+	if backup != nil {
+		switch backup.format {
+		case archive.Gzip:
+			tools = append(tools, podTool{name: "gzip"}, podTool{name: "pigz", optional: true})
+		case archive.Zstd, archive.LZ4:
+			tools = append(tools, podTool{name: backup.format.Decompressor()})
+		}
+	}
+	return tools
+}
+
+func (r *IoTDBRestorer) checkTools(ctx context.Context, backup *preflightBackup) CheckResult {
+	tools := r.requiredTools(backup)
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.name
+	}
+	// 一次执行检查所有命令，输出缺失的命令名
+	cmd := fmt.Sprintf("for t in %s; do command -v \"$t\" >/dev/null 2>&1 || echo \"$t\"; done", strings.Join(names, " "))
+	output, err := r.executor.ExecSimple(ctx, cmd)
+	if err != nil {
+		return CheckResult{Name: checkTools, Status: CheckFail, Detail: fmt.Sprintf("检查命令失败: %v", err)}
+	}
+	absent := make(map[string]bool)
+	for _, name := range strings.Fields(output) {
+		absent[name] = true
+	}
+
+	var found, missing, missingOptional []string
+	for _, tool := range tools {
+		switch {
+		case !absent[tool.name]:
+			found = append(found, tool.name)
+		case tool.optional:
+			missingOptional = append(missingOptional, tool.name)
+		default:
+			missing = append(missing, tool.name)
+		}
+	}
+
+	switch {
+	case len(missing) > 0:
+		return CheckResult{Name: checkTools, Status: CheckFail, Detail: "缺少 " + strings.Join(missing, "、")}
+	case len(missingOptional) > 0:
+		return CheckResult{Name: checkTools, Status: CheckWarn, Detail: fmt.Sprintf("%s 可用，未安装 %s", strings.Join(found, "、"), strings.Join(missingOptional, "、"))}
+	}
+	return CheckResult{Name: checkTools, Status: CheckPass, Detail: strings.Join(found, "、") + " 可用"}
+}
+
+// diskNeed 一个目录需要的空间
+type diskNeed struct {
+	dir   string
+	usage string
+	bytes int64
+}
+
+// diskNeeds 按备份大小估算 Pod 内各目录需要的空间，解压后的大小至少为归档大小
+func (r *IoTDBRestorer) diskNeeds(size int64) []diskNeed {
+	if r.config.Backup.UsesClusterStream() {
+		archiveDir := r.config.Backup.ArchiveDir
+		if archiveDir == "" {
+			archiveDir = podBackupPath
+		}
+		return []diskNeed{
+			{dir: archiveDir, usage: "拉取归档", bytes: size},
+			{dir: r.config.Backup.StagingDir, usage: "暂存解压", bytes: size},
+		}
+	}
+
+	var needs []diskNeed
+	if r.config.Backup.DownloadStrategy != "stream" {
+		needs = append(needs, diskNeed{dir: podBackupPath, usage: "下载归档", bytes: size})
+	}
+	return append(needs, diskNeed{dir: r.config.IoTDB.DataDir, usage: "解压", bytes: size})
+}
+
+// DiskUsage Pod 内一个挂载点的可用空间和恢复需要的空间
+type DiskUsage struct {
+	Mount     string   `json:"mount"`
+	Available int64    `json:"available"`
+	Needed    int64    `json:"needed"`
+	Usages    []string `json:"usages"`
+}
+
+// diskUsage 按挂载点汇总 Pod 内各目录需要的空间，同一挂载点上的需求累加
+func (r *IoTDBRestorer) diskUsage(ctx context.Context, size int64) ([]DiskUsage, error) {
+	var mounts []DiskUsage
+	index := make(map[string]int)
+	for _, need := range r.diskNeeds(size) {
+		mount, available, err := r.diskFree(ctx, need.dir)
+		if err != nil {
+			return nil, fmt.Errorf("无法获取 %s 的可用空间: %w", need.dir, err)
+		}
+		i, ok := index[mount]
+		if !ok {
+			i = len(mounts)
+			index[mount] = i
+			mounts = append(mounts, DiskUsage{Mount: mount, Available: available})
+		}
+		mounts[i].Needed += need.bytes
+		mounts[i].Usages = append(mounts[i].Usages, fmt.Sprintf("%s %s", need.dir, need.usage))
+	}
+	return mounts, nil
+}
+
+// checkDisk 比较 Pod 内各目录所在挂载点的可用空间与备份大小
+func (r *IoTDBRestorer) checkDisk(ctx context.Context, backup *preflightBackup) CheckResult {
+	if backup == nil || backup.size <= 0 {
+		return CheckResult{Name: checkDisk, Status: CheckSkip, Detail: "备份大小未知"}
+	}
+
+	mounts, err := r.diskUsage(ctx, backup.size)
+	if err != nil {
+		return CheckResult{Name: checkDisk, Status: CheckFail, Detail: err.Error()}
+	}
+
+	var details []string
+	status := CheckPass
+	for _, space := range mounts {
+		details = append(details, fmt.Sprintf("%s 可用 %s，需要至少 %s（%s）",
+			space.Mount, downloader.FormatBytes(space.Available), downloader.FormatBytes(space.Needed), strings.Join(space.Usages, " + ")))
+		if space.Available < space.Needed {
+			status = CheckFail
+		}
+	}
+	return CheckResult{Name: checkDisk, Status: status, Detail: strings.Join(details, "；")}
+}
+
+// diskFree 返回目录所在的挂载点和可用字节数。目录尚不存在时检查其最近的已存在上级目录
+func (r *IoTDBRestorer) diskFree(ctx context.Context, dir string) (string, int64, error) {
+	output, err := r.executor.ExecSimple(ctx, fmt.Sprintf("d='%s'; while [ ! -d \"$d\" ]; do d=$(dirname \"$d\"); done; df -Pk \"$d\"", filepath.Clean(dir)))
+	if err != nil {
+		return "", 0, err
+	}
+	lines := splitLines(output)
+	if len(lines) < 2 {
+		return "", 0, fmt.Errorf("无法解析 df 输出: %s", strings.TrimSpace(output))
+	}
+	// Filesystem 1024-blocks Used Available Capacity Mounted-on
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 6 {
+		return "", 0, fmt.Errorf("无法解析 df 输出: %s", strings.TrimSpace(output))
+	}
+	kb, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("无法解析 df 输出: %s", strings.TrimSpace(output))
+	}
+	return fields[5], kb << 10, nil
+}
+
+func firstField(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
@@ -0,0 +1,201 @@
+package restorer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func checkStatuses(result *PreflightResult) map[string]string {
+	statuses := make(map[string]string)
+	for _, check := range result.Checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name      string
+		timestamp string
+		setup     func(r *IoTDBRestorer, pod *fakePod, clientset *fake.Clientset)
+		want      map[string]string
+	}{
+		{
+			name: "all pass",
+			want: map[string]string{
+				checkAPI:    CheckPass,
+				checkRBAC:   CheckPass,
+				checkPod:    CheckPass,
+				checkCLI:    CheckPass,
+				checkBackup: CheckPass,
+				// pigz 不可用时回退到 gzip
+				checkTools: CheckWarn,
+				checkDisk:  CheckPass,
+			},
+		},
+		{
+			name: "missing tar",
+			setup: func(r *IoTDBRestorer, pod *fakePod, clientset *fake.Clientset) {
+				pod.missingTools["tar"] = true
+			},
+			want: map[string]string{checkTools: CheckFail},
+		},
+		{
+			name: "missing decompressor",
+			setup: func(r *IoTDBRestorer, pod *fakePod, clientset *fake.Clientset) {
+				pod.missingTools["gzip"] = true
+			},
+			want: map[string]string{checkTools: CheckFail},
+		},
+		{
+			name: "rbac denied",
+			setup: func(r *IoTDBRestorer, pod *fakePod, clientset *fake.Clientset) {
+				clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+					attrs := review.Spec.ResourceAttributes
+					review.Status.Allowed = !(attrs.Resource == "pods" && attrs.Verb == "delete")
+					return true, review, nil
+				})
+			},
+			want: map[string]string{checkRBAC: CheckFail, checkPod: CheckPass},
+		},
+		{
+			name: "pod not ready",
+			setup: func(r *IoTDBRestorer, pod *fakePod, clientset *fake.Clientset) {
+				notReady := newRunningPod("uid-0")
+				notReady.Status.ContainerStatuses[0].Ready = false
+				if _, err := clientset.CoreV1().Pods(testNamespace).Update(context.Background(), notReady, metav1.UpdateOptions{}); err != nil {
+					panic(err)
+				}
+			},
+			want: map[string]string{
+				checkPod:    CheckFail,
+				checkCLI:    CheckSkip,
+				checkBackup: CheckPass,
+				checkTools:  CheckSkip,
+				checkDisk:   CheckSkip,
+			},
+		},
+		{
+			name: "cli missing",
+			setup: func(r *IoTDBRestorer, pod *fakePod, clientset *fake.Clientset) {
+				pod.missingTools[r.config.IoTDB.CLIPath] = true
+			},
+			want: map[string]string{checkCLI: CheckFail},
+		},
+		{
+			name: "cli missing with uppercase client",
+			setup: func(r *IoTDBRestorer, pod *fakePod, clientset *fake.Clientset) {
+				r.config.IoTDB.Client = "CLI"
+				pod.missingTools[r.config.IoTDB.CLIPath] = true
+			},
+			want: map[string]string{checkCLI: CheckFail},
+		},
+		{
+			name:      "backup not found",
+			timestamp: "20260101000000",
+			want:      map[string]string{checkBackup: CheckFail, checkDisk: CheckSkip},
+		},
+		{
+			name: "low disk",
+			setup: func(r *IoTDBRestorer, pod *fakePod, clientset *fake.Clientset) {
+				url := fmt.Sprintf("%s/emsau_%s_%s.tar.gz", testBaseURL, testPodName, testTimestamp)
+				pod.backupContents[url] = make([]byte, 4<<10)
+				pod.diskKB = 4
+			},
+			want: map[string]string{checkDisk: CheckFail},
+		},
+		{
+			name: "optional od with inspect off",
+			setup: func(r *IoTDBRestorer, pod *fakePod, clientset *fake.Clientset) {
+				r.config.Import.Inspect = "off"
+				pod.missingTools["od"] = true
+			},
+			want: map[string]string{checkTools: CheckWarn},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, pod, clientset := newTestRestorer(t)
+			if tt.setup != nil {
+				tt.setup(r, pod, clientset)
+			}
+			timestamp := tt.timestamp
+			if timestamp == "" {
+				timestamp = testTimestamp
+			}
+
+			result := r.Preflight(context.Background(), timestamp)
+			got := checkStatuses(result)
+			if len(result.Checks) != 7 {
+				t.Fatalf("expected 7 checks, got %+v", result.Checks)
+			}
+			for name, status := range tt.want {
+				if got[name] != status {
+					t.Fatalf("expected %s=%s, got %+v", name, status, result.Checks)
+				}
+			}
+
+			wantErr := false
+			for _, status := range got {
+				wantErr = wantErr || status == CheckFail
+			}
+			if err := result.Err(); (err != nil) != wantErr {
+				t.Fatalf("unexpected error %v for checks %+v", err, result.Checks)
+			}
+		})
+	}
+}
+
+func TestPreflightDiskGroupsByMount(t *testing.T) {
+	r, _, _ := newTestRestorer(t)
+
+	result := r.Preflight(context.Background(), testTimestamp)
+	for _, check := range result.Checks {
+		if check.Name != checkDisk {
+			continue
+		}
+		// 下载目录和数据目录在同一挂载点上，需求累加
+		if strings.Count(check.Detail, "可用") != 1 || !strings.Contains(check.Detail, "下载归档 + ") {
+			t.Fatalf("expected needs grouped by mount, got %q", check.Detail)
+		}
+		return
+	}
+	t.Fatalf("missing disk check: %+v", result.Checks)
+}
+
+func TestRestorePreflightFailure(t *testing.T) {
+	r, pod, clientset := newTestRestorer(t)
+	pod.missingTools["tar"] = true
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err == nil || !strings.Contains(err.Error(), "tar") {
+		t.Fatalf("expected preflight error, got %v", err)
+	}
+	if result.FailedPhase != PhasePreflight {
+		t.Fatalf("expected failed phase %s, got %s", PhasePreflight, result.FailedPhase)
+	}
+	if result.Preflight == nil || result.Preflight.Err() == nil {
+		t.Fatalf("expected preflight result, got %+v", result.Preflight)
+	}
+	if got := pod.executedSQL("delete database "); len(got) != 0 {
+		t.Fatalf("no database should be deleted after preflight failure, got %v", got)
+	}
+	if n := podRestarts(clientset); n != 0 {
+		t.Fatalf("pod should not be restarted, got %d restarts", n)
+	}
+	for _, cmd := range pod.commands {
+		if strings.HasPrefix(cmd, "rm ") {
+			t.Fatalf("nothing should be removed after preflight failure, got %q", cmd)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package restorer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportReport 导入报告，记录每个文件的导入结果
+type ImportReport struct {
+	RunID        string        `json:"run_id"`
+	GeneratedAt  time.Time     `json:"generated_at"`
+	TotalFiles   int           `json:"total_files"`
+	SuccessCount int           `json:"success_count"`
+	FailedCount  int           `json:"failed_count"`
+	SkippedCount int           `json:"skipped_count"`
+	Files        []*FileRecord `json:"files"`
+	// Concurrency 导入并发及自适应调整记录
+	Concurrency *ConcurrencySummary `json:"concurrency,omitempty"`
+}
+
+// reportRecord JSON 报告中的文件记录，耗时以毫秒输出
+type reportRecord struct {
+	*FileRecord
+	DurationMS int64 `json:"duration_ms"`
+}
+
+var csvHeader = []string{"path", "size", "attempts", "duration_ms", "status", "error_class", "error"}
+
+// NewImportReport 根据导入结果生成报告
+func NewImportReport(runID string, result *ImportResult) *ImportReport {
+	return &ImportReport{
+		RunID:        runID,
+		GeneratedAt:  time.Now(),
+		TotalFiles:   result.TotalFiles,
+		SuccessCount: result.SuccessCount,
+		FailedCount:  result.FailedCount,
+		SkippedCount: result.SkippedCount,
+		Files:        result.Files,
+		Concurrency:  result.Concurrency,
+	}
+}
+
+// WriteFile 将报告写入 dir，format 为 json 或 csv，返回报告路径
+func (rep *ImportReport) WriteFile(dir, format string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("创建报告目录失败: %w", err)
+	}
+
+	name := "import-" + rep.RunID
+	if rep.RunID == "" {
+		name = "import-" + rep.GeneratedAt.Format("20060102-150405")
+	}
+	path := filepath.Join(dir, name+"."+format)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("创建报告文件失败: %w", err)
+	}
+	defer f.Close()
+
+	switch format {
+	case "json":
+		err = rep.writeJSON(f)
+	case "csv":
+		err = rep.writeCSV(f)
+	default:
+		err = fmt.Errorf("未知的报告格式: %s", format)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("写入报告文件失败: %w", err)
+	}
+	return path, nil
+}
+
+func (rep *ImportReport) writeJSON(w io.Writer) error {
+	out := struct {
+		*ImportReport
+		Files []reportRecord `json:"files"`
+	}{ImportReport: rep}
+	for _, record := range rep.Files {
+		out.Files = append(out.Files, reportRecord{FileRecord: record, DurationMS: record.Duration.Milliseconds()})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("写入 JSON 报告失败: %w", err)
+	}
+	return nil
+}
+
+func (rep *ImportReport) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return fmt.Errorf("写入 CSV 报告失败: %w", err)
+	}
+	for _, record := range rep.Files {
+		if err := cw.Write([]string{
+			record.Path,
+			strconv.FormatInt(record.Size, 10),
+			strconv.Itoa(record.Attempts),
+			strconv.FormatInt(record.Duration.Milliseconds(), 10),
+			string(record.Status),
+			record.ErrorClass,
+			record.Error,
+		}); err != nil {
+			return fmt.Errorf("写入 CSV 报告失败: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("写入 CSV 报告失败: %w", err)
+	}
+	return nil
+}
+
+// ReadImportReport 读取 JSON 或 CSV 格式的导入报告（按扩展名判断）
+func ReadImportReport(path string) (*ImportReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开导入报告失败: %w", err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readCSVReport(f)
+	}
+	return readJSONReport(f)
+}
+
+func readJSONReport(r io.Reader) (*ImportReport, error) {
+	var in struct {
+		ImportReport
+		Files []struct {
+			FileRecord
+			DurationMS int64 `json:"duration_ms"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("解析 JSON 报告失败: %w", err)
+	}
+
+	rep := in.ImportReport
+	rep.Files = make([]*FileRecord, 0, len(in.Files))
+	for i := range in.Files {
+		record := in.Files[i].FileRecord
+		record.Duration = time.Duration(in.Files[i].DurationMS) * time.Millisecond
+		rep.Files = append(rep.Files, &record)
+	}
+	return &rep, nil
+}
+
+func readCSVReport(r io.Reader) (*ImportReport, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析 CSV 报告失败: %w", err)
+	}
+	if len(rows) == 0 || strings.Join(rows[0], ",") != strings.Join(csvHeader, ",") {
+		return nil, fmt.Errorf("CSV 报告表头不符合预期")
+	}
+
+	rep := &ImportReport{}
+	for i, row := range rows[1:] {
+		size, sizeErr := strconv.ParseInt(row[1], 10, 64)
+		attempts, attemptsErr := strconv.Atoi(row[2])
+		durationMS, durationErr := strconv.ParseInt(row[3], 10, 64)
+		if sizeErr != nil || attemptsErr != nil || durationErr != nil {
+			return nil, fmt.Errorf("CSV 报告第 %d 行格式错误", i+2)
+		}
+
+		record := &FileRecord{
+			Path:       row[0],
+			Size:       size,
+			Attempts:   attempts,
+			Duration:   time.Duration(durationMS) * time.Millisecond,
+			Status:     FileStatus(row[4]),
+			ErrorClass: row[5],
+			Error:      row[6],
+		}
+		rep.Files = append(rep.Files, record)
+		rep.TotalFiles++
+		switch record.Status {
+		case FileImported:
+			rep.SuccessCount++
+		case FileFailed:
+			rep.FailedCount++
+		case FileSkipped:
+			rep.SkippedCount++
+			rep.SuccessCount++
+		}
+	}
+	return rep, nil
+}
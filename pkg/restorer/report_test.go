@@ -0,0 +1,82 @@
+package restorer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestImportReportRoundTrip(t *testing.T) {
+	result := &ImportResult{
+		TotalFiles:   3,
+		SuccessCount: 2,
+		FailedCount:  1,
+		SkippedCount: 1,
+		Files: []*FileRecord{
+			{Path: "/data/a.tsfile", Size: 10, Attempts: 1, Duration: 1500 * time.Millisecond, Status: FileImported},
+			{Path: "/data/b.tsfile", Status: FileSkipped},
+			{
+				Path:       "/data/c.tsfile",
+				Size:       20,
+				Attempts:   3,
+				Duration:   2 * time.Second,
+				Status:     FileFailed,
+				ErrorClass: ErrorClassRegionNotReady,
+				Error:      "执行命令失败: 301: Failed to get replicaSet, \"quoted\"",
+			},
+		},
+	}
+
+	for _, format := range []string{"json", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			path, err := NewImportReport("run-1", result).WriteFile(t.TempDir(), format)
+			if err != nil {
+				t.Fatalf("write report: %v", err)
+			}
+
+			report, err := ReadImportReport(path)
+			if err != nil {
+				t.Fatalf("read report: %v", err)
+			}
+			if report.TotalFiles != 3 || report.SuccessCount != 2 || report.FailedCount != 1 || report.SkippedCount != 1 {
+				t.Fatalf("unexpected report counts: %+v", report)
+			}
+			if len(report.Files) != len(result.Files) {
+				t.Fatalf("expected %d records, got %d", len(result.Files), len(report.Files))
+			}
+			for i, want := range result.Files {
+				if got := report.Files[i]; *got != *want {
+					t.Fatalf("record %d: expected %+v, got %+v", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestClassifyImportError(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{message: "Failed to get replicaSet of consensus group[id= DataRegion[3]]", want: ErrorClassRegionNotReady},
+		{message: "301: The system mode is READONLY", want: ErrorClassReadOnly},
+		{message: "305: TsFile /data/a.tsfile does not exist", want: ErrorClassFileMissing},
+		{message: "305: TsFile is broken", want: ErrorClassFileCorrupted},
+		{message: "something else", want: ErrorClassOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := classifyImportError(context.Background(), errors.New(tt.message)); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := classifyImportError(ctx, errors.New("boom")); got != ErrorClassCanceled {
+		t.Fatalf("expected %s, got %s", ErrorClassCanceled, got)
+	}
+}
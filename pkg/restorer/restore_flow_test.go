@@ -0,0 +1,755 @@
+package restorer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"github.com/vnnox/iotdb-restore-tool/pkg/selector"
+	"github.com/vnnox/iotdb-restore-tool/pkg/tsfile/tsfiletest"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	testNamespace = "iotdb"
+	testPodName   = "iotdb-datanode-0"
+	testTimestamp = "20260203083502"
+	testBaseURL   = "https://backup.example.com/ems-au"
+)
+
+var testTsFiles = []string{
+	"iotdb/data/datanode/data/sequence/root.energy/1/2920/1770000000000-1-0-0.tsfile",
+	"iotdb/data/datanode/data/sequence/root.emsplus/2/2920/1770000000001-1-0-0.tsfile",
+}
+
+func init() {
+	_ = logger.Init("error", "console")
+
+	regionReadyTimeout = 5 * time.Second
+	regionPollInterval = time.Millisecond
+	importRetryBaseDelay = time.Millisecond
+	adaptivePauseUnit = time.Millisecond
+}
+
+// newRunningPod 返回 Running 且容器 Ready 的 Pod
+func newRunningPod(uid string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testPodName,
+			Namespace: testNamespace,
+			UID:       types.UID(uid),
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "iotdb-datanode", Ready: true},
+			},
+		},
+	}
+}
+
+// newFakeClientset 模拟 StatefulSet：删除 Pod 后立即以新 UID 重建，并授予所有 RBAC 权限
+func newFakeClientset() *fake.Clientset {
+	clientset := fake.NewSimpleClientset(newRunningPod("uid-0"))
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = true
+		return true, review, nil
+	})
+	restarts := 0
+	clientset.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		restarts++
+		pod := newRunningPod(fmt.Sprintf("uid-%d", restarts))
+		gvr := corev1.SchemeGroupVersion.WithResource("pods")
+		return true, nil, clientset.Tracker().Update(gvr, pod, testNamespace)
+	})
+	return clientset
+}
+
+func newTestConfig() *config.Config {
+	cfg := &config.Config{
+		Kubernetes: config.KubeConfig{
+			Namespace: testNamespace,
+			PodName:   testPodName,
+		},
+		IoTDB: config.IoTDBConfig{
+			DataDir: "/iotdb/data",
+		},
+		Backup: config.BackupConfig{
+			BaseURL:          testBaseURL,
+			DownloadStrategy: "pod",
+			// 默认不访问网络获取校验和，完整性校验由 integrity_test 覆盖
+			Checksum: "off",
+		},
+		Import: config.ImportConfig{
+			Concurrency: 2,
+			BatchSize:   2,
+			RetryCount:  3,
+		},
+	}
+	cfg.SetDefaults()
+	return cfg
+}
+
+// newTestRestorer 创建使用 fakePod 和 fake clientset 的恢复器，并注册默认备份
+func newTestRestorer(t *testing.T) (*IoTDBRestorer, *fakePod, *fake.Clientset) {
+	t.Helper()
+
+	pod := newFakePod()
+	pod.databases["root.energy"] = true
+	pod.addBackup(fmt.Sprintf("%s/emsau_%s_%s.tar.gz", testBaseURL, testPodName, testTimestamp), testTsFiles...)
+
+	cfg := newTestConfig()
+	cfg.Import.ReportDir = t.TempDir()
+
+	clientset := newFakeClientset()
+	r := NewRestorer(pod, clientset, nil, cfg)
+	r.SetBackupResolver(&fakeResolver{pod: pod, config: &cfg.Backup})
+	r.remote = pod
+	pod.liveDir = r.liveDataDir()
+	return r, pod, clientset
+}
+
+// newTestRestorerWithServer 返回 newTestRestorer 的恢复器，并用 handler 启动测试服务器（测试结束时关闭），
+// 返回服务器地址。handler 为 nil 时不启动服务器，地址为空
+func newTestRestorerWithServer(t *testing.T, handler http.HandlerFunc) (*IoTDBRestorer, *fakePod, *fake.Clientset, string) {
+	t.Helper()
+
+	r, pod, clientset := newTestRestorer(t)
+	if handler == nil {
+		return r, pod, clientset, ""
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return r, pod, clientset, srv.URL
+}
+
+func loadedFiles(pod *fakePod) []string {
+	var files []string
+	for _, sql := range pod.executedSQL("load ") {
+		files = append(files, loadPattern.FindStringSubmatch(sql)[1])
+	}
+	return files
+}
+
+func TestRestoreFullFlow(t *testing.T) {
+	r, pod, clientset := newTestRestorer(t)
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	if result.TotalFiles != 2 || result.SuccessCount != 2 || result.FailedCount != 0 {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+	if result.FailedPhase != "" {
+		t.Fatalf("unexpected failed phase: %s", result.FailedPhase)
+	}
+	if len(result.Probes) != 1 {
+		t.Fatalf("expected 1 probe, got %d", len(result.Probes))
+	}
+	if probe := result.Probes[0]; probe.Error != "" || probe.QueryResult != fmt.Sprint(probe.Value) {
+		t.Fatalf("unexpected probe result: %+v", probe)
+	}
+
+	if got := pod.executedSQL("delete database "); len(got) != len(r.config.Databases) {
+		t.Fatalf("expected databases to be deleted, got %v", got)
+	}
+	for _, db := range r.config.Databases {
+		if !pod.databases[db.Name] {
+			t.Fatalf("database %s not recreated", db.Name)
+		}
+	}
+
+	restored, err := clientset.CoreV1().Pods(testNamespace).Get(context.Background(), testPodName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pod: %v", err)
+	}
+	if restored.UID == "uid-0" {
+		t.Fatalf("expected pod to be restarted")
+	}
+
+	loaded := loadedFiles(pod)
+	if len(loaded) != len(testTsFiles) {
+		t.Fatalf("expected %d loads, got %v", len(testTsFiles), loaded)
+	}
+	for _, file := range loaded {
+		if !strings.HasPrefix(file, r.restoreScanRoot()+"/") {
+			t.Fatalf("loaded file outside scan root: %s", file)
+		}
+	}
+
+	backupPath := fmt.Sprintf("%s/emsau_%s_%s.tar.gz", podBackupPath, testPodName, testTimestamp)
+	if pod.hasFile(backupPath) {
+		t.Fatalf("expected backup archive to be cleaned up")
+	}
+}
+
+func TestRestoreSelectedDatabase(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+	live := r.liveDataDir()
+	keep := live + "/sequence/root.emsplus/2/2920/1760000000000-1-0-0.tsfile"
+	stale := live + "/sequence/root.energy/1/2920/1760000000000-1-0-0.tsfile"
+	pod.files[keep] = true
+	pod.files[stale] = true
+
+	result, err := r.Restore(context.Background(), RestoreOptions{
+		Timestamp: testTimestamp,
+		Filter:    selector.Filter{Databases: []string{"root.energy"}},
+	})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if result.TotalFiles != 1 || result.SuccessCount != 1 {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+
+	if got := pod.executedSQL("delete database "); len(got) != 1 || got[0] != "delete database root.energy" {
+		t.Fatalf("expected only root.energy to be deleted, got %v", got)
+	}
+	if !pod.hasFile(keep) {
+		t.Fatalf("data of unselected database should be kept")
+	}
+	if pod.hasFile(stale) {
+		t.Fatalf("data of selected database should be cleaned up")
+	}
+	loaded := loadedFiles(pod)
+	if len(loaded) != 1 || !strings.Contains(loaded[0], "/root.energy/") {
+		t.Fatalf("expected only root.energy files to be loaded, got %v", loaded)
+	}
+}
+
+func TestRestoreFilterRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter selector.Filter
+		force  bool
+		phase  Phase
+	}{
+		{
+			name:   "unknown database",
+			filter: selector.Filter{Databases: []string{"root.unknown"}},
+		},
+		{
+			name:   "path prefix outside managed databases",
+			filter: selector.Filter{PathPrefixes: []string{"root.other.site42"}},
+		},
+		{
+			name:   "device prefix without skip delete",
+			filter: selector.Filter{PathPrefixes: []string{"root.energy.site42"}},
+		},
+		{
+			name:   "time range without skip delete",
+			filter: selector.Filter{Since: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:   "no tsfile in time range",
+			filter: selector.Filter{Since: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+			force:  true,
+			phase:  PhaseImport,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, pod, _ := newTestRestorer(t)
+			result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp, Filter: tt.filter, ForceDeleteFullDB: tt.force})
+			if err == nil || result.FailedPhase != tt.phase {
+				t.Fatalf("expected failure in phase %q, got %v (phase %q)", tt.phase, err, result.FailedPhase)
+			}
+			if tt.phase == "" && len(pod.executedSQL("delete database ")) > 0 {
+				t.Fatalf("invalid filter should fail before deleting databases")
+			}
+		})
+	}
+}
+
+func TestRestoreRetriesWhenRegionNotReady(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+
+	// 重启后前两轮 Region 查询为空，第一次 load 报 Region 副本不存在
+	pod.regionWarmup = 2
+	pod.replySQL("load ", "Msg: 301: Failed to get replicaSet of consensus group[id= DataRegion[3]]\n", errExit)
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if result.SuccessCount != 2 || result.FailedCount != 0 {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+
+	loaded := loadedFiles(pod)
+	if len(loaded) != len(testTsFiles)+1 {
+		t.Fatalf("expected one retried load, got %v", loaded)
+	}
+	if pod.regionWarmup != 0 {
+		t.Fatalf("expected region warmup to be consumed, left %d", pod.regionWarmup)
+	}
+}
+
+func TestRestoreCountsNonRetryableImportFailure(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+
+	pod.replySQL("load ", "Msg: 305: TsFile is broken\n", errExit)
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if result.SuccessCount != 1 || result.FailedCount != 1 {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+	if len(loadedFiles(pod)) != len(testTsFiles) {
+		t.Fatalf("non-retryable failure should not be retried")
+	}
+
+	if len(result.FailedFiles) != 1 {
+		t.Fatalf("expected 1 failed file, got %+v", result.FailedFiles)
+	}
+	failed := result.FailedFiles[0]
+	if failed.ErrorClass != ErrorClassFileCorrupted || failed.Attempts != 1 || failed.Size != fakeFileSize {
+		t.Fatalf("unexpected failed record: %+v", failed)
+	}
+
+	report, err := ReadImportReport(result.ImportReport)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	if report.RunID != result.RunID || len(report.Files) != 2 || len(FailedFiles(report.Files)) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+// addTsFileBackup 注册包含指定 tsfile 内容的备份，键为归档内的相对路径
+func addTsFileBackup(pod *fakePod, contents map[string][]byte) {
+	var entries []string
+	for entry, content := range contents {
+		entries = append(entries, entry)
+		pod.contents[path.Join("/iotdb/data", entry)] = content
+	}
+	sort.Strings(entries)
+	pod.addBackup(fmt.Sprintf("%s/emsau_%s_%s.tar.gz", testBaseURL, testPodName, testTimestamp), entries...)
+}
+
+func TestRestoreSkipsCorruptTsFile(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+	r.config.Import.Inspect = "always"
+
+	valid := tsfiletest.Build(tsfiletest.Device{ID: "root.energy.site42.meter1", Int64: []string{"power"}, StartTime: 1770000000000, EndTime: 1770000005000})
+	corrupt := "iotdb/data/datanode/data/sequence/root.energy/1/2920/1770000000002-2-0-0.tsfile"
+	addTsFileBackup(pod, map[string][]byte{
+		testTsFiles[0]: valid,
+		testTsFiles[1]: tsfiletest.Build(tsfiletest.Device{ID: "root.emsplus.site1.pcs1", Text: []string{"status"}, Aligned: true, StartTime: 1770000000000, EndTime: 1770000001000}),
+		corrupt:        valid[:len(valid)-20],
+	})
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if result.TotalFiles != 3 || result.SuccessCount != 2 || result.FailedCount != 1 {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+	for _, file := range loadedFiles(pod) {
+		if strings.HasSuffix(file, path.Base(corrupt)) {
+			t.Fatalf("corrupt file should not be loaded")
+		}
+	}
+
+	failed := result.FailedFiles[0]
+	if failed.ErrorClass != ErrorClassFileCorrupted || failed.Attempts != 0 || !strings.Contains(failed.Error, "截断") {
+		t.Fatalf("unexpected failed record: %+v", failed)
+	}
+
+	report, err := ReadImportReport(result.ImportReport)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	for _, record := range report.Files {
+		if record.Status == FileImported && (record.Devices != 1 || record.StartTime != 1770000000000) {
+			t.Fatalf("expected metadata summary in report, got %+v", record)
+		}
+	}
+}
+
+func TestRestoreFiltersTsFilesByDevice(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+
+	other := "iotdb/data/datanode/data/sequence/root.energy/1/2920/1770000000002-2-0-0.tsfile"
+	addTsFileBackup(pod, map[string][]byte{
+		testTsFiles[0]: tsfiletest.Build(tsfiletest.Device{ID: "root.energy.site42.meter1", Int64: []string{"power"}, StartTime: 1770000000000, EndTime: 1770000005000}),
+		testTsFiles[1]: tsfiletest.Build(tsfiletest.Device{ID: "root.emsplus.site42.pcs1", Int64: []string{"power"}, StartTime: 1770000000000, EndTime: 1770000005000}),
+		other:          tsfiletest.Build(tsfiletest.Device{ID: "root.energy.site43.meter1", Int64: []string{"power"}, StartTime: 1770000000000, EndTime: 1770000005000}),
+	})
+
+	result, err := r.Restore(context.Background(), RestoreOptions{
+		Timestamp:  testTimestamp,
+		SkipDelete: true,
+		Filter:     selector.Filter{PathPrefixes: []string{"root.energy.site42"}},
+	})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	loaded := loadedFiles(pod)
+	if result.TotalFiles != 1 || len(loaded) != 1 || !strings.HasSuffix(loaded[0], path.Base(testTsFiles[0])) {
+		t.Fatalf("expected only the site42 file to be loaded, got %v (%+v)", loaded, result)
+	}
+}
+
+func TestImportFilesFromReport(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+
+	pod.replySQL("load ", "Msg: 305: TsFile is broken\n", errExit)
+	first, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	report, err := ReadImportReport(first.ImportReport)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var files []string
+	for _, record := range FailedFiles(report.Files) {
+		files = append(files, record.Path)
+	}
+
+	result, err := r.ImportFiles(context.Background(), files)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if result.TotalFiles != 1 || result.SuccessCount != 1 || result.FailedCount != 0 {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+	if result.ImportReport == "" || result.ImportReport == first.ImportReport {
+		t.Fatalf("expected a new report, got %q", result.ImportReport)
+	}
+	if got := loadedFiles(pod); got[len(got)-1] != files[0] {
+		t.Fatalf("expected %s to be reloaded, got %v", files[0], got)
+	}
+}
+
+func TestRestoreProbeMismatch(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+
+	pod.replySQL("select restore_check", renderCLITable(
+		[]string{"Time", "root.energy.__restore_probe.restore_check"},
+		[][]string{{"1", "42"}},
+	), nil)
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err == nil {
+		t.Fatalf("expected probe mismatch error")
+	}
+	if result.FailedPhase != PhaseProbe {
+		t.Fatalf("expected failed phase %s, got %s", PhaseProbe, result.FailedPhase)
+	}
+	if len(result.Probes) != 1 {
+		t.Fatalf("expected 1 probe, got %d", len(result.Probes))
+	}
+	if probe := result.Probes[0]; probe.QueryResult != "42" || !strings.Contains(probe.Error, "不匹配") {
+		t.Fatalf("unexpected probe result: %+v", probe)
+	}
+	if result.SuccessCount != 2 {
+		t.Fatalf("import should complete before probe: %+v", result)
+	}
+}
+
+func TestRestoreDownloadFailure(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+
+	// 跳过预检，覆盖下载阶段自身的失败处理
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: "20260101000000", SkipPreflight: true})
+	if err == nil {
+		t.Fatalf("expected download error")
+	}
+	if result.FailedPhase != PhaseDownload {
+		t.Fatalf("expected failed phase %s, got %s", PhaseDownload, result.FailedPhase)
+	}
+	if len(loadedFiles(pod)) != 0 {
+		t.Fatalf("no file should be loaded after download failure")
+	}
+}
+
+func TestRestoreUsesConfiguredDatabases(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+	r.config.Databases = []config.DatabaseConfig{
+		{
+			Name:                 "root.factory",
+			TTL:                  86400000,
+			SchemaRegionGroupNum: 1,
+			DataRegionGroupNum:   4,
+			BootstrapSeries:      "root.factory.__restore_bootstrap.status",
+			ProbeSeries:          "root.factory.__probe.check",
+		},
+		{
+			Name:            "root.audit",
+			BootstrapSeries: "root.audit.boot.flag",
+		},
+	}
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"delete database root.factory",
+		"delete database root.audit",
+		"create database root.factory with SCHEMA_REGION_GROUP_NUM=1, DATA_REGION_GROUP_NUM=4",
+		"set ttl to root.factory 86400000",
+		"create database root.audit",
+		"create timeseries root.audit.boot.flag",
+		"insert into root.audit.boot(time,flag)",
+		"select check from root.factory.__probe",
+	} {
+		if len(pod.executedSQL(want)) == 0 {
+			t.Fatalf("expected SQL %q to be executed", want)
+		}
+	}
+	if len(pod.executedSQL("delete database root.energy")) != 0 {
+		t.Fatalf("unmanaged database should not be deleted")
+	}
+	if len(pod.executedSQL("set ttl to root.audit")) != 0 {
+		t.Fatalf("ttl should not be set without configuration")
+	}
+
+	if len(result.Probes) != 1 || result.Probes[0].Database != "root.factory" {
+		t.Fatalf("unexpected probes: %+v", result.Probes)
+	}
+}
+
+func TestRestorePodStrategyUsesPresignedURL(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+	r.config.Backup.SourceType = "s3"
+	r.config.Backup.Credentials = config.BackupCredentials{AccessKeyID: "AKID", AccessKeySecret: "secret"}
+
+	if _, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp}); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	var wget string
+	for _, cmd := range pod.commands {
+		if strings.HasPrefix(cmd, "wget ") {
+			wget = cmd
+		}
+	}
+	if !strings.Contains(wget, "X-Amz-Signature=") || !strings.Contains(wget, "X-Amz-Credential=AKID%2F") {
+		t.Fatalf("expected presigned download url, got %q", wget)
+	}
+}
+
+func TestRestoreUsesFilenameTemplate(t *testing.T) {
+	r, pod, _ := newTestRestorer(t)
+	r.config.Backup.FilenameTemplate = "{prefix}-{pod}-{ts:2006-01-02T15-04-05}.{ext}"
+	r.config.Backup.FilenamePrefix = "iotdb"
+	backupFile := fmt.Sprintf("iotdb-%s-2026-02-03T08-35-02.tar.gz", testPodName)
+	pod.addBackup(testBaseURL+"/"+backupFile, testTsFiles...)
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if result.BackupFile != backupFile || result.SuccessCount != len(testTsFiles) {
+		t.Fatalf("expected restore from %s, got %+v", backupFile, result)
+	}
+}
+
+// resolverFunc 以函数实现 BackupResolver
+type resolverFunc func(ctx context.Context, baseURL, podName, timestamp string) (*downloader.BackupObject, error)
+
+func (f resolverFunc) Resolve(ctx context.Context, baseURL, podName, timestamp string) (*downloader.BackupObject, error) {
+	return f(ctx, baseURL, podName, timestamp)
+}
+
+func TestRestoreArchiveFormats(t *testing.T) {
+	backupName := fmt.Sprintf("emsau_%s_%s", testPodName, "20260203093502")
+	zstdMagic := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}
+
+	tests := []struct {
+		name string
+		// setup 注册备份，返回期望下载的文件名
+		setup     func(r *IoTDBRestorer, pod *fakePod) []string
+		wantFlags string
+		wantErr   bool
+	}{
+		{
+			name: "split zstd volumes",
+			setup: func(r *IoTDBRestorer, pod *fakePod) []string {
+				var files []string
+				for i, entry := range testTsFiles {
+					file := fmt.Sprintf("%s.tar.zst.part%03d", backupName, i)
+					pod.addBackup(testBaseURL+"/"+file, entry)
+					files = append(files, file)
+				}
+				return files
+			},
+			wantFlags: "-I zstd -xf",
+		},
+		{
+			name: "plain tar",
+			setup: func(r *IoTDBRestorer, pod *fakePod) []string {
+				pod.addBackup(testBaseURL+"/"+backupName+".tar", testTsFiles...)
+				return []string{backupName + ".tar"}
+			},
+			wantFlags: "-xf",
+		},
+		{
+			name: "header wins over extension",
+			setup: func(r *IoTDBRestorer, pod *fakePod) []string {
+				pod.addBackup(testBaseURL+"/"+backupName+".tar.gz", testTsFiles...)
+				pod.backupContents[testBaseURL+"/"+backupName+".tar.gz"] = zstdMagic
+				return []string{backupName + ".tar.gz"}
+			},
+			wantFlags: "-I zstd -xf",
+		},
+		{
+			name: "missing lz4 in pod",
+			setup: func(r *IoTDBRestorer, pod *fakePod) []string {
+				pod.addBackup(testBaseURL+"/"+backupName+".tar.lz4", testTsFiles...)
+				pod.missingTools["lz4"] = true
+				return []string{backupName + ".tar.lz4"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unreachable source falls back to default name",
+			setup: func(r *IoTDBRestorer, pod *fakePod) []string {
+				pod.addBackup(testBaseURL+"/"+backupName+".tar.gz", testTsFiles...)
+				r.SetBackupResolver(resolverFunc(func(ctx context.Context, baseURL, podName, timestamp string) (*downloader.BackupObject, error) {
+					return nil, fmt.Errorf("dial tcp: connection refused")
+				}))
+				return []string{backupName + ".tar.gz"}
+			},
+			wantFlags: "-xzf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, pod, _ := newTestRestorer(t)
+			files := tt.setup(r, pod)
+
+			// 跳过预检，由解压阶段自身检查 Pod 内的解压命令
+			result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: "20260203093502", SkipPreflight: true})
+			if tt.wantErr {
+				if err == nil || result.FailedPhase != PhaseExtract {
+					t.Fatalf("expected extract failure, got %v (phase %s)", err, result.FailedPhase)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("restore failed: %v", err)
+			}
+			if len(pod.extractFlags) != 1 || pod.extractFlags[0] != tt.wantFlags {
+				t.Fatalf("expected tar flags %q, got %v", tt.wantFlags, pod.extractFlags)
+			}
+			if result.SuccessCount != len(testTsFiles) {
+				t.Fatalf("unexpected import counts: %+v", result)
+			}
+			if len(files) > 1 && !containsCommand(pod, "cat "+strings.Join(files, " ")+" | tar ") {
+				t.Fatalf("expected volumes to be concatenated in order, commands: %v", pod.commands)
+			}
+			for _, file := range files {
+				if pod.hasFile(podBackupPath + "/" + file) {
+					t.Fatalf("expected %s to be cleaned up", file)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreExtractFailure(t *testing.T) {
+	backupName := fmt.Sprintf("emsau_%s_%s", testPodName, "20260203093502")
+
+	tests := []struct {
+		name  string
+		setup func(pod *fakePod)
+		// wantFlags 依次尝试的 tar 参数
+		wantFlags string
+		// wantErr 为空时期望恢复成功
+		wantErr string
+	}{
+		{
+			name: "pigz failure falls back to gzip",
+			setup: func(pod *fakePod) {
+				pod.addBackup(testBaseURL+"/"+backupName+".tar.gz", testTsFiles...)
+				pod.hasPigz = true
+				pod.extractErrors["-I 'pigz -p 4' -xf"] = "pigz: abort: internal threads error"
+			},
+			wantFlags: "-I 'pigz -p 4' -xf,-xzf",
+		},
+		{
+			name: "corrupt gzip archive",
+			setup: func(pod *fakePod) {
+				pod.addBackup(testBaseURL+"/"+backupName+".tar.gz", testTsFiles...)
+				pod.extractErrors["-xzf"] = "gzip: stdin: invalid compressed data--format violated\ntar: Child returned status 1\ntar: Error is not recoverable: exiting now"
+			},
+			wantFlags: "-xzf",
+			wantErr:   "Error is not recoverable",
+		},
+		{
+			// 缺少最后一个分卷时完整性检查无法发现，由 tar 报告归档被截断
+			name: "truncated split volumes",
+			setup: func(pod *fakePod) {
+				for i, entry := range testTsFiles {
+					pod.addBackup(fmt.Sprintf("%s/%s.tar.zst.part%03d", testBaseURL, backupName, i), entry)
+				}
+				pod.extractErrors["-I zstd -xf"] = "zstd: /*stdin*\\: unexpected end of file\ntar: Unexpected EOF in archive"
+			},
+			wantFlags: "-I zstd -xf",
+			wantErr:   "Unexpected EOF in archive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, pod, _ := newTestRestorer(t)
+			tt.setup(pod)
+
+			// 跳过预检，覆盖解压阶段自身的错误处理
+			result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: "20260203093502", SkipPreflight: true})
+			if got := strings.Join(pod.extractFlags, ","); got != tt.wantFlags {
+				t.Fatalf("expected tar flags %q, got %q", tt.wantFlags, got)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("restore failed: %v", err)
+				}
+				return
+			}
+			if err == nil || result.FailedPhase != PhaseExtract || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected extract failure with %q, got %v (phase %s)", tt.wantErr, err, result.FailedPhase)
+			}
+			if result.SuccessCount != 0 {
+				t.Fatalf("nothing should be imported after extract failure: %+v", result)
+			}
+		})
+	}
+}
+
+func containsCommand(pod *fakePod, substr string) bool {
+	for _, cmd := range pod.commands {
+		if strings.Contains(cmd, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package restorer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,22 +10,44 @@ import (
 	"strings"
 	"time"
 
+	"github.com/vnnox/iotdb-restore-tool/pkg/archive"
+	"github.com/vnnox/iotdb-restore-tool/pkg/backupname"
 	"github.com/vnnox/iotdb-restore-tool/pkg/config"
 	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/journal"
 	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
 	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"github.com/vnnox/iotdb-restore-tool/pkg/selector"
 	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 const (
-	podBackupPath      = "/tmp"
+	podBackupPath = "/tmp"
+)
+
+// 等待 Pod 和 Region 就绪的超时与轮询间隔，测试中会缩短
+var (
 	regionReadyTimeout = 10 * time.Minute
 	regionPollInterval = 5 * time.Second
-	probeSeriesPath    = "root.energy.__restore_probe.restore_check"
-	probeTimeseriesSQL = "create timeseries root.energy.__restore_probe.restore_check with datatype=INT64, encoding=RLE, compressor=SNAPPY"
 )
 
-var managedDatabases = []string{"root.emsplus", "root.energy"}
+// Phase 恢复流程阶段，用于定位失败位置。
+type Phase string
+
+const (
+	PhasePreflight Phase = "preflight"
+	PhaseDelete    Phase = "delete"
+	PhaseSnapshot  Phase = "snapshot"
+	PhaseRestart   Phase = "restart"
+	PhaseRegion    Phase = "region"
+	PhaseDownload  Phase = "download"
+	PhaseExtract   Phase = "extract"
+	PhaseImport    Phase = "import"
+	PhaseProbe     Phase = "probe"
+	PhaseVerify    Phase = "verify"
+)
 
 // Restorer 恢复器接口
 type Restorer interface {
@@ -33,9 +56,19 @@ type Restorer interface {
 
 // RestoreOptions 恢复选项
 type RestoreOptions struct {
-	Timestamp  string
-	DryRun     bool
-	SkipDelete bool // 跳过删除现有数据库
+	Timestamp   string
+	DryRun      bool
+	SkipDelete  bool   // 跳过删除现有数据库
+	ResumeRunID string // 从指定运行日志续传，跳过已完成阶段和已导入文件
+	RunID       string // 新运行的运行 ID，为空时自动生成
+	// SkipPreflight 跳过删除阶段之前的预检。续传已删除或移出在线数据的运行时不执行预检
+	SkipPreflight bool
+	// Filter 选择性恢复条件，零值恢复全部数据库。
+	// 指定数据库或路径前缀时只删除、重建和导入相关的数据库
+	Filter selector.Filter
+	// ForceDeleteFullDB 恢复范围只覆盖数据库的部分数据时仍删除整个数据库，
+	// 未设置时此类恢复必须跳过删除阶段
+	ForceDeleteFullDB bool
 }
 
 // ProbeResult 记录恢复后的数据库写读探测结果。
@@ -57,19 +90,60 @@ type RestoreResult struct {
 	TotalFiles   int
 	SuccessCount int
 	FailedCount  int
+	SkippedCount int // 续传时跳过的已导入文件数（已计入 SuccessCount）
 	BackupFile   string
 	Timestamp    string
-	Probe        *ProbeResult
-	Error        error
+	RunID        string
+	Resumed      bool
+	Probes       []*ProbeResult
+	Verification *VerificationResult
+	// ImportReport 逐文件导入报告路径
+	ImportReport string
+	// FailedFiles 导入失败的文件记录
+	FailedFiles []*FileRecord
+	// Concurrency 导入并发及自适应调整的汇总
+	Concurrency *ConcurrencySummary
+	// Preflight 恢复前预检结果，跳过预检时为空
+	Preflight *PreflightResult
+	// Plan 干运行生成的执行计划
+	Plan *RestorePlan
+	// Rollback 安全模式下的快照和回滚情况，未启用安全模式时为空
+	Rollback    *RollbackResult
+	FailedPhase Phase
+	Error       error
+}
+
+// BackupResolver 按时间戳定位备份归档的格式和分卷
+type BackupResolver interface {
+	Resolve(ctx context.Context, baseURL, podName, timestamp string) (*downloader.BackupObject, error)
 }
 
 // IoTDBRestorer IoTDB 恢复器
 type IoTDBRestorer struct {
-	executor       *k8s.Executor
+	executor       k8s.CommandExecutor
+	clientset      kubernetes.Interface
+	restConfig     *rest.Config
+	sql            SQLClient
 	config         *config.Config
+	journalStore   journal.Store
+	journal        *journal.Recorder
+	backups        BackupResolver
+	remote         remoteSource
 	result         *RestoreResult
 	startTime      time.Time
 	restoreScanDir string
+	// backupFiles 需要下载和解压的备份文件，分卷备份按分卷序号排列
+	backupFiles []string
+	// streamed 备份已通过 stream 策略直接解压，无需解压阶段
+	streamed bool
+	// sourceStats 直连恢复拉取前采集的源集群统计，作为数据校验基准
+	sourceStats *DataStats
+	// filter 选择性恢复条件
+	filter selector.Filter
+	// databases 本次恢复的数据库，未设置时为全部受管数据库
+	databases []config.DatabaseConfig
+	// snapshotted 安全模式下快照已创建、开始删除数据库，失败时需要回滚
+	snapshotted bool
 }
 
 type regionSnapshot struct {
@@ -79,14 +153,33 @@ type regionSnapshot struct {
 	LastObservedAtUTC time.Time
 }
 
-// NewRestorer 创建恢复器
-func NewRestorer(executor *k8s.Executor, cfg *config.Config) *IoTDBRestorer {
+// NewRestorer 创建恢复器。executor 在目标 Pod 中执行命令，
+// clientset 和 restConfig 用于重启 Pod 及跨 Pod 传输文件。
+func NewRestorer(executor k8s.CommandExecutor, clientset kubernetes.Interface, restConfig *rest.Config, cfg *config.Config) *IoTDBRestorer {
 	return &IoTDBRestorer{
-		executor: executor,
-		config:   cfg,
+		executor:   executor,
+		clientset:  clientset,
+		restConfig: restConfig,
+		sql:        newCLIClient(executor, &cfg.IoTDB),
+		config:     cfg,
 	}
 }
 
+// SetSQLClient 设置 SQL 客户端，未设置时通过 Pod 内的 start-cli.sh 执行
+func (r *IoTDBRestorer) SetSQLClient(client SQLClient) {
+	r.sql = client
+}
+
+// SetJournalStore 设置运行日志存储，未设置时检查点仅保存在内存中，无法续传
+func (r *IoTDBRestorer) SetJournalStore(store journal.Store) {
+	r.journalStore = store
+}
+
+// SetBackupResolver 设置备份定位器，未设置时按配置列举备份源
+func (r *IoTDBRestorer) SetBackupResolver(resolver BackupResolver) {
+	r.backups = resolver
+}
+
 // Restore 执行完整的恢复流程
 func (r *IoTDBRestorer) Restore(ctx context.Context, opts RestoreOptions) (result *RestoreResult, err error) {
 	r.startTime = time.Now()
@@ -112,50 +205,126 @@ func (r *IoTDBRestorer) Restore(ctx context.Context, opts RestoreOptions) (resul
 	)
 
 	if opts.DryRun {
-		return r.dryRun(ctx)
+		return r.dryRun(ctx, opts)
+	}
+
+	if err = r.openJournal(ctx, &opts); err != nil {
+		return r.result, fmt.Errorf("打开运行日志失败: %w", err)
+	}
+	r.result.RunID = r.journal.RunID()
+	r.result.Timestamp = opts.Timestamp
+
+	if err = r.applyFilter(opts); err != nil {
+		return r.result, err
+	}
+
+	// 续传的运行已改动在线数据时预检不再起保护作用，已下载的归档也会影响磁盘空间的估算
+	touched := r.journal.PhaseDone(string(PhaseDelete)) || r.journal.PhaseDone(string(PhaseSnapshot))
+	if !opts.SkipPreflight && !touched {
+		if err = r.runPreflight(ctx); err != nil {
+			return r.result, err
+		}
+	}
+
+	safety := r.safetyEnabled(opts.SkipDelete)
+	if safety {
+		// 开始删除数据库之后的任一步骤失败都从快照回滚
+		r.snapshotted = r.journal.PhaseDone(string(PhaseSnapshot))
+		defer func() {
+			r.finishSnapshot(ctx, err)
+		}()
+		if err = r.runPhase(ctx, PhaseSnapshot, r.snapshotLiveData); err != nil {
+			return r.result, fmt.Errorf("移出在线数据失败: %w", err)
+		}
 	}
 
 	if !opts.SkipDelete {
-		if err = r.deleteDatabasesAndCleanup(ctx); err != nil {
-			return r.result, fmt.Errorf("删除数据库和清理旧数据失败: %w", err)
+		if !safety {
+			if err = r.runPhase(ctx, PhaseDelete, r.deleteDatabasesAndCleanup); err != nil {
+				return r.result, fmt.Errorf("删除数据库和清理旧数据失败: %w", err)
+			}
 		}
 
-		if err = r.restartPodAndWaitReady(ctx); err != nil {
+		if err = r.runPhase(ctx, PhaseRestart, r.restartPodAndWaitReady); err != nil {
 			return r.result, fmt.Errorf("重启并等待 Pod 就绪失败: %w", err)
 		}
 	}
 
+	// Region 就绪检查会创建缺失的数据库和 bootstrap 序列并写入一个点，重复执行是安全的：
+	// 已存在的数据库和序列不会重建，写入只落在专用的 bootstrap 序列上，不影响已导入的数据。
+	// 因此续传时同样执行，以确认 Pod 重建后 Region 仍然可用
 	if err = r.ensureDatabasesAndRegionsReady(ctx); err != nil {
+		r.result.FailedPhase = PhaseRegion
 		return r.result, fmt.Errorf("数据库和 Region 就绪检查失败: %w", err)
 	}
 
-	inputRef := r.restoreInputRef(opts.Timestamp)
+	inputRef, err := r.resolveRestoreInput(ctx, opts.Timestamp)
+	if err != nil {
+		r.result.FailedPhase = PhaseDownload
+		return r.result, err
+	}
 	r.result.BackupFile = inputRef
 
-	if err = r.prepareRestoreInput(ctx, opts.Timestamp); err != nil {
+	if err = r.runPhase(ctx, PhaseDownload, func(ctx context.Context) error {
+		return r.prepareRestoreInput(ctx)
+	}); err != nil {
 		return r.result, fmt.Errorf("准备恢复输入失败: %w", err)
 	}
-	defer r.cleanup(ctx, inputRef)
+	defer func() {
+		if err != nil && r.journal.Persistent() {
+			logger.Info("恢复失败，保留临时文件以便续传",
+				zap.String("run_id", r.journal.RunID()),
+			)
+			return
+		}
+		r.cleanup(ctx)
+	}()
 
-	if !r.config.Backup.UsesClusterStream() {
-		if err = r.extractBackup(ctx, inputRef); err != nil {
+	if r.streamed {
+		logger.Info("备份已在流式下载时解压，跳过解压阶段")
+	} else if !r.config.Backup.UsesClusterStream() {
+		if err = r.runPhase(ctx, PhaseExtract, func(ctx context.Context) error {
+			return r.extractBackup(ctx, r.backupFiles)
+		}); err != nil {
 			return r.result, fmt.Errorf("解压备份文件失败: %w", err)
 		}
 	}
 
-	importResult, err := r.importTsFiles(ctx)
-	if err != nil {
-		return r.result, fmt.Errorf("导入 tsfile 文件失败: %w", err)
-	}
+	if !r.journal.PhaseDone(string(PhaseImport)) {
+		importResult, importErr := r.importTsFiles(ctx)
+		if importErr != nil {
+			r.result.FailedPhase = PhaseImport
+			return r.result, fmt.Errorf("导入 tsfile 文件失败: %w", importErr)
+		}
 
-	r.result.TotalFiles = importResult.TotalFiles
-	r.result.SuccessCount = importResult.SuccessCount
-	r.result.FailedCount = importResult.FailedCount
+		r.applyImportResult(importResult)
+
+		// 存在失败文件时不标记导入完成，续传时会重试这些文件
+		if importResult.FailedCount == 0 {
+			r.markPhase(ctx, PhaseImport)
+		} else if safety {
+			// 安全模式下不保留部分恢复的数据，按导入失败回滚
+			r.result.FailedPhase = PhaseImport
+			return r.result, fmt.Errorf("导入 tsfile 文件失败: %d 个文件导入失败", importResult.FailedCount)
+		}
+	} else {
+		logger.Info("导入阶段已在之前的运行中完成，跳过", zap.String("run_id", r.journal.RunID()))
+	}
 
-	if err = r.verifyDatabaseWriteRead(ctx); err != nil {
+	if err = r.runPhase(ctx, PhaseProbe, r.verifyDatabaseWriteRead); err != nil {
 		return r.result, fmt.Errorf("数据库写读探测失败: %w", err)
 	}
 
+	if r.config.Verify.Enabled {
+		if err = r.runPhase(ctx, PhaseVerify, r.verifyRestoredData); err != nil {
+			return r.result, fmt.Errorf("恢复后数据校验失败: %w", err)
+		}
+	}
+
+	if finishErr := r.journal.Finish(ctx); finishErr != nil {
+		logger.Warn("更新运行日志失败", zap.Error(finishErr))
+	}
+
 	logger.Info("恢复操作完成",
 		zap.Int("total_files", r.result.TotalFiles),
 		zap.Int("success_count", r.result.SuccessCount),
@@ -166,25 +335,281 @@ func (r *IoTDBRestorer) Restore(ctx context.Context, opts RestoreOptions) (resul
 	return r.result, nil
 }
 
-func (r *IoTDBRestorer) restoreInputRef(timestamp string) string {
+// openJournal 创建新的运行日志，或按 ResumeRunID 加载已有运行日志
+func (r *IoTDBRestorer) openJournal(ctx context.Context, opts *RestoreOptions) error {
+	if opts.ResumeRunID == "" {
+		now := time.Now()
+		runID := opts.RunID
+		if runID == "" {
+			runID = journal.NewRunID(now)
+		}
+		j := &journal.Journal{
+			RunID:      runID,
+			Timestamp:  opts.Timestamp,
+			SourceType: r.config.Backup.SourceType,
+			Namespace:  r.config.Kubernetes.Namespace,
+			PodName:    r.config.Kubernetes.PodName,
+			CreatedAt:  now,
+		}
+		if !opts.Filter.IsZero() {
+			filter := opts.Filter
+			j.Filter = &filter
+		}
+		r.journal = journal.NewRecorder(r.journalStore, j)
+		if err := r.journal.Save(ctx); err != nil {
+			logger.Warn("保存运行日志失败，本次运行将无法续传", zap.Error(err))
+		}
+		logger.Info("恢复运行 ID", zap.String("run_id", j.RunID))
+		return nil
+	}
+
+	if r.journalStore == nil {
+		return fmt.Errorf("未配置运行日志存储，无法续传")
+	}
+
+	j, err := r.journalStore.Load(ctx, opts.ResumeRunID)
+	if err != nil {
+		return err
+	}
+	if j.Finished {
+		return fmt.Errorf("运行 %s 已完成，无需续传", j.RunID)
+	}
+	if j.Namespace != r.config.Kubernetes.Namespace || j.PodName != r.config.Kubernetes.PodName {
+		return fmt.Errorf("运行 %s 的目标 Pod %s/%s 与当前配置不一致", j.RunID, j.Namespace, j.PodName)
+	}
+	if opts.Timestamp != "" && opts.Timestamp != j.Timestamp {
+		return fmt.Errorf("运行 %s 的时间戳为 %s，与指定的 %s 不一致", j.RunID, j.Timestamp, opts.Timestamp)
+	}
+	opts.Timestamp = j.Timestamp
+
+	var filter selector.Filter
+	if j.Filter != nil {
+		filter = *j.Filter
+	}
+	if !opts.Filter.IsZero() && opts.Filter.String() != filter.String() {
+		return fmt.Errorf("运行 %s 的恢复范围为 %s，与指定的 %s 不一致", j.RunID, filter, opts.Filter)
+	}
+	opts.Filter = filter
+
+	r.journal = journal.NewRecorder(r.journalStore, j)
+	r.result.Resumed = true
+	logger.Info("从运行日志续传",
+		zap.String("run_id", j.RunID),
+		zap.String("timestamp", j.Timestamp),
+		zap.Strings("completed_phases", j.CompletedPhases),
+		zap.Int("imported_files", len(j.ImportedFiles)),
+	)
+	return nil
+}
+
+// applyFilter 按选择性恢复条件确定本次恢复的数据库。恢复范围只覆盖数据库的部分数据时，
+// 删除阶段会删除范围外的数据，除非跳过删除或明确要求删除整个数据库，否则拒绝恢复
+func (r *IoTDBRestorer) applyFilter(opts RestoreOptions) error {
+	filter := opts.Filter
+	r.filter = filter
+	r.databases = nil
+	if filter.IsZero() {
+		return nil
+	}
+
+	names, err := filter.SelectDatabases(configDatabaseNames(r.config.Databases))
+	if err != nil {
+		return fmt.Errorf("选择性恢复条件无效: %w", err)
+	}
+	for _, db := range r.config.Databases {
+		for _, name := range names {
+			if db.Name == name {
+				r.databases = append(r.databases, db)
+			}
+		}
+	}
+
+	if !opts.SkipDelete {
+		for _, name := range names {
+			if !filter.Partial(name) {
+				continue
+			}
+			if !opts.ForceDeleteFullDB {
+				return fmt.Errorf("恢复范围只覆盖数据库 %s 的部分数据，删除阶段会删除整个数据库；请使用 --skip-delete 保留范围外的数据，或使用 --force-delete-full-db 确认删除", name)
+			}
+			logger.Warn("恢复范围只覆盖数据库的部分数据，按 --force-delete-full-db 删除整个数据库",
+				zap.String("database", name),
+			)
+		}
+	}
+
+	logger.Info("选择性恢复",
+		zap.String("filter", filter.String()),
+		zap.Strings("databases", names),
+	)
+	return nil
+}
+
+// selective 是否只恢复部分受管数据库
+func (r *IoTDBRestorer) selective() bool {
+	return r.databases != nil && len(r.databases) < len(r.config.Databases)
+}
+
+// partialRestore 是否有数据库只恢复了部分数据（时间范围或深于数据库的路径前缀）
+func (r *IoTDBRestorer) partialRestore() bool {
+	for _, db := range r.databaseNames() {
+		if r.filter.Partial(db) {
+			return true
+		}
+	}
+	return false
+}
+
+// runPhase 执行阶段：已完成的阶段直接跳过，成功后写入运行日志
+func (r *IoTDBRestorer) runPhase(ctx context.Context, phase Phase, fn func(context.Context) error) error {
+	if r.journal.PhaseDone(string(phase)) {
+		logger.Info("阶段已在之前的运行中完成，跳过",
+			zap.String("phase", string(phase)),
+			zap.String("run_id", r.journal.RunID()),
+		)
+		return nil
+	}
+
+	if err := fn(ctx); err != nil {
+		r.result.FailedPhase = phase
+		return err
+	}
+
+	r.markPhase(ctx, phase)
+	return nil
+}
+
+func (r *IoTDBRestorer) markPhase(ctx context.Context, phase Phase) {
+	if err := r.journal.MarkPhase(ctx, string(phase)); err != nil {
+		logger.Warn("更新运行日志失败",
+			zap.String("phase", string(phase)),
+			zap.Error(err),
+		)
+	}
+}
+
+// resolveRestoreInput 返回恢复输入的标识：备份归档名或直连源 Pod，并定位需要下载的备份文件。
+// 续传时使用运行日志中记录的备份文件，保证与之前下载的文件一致
+func (r *IoTDBRestorer) resolveRestoreInput(ctx context.Context, timestamp string) (string, error) {
 	if r.config.Backup.UsesClusterStream() {
-		return fmt.Sprintf("cluster_stream:%s/%s", r.config.Backup.SourceNamespace, r.config.Backup.SourcePodName)
+		return fmt.Sprintf("cluster_stream:%s/%s", r.config.Backup.SourceNamespace, r.config.Backup.SourcePodName), nil
+	}
+
+	if files := r.journal.BackupFiles(); len(files) > 0 {
+		r.backupFiles = files
+		name, _, _ := archive.SplitPart(files[0])
+		return name, nil
+	}
+
+	backup, err := r.resolveBackup(ctx, timestamp)
+	if err != nil {
+		return "", err
+	}
+	r.backupFiles = backup.Files()
+	if err := r.journal.SetBackupFiles(ctx, r.backupFiles); err != nil {
+		logger.Warn("更新运行日志失败", zap.Error(err))
+	}
+	return backup.Name, nil
+}
+
+// resolveBackup 定位备份归档的格式和分卷。备份源在本地不可访问（如只允许 Pod 访问）时，
+// 回退到按 backup.filename_template 和默认扩展名构建的文件名
+func (r *IoTDBRestorer) resolveBackup(ctx context.Context, timestamp string) (*downloader.BackupObject, error) {
+	filenames, err := r.config.Backup.Filenames()
+	if err != nil {
+		return nil, fmt.Errorf("构建备份文件名失败: %w", err)
+	}
+
+	backup, err := r.locateBackup(ctx, timestamp)
+	if err == nil || errors.Is(err, downloader.ErrNoBackup) {
+		return backup, err
+	}
+
+	name := filenames.Filename(r.config.Kubernetes.PodName, timestamp, "")
+	logger.Warn("无法定位备份归档，按默认文件名下载",
+		zap.String("file", name),
+		zap.Error(err),
+	)
+	return &downloader.BackupObject{Name: name, Timestamp: timestamp, Ext: backupname.DefaultExtension}, nil
+}
+
+// locateBackup 在备份源中定位备份归档，不回退到默认文件名
+func (r *IoTDBRestorer) locateBackup(ctx context.Context, timestamp string) (*downloader.BackupObject, error) {
+	resolver := r.backups
+	if resolver == nil {
+		filenames, err := r.config.Backup.Filenames()
+		if err != nil {
+			return nil, fmt.Errorf("构建备份文件名失败: %w", err)
+		}
+		d, err := downloader.NewDownloader(&r.config.Backup)
+		if err != nil {
+			return nil, err
+		}
+		resolver = downloader.NewDetector(d, filenames)
 	}
-	return fmt.Sprintf("emsau_%s_%s.tar.gz", r.config.Kubernetes.PodName, timestamp)
+
+	backup, err := resolver.Resolve(ctx, r.config.Backup.BaseURL, r.config.Kubernetes.PodName, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("定位到备份归档",
+		zap.String("name", backup.Name),
+		zap.String("format", backup.Ext),
+		zap.Int("parts", len(backup.Parts)),
+	)
+	return backup, nil
 }
 
-func (r *IoTDBRestorer) prepareRestoreInput(ctx context.Context, timestamp string) error {
+func (r *IoTDBRestorer) prepareRestoreInput(ctx context.Context) error {
 	r.restoreScanDir = ""
 	if r.config.Backup.UsesClusterStream() {
 		return r.streamClusterData(ctx)
 	}
-	backupFile := r.restoreInputRef(timestamp)
-	return r.downloadBackup(ctx, backupFile)
+	return r.downloadBackup(ctx, r.backupFiles)
 }
 
-// downloadBackup 下载备份文件到 Pod
-func (r *IoTDBRestorer) downloadBackup(ctx context.Context, backupFile string) error {
-	logger.Info("步骤 1: 下载备份文件", zap.String("file", backupFile))
+// downloadBackup 下载备份文件到 Pod，分卷备份逐个下载并校验每个分卷
+func (r *IoTDBRestorer) downloadBackup(ctx context.Context, files []string) error {
+	logger.Info("步骤 1: 下载备份文件", zap.Strings("files", files))
+
+	strategy := r.config.Backup.DownloadStrategy
+	if strategy == "" {
+		strategy = "local"
+	}
+	if strategy == "stream" {
+		err := r.streamBackup(ctx, files)
+		if err == nil {
+			// 流式下载时已完成解压，记录解压阶段以便续传时跳过
+			r.streamed = true
+			r.markPhase(ctx, PhaseExtract)
+			return nil
+		}
+		fallback := r.config.Backup.StreamFallback
+		if fallback == "none" || streamUnrecoverable(ctx, err) {
+			return err
+		}
+		logger.Warn("流式恢复失败，降级下载策略",
+			zap.String("fallback", fallback),
+			zap.Error(err),
+		)
+		strategy = fallback
+	}
+
+	for _, file := range files {
+		if err := r.downloadBackupFile(ctx, file, strategy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadBackupFile 按 strategy（local 或 pod）下载单个备份文件（或分卷）到 Pod
+func (r *IoTDBRestorer) downloadBackupFile(ctx context.Context, backupFile, strategy string) error {
+	backupURL := fmt.Sprintf("%s/%s", r.config.Backup.BaseURL, backupFile)
+	checksum, err := r.resolveChecksum(ctx, backupURL)
+	if err != nil {
+		return err
+	}
 
 	remotePath := filepath.Join(podBackupPath, backupFile)
 	exists, err := r.executor.FileExists(ctx, remotePath)
@@ -193,23 +618,25 @@ func (r *IoTDBRestorer) downloadBackup(ctx context.Context, backupFile string) e
 	}
 
 	if exists {
-		logger.Info("备份文件已存在，跳过下载")
-		return nil
-	}
-
-	backupURL := fmt.Sprintf("%s/%s", r.config.Backup.BaseURL, backupFile)
-	strategy := r.config.Backup.DownloadStrategy
-	if strategy == "" {
-		strategy = "local"
+		if checksum == nil {
+			logger.Info("备份文件已存在，跳过下载")
+			return nil
+		}
+		if err := r.verifyPodChecksum(ctx, remotePath, checksum); err != nil {
+			logger.Warn("已存在的备份文件未通过校验，重新下载", zap.Error(err))
+		} else {
+			logger.Info("备份文件已存在且校验通过，跳过下载")
+			return nil
+		}
 	}
 
 	logger.Info("使用下载策略", zap.String("strategy", strategy))
 
 	switch strategy {
 	case "local":
-		return r.downloadAndTransfer(ctx, backupURL, backupFile, remotePath)
+		return r.downloadAndTransfer(ctx, backupURL, backupFile, remotePath, checksum)
 	case "pod":
-		return r.downloadInPod(ctx, backupURL, backupFile, remotePath)
+		return r.downloadInPod(ctx, backupURL, backupFile, remotePath, checksum)
 	default:
 		return fmt.Errorf("未知的下载策略: %s", strategy)
 	}
@@ -225,8 +652,7 @@ func (r *IoTDBRestorer) streamClusterData(ctx context.Context) error {
 
 	archivePath := r.clusterStreamArchivePath()
 	cleanupOnError := func() {
-		cleanupCmd := fmt.Sprintf("rm -f '%s' && rm -rf '%s'", archivePath, r.config.Backup.StagingDir)
-		if _, _, cleanupErr := r.executor.Exec(ctx, []string{"sh", "-c", cleanupCmd}); cleanupErr != nil {
+		if _, _, cleanupErr := r.executor.Exec(ctx, []string{"sh", "-c", r.clusterStreamCleanupCommand()}); cleanupErr != nil {
 			logger.Warn("清理失败的直连恢复临时文件失败",
 				zap.String("archive_path", archivePath),
 				zap.String("staging_dir", r.config.Backup.StagingDir),
@@ -236,26 +662,14 @@ func (r *IoTDBRestorer) streamClusterData(ctx context.Context) error {
 	}
 
 	sourceExecutor := k8s.NewExecutor(
-		r.executor.Clientset,
-		r.executor.RestConfig,
+		r.clientset,
+		r.restConfig,
 		r.config.Backup.SourceNamespace,
 		r.config.Backup.SourcePodName,
 		nil,
 	)
-	sourceChecker := k8s.NewPodChecker(r.executor.Clientset, r.config.Backup.SourceNamespace)
-	exists, err := sourceChecker.Exists(ctx, r.config.Backup.SourcePodName)
-	if err != nil {
-		return fmt.Errorf("检查源 Pod 失败: %w", err)
-	}
-	if !exists {
-		return fmt.Errorf("源 Pod %s/%s 不存在", r.config.Backup.SourceNamespace, r.config.Backup.SourcePodName)
-	}
-	running, err := sourceChecker.IsRunning(ctx, r.config.Backup.SourcePodName)
-	if err != nil {
-		return fmt.Errorf("检查源 Pod 状态失败: %w", err)
-	}
-	if !running {
-		return fmt.Errorf("源 Pod %s/%s 未处于运行状态", r.config.Backup.SourceNamespace, r.config.Backup.SourcePodName)
+	if err := r.checkSourcePod(ctx); err != nil {
+		return err
 	}
 
 	if _, err := sourceExecutor.ExecSimple(ctx, fmt.Sprintf(
@@ -276,28 +690,27 @@ func (r *IoTDBRestorer) streamClusterData(ctx context.Context) error {
 	}); err != nil {
 		return fmt.Errorf("刷新源集群失败: %w", err)
 	}
+	r.captureSourceStats(ctx, newCLIClient(sourceExecutor, &r.config.IoTDB))
 
-	if _, _, err := r.executor.Exec(ctx, []string{
-		"sh", "-c",
-		fmt.Sprintf("rm -f '%s' && rm -rf '%s'", archivePath, r.config.Backup.StagingDir),
-	}); err != nil {
+	if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", r.clusterStreamCleanupCommand()}); err != nil {
 		return fmt.Errorf("清理旧的 staging 和归档失败: %w", err)
 	}
 
 	transfer := k8s.NewTransfer(
-		r.executor.Clientset,
-		r.executor.RestConfig,
-		r.executor.Namespace,
-		r.executor.PodName,
+		r.clientset,
+		r.restConfig,
+		r.config.Kubernetes.Namespace,
+		r.config.Kubernetes.PodName,
 	)
-	if err := transfer.CopyDirectoryAsArchiveFromPod(
+	archiveChecksum, err := transfer.CopyDirectoryAsArchiveFromPod(
 		ctx,
 		r.config.Backup.SourceNamespace,
 		r.config.Backup.SourcePodName,
 		r.config.Backup.SourceDataDir,
 		[]string{"data/sequence", "data/unsequence"},
 		archivePath,
-	); err != nil {
+	)
+	if err != nil {
 		cleanupOnError()
 		return fmt.Errorf("从源 Pod 复制数据失败: %w", err)
 	}
@@ -312,6 +725,18 @@ func (r *IoTDBRestorer) streamClusterData(ctx context.Context) error {
 		zap.String("archive_bytes", strings.TrimSpace(archiveStats)),
 	)
 
+	if r.config.Backup.Checksum != "off" {
+		expected := &downloader.Checksum{
+			Algorithm: downloader.AlgorithmSHA256,
+			Value:     archiveChecksum,
+			Source:    downloader.ChecksumFromTransfer,
+		}
+		if err := r.verifyPodChecksum(ctx, archivePath, expected); err != nil {
+			cleanupOnError()
+			return fmt.Errorf("目标 Pod 临时归档完整性校验失败: %w", err)
+		}
+	}
+
 	if _, _, err := r.executor.Exec(ctx, []string{
 		"sh", "-c",
 		fmt.Sprintf("tar -tf '%s' >/dev/null", archivePath),
@@ -351,19 +776,24 @@ func (r *IoTDBRestorer) streamClusterData(ctx context.Context) error {
 }
 
 // downloadAndTransfer 本地下载后传输到 Pod（新策略）
-func (r *IoTDBRestorer) downloadAndTransfer(ctx context.Context, backupURL, backupFile, remotePath string) error {
+// checksum 为备份的期望校验和，可为空；本地下载时总会计算校验和，用于复核传输到 Pod 的文件。
+func (r *IoTDBRestorer) downloadAndTransfer(ctx context.Context, backupURL, backupFile, remotePath string, checksum *downloader.Checksum) error {
 	logger.Info("使用本地下载 + 传输策略",
 		zap.String("url", backupURL),
 		zap.String("remote", remotePath),
 	)
 
-	downloader := downloader.NewOSSDownloader()
 	localTempDir := r.config.Backup.LocalTempDir
 	if localTempDir == "" {
 		localTempDir = os.TempDir()
 	}
+	localPath := filepath.Join(localTempDir, downloader.ExtractFilename(backupURL))
 
-	localPath, err := downloader.DownloadToLocal(ctx, backupURL, localTempDir)
+	d, err := downloader.NewDownloader(&r.config.Backup)
+	if err != nil {
+		return err
+	}
+	localChecksum, err := d.DownloadVerified(ctx, backupURL, localPath, checksum)
 	if err != nil {
 		return fmt.Errorf("本地下载失败: %w", err)
 	}
@@ -381,6 +811,10 @@ func (r *IoTDBRestorer) downloadAndTransfer(ctx context.Context, backupURL, back
 		}
 	}()
 
+	if err := checkArchiveFormat(localPath, backupFile); err != nil {
+		return err
+	}
+
 	const maxRetries = 3
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		if attempt > 0 {
@@ -392,10 +826,10 @@ func (r *IoTDBRestorer) downloadAndTransfer(ctx context.Context, backupURL, back
 		}
 
 		transfer := k8s.NewTransfer(
-			r.executor.Clientset,
-			r.executor.RestConfig,
-			r.executor.Namespace,
-			r.executor.PodName,
+			r.clientset,
+			r.restConfig,
+			r.config.Kubernetes.Namespace,
+			r.config.Kubernetes.PodName,
 		)
 
 		if err := transfer.CopyFile(ctx, localPath, remotePath); err != nil {
@@ -406,11 +840,24 @@ func (r *IoTDBRestorer) downloadAndTransfer(ctx context.Context, backupURL, back
 
 			if attempt == maxRetries-1 {
 				logger.Warn("本地传输失败，降级到 Pod 下载", zap.Error(err))
-				return r.downloadInPod(ctx, backupURL, backupFile, remotePath)
+				return r.downloadInPod(ctx, backupURL, backupFile, remotePath, checksum)
 			}
 			continue
 		}
 
+		if r.config.Backup.Checksum != "off" {
+			if err := r.verifyPodChecksum(ctx, remotePath, localChecksum); err != nil {
+				logger.Warn("传输后校验失败",
+					zap.Int("attempt", attempt+1),
+					zap.Error(err),
+				)
+				if attempt == maxRetries-1 {
+					return fmt.Errorf("传输后校验失败，已重试 %d 次: %w", maxRetries, err)
+				}
+				continue
+			}
+		}
+
 		logger.Info("文件传输完成",
 			zap.String("local", localPath),
 			zap.String("remote", remotePath),
@@ -421,14 +868,23 @@ func (r *IoTDBRestorer) downloadAndTransfer(ctx context.Context, backupURL, back
 	return fmt.Errorf("传输失败，已重试 %d 次", maxRetries)
 }
 
-// downloadInPod 在 Pod 中直接下载（原有方式）
-func (r *IoTDBRestorer) downloadInPod(ctx context.Context, backupURL, backupFile, remotePath string) error {
+// downloadInPod 在 Pod 中直接下载（原有方式），checksum 不为空时下载后在 Pod 内校验
+func (r *IoTDBRestorer) downloadInPod(ctx context.Context, backupURL, backupFile, remotePath string, checksum *downloader.Checksum) error {
 	logger.Info("使用 Pod 下载策略",
 		zap.String("url", backupURL),
 		zap.String("remote", remotePath),
 	)
 
-	cmd := fmt.Sprintf("wget -q -O '%s' '%s'", remotePath, backupURL)
+	// 私有存储空间生成预签名地址，Pod 内无需凭证即可下载；日志中只记录未签名的地址
+	d, err := downloader.NewDownloader(&r.config.Backup)
+	if err != nil {
+		return err
+	}
+	podURL, err := d.PresignURL(backupURL, time.Duration(r.config.Backup.PresignExpiryMinutes)*time.Minute)
+	if err != nil {
+		return err
+	}
+	cmd := podDownloadCommand(remotePath, podURL)
 
 	logger.Info("开始下载备份文件",
 		zap.String("url", backupURL),
@@ -441,43 +897,88 @@ func (r *IoTDBRestorer) downloadInPod(ctx context.Context, backupURL, backupFile
 	}
 
 	logger.Info("下载完成", zap.String("output", stdout))
+
+	if checksum != nil {
+		return r.verifyPodChecksum(ctx, remotePath, checksum)
+	}
+	return nil
+}
+
+// checkArchiveFormat 按文件头检查本地下载的归档格式，不受支持时在传输到 Pod 之前失败。
+// 分卷备份只有第一个分卷带有文件头
+func checkArchiveFormat(localPath, backupFile string) error {
+	if _, part, ok := archive.SplitPart(backupFile); ok && part > 0 {
+		return nil
+	}
+	magic, ext, err := archive.DetectFile(localPath)
+	if err != nil {
+		return err
+	}
+	if magic == archive.Unknown {
+		return fmt.Errorf("备份文件不是受支持的归档格式（tar、tar.gz、tar.zst、tar.lz4）: %s", backupFile)
+	}
+	if ext != archive.Unknown && ext != magic {
+		logger.Warn("备份文件扩展名与内容不一致，按文件内容解压",
+			zap.String("file", backupFile),
+			zap.String("extension", string(ext)),
+			zap.String("format", string(magic)),
+		)
+	}
 	return nil
 }
 
 // extractBackup 解压备份文件到 data_dir，避免覆盖运行中节点的持久化元数据。
-func (r *IoTDBRestorer) extractBackup(ctx context.Context, backupFile string) error {
+// 按文件头识别归档格式，分卷备份按顺序拼接后解压。
+func (r *IoTDBRestorer) extractBackup(ctx context.Context, files []string) error {
 	logger.Info("步骤 2: 解压备份文件到数据目录")
 
-	checkCmd := fmt.Sprintf("ls -lh %s/%s | awk '{print $5}'", podBackupPath, backupFile)
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, filepath.Join(podBackupPath, file))
+	}
+	checkCmd := fmt.Sprintf("ls -lh %s | awk '{print $5}'", strings.Join(paths, " "))
 	sizeOutput, _ := r.executor.ExecSimple(ctx, checkCmd)
 	logger.Info("备份文件大小", zap.String("size", strings.TrimSpace(sizeOutput)))
 
+	format, err := r.detectArchiveFormat(ctx, files[0])
+	if err != nil {
+		return err
+	}
+
 	scanRoot := r.restoreScanRoot()
-	extractCmd := fmt.Sprintf("cd %s && tar --overwrite -I 'pigz -p 4' -xf %s -C %s 2>&1 | tail -10", podBackupPath, backupFile, r.config.IoTDB.DataDir)
-	fallbackCmd := fmt.Sprintf("cd %s && tar --overwrite -xzf %s -C %s 2>&1 | tail -10", podBackupPath, backupFile, r.config.IoTDB.DataDir)
-
-	checkPigz := "command -v pigz >/dev/null 2>&1"
-	if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", checkPigz}); err == nil {
-		logger.Info("使用 pigz 并行解压（4 线程）")
-		logger.Info("开始解压", zap.String("cmd", extractCmd))
-		stdout, stderr, execErr := r.executor.Exec(ctx, []string{"sh", "-c", extractCmd})
-		if execErr != nil {
-			logger.Warn("pigz 解压失败，尝试使用 gzip", zap.Error(execErr))
-			logger.Info("开始解压", zap.String("cmd", fallbackCmd))
-			stdout, stderr, execErr = r.executor.Exec(ctx, []string{"sh", "-c", fallbackCmd})
-			if execErr != nil {
-				return fmt.Errorf("解压失败: %w: %s", execErr, stderr)
+	switch format {
+	case archive.Gzip:
+		extractCmd := r.tarCommand(files, "-I 'pigz -p 4' -xf")
+		fallbackCmd := r.tarCommand(files, "-xzf")
+
+		checkPigz := "command -v pigz >/dev/null 2>&1"
+		if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", checkPigz}); err == nil {
+			logger.Info("使用 pigz 并行解压（4 线程）")
+			if err := r.runExtract(ctx, extractCmd); err != nil {
+				logger.Warn("pigz 解压失败，尝试使用 gzip", zap.Error(err))
+				if err := r.runExtract(ctx, fallbackCmd); err != nil {
+					return err
+				}
+			}
+		} else {
+			logger.Info("pigz 不可用，使用 gzip 单线程解压（建议安装 pigz 以加速）")
+			if err := r.runExtract(ctx, fallbackCmd); err != nil {
+				return err
 			}
 		}
-		logger.Info("解压完成", zap.String("output", stdout))
-	} else {
-		logger.Info("pigz 不可用，使用 gzip 单线程解压（建议安装 pigz 以加速）")
-		logger.Info("开始解压", zap.String("cmd", fallbackCmd))
-		stdout, stderr, execErr := r.executor.Exec(ctx, []string{"sh", "-c", fallbackCmd})
-		if execErr != nil {
-			return fmt.Errorf("解压失败: %w: %s", execErr, stderr)
+	case archive.Zstd, archive.LZ4:
+		tool := format.Decompressor()
+		checkTool := fmt.Sprintf("command -v %s >/dev/null 2>&1", tool)
+		if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", checkTool}); err != nil {
+			return fmt.Errorf("Pod 内缺少 %s 命令，无法解压 %s 格式的备份", tool, format)
+		}
+		if err := r.runExtract(ctx, r.tarCommand(files, "-I "+tool+" -xf")); err != nil {
+			return err
+		}
+	default:
+		if err := r.runExtract(ctx, r.tarCommand(files, "-xf")); err != nil {
+			return err
 		}
-		logger.Info("解压完成", zap.String("output", stdout))
 	}
 
 	listOutput, err := r.executor.ExecSimple(ctx, fmt.Sprintf("find %s -type f | head -20", scanRoot))
@@ -494,12 +995,88 @@ func (r *IoTDBRestorer) extractBackup(ctx context.Context, backupFile string) er
 	return nil
 }
 
+// detectArchiveFormat 读取 Pod 内归档的文件头识别格式，无法读取或识别时按扩展名判断
+func (r *IoTDBRestorer) detectArchiveFormat(ctx context.Context, file string) (archive.Format, error) {
+	var header []byte
+	headerCmd := fmt.Sprintf("od -An -tx1 -N %d '%s'", archive.MagicSize, filepath.Join(podBackupPath, file))
+	if output, err := r.executor.ExecSimple(ctx, headerCmd); err != nil {
+		logger.Warn("读取归档文件头失败，按扩展名识别格式", zap.Error(err))
+	} else {
+		header = parseHexDump(output)
+	}
+
+	format := archive.Detect(header, file)
+	if format == archive.Unknown {
+		return archive.Unknown, fmt.Errorf("无法识别备份归档格式（支持 tar、tar.gz、tar.zst、tar.lz4）: %s", file)
+	}
+	if ext := archive.FromExtension(file); ext != archive.Unknown && ext != format {
+		logger.Warn("备份文件扩展名与内容不一致，按文件内容解压",
+			zap.String("file", file),
+			zap.String("extension", string(ext)),
+			zap.String("format", string(format)),
+		)
+	}
+	logger.Info("备份归档格式", zap.String("format", string(format)))
+	return format, nil
+}
+
+// tarCommand 构建解压命令，分卷备份按顺序拼接后通过管道交给 tar。
+// 命令的退出码即 tar 的退出码；分卷时 shell 支持 pipefail 则同时反映 cat 的失败（如缺少分卷）
+func (r *IoTDBRestorer) tarCommand(files []string, flags string) string {
+	input, source := "", files[0]
+	if len(files) > 1 {
+		input = "if (set -o pipefail) 2>/dev/null; then set -o pipefail; fi && cat " + strings.Join(files, " ") + " | "
+		source = "-"
+	}
+	return fmt.Sprintf("cd %s && %star --overwrite %s %s -C %s 2>&1", podBackupPath, input, flags, source, r.config.IoTDB.DataDir)
+}
+
+func (r *IoTDBRestorer) runExtract(ctx context.Context, cmd string) error {
+	logger.Info("开始解压", zap.String("cmd", cmd))
+	stdout, stderr, err := r.executor.Exec(ctx, []string{"sh", "-c", cmd})
+	output := tailLines(strings.TrimSpace(stdout+"\n"+stderr), 10)
+	if err != nil {
+		return fmt.Errorf("解压失败: %w: %s", err, output)
+	}
+	logger.Info("解压完成", zap.String("output", output))
+	return nil
+}
+
+// tailLines 返回输出的最后 n 行
+func tailLines(output string, n int) string {
+	lines := strings.Split(output, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseHexDump 解析 od -An -tx1 的输出
+func parseHexDump(output string) []byte {
+	var data []byte
+	for _, field := range strings.Fields(output) {
+		b, err := strconv.ParseUint(field, 16, 8)
+		if err != nil {
+			break
+		}
+		data = append(data, byte(b))
+	}
+	return data
+}
+
 func (r *IoTDBRestorer) deleteDatabasesAndCleanup(ctx context.Context) error {
 	logger.Info("步骤 0: 删除现有数据库并清理旧数据")
 
-	for _, db := range managedDatabases {
+	r.dropDatabases(ctx)
+	return r.runCleanup(ctx, r.cleanupCommands())
+}
+
+// dropDatabases 删除恢复的数据库并刷新，DataNode 随之释放并删除这些数据库的在线文件。
+// 数据库可能不存在，删除失败只记录日志
+func (r *IoTDBRestorer) dropDatabases(ctx context.Context) {
+	for _, db := range r.databaseNames() {
 		sql := fmt.Sprintf("delete database %s", db)
-		if _, _, err := r.execSQL(ctx, sql); err != nil {
+		if _, err := r.execSQL(ctx, sql); err != nil {
 			logger.Warn("删除数据库失败，继续执行",
 				zap.String("database", db),
 				zap.Error(err),
@@ -509,36 +1086,54 @@ func (r *IoTDBRestorer) deleteDatabasesAndCleanup(ctx context.Context) error {
 		}
 	}
 
-	if _, _, err := r.execSQL(ctx, "flush"); err != nil {
+	if _, err := r.execSQL(ctx, "flush"); err != nil {
 		logger.Warn("刷新数据失败", zap.Error(err))
 	}
+}
 
-	liveDataRoot := r.liveDataDir()
-	cleanupCommands := []string{
-		"rm -rf /iotdb/data/backup_before_restore /iotdb/data/backup_before_restore_old_*",
-		fmt.Sprintf("mkdir -p %s && rm -rf %s/* %s/.[!.]* %s/..?* 2>/dev/null || true", liveDataRoot, liveDataRoot, liveDataRoot, liveDataRoot),
-	}
-
-	for _, cmd := range cleanupCommands {
+func (r *IoTDBRestorer) runCleanup(ctx context.Context, commands []string) error {
+	for _, cmd := range commands {
 		if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", cmd}); err != nil {
 			return fmt.Errorf("执行清理命令失败: %s: %w", cmd, err)
 		}
 	}
-
 	return nil
 }
 
+// cleanupCommands 删除阶段清理旧数据的命令
+func (r *IoTDBRestorer) cleanupCommands() []string {
+	return append([]string{
+		"rm -rf /iotdb/data/backup_before_restore /iotdb/data/backup_before_restore_old_*",
+	}, r.liveDataCleanupCommands()...)
+}
+
+// liveDataCleanupCommands 清理在线数据目录中残留文件的命令，须在删除数据库之后执行
+func (r *IoTDBRestorer) liveDataCleanupCommands() []string {
+	liveDataRoot := r.liveDataDir()
+	if r.selective() {
+		// 只清理选中数据库的数据目录，其他数据库保持不变
+		var commands []string
+		for _, db := range r.databaseNames() {
+			commands = append(commands, fmt.Sprintf("rm -rf %s/sequence/%s %s/unsequence/%s", liveDataRoot, db, liveDataRoot, db))
+		}
+		return commands
+	}
+	return []string{
+		fmt.Sprintf("mkdir -p %s && rm -rf %s/* %s/.[!.]* %s/..?* 2>/dev/null || true", liveDataRoot, liveDataRoot, liveDataRoot, liveDataRoot),
+	}
+}
+
 func (r *IoTDBRestorer) restartPodAndWaitReady(ctx context.Context) error {
 	logger.Info("步骤 1: 重启 Pod 并等待 Ready")
 
-	checker := k8s.NewPodChecker(r.executor.Clientset, r.executor.Namespace)
+	checker := k8s.NewPodChecker(r.clientset, r.config.Kubernetes.Namespace)
 	var previousUID string
-	existingPod, err := checker.GetPod(ctx, r.executor.PodName)
+	existingPod, err := checker.GetPod(ctx, r.config.Kubernetes.PodName)
 	if err == nil && existingPod != nil {
 		previousUID = string(existingPod.UID)
 	}
 
-	if err := checker.Delete(ctx, r.executor.PodName); err != nil {
+	if err := checker.Delete(ctx, r.config.Kubernetes.PodName); err != nil {
 		return err
 	}
 
@@ -549,7 +1144,7 @@ func (r *IoTDBRestorer) restartPodAndWaitReady(ctx context.Context) error {
 	defer ticker.Stop()
 
 	for {
-		ready, pod, err := checker.IsReady(waitCtx, r.executor.PodName)
+		ready, pod, err := checker.IsReady(waitCtx, r.config.Kubernetes.PodName)
 		if err != nil {
 			return err
 		}
@@ -572,7 +1167,7 @@ func (r *IoTDBRestorer) restartPodAndWaitReady(ctx context.Context) error {
 		}
 
 		logger.Info("等待 Pod 就绪",
-			zap.String("pod", r.executor.PodName),
+			zap.String("pod", r.config.Kubernetes.PodName),
 			zap.String("previous_uid", previousUID),
 			zap.String("current_uid", currentUID),
 			zap.String("phase", phase),
@@ -582,7 +1177,7 @@ func (r *IoTDBRestorer) restartPodAndWaitReady(ctx context.Context) error {
 		)
 
 		if ready && currentUID != "" && currentUID != previousUID && !deleting {
-			logger.Info("Pod 已就绪", zap.String("pod", r.executor.PodName))
+			logger.Info("Pod 已就绪", zap.String("pod", r.config.Kubernetes.PodName))
 			return nil
 		}
 
@@ -614,7 +1209,7 @@ func (r *IoTDBRestorer) ensureDatabasesAndRegionsReady(ctx context.Context) erro
 			lastSnapshot = snapshot
 
 			allReady := true
-			for _, db := range managedDatabases {
+			for _, db := range r.databaseNames() {
 				dbExists := snapshot.Databases[db]
 				schemaCount := snapshot.RunningSchema[db]
 				dataCount := snapshot.RunningData[db]
@@ -638,27 +1233,25 @@ func (r *IoTDBRestorer) ensureDatabasesAndRegionsReady(ctx context.Context) erro
 
 		select {
 		case <-waitCtx.Done():
-			return fmt.Errorf("等待数据库和 Region 就绪超时，最后状态: %s", formatRegionSnapshot(lastSnapshot))
+			return fmt.Errorf("等待数据库和 Region 就绪超时，最后状态: %s", formatRegionSnapshot(r.databaseNames(), lastSnapshot))
 		case <-ticker.C:
 		}
 	}
 }
 
 func (r *IoTDBRestorer) ensureDatabasesAndCollectSnapshot(ctx context.Context) (*regionSnapshot, error) {
-	databaseOutput, _, err := r.execSQL(ctx, "show databases")
+	databaseResult, err := r.execSQL(ctx, "show databases")
 	if err != nil {
 		return nil, fmt.Errorf("show databases 执行失败: %w", err)
 	}
 
-	databases := parseDatabaseList(databaseOutput)
-	for _, db := range managedDatabases {
-		if databases[db] {
+	databases := databaseSet(databaseResult.Rows)
+	for _, db := range r.managedDatabases() {
+		if databases[db.Name] {
 			continue
 		}
-
-		sql := fmt.Sprintf("create database %s", db)
-		if _, _, err := r.execSQL(ctx, sql); err != nil {
-			return nil, fmt.Errorf("创建数据库失败 %s: %w", db, err)
+		if err := r.createDatabase(ctx, db); err != nil {
+			return nil, err
 		}
 	}
 
@@ -670,25 +1263,25 @@ func (r *IoTDBRestorer) ensureDatabasesAndCollectSnapshot(ctx context.Context) (
 }
 
 func (r *IoTDBRestorer) collectRegionSnapshot(ctx context.Context) (*regionSnapshot, error) {
-	databaseOutput, _, err := r.execSQL(ctx, "show databases")
+	databaseResult, err := r.execSQL(ctx, "show databases")
 	if err != nil {
 		return nil, fmt.Errorf("查询数据库列表失败: %w", err)
 	}
 
-	schemaOutput, _, err := r.execSQL(ctx, "show schema regions")
+	schemaResult, err := r.execSQL(ctx, "show schema regions")
 	if err != nil {
 		return nil, fmt.Errorf("查询 SchemaRegion 失败: %w", err)
 	}
 
-	dataOutput, _, err := r.execSQL(ctx, "show data regions")
+	dataResult, err := r.execSQL(ctx, "show data regions")
 	if err != nil {
 		return nil, fmt.Errorf("查询 DataRegion 失败: %w", err)
 	}
 
 	snapshot := &regionSnapshot{
-		Databases:         parseDatabaseList(databaseOutput),
-		RunningSchema:     parseRunningRegionCounts(schemaOutput),
-		RunningData:       parseRunningRegionCounts(dataOutput),
+		Databases:         databaseSet(databaseResult.Rows),
+		RunningSchema:     runningRegionCounts(schemaResult.Rows),
+		RunningData:       runningRegionCounts(dataResult.Rows),
 		LastObservedAtUTC: time.Now().UTC(),
 	}
 	return snapshot, nil
@@ -711,22 +1304,145 @@ func (r *IoTDBRestorer) importTsFiles(ctx context.Context) (*ImportResult, error
 	files := parseFileList(output)
 	logger.Info("找到 tsfile 文件", zap.Int("count", len(files)))
 
-	importer := NewImporter(r.executor, r.config, r.ensureDatabasesAndRegionsReady)
-	return importer.Import(ctx, files)
+	if !r.filter.IsZero() {
+		selected, skipped, unparsed := r.filter.Select(files, r.config.IoTDB.TimePartitionInterval)
+		for _, file := range unparsed {
+			logger.Warn("无法按目录结构解析 tsfile 路径，保留导入", zap.String("file", file))
+		}
+		logger.Info("按恢复范围筛选 tsfile",
+			zap.String("filter", r.filter.String()),
+			zap.Int("selected", len(selected)),
+			zap.Int("skipped", len(skipped)),
+		)
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("恢复范围 %s 内没有 tsfile 文件", r.filter)
+		}
+		files = selected
+	}
+
+	inspected, err := r.inspectTsFiles(ctx, files)
+	if err != nil {
+		return nil, fmt.Errorf("检查 tsfile 元数据失败: %w", err)
+	}
+	if len(inspected.selected) == 0 && len(inspected.corrupt) == 0 {
+		return nil, fmt.Errorf("恢复范围 %s 内没有 tsfile 文件", r.filter)
+	}
+
+	importer := r.newImporter()
+	importer.SetCheckpoint(r.journal)
+	result, err := importer.Import(ctx, inspected.selected)
+	// 检查点按批保存，导入结束时（包括失败和取消）保存剩余的记录，续传时不再重复导入
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if saveErr := r.journal.Save(saveCtx); saveErr != nil {
+		logger.Warn("保存导入检查点失败", zap.Error(saveErr))
+	}
+	if err != nil {
+		return nil, err
+	}
+	inspected.apply(result)
+	return result, nil
+}
+
+// ImportFiles 重新导入指定文件（如导入报告中的失败文件），不执行删除、下载等其他阶段
+func (r *IoTDBRestorer) ImportFiles(ctx context.Context, files []string) (result *RestoreResult, err error) {
+	r.startTime = time.Now()
+	r.result = &RestoreResult{
+		StartTime: r.startTime,
+		RunID:     journal.NewRunID(r.startTime),
+	}
+	result = r.result
+
+	defer func() {
+		r.result.EndTime = time.Now()
+		r.result.Duration = r.result.EndTime.Sub(r.result.StartTime)
+		if err != nil {
+			r.result.Error = err
+		}
+	}()
+
+	logger.Info("重新导入文件", zap.Int("files", len(files)), zap.String("run_id", r.result.RunID))
+
+	if err = r.ensureDatabasesAndRegionsReady(ctx); err != nil {
+		r.result.FailedPhase = PhaseRegion
+		return r.result, fmt.Errorf("数据库和 Region 就绪检查失败: %w", err)
+	}
+
+	importer := r.newImporter()
+	importResult, err := importer.Import(ctx, files)
+	if err != nil {
+		r.result.FailedPhase = PhaseImport
+		return r.result, fmt.Errorf("导入 tsfile 文件失败: %w", err)
+	}
+	r.applyImportResult(importResult)
+
+	return r.result, nil
+}
+
+// newImporter 创建导入器，自适应并发通过 Kubernetes API 查询目标 Pod 的容器重启次数
+func (r *IoTDBRestorer) newImporter() *Importer {
+	importer := NewImporter(r.executor, r.sql, r.config, r.ensureDatabasesAndRegionsReady)
+	checker := k8s.NewPodChecker(r.clientset, r.config.Kubernetes.Namespace)
+	importer.SetRestartCounter(func(ctx context.Context) (int32, error) {
+		return checker.RestartCount(ctx, r.config.Kubernetes.PodName)
+	})
+	return importer
+}
+
+// applyImportResult 汇总导入结果并写出导入报告，报告写入失败不影响恢复结果
+func (r *IoTDBRestorer) applyImportResult(importResult *ImportResult) {
+	r.result.TotalFiles = importResult.TotalFiles
+	r.result.SuccessCount = importResult.SuccessCount
+	r.result.FailedCount = importResult.FailedCount
+	r.result.SkippedCount = importResult.SkippedCount
+	r.result.FailedFiles = FailedFiles(importResult.Files)
+	r.result.Concurrency = importResult.Concurrency
+
+	report := NewImportReport(r.result.RunID, importResult)
+	path, err := report.WriteFile(r.config.Import.ReportDir, r.config.Import.ReportFormat)
+	if err != nil {
+		logger.Warn("写入导入报告失败", zap.Error(err))
+		return
+	}
+	r.result.ImportReport = path
+	logger.Info("导入报告已生成",
+		zap.String("path", path),
+		zap.Int("failed_files", len(r.result.FailedFiles)),
+	)
 }
 
 func (r *IoTDBRestorer) verifyDatabaseWriteRead(ctx context.Context) error {
 	logger.Info("步骤 4: 执行数据库写入和查询探测")
 
+	probed := false
+	for _, db := range r.managedDatabases() {
+		if db.ProbeSeries == "" {
+			continue
+		}
+		probed = true
+		if err := r.probeSeries(ctx, db); err != nil {
+			return err
+		}
+	}
+
+	if !probed {
+		logger.Info("未配置探测序列，跳过写读探测")
+	}
+	return nil
+}
+
+// probeSeries 向探测序列写入当前时间戳并读回校验
+func (r *IoTDBRestorer) probeSeries(ctx context.Context, db config.DatabaseConfig) error {
 	probe := &ProbeResult{
 		Executed:   true,
-		Database:   "root.energy",
-		SeriesPath: probeSeriesPath,
+		Database:   db.Name,
+		SeriesPath: db.ProbeSeries,
 	}
-	r.result.Probe = probe
+	r.result.Probes = append(r.result.Probes, probe)
 
-	stdout, stderr, err := r.execSQL(ctx, probeTimeseriesSQL)
-	if err != nil && !containsAlreadyExists(stdout, stderr, err) {
+	device, measurement := splitSeriesPath(db.ProbeSeries)
+	createSQL := fmt.Sprintf("create timeseries %s with datatype=INT64, encoding=RLE, compressor=SNAPPY", db.ProbeSeries)
+	if _, err := r.execSQL(ctx, createSQL); err != nil && !containsAlreadyExists(err) {
 		probe.Error = err.Error()
 		return err
 	}
@@ -735,26 +1451,30 @@ func (r *IoTDBRestorer) verifyDatabaseWriteRead(ctx context.Context) error {
 	probe.Value = probe.Timestamp
 
 	insertSQL := fmt.Sprintf(
-		"insert into root.energy.__restore_probe(time, restore_check) values(%d, %d)",
+		"insert into %s(time, %s) values(%d, %d)",
+		device,
+		measurement,
 		probe.Timestamp,
 		probe.Value,
 	)
-	if _, _, err := r.execSQL(ctx, insertSQL); err != nil {
+	if _, err := r.execSQL(ctx, insertSQL); err != nil {
 		probe.Error = err.Error()
 		return err
 	}
 
 	querySQL := fmt.Sprintf(
-		"select restore_check from root.energy.__restore_probe where time = %d",
+		"select %s from %s where time = %d",
+		measurement,
+		device,
 		probe.Timestamp,
 	)
-	queryOutput, _, err := r.execSQL(ctx, querySQL)
+	queryResult, err := r.execSQL(ctx, querySQL)
 	if err != nil {
 		probe.Error = err.Error()
 		return err
 	}
 
-	queryValue, err := extractSingleQueryValue(queryOutput)
+	queryValue, err := singleQueryValue(queryResult.Rows)
 	if err != nil {
 		probe.Error = err.Error()
 		return err
@@ -767,6 +1487,7 @@ func (r *IoTDBRestorer) verifyDatabaseWriteRead(ctx context.Context) error {
 	}
 
 	logger.Info("数据库探测成功",
+		zap.String("database", probe.Database),
 		zap.String("series", probe.SeriesPath),
 		zap.Int64("timestamp", probe.Timestamp),
 		zap.Int64("value", probe.Value),
@@ -775,12 +1496,18 @@ func (r *IoTDBRestorer) verifyDatabaseWriteRead(ctx context.Context) error {
 }
 
 // cleanup 清理临时文件
-func (r *IoTDBRestorer) cleanup(ctx context.Context, backupFile string) {
-	logger.Info("步骤 5: 清理临时文件")
+func (r *IoTDBRestorer) cleanup(ctx context.Context) {
+	logger.Info("步骤 6: 清理临时文件")
 
 	if r.config.Backup.UsesClusterStream() {
-		cleanupCmd := fmt.Sprintf("rm -f '%s' && rm -rf '%s'", r.clusterStreamArchivePath(), r.config.Backup.StagingDir)
-		if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", cleanupCmd}); err != nil {
+		if r.result.FailedCount > 0 {
+			logger.Warn("存在导入失败的文件，保留暂存目录以便通过 restore import --from-report 重试",
+				zap.String("staging_dir", r.config.Backup.StagingDir),
+				zap.Int("failed_count", r.result.FailedCount),
+			)
+			return
+		}
+		if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", r.clusterStreamCleanupCommand()}); err != nil {
 			logger.Warn("清理直连恢复临时文件失败",
 				zap.String("archive_path", r.clusterStreamArchivePath()),
 				zap.String("staging_dir", r.config.Backup.StagingDir),
@@ -795,31 +1522,28 @@ func (r *IoTDBRestorer) cleanup(ctx context.Context, backupFile string) {
 		return
 	}
 
-	cleanupCmd := fmt.Sprintf("rm -f %s/%s", podBackupPath, backupFile)
-	if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", cleanupCmd}); err != nil {
+	if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", backupCleanupCommand(r.backupFiles)}); err != nil {
 		logger.Warn("清理临时文件失败", zap.Error(err))
 	} else {
 		logger.Info("临时文件已删除")
 	}
 }
 
-// dryRun 干运行（仅检查，不实际执行）
-func (r *IoTDBRestorer) dryRun(ctx context.Context) (*RestoreResult, error) {
-	logger.Info("干运行模式，不执行实际操作")
-
-	r.result.EndTime = time.Now()
-	r.result.Duration = r.result.EndTime.Sub(r.result.StartTime)
+// dryRun 干运行：生成执行计划，不修改任何数据
+func (r *IoTDBRestorer) dryRun(ctx context.Context, opts RestoreOptions) (*RestoreResult, error) {
+	logger.Info("干运行模式，只生成执行计划，不执行实际操作")
 
+	plan, err := r.Plan(ctx, opts)
+	if err != nil {
+		return r.result, fmt.Errorf("生成执行计划失败: %w", err)
+	}
+	r.result.Plan = plan
+	r.result.RunID = r.journal.RunID()
 	return r.result, nil
 }
 
-func (r *IoTDBRestorer) execSQL(ctx context.Context, sql string) (string, string, error) {
-	cmd := fmt.Sprintf("%s -h %s -e \"%s\"",
-		r.config.IoTDB.CLIPath,
-		r.config.IoTDB.Host,
-		strings.ReplaceAll(sql, "\"", "\\\""),
-	)
-	return r.executor.Exec(ctx, []string{"sh", "-c", cmd})
+func (r *IoTDBRestorer) execSQL(ctx context.Context, sql string) (*SQLResult, error) {
+	return r.sql.Exec(ctx, sql)
 }
 
 func (r *IoTDBRestorer) liveDataDir() string {
@@ -850,6 +1574,25 @@ func (r *IoTDBRestorer) clusterStreamArchivePath() string {
 	return filepath.Join(archiveDir, name)
 }
 
+// clusterStreamCleanupCommand 删除直连恢复的临时归档和暂存目录
+func (r *IoTDBRestorer) clusterStreamCleanupCommand() string {
+	return fmt.Sprintf("rm -f '%s' && rm -rf '%s'", r.clusterStreamArchivePath(), r.config.Backup.StagingDir)
+}
+
+// backupCleanupCommand 删除下载到 Pod 内的备份文件
+func backupCleanupCommand(files []string) string {
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, filepath.Join(podBackupPath, file))
+	}
+	return fmt.Sprintf("rm -f %s", strings.Join(paths, " "))
+}
+
+// podDownloadCommand 在 Pod 内下载备份文件的命令
+func podDownloadCommand(remotePath, url string) string {
+	return fmt.Sprintf("wget -q -O '%s' '%s'", remotePath, url)
+}
+
 func parseFileList(output string) []string {
 	lines := splitLines(output)
 	files := make([]string, 0, len(lines))
@@ -885,8 +1628,7 @@ func splitLines(s string) []string {
 	return lines
 }
 
-func parseDatabaseList(output string) map[string]bool {
-	rows := parseCLITable(output)
+func databaseSet(rows []SQLRow) map[string]bool {
 	result := make(map[string]bool, len(rows))
 	for _, row := range rows {
 		db := strings.TrimSpace(row["Database"])
@@ -898,7 +1640,10 @@ func parseDatabaseList(output string) map[string]bool {
 }
 
 func parseRunningRegionCounts(output string) map[string]int {
-	rows := parseCLITable(output)
+	return runningRegionCounts(parseCLITable(output))
+}
+
+func runningRegionCounts(rows []SQLRow) map[string]int {
 	counts := make(map[string]int)
 	for _, row := range rows {
 		if !strings.EqualFold(strings.TrimSpace(row["Status"]), "Running") {
@@ -913,12 +1658,12 @@ func parseRunningRegionCounts(output string) map[string]int {
 	return counts
 }
 
-func formatRegionSnapshot(snapshot *regionSnapshot) string {
+func formatRegionSnapshot(databases []string, snapshot *regionSnapshot) string {
 	if snapshot == nil {
 		return "no snapshot"
 	}
-	parts := make([]string, 0, len(managedDatabases))
-	for _, db := range managedDatabases {
+	parts := make([]string, 0, len(databases))
+	for _, db := range databases {
 		parts = append(parts, fmt.Sprintf(
 			"%s(db=%t,schema=%d,data=%d)",
 			db,
@@ -931,36 +1676,91 @@ func formatRegionSnapshot(snapshot *regionSnapshot) string {
 }
 
 func (r *IoTDBRestorer) databaseExists(ctx context.Context, database string) (bool, error) {
-	output, _, err := r.execSQL(ctx, "show databases")
+	res, err := r.execSQL(ctx, "show databases")
 	if err != nil {
 		return false, err
 	}
-	return parseDatabaseList(output)[database], nil
+	return databaseSet(res.Rows)[database], nil
+}
+
+// managedDatabases 返回本次恢复的数据库，选择性恢复时只包含选中的数据库
+func (r *IoTDBRestorer) managedDatabases() []config.DatabaseConfig {
+	if r.databases != nil {
+		return r.databases
+	}
+	return r.config.Databases
+}
+
+// databaseNames 返回本次恢复的数据库名称
+func (r *IoTDBRestorer) databaseNames() []string {
+	return configDatabaseNames(r.managedDatabases())
+}
+
+func configDatabaseNames(databases []config.DatabaseConfig) []string {
+	names := make([]string, 0, len(databases))
+	for _, db := range databases {
+		names = append(names, db.Name)
+	}
+	return names
+}
+
+// createDatabase 按配置创建数据库并设置 TTL
+func (r *IoTDBRestorer) createDatabase(ctx context.Context, db config.DatabaseConfig) error {
+	if _, err := r.execSQL(ctx, createDatabaseSQL(db)); err != nil {
+		return fmt.Errorf("创建数据库失败 %s: %w", db.Name, err)
+	}
+
+	if db.TTL > 0 {
+		ttlSQL := fmt.Sprintf("set ttl to %s %d", db.Name, db.TTL)
+		if _, err := r.execSQL(ctx, ttlSQL); err != nil {
+			return fmt.Errorf("设置数据库 TTL 失败 %s: %w", db.Name, err)
+		}
+	}
+
+	logger.Info("创建数据库成功",
+		zap.String("database", db.Name),
+		zap.Int64("ttl", db.TTL),
+	)
+	return nil
+}
+
+// createDatabaseSQL 按配置的 Region 组数构建建库语句
+func createDatabaseSQL(db config.DatabaseConfig) string {
+	sql := fmt.Sprintf("create database %s", db.Name)
+	var attrs []string
+	if db.SchemaRegionGroupNum > 0 {
+		attrs = append(attrs, fmt.Sprintf("SCHEMA_REGION_GROUP_NUM=%d", db.SchemaRegionGroupNum))
+	}
+	if db.DataRegionGroupNum > 0 {
+		attrs = append(attrs, fmt.Sprintf("DATA_REGION_GROUP_NUM=%d", db.DataRegionGroupNum))
+	}
+	if len(attrs) > 0 {
+		sql += " with " + strings.Join(attrs, ", ")
+	}
+	return sql
 }
 
 func (r *IoTDBRestorer) bootstrapRegions(ctx context.Context) error {
 	now := time.Now().UnixMilli()
-	bootstrapSeries := []string{
-		"root.emsplus.__restore_bootstrap.status",
-		"root.energy.__restore_bootstrap.status",
-	}
 
-	for _, series := range bootstrapSeries {
+	for _, db := range r.managedDatabases() {
+		series := db.BootstrapSeries
 		createSQL := fmt.Sprintf(
 			"create timeseries %s with datatype=INT64, encoding=RLE, compressor=SNAPPY",
 			series,
 		)
-		stdout, stderr, err := r.execSQL(ctx, createSQL)
-		if err != nil && !containsAlreadyExists(stdout, stderr, err) {
+		if _, err := r.execSQL(ctx, createSQL); err != nil && !containsAlreadyExists(err) {
 			return fmt.Errorf("创建 bootstrap timeseries 失败 %s: %w", series, err)
 		}
 
+		device, measurement := splitSeriesPath(series)
 		insertSQL := fmt.Sprintf(
-			"insert into %s(time,status) values(%d,1)",
-			strings.TrimSuffix(series, ".status"),
+			"insert into %s(time,%s) values(%d,1)",
+			device,
+			measurement,
 			now,
 		)
-		if _, _, err := r.execSQL(ctx, insertSQL); err != nil {
+		if _, err := r.execSQL(ctx, insertSQL); err != nil {
 			return fmt.Errorf("写入 bootstrap 数据失败 %s: %w", series, err)
 		}
 	}
@@ -968,15 +1768,27 @@ func (r *IoTDBRestorer) bootstrapRegions(ctx context.Context) error {
 	return nil
 }
 
-func containsAlreadyExists(stdout, stderr string, err error) bool {
-	combined := strings.ToLower(strings.Join([]string{stdout, stderr, err.Error()}, " "))
+// splitSeriesPath 将序列路径拆分为设备路径和测点名
+func splitSeriesPath(series string) (string, string) {
+	idx := strings.LastIndex(series, ".")
+	if idx < 0 {
+		return series, ""
+	}
+	return series[:idx], series[idx+1:]
+}
+
+func containsAlreadyExists(err error) bool {
+	combined := strings.ToLower(err.Error())
 	return strings.Contains(combined, "already exist") ||
 		strings.Contains(combined, "already been created") ||
 		strings.Contains(combined, "path already exists")
 }
 
 func extractSingleQueryValue(output string) (string, error) {
-	rows := parseCLITable(output)
+	return singleQueryValue(parseCLITable(output))
+}
+
+func singleQueryValue(rows []SQLRow) (string, error) {
 	if len(rows) == 0 {
 		return "", fmt.Errorf("查询结果为空")
 	}
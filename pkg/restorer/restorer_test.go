@@ -1,10 +1,16 @@
 package restorer
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/iotdb"
 )
 
 func TestParseCLITable(t *testing.T) {
@@ -96,6 +102,16 @@ func TestIsRetryableImportError(t *testing.T) {
 			err:  errors.New("TsFile /tmp/a.tsfile is empty"),
 			want: false,
 		},
+		{
+			name: "session status 701 on region",
+			err:  fmt.Errorf("导入失败: %w", &iotdb.StatusError{Code: 701, Message: "Auto create region failed"}),
+			want: true,
+		},
+		{
+			name: "session status 701 without region",
+			err:  &iotdb.StatusError{Code: 701, Message: "TsFile is broken"},
+			want: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -107,6 +123,120 @@ func TestIsRetryableImportError(t *testing.T) {
 	}
 }
 
+func TestParseCLIStatus(t *testing.T) {
+	output := "Msg: org.apache.iotdb.jdbc.IoTDBSQLException: 508: Path [root.energy.a] already exists"
+
+	status := parseCLIStatus(output)
+	if status == nil {
+		t.Fatalf("expected status")
+	}
+	if status.Code != 508 || status.Message != "Path [root.energy.a] already exists" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if !containsAlreadyExists(status) {
+		t.Fatalf("expected already exists")
+	}
+
+	if parseCLIStatus("Msg: The statement is executed successfully.") != nil {
+		t.Fatalf("expected no status for success output")
+	}
+}
+
+func TestSessionClientReachesMaxConcurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		importer config.ImportConfig
+		want     int
+	}{
+		{
+			name:     "adaptive raises above concurrency",
+			importer: config.ImportConfig{Concurrency: 4, Lanes: 1, Adaptive: config.AdaptiveConfig{Enabled: true}},
+			want:     8,
+		},
+		{
+			name:     "explicit max concurrency",
+			importer: config.ImportConfig{Concurrency: 2, Lanes: 1, Adaptive: config.AdaptiveConfig{Enabled: true, MaxConcurrency: 6}},
+			want:     6,
+		},
+		{
+			name:     "parallel lanes",
+			importer: config.ImportConfig{Concurrency: 3, Lanes: 2},
+			want:     6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 接受连接但不应答，每个进行中的 SQL 占用一个连接
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+			defer listener.Close()
+			var mu sync.Mutex
+			var conns []net.Conn
+			go func() {
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					conns = append(conns, conn)
+					mu.Unlock()
+				}
+			}()
+			accepted := func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(conns)
+			}
+			defer func() {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, conn := range conns {
+					conn.Close()
+				}
+			}()
+
+			addr := listener.Addr().(*net.TCPAddr)
+			cfg := &config.Config{
+				IoTDB:  config.IoTDBConfig{Client: "session", Host: addr.IP.String(), Port: addr.Port},
+				Import: tt.importer,
+			}
+			client, err := NewSQLClient(nil, cfg)
+			if err != nil {
+				t.Fatalf("new sql client: %v", err)
+			}
+			defer client.Close()
+
+			// 导入的 load 并发之外，再留一个会话给其他 SQL
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			for i := 0; i < tt.want+2; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					client.Exec(ctx, "load '/tmp/a.tsfile'")
+				}()
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for accepted() < tt.want+1 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			time.Sleep(50 * time.Millisecond)
+			got := accepted()
+			cancel()
+			wg.Wait()
+
+			if got != tt.want+1 {
+				t.Fatalf("expected %d concurrent sessions, got %d", tt.want+1, got)
+			}
+		})
+	}
+}
+
 func TestRestoreScanRootUsesNestedExtractPath(t *testing.T) {
 	restorer := &IoTDBRestorer{
 		config: &config.Config{
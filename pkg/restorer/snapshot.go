@@ -0,0 +1,277 @@
+package restorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/archive"
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
+	"github.com/vnnox/iotdb-restore-tool/pkg/lock"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// rollbackTimeout 回滚的超时时间，回滚不受已取消的恢复 ctx 影响
+const rollbackTimeout = 20 * time.Minute
+
+// RollbackResult 安全模式下的快照和回滚情况
+type RollbackResult struct {
+	// Snapshot Pod 内的快照目录
+	Snapshot string
+	// RolledBack 恢复失败后已删除导入的数据并从快照重新加载恢复前的数据
+	RolledBack bool
+	// Error 回滚失败的原因，快照保留在 Snapshot 中
+	Error string
+}
+
+// snapshotMove 快照的一个目录：live 为在线数据目录，saved 为快照中的位置
+type snapshotMove struct {
+	live  string
+	saved string
+}
+
+// safetyEnabled 是否在删除阶段改为移出数据的安全模式
+func (r *IoTDBRestorer) safetyEnabled(skipDelete bool) bool {
+	return r.config.Safety.Enabled && !skipDelete
+}
+
+// snapshotDir 本次运行的快照目录，续传时与之前的运行一致
+func (r *IoTDBRestorer) snapshotDir() string {
+	return filepath.Join(r.config.Safety.SnapshotDir, r.journal.RunID())
+}
+
+// snapshotMoves 需要快照的目录。选择性恢复只快照选中数据库的数据目录
+func (r *IoTDBRestorer) snapshotMoves() []snapshotMove {
+	liveDataRoot := r.liveDataDir()
+	snapshot := r.snapshotDir()
+	if !r.selective() {
+		return []snapshotMove{{live: liveDataRoot, saved: filepath.Join(snapshot, "data")}}
+	}
+
+	var moves []snapshotMove
+	for _, db := range r.databaseNames() {
+		for _, dir := range []string{"sequence", "unsequence"} {
+			moves = append(moves, snapshotMove{
+				live:  filepath.Join(liveDataRoot, dir, db),
+				saved: filepath.Join(snapshot, dir, db),
+			})
+		}
+	}
+	return moves
+}
+
+// linkCommand 以硬链接把源目录复制到快照中，在线文件保持原位，DataNode 运行时也可以执行。
+// 先写入临时目录再重命名，快照已存在时（续传）不再覆盖
+func linkCommand(src, dst string) string {
+	tmp := dst + ".tmp"
+	return fmt.Sprintf("if [ -e '%s' ] && [ ! -e '%s' ]; then rm -rf '%s' && mkdir -p '%s' && cp -al '%s' '%s' && mv '%s' '%s'; fi",
+		src, dst, tmp, filepath.Dir(dst), src, tmp, tmp, dst)
+}
+
+// snapshotLiveData 安全模式的删除阶段：确认备份完整存在后，以硬链接把在线数据保存到快照目录，
+// 再像删除阶段一样删除数据库并清理在线数据目录。不清理 backup_before_restore 目录
+func (r *IoTDBRestorer) snapshotLiveData(ctx context.Context) error {
+	logger.Info("步骤 0: 安全模式，确认备份可用后为在线数据创建快照",
+		zap.String("snapshot", r.snapshotDir()),
+	)
+
+	if err := r.validateBackupPresent(ctx); err != nil {
+		return fmt.Errorf("备份检查未通过，未改动在线数据: %w", err)
+	}
+
+	// 未刷盘的数据不在 tsfile 中，快照会缺少这部分数据
+	if _, err := r.execSQL(ctx, "flush"); err != nil {
+		return fmt.Errorf("刷新数据失败，未改动在线数据: %w", err)
+	}
+	for _, move := range r.snapshotMoves() {
+		if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", linkCommand(move.live, move.saved)}); err != nil {
+			return fmt.Errorf("创建 %s 的快照失败，未改动在线数据: %w", move.live, err)
+		}
+	}
+	logger.Info("快照已创建", zap.String("snapshot", r.snapshotDir()))
+
+	// 快照完整后才删除数据库：DataNode 自行释放并删除在线文件，快照中的硬链接不受影响
+	r.snapshotted = true
+	r.dropDatabases(ctx)
+	return r.runCleanup(ctx, r.liveDataCleanupCommands())
+}
+
+// validateBackupPresent 在改动在线数据前确认恢复输入可用：直连恢复检查源 Pod 正在运行，
+// 备份归档必须能在备份源中定位到且所有分卷齐全，不回退到默认文件名
+func (r *IoTDBRestorer) validateBackupPresent(ctx context.Context) error {
+	if r.config.Backup.UsesClusterStream() {
+		return r.checkSourcePod(ctx)
+	}
+	if len(r.journal.BackupFiles()) > 0 {
+		return nil
+	}
+
+	backup, err := r.locateBackup(ctx, r.result.Timestamp)
+	if err != nil {
+		return err
+	}
+	if err := checkBackupComplete(backup); err != nil {
+		return err
+	}
+
+	r.backupFiles = backup.Files()
+	if err := r.journal.SetBackupFiles(ctx, r.backupFiles); err != nil {
+		logger.Warn("更新运行日志失败", zap.Error(err))
+	}
+	logger.Info("备份归档完整",
+		zap.String("name", backup.Name),
+		zap.Int("files", len(r.backupFiles)),
+		zap.String("size", downloader.FormatBytes(backup.Size)),
+	)
+	return nil
+}
+
+// checkBackupComplete 检查备份大小不为 0，分卷从 part000 起连续
+func checkBackupComplete(backup *downloader.BackupObject) error {
+	if backup.Size <= 0 {
+		return fmt.Errorf("备份 %s 的大小为 0", backup.Name)
+	}
+	for i, part := range backup.Parts {
+		if _, n, ok := archive.SplitPart(part); !ok || n != i {
+			return fmt.Errorf("备份 %s 缺少分卷 %s", backup.Name, archive.PartName(backup.Name, i))
+		}
+	}
+	return nil
+}
+
+// checkSourcePod 检查直连恢复的源 Pod 存在且正在运行
+func (r *IoTDBRestorer) checkSourcePod(ctx context.Context) error {
+	checker := k8s.NewPodChecker(r.clientset, r.config.Backup.SourceNamespace)
+	exists, err := checker.Exists(ctx, r.config.Backup.SourcePodName)
+	if err != nil {
+		return fmt.Errorf("检查源 Pod 失败: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("源 Pod %s/%s 不存在", r.config.Backup.SourceNamespace, r.config.Backup.SourcePodName)
+	}
+	running, err := checker.IsRunning(ctx, r.config.Backup.SourcePodName)
+	if err != nil {
+		return fmt.Errorf("检查源 Pod 状态失败: %w", err)
+	}
+	if !running {
+		return fmt.Errorf("源 Pod %s/%s 未处于运行状态", r.config.Backup.SourceNamespace, r.config.Backup.SourcePodName)
+	}
+	return nil
+}
+
+// finishSnapshot 恢复结束后处理快照：失败时回滚，成功时按配置删除快照
+func (r *IoTDBRestorer) finishSnapshot(ctx context.Context, restoreErr error) {
+	if !r.snapshotted {
+		return
+	}
+	r.result.Rollback = &RollbackResult{Snapshot: r.snapshotDir()}
+	if restoreErr == nil {
+		if r.config.Safety.KeepSnapshot {
+			logger.Info("恢复成功，按配置保留快照", zap.String("snapshot", r.snapshotDir()))
+			return
+		}
+		if err := r.removeSnapshot(ctx); err != nil {
+			logger.Warn("删除快照失败，请手动清理", zap.String("snapshot", r.snapshotDir()), zap.Error(err))
+		}
+		return
+	}
+
+	// 锁已被其他任务接管时，新任务可能正在操作同一个 Pod，回滚会破坏它的数据，保留快照由人工还原
+	if cause := context.Cause(ctx); errors.Is(cause, lock.ErrLost) {
+		r.result.Rollback.Error = fmt.Sprintf("%s，未执行回滚", cause)
+		logger.Error("恢复任务锁已被其他任务接管，跳过回滚，恢复前的数据保留在快照中，请手动还原",
+			zap.String("snapshot", r.snapshotDir()),
+			zap.Error(restoreErr),
+		)
+		return
+	}
+
+	// 恢复 ctx 可能已被取消（如收到 SIGTERM），回滚使用独立的超时
+	rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+
+	logger.Warn("恢复失败，开始回滚到恢复前的数据",
+		zap.String("failed_phase", string(r.result.FailedPhase)),
+		zap.String("snapshot", r.snapshotDir()),
+		zap.Error(restoreErr),
+	)
+	if err := r.rollback(rollbackCtx); err != nil {
+		r.result.Rollback.Error = err.Error()
+		logger.Error("回滚失败，恢复前的数据保留在快照中，请手动还原",
+			zap.String("snapshot", r.snapshotDir()),
+			zap.Error(err),
+		)
+		return
+	}
+	r.result.Rollback.RolledBack = true
+	logger.Info("已回滚到恢复前的数据", zap.String("run_id", r.journal.RunID()))
+
+	// 数据已还原，本次运行不再续传；失败时为续传保留的临时文件一并清理
+	if r.journal.Persistent() {
+		r.cleanup(rollbackCtx)
+	}
+	if err := r.journal.Finish(rollbackCtx); err != nil {
+		logger.Warn("更新运行日志失败", zap.Error(err))
+	}
+}
+
+// rollback 与删除阶段一样先删除数据库（DataNode 不再引用在线文件）再清理目录，
+// 重启 Pod 后重建数据库，从快照重新加载恢复前的 tsfile
+func (r *IoTDBRestorer) rollback(ctx context.Context) error {
+	r.dropDatabases(ctx)
+	if err := r.runCleanup(ctx, r.liveDataCleanupCommands()); err != nil {
+		return fmt.Errorf("清理已导入的数据失败: %w", err)
+	}
+
+	if err := r.restartPodAndWaitReady(ctx); err != nil {
+		return fmt.Errorf("回滚后重启 Pod 失败: %w", err)
+	}
+	if err := r.ensureDatabasesAndRegionsReady(ctx); err != nil {
+		return fmt.Errorf("回滚后重建数据库失败: %w", err)
+	}
+	if err := r.reloadSnapshot(ctx); err != nil {
+		return err
+	}
+
+	if err := r.removeSnapshot(ctx); err != nil {
+		logger.Warn("删除快照失败，请手动清理", zap.String("snapshot", r.snapshotDir()), zap.Error(err))
+	}
+	return nil
+}
+
+// reloadSnapshot 加载快照中的 tsfile，还原恢复前的数据和序列
+func (r *IoTDBRestorer) reloadSnapshot(ctx context.Context) error {
+	findCmd := fmt.Sprintf("find %s -name '*.tsfile' -type f", r.snapshotDir())
+	output, stderr, err := r.executor.Exec(ctx, []string{"sh", "-c", findCmd})
+	if err != nil {
+		return fmt.Errorf("查找快照中的 tsfile 失败: %w: %s", err, stderr)
+	}
+	files := parseFileList(output)
+	if len(files) == 0 {
+		logger.Info("快照中没有 tsfile，无需加载", zap.String("snapshot", r.snapshotDir()))
+		return nil
+	}
+
+	result, err := r.newImporter().Import(ctx, files)
+	if err != nil {
+		return fmt.Errorf("加载快照失败: %w", err)
+	}
+	if result.FailedCount > 0 {
+		return fmt.Errorf("加载快照失败: %d 个 tsfile 加载失败", result.FailedCount)
+	}
+	logger.Info("已从快照加载恢复前的数据", zap.Int("files", result.SuccessCount))
+	return nil
+}
+
+func (r *IoTDBRestorer) removeSnapshot(ctx context.Context) error {
+	cmd := fmt.Sprintf("rm -rf '%s'", r.snapshotDir())
+	if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", cmd}); err != nil {
+		return err
+	}
+	logger.Info("快照已删除", zap.String("snapshot", r.snapshotDir()))
+	return nil
+}
@@ -0,0 +1,234 @@
+package restorer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/lock"
+	"github.com/vnnox/iotdb-restore-tool/pkg/selector"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newSafetyRestorer 启用安全模式，并在在线数据目录中放入恢复前的数据
+func newSafetyRestorer(t *testing.T) (*IoTDBRestorer, *fakePod, *fake.Clientset, []string) {
+	t.Helper()
+
+	r, pod, clientset, _ := newTestRestorerWithServer(t, nil)
+	r.config.Safety.Enabled = true
+	pod.databases["root.emsplus"] = true
+
+	live := r.liveDataDir()
+	existing := []string{
+		live + "/sequence/root.energy/1/2920/1760000000000-1-0-0.tsfile",
+		live + "/sequence/root.emsplus/2/2920/1760000000000-1-0-0.tsfile",
+	}
+	for _, file := range existing {
+		pod.files[file] = true
+	}
+	return r, pod, clientset, existing
+}
+
+// podRestarts 返回删除 Pod 的次数
+func podRestarts(clientset *fake.Clientset) int {
+	restarts := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "delete" && action.GetResource().Resource == "pods" {
+			restarts++
+		}
+	}
+	return restarts
+}
+
+func snapshotFiles(r *IoTDBRestorer, pod *fakePod) string {
+	pod.mu.Lock()
+	defer pod.mu.Unlock()
+	return pod.listFiles(r.config.Safety.SnapshotDir, "")
+}
+
+func TestRestoreSafetyRemovesSnapshotOnSuccess(t *testing.T) {
+	r, pod, clientset, existing := newSafetyRestorer(t)
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if result.SuccessCount != len(testTsFiles) {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+	// 快照创建后与删除阶段一样删除数据库，DataNode 不再引用在线文件
+	if got := pod.executedSQL("delete database "); len(got) != 2 {
+		t.Fatalf("safety mode should drop databases after the snapshot, got %v", got)
+	}
+	if containsCommand(pod, "backup_before_restore") {
+		t.Fatalf("safety mode should not remove backup_before_restore")
+	}
+	for _, file := range existing {
+		if pod.hasFile(file) {
+			t.Fatalf("previous data should be removed from the live dir: %s", file)
+		}
+	}
+	if files := snapshotFiles(r, pod); files != "" {
+		t.Fatalf("snapshot should be removed after success, got %s", files)
+	}
+	if rb := result.Rollback; rb == nil || rb.RolledBack || !strings.HasSuffix(rb.Snapshot, result.RunID) {
+		t.Fatalf("unexpected rollback result: %+v", rb)
+	}
+	if n := podRestarts(clientset); n != 1 {
+		t.Fatalf("expected 1 restart, got %d", n)
+	}
+}
+
+func TestRestoreSafetyKeepSnapshot(t *testing.T) {
+	r, pod, _, existing := newSafetyRestorer(t)
+	r.config.Safety.KeepSnapshot = true
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	for _, file := range existing {
+		saved := result.Rollback.Snapshot + "/data" + strings.TrimPrefix(file, r.liveDataDir())
+		if !pod.hasFile(saved) {
+			t.Fatalf("expected %s in snapshot, got %s", saved, snapshotFiles(r, pod))
+		}
+	}
+}
+
+func TestRestoreSafetyRollsBack(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter selector.Filter
+		setup  func(pod *fakePod)
+		phase  Phase
+	}{
+		{
+			name: "import failure",
+			setup: func(pod *fakePod) {
+				pod.replySQL("load ", "Msg: 305: TsFile is broken\n", errExit)
+			},
+			phase: PhaseImport,
+		},
+		{
+			name: "probe failure",
+			setup: func(pod *fakePod) {
+				pod.replySQL("select restore_check", renderCLITable(
+					[]string{"Time", "root.energy.__restore_probe.restore_check"},
+					[][]string{{"1", "42"}},
+				), nil)
+			},
+			phase: PhaseProbe,
+		},
+		{
+			name:   "selective probe failure",
+			filter: selector.Filter{Databases: []string{"root.energy"}},
+			setup: func(pod *fakePod) {
+				pod.replySQL("select restore_check", renderCLITable(
+					[]string{"Time", "root.energy.__restore_probe.restore_check"},
+					[][]string{{"1", "42"}},
+				), nil)
+			},
+			phase: PhaseProbe,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, pod, clientset, existing := newSafetyRestorer(t)
+			tt.setup(pod)
+
+			result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp, Filter: tt.filter})
+			if err == nil {
+				t.Fatalf("expected restore to fail")
+			}
+			if result.FailedPhase != tt.phase {
+				t.Fatalf("expected failed phase %s, got %s", tt.phase, result.FailedPhase)
+			}
+			if rb := result.Rollback; rb == nil || !rb.RolledBack || rb.Error != "" {
+				t.Fatalf("expected rollback, got %+v", rb)
+			}
+			for _, file := range existing {
+				if !pod.hasFile(file) {
+					t.Fatalf("previous data not restored: %s", file)
+				}
+			}
+			pod.mu.Lock()
+			imported := pod.listFiles(r.liveDataDir(), ".tsfile")
+			pod.mu.Unlock()
+			if got := strings.Count(imported, "\n"); got != len(existing) {
+				t.Fatalf("live dir should only contain previous data, got %s", imported)
+			}
+			// 恢复前的 tsfile 从快照重新加载，而不是在 DataNode 运行时移回原位
+			reloaded := 0
+			for _, file := range loadedFiles(pod) {
+				if strings.HasPrefix(file, result.Rollback.Snapshot+"/") {
+					reloaded++
+				}
+			}
+			if reloaded == 0 {
+				t.Fatalf("expected tsfiles to be reloaded from the snapshot, loaded %v", loadedFiles(pod))
+			}
+			if files := snapshotFiles(r, pod); files != "" {
+				t.Fatalf("snapshot should be removed after rollback, got %s", files)
+			}
+			if n := podRestarts(clientset); n != 2 {
+				t.Fatalf("expected restart after rollback, got %d restarts", n)
+			}
+		})
+	}
+}
+
+func TestRestoreSafetySkipsRollbackWhenLockLost(t *testing.T) {
+	r, pod, clientset, existing := newSafetyRestorer(t)
+
+	// 导入期间锁被其他任务接管
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	pod.onSQL = func(sql string) {
+		if strings.HasPrefix(sql, "load ") {
+			cancel(lock.ErrLost)
+		}
+	}
+
+	result, err := r.Restore(ctx, RestoreOptions{Timestamp: testTimestamp})
+	if err == nil {
+		t.Fatalf("expected restore to fail")
+	}
+	rb := result.Rollback
+	if rb == nil || rb.RolledBack || !strings.Contains(rb.Error, "接管") {
+		t.Fatalf("rollback should be skipped, got %+v", rb)
+	}
+	for _, file := range existing {
+		saved := rb.Snapshot + "/data" + strings.TrimPrefix(file, r.liveDataDir())
+		if !pod.hasFile(saved) {
+			t.Fatalf("snapshot should be kept for manual recovery, missing %s in %s", saved, snapshotFiles(r, pod))
+		}
+	}
+	if n := podRestarts(clientset); n != 1 {
+		t.Fatalf("pod should not be restarted by rollback, got %d restarts", n)
+	}
+}
+
+func TestRestoreSafetyRequiresBackup(t *testing.T) {
+	r, pod, clientset, existing := newSafetyRestorer(t)
+
+	// 跳过预检，覆盖快照阶段自身的备份检查
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: "20260101000000", SkipPreflight: true})
+	if err == nil {
+		t.Fatalf("expected missing backup error")
+	}
+	if result.FailedPhase != PhaseSnapshot {
+		t.Fatalf("expected failed phase %s, got %s", PhaseSnapshot, result.FailedPhase)
+	}
+	if result.Rollback != nil {
+		t.Fatalf("nothing should be rolled back: %+v", result.Rollback)
+	}
+	for _, file := range existing {
+		if !pod.hasFile(file) {
+			t.Fatalf("live data should be untouched: %s", file)
+		}
+	}
+	if n := podRestarts(clientset); n != 0 {
+		t.Fatalf("pod should not be restarted, got %d restarts", n)
+	}
+}
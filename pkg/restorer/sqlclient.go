@@ -0,0 +1,145 @@
+package restorer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/iotdb"
+	"github.com/vnnox/iotdb-restore-tool/pkg/k8s"
+)
+
+// SQLClient 执行 IoTDB SQL 语句
+type SQLClient interface {
+	Exec(ctx context.Context, sql string) (*SQLResult, error)
+	Close() error
+}
+
+// SQLResult SQL 执行结果
+type SQLResult struct {
+	Rows []SQLRow
+	// Output cli 后端的原始输出，session 后端为空
+	Output string
+}
+
+// NewSQLClient 根据 iotdb.client 配置创建 SQL 客户端
+func NewSQLClient(executor k8s.CommandExecutor, cfg *config.Config) (SQLClient, error) {
+	switch strings.ToLower(cfg.IoTDB.Client) {
+	case "", "cli":
+		return newCLIClient(executor, &cfg.IoTDB), nil
+	case "session":
+		// 每个导入通道的并发可升到 MaxAdaptiveConcurrency，另留一个会话给导入期间的其他 SQL
+		poolSize := cfg.Import.MaxAdaptiveConcurrency()*max(cfg.Import.Lanes, 1) + 1
+		return newSessionClient(&cfg.IoTDB, poolSize), nil
+	default:
+		return nil, fmt.Errorf("未知的 IoTDB 客户端类型: %s", cfg.IoTDB.Client)
+	}
+}
+
+// cliClient 通过 Pod 内的 start-cli.sh 执行 SQL
+type cliClient struct {
+	executor k8s.CommandExecutor
+	config   *config.IoTDBConfig
+}
+
+func newCLIClient(executor k8s.CommandExecutor, cfg *config.IoTDBConfig) *cliClient {
+	return &cliClient{
+		executor: executor,
+		config:   cfg,
+	}
+}
+
+// cliStatusPattern 匹配 start-cli.sh 输出的错误状态，如 "Msg: 301: ..."
+var cliStatusPattern = regexp.MustCompile(`Msg:\s*(?:[\w.]+Exception:\s*)?(\d{3}):\s*(.*)`)
+
+func (c *cliClient) Exec(ctx context.Context, sql string) (*SQLResult, error) {
+	cmd := fmt.Sprintf("%s -h %s -p %d -u %s -pw %s -e \"%s\"",
+		c.config.CLIPath,
+		c.config.Host,
+		c.config.Port,
+		shellQuote(c.config.Username),
+		shellQuote(c.config.Password),
+		strings.ReplaceAll(sql, "\"", "\\\""),
+	)
+
+	stdout, stderr, err := c.executor.Exec(ctx, []string{"sh", "-c", cmd})
+	output := strings.TrimSpace(strings.Join([]string{stdout, stderr}, "\n"))
+	if err != nil {
+		if status := parseCLIStatus(output); status != nil {
+			return nil, fmt.Errorf("执行命令失败: %w", status)
+		}
+		msg := strings.TrimSpace(stderr)
+		if msg == "" {
+			msg = strings.TrimSpace(stdout)
+		}
+		return nil, fmt.Errorf("执行命令失败: %w: %s", err, msg)
+	}
+
+	return &SQLResult{
+		Rows:   parseCLITable(stdout),
+		Output: output,
+	}, nil
+}
+
+func (c *cliClient) Close() error {
+	return nil
+}
+
+// parseCLIStatus 从 start-cli.sh 输出中提取 IoTDB 状态码
+func parseCLIStatus(output string) *iotdb.StatusError {
+	match := cliStatusPattern.FindStringSubmatch(output)
+	if match == nil {
+		return nil
+	}
+	code, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil
+	}
+	return &iotdb.StatusError{Code: int32(code), Message: strings.TrimSpace(match[2])}
+}
+
+// sessionClient 通过原生会话协议直连 IoTDB
+type sessionClient struct {
+	pool *iotdb.Pool
+}
+
+func newSessionClient(cfg *config.IoTDBConfig, poolSize int) *sessionClient {
+	return &sessionClient{
+		pool: iotdb.NewPool(iotdb.Options{
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			Username: cfg.Username,
+			Password: cfg.Password,
+		}, poolSize),
+	}
+}
+
+func (c *sessionClient) Exec(ctx context.Context, sql string) (*SQLResult, error) {
+	res, err := c.pool.Execute(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]SQLRow, 0, len(res.Rows))
+	for _, values := range res.Rows {
+		row := make(SQLRow, len(res.Columns))
+		for i, column := range res.Columns {
+			if i < len(values) {
+				row[column] = values[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return &SQLResult{Rows: rows}, nil
+}
+
+func (c *sessionClient) Close() error {
+	return c.pool.Close()
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
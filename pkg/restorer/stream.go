@@ -0,0 +1,179 @@
+package restorer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/archive"
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// streamBufferSize 流式解压的读缓冲，同时用于预读归档文件头
+const streamBufferSize = 1 << 20
+
+// streamBackup 将备份从对象存储直接流式传给 Pod 内的 tar 解压，不在本地和 Pod 中落盘。
+// 分卷按顺序依次读取；每个文件读完时校验大小和校验和，不一致时中止解压并返回 *downloader.ChecksumError。
+func (r *IoTDBRestorer) streamBackup(ctx context.Context, files []string) error {
+	logger.Info("使用流式下载 + 解压策略", zap.Strings("files", files))
+
+	d, err := downloader.NewDownloader(&r.config.Backup)
+	if err != nil {
+		return err
+	}
+
+	volumes := &volumeReader{ctx: ctx, downloader: d}
+	for _, file := range files {
+		url := fmt.Sprintf("%s/%s", r.config.Backup.BaseURL, file)
+		checksum, err := r.resolveChecksum(ctx, url)
+		if err != nil {
+			return err
+		}
+		volumes.urls = append(volumes.urls, url)
+		volumes.expected = append(volumes.expected, checksum)
+	}
+	defer volumes.Close()
+
+	input := bufio.NewReaderSize(volumes, streamBufferSize)
+	header, err := input.Peek(archive.MagicSize)
+	if err != nil && err != io.EOF {
+		if volumes.err != nil {
+			return volumes.err
+		}
+		return fmt.Errorf("读取归档文件头失败: %w", err)
+	}
+	format := archive.Detect(header, files[0])
+	if format == archive.Unknown {
+		return fmt.Errorf("无法识别备份归档格式（支持 tar、tar.gz、tar.zst、tar.lz4）: %s", files[0])
+	}
+
+	flags, err := r.streamTarFlags(ctx, format)
+	if err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf("tar --overwrite %s-xf - -C %s", flags, r.config.IoTDB.DataDir)
+	logger.Info("开始流式解压",
+		zap.String("format", string(format)),
+		zap.String("cmd", cmd),
+	)
+
+	var stdout, stderr bytes.Buffer
+	execErr := r.executor.ExecStream(ctx, []string{"sh", "-c", cmd}, input, &stdout, &stderr)
+	// tar 读到归档结束标记即退出，剩余的填充数据也要读完才能完成校验
+	if execErr == nil && volumes.err == nil {
+		if _, err := io.Copy(io.Discard, input); err != nil && volumes.err == nil {
+			volumes.err = err
+		}
+	}
+	if volumes.err != nil {
+		return volumes.err
+	}
+	if execErr != nil {
+		return fmt.Errorf("流式解压失败: %w: %s", execErr, strings.TrimSpace(stderr.String()))
+	}
+
+	for i, sum := range volumes.checksums {
+		logger.Info("流式下载校验通过",
+			zap.String("file", files[i]),
+			zap.String("checksum", sum.String()),
+			zap.String("source", volumes.source(i)),
+		)
+	}
+	logger.Info("流式解压完成", zap.String("output", strings.TrimSpace(stdout.String())))
+	return nil
+}
+
+// streamTarFlags 返回流式解压的 tar 参数，解压工具在 Pod 内执行
+func (r *IoTDBRestorer) streamTarFlags(ctx context.Context, format archive.Format) (string, error) {
+	switch format {
+	case archive.Gzip:
+		if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", "command -v pigz >/dev/null 2>&1"}); err == nil {
+			return "-I 'pigz -p 4' ", nil
+		}
+		return "-z ", nil
+	case archive.Zstd, archive.LZ4:
+		tool := format.Decompressor()
+		if _, _, err := r.executor.Exec(ctx, []string{"sh", "-c", fmt.Sprintf("command -v %s >/dev/null 2>&1", tool)}); err != nil {
+			return "", fmt.Errorf("Pod 内缺少 %s 命令，无法解压 %s 格式的备份", tool, format)
+		}
+		return "-I " + tool + " ", nil
+	default:
+		return "", nil
+	}
+}
+
+// volumeReader 按顺序读取多个远程文件（分卷），记录每个文件的校验和
+type volumeReader struct {
+	ctx        context.Context
+	downloader *downloader.OSSDownloader
+	urls       []string
+	expected   []*downloader.Checksum
+	checksums  []*downloader.Checksum
+
+	current *downloader.StreamReader
+	next    int
+	// err 读取远程文件时的错误；tar 可能因 stdin 中断而失败，以此区分根因
+	err error
+}
+
+func (v *volumeReader) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	for {
+		if v.current == nil {
+			if v.next >= len(v.urls) {
+				return 0, io.EOF
+			}
+			stream, err := v.downloader.OpenStream(v.ctx, v.urls[v.next], v.expected[v.next])
+			if err != nil {
+				v.err = err
+				return 0, err
+			}
+			v.current = stream
+			v.next++
+		}
+
+		n, err := v.current.Read(p)
+		if err == io.EOF {
+			v.checksums = append(v.checksums, v.current.Checksum())
+			v.current.Close()
+			v.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		if err != nil {
+			v.err = err
+		}
+		return n, err
+	}
+}
+
+// source 返回第 i 个文件的校验和来源
+func (v *volumeReader) source(i int) string {
+	if v.expected[i] != nil {
+		return v.expected[i].Source
+	}
+	return v.checksums[i].Source
+}
+
+// Close 关闭正在读取的文件
+func (v *volumeReader) Close() error {
+	if v.current != nil {
+		return v.current.Close()
+	}
+	return nil
+}
+
+// streamUnrecoverable 流式恢复失败后是否不应降级：备份内容本身损坏或已取消时，换用其他策略也无法成功
+func streamUnrecoverable(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, downloader.ErrChecksumMismatch) || errors.Is(err, downloader.ErrNotFound)
+}
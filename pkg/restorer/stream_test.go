@@ -0,0 +1,136 @@
+package restorer
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+)
+
+// newStreamRestorer 返回使用 stream 策略的恢复器，备份按分卷放在测试服务器上，每个分卷包含一个 tsfile
+func newStreamRestorer(t *testing.T, mode string, sidecars map[string]string) (*IoTDBRestorer, *fakePod, []string) {
+	t.Helper()
+
+	contents := make(map[string]string)
+	var files []string
+	for i, entry := range testTsFiles {
+		file := fmt.Sprintf("emsau_%s_%s.tar.zst.part%03d", testPodName, testTimestamp, i)
+		contents["/"+file] = entry + "\n"
+		files = append(files, file)
+	}
+
+	r, pod, _, url := newTestRestorerWithServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if sum, ok := sidecars[strings.TrimPrefix(req.URL.Path, "/")]; ok {
+			w.Write([]byte(sum))
+			return
+		}
+		content, ok := contents[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write([]byte(content))
+	})
+	r.config.Backup.BaseURL = url
+	r.config.Backup.DownloadStrategy = "stream"
+	r.config.Backup.StreamFallback = "pod"
+	r.config.Backup.Checksum = mode
+	for i, file := range files {
+		pod.addBackup(url+"/"+file, testTsFiles[i])
+	}
+	return r, pod, files
+}
+
+func TestRestoreStreamsBackupIntoPod(t *testing.T) {
+	r, pod, files := newStreamRestorer(t, "required", map[string]string{
+		fmt.Sprintf("emsau_%s_%s.tar.zst.part000.sha256", testPodName, testTimestamp): fmt.Sprintf("%x  part000\n", sha256.Sum256([]byte(testTsFiles[0]+"\n"))),
+		fmt.Sprintf("emsau_%s_%s.tar.zst.part001.sha256", testPodName, testTimestamp): fmt.Sprintf("%x  part001\n", sha256.Sum256([]byte(testTsFiles[1]+"\n"))),
+	})
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if result.SuccessCount != len(testTsFiles) {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+	if len(pod.extractFlags) != 1 || pod.extractFlags[0] != "-I zstd" {
+		t.Fatalf("expected a single zstd stream extraction, got %v", pod.extractFlags)
+	}
+	if containsCommand(pod, "wget -q ") {
+		t.Fatalf("stream strategy should not download into the pod, commands: %v", pod.commands)
+	}
+	for _, file := range files {
+		if pod.hasFile(podBackupPath + "/" + file) {
+			t.Fatalf("stream strategy should not write %s to the pod", file)
+		}
+	}
+	if !r.journal.PhaseDone(string(PhaseExtract)) {
+		t.Fatalf("expected extract phase to be recorded after streaming")
+	}
+}
+
+func TestRestoreStreamFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback string
+		sidecars map[string]string
+		setup    func(pod *fakePod)
+		wantWget bool
+		wantErr  bool
+		// errIs 期望错误链中包含的错误
+		errIs error
+	}{
+		{
+			name:     "falls back to pod download",
+			fallback: "pod",
+			setup:    func(pod *fakePod) { pod.streamErr = errExit },
+			wantWget: true,
+		},
+		{
+			name:     "no fallback",
+			fallback: "none",
+			setup:    func(pod *fakePod) { pod.streamErr = errExit },
+			wantErr:  true,
+		},
+		{
+			name:     "checksum mismatch is not retried",
+			fallback: "pod",
+			sidecars: map[string]string{
+				fmt.Sprintf("emsau_%s_%s.tar.zst.part001.sha256", testPodName, testTimestamp): strings.Repeat("0", 64) + "  part001\n",
+			},
+			wantErr: true,
+			errIs:   downloader.ErrChecksumMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, pod, _ := newStreamRestorer(t, "auto", tt.sidecars)
+			r.config.Backup.StreamFallback = tt.fallback
+			if tt.setup != nil {
+				tt.setup(pod)
+			}
+
+			result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp})
+			if tt.wantErr {
+				if err == nil || result.FailedPhase != PhaseDownload {
+					t.Fatalf("expected download failure, got %v (phase %s)", err, result.FailedPhase)
+				}
+				if tt.errIs != nil && !errors.Is(err, tt.errIs) {
+					t.Fatalf("expected %v, got %v", tt.errIs, err)
+				}
+			} else if err != nil {
+				t.Fatalf("restore failed: %v", err)
+			}
+			if got := containsCommand(pod, "wget -q "); got != tt.wantWget {
+				t.Fatalf("expected wget=%v, commands: %v", tt.wantWget, pod.commands)
+			}
+		})
+	}
+}
@@ -2,9 +2,10 @@ package restorer
 
 import "strings"
 
-type cliTableRow map[string]string
+// SQLRow 查询结果的一行，列名到值的映射
+type SQLRow map[string]string
 
-func parseCLITable(output string) []cliTableRow {
+func parseCLITable(output string) []SQLRow {
 	lines := splitLines(output)
 	tableLines := make([]string, 0, len(lines))
 	for _, line := range lines {
@@ -23,14 +24,14 @@ func parseCLITable(output string) []cliTableRow {
 		return nil
 	}
 
-	rows := make([]cliTableRow, 0, len(tableLines)-1)
+	rows := make([]SQLRow, 0, len(tableLines)-1)
 	for _, line := range tableLines[1:] {
 		columns := parseCLIColumns(line)
 		if len(columns) != len(headers) {
 			continue
 		}
 
-		row := make(cliTableRow, len(headers))
+		row := make(SQLRow, len(headers))
 		for i, header := range headers {
 			row[header] = columns[i]
 		}
@@ -0,0 +1,316 @@
+package restorer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+	"github.com/vnnox/iotdb-restore-tool/pkg/downloader"
+	"github.com/vnnox/iotdb-restore-tool/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// DataStats 数据统计快照，同时作为 oss 模式的校验清单格式
+type DataStats struct {
+	Databases map[string]*DatabaseStats `json:"databases"`
+}
+
+// DatabaseStats 单个数据库的统计
+type DatabaseStats struct {
+	Timeseries int64                   `json:"timeseries"`
+	Devices    int64                   `json:"devices"`
+	Series     map[string]*SeriesStats `json:"series,omitempty"`
+}
+
+// SeriesStats 抽样序列的统计
+type SeriesStats struct {
+	Count   int64 `json:"count"`
+	MaxTime int64 `json:"max_time"`
+}
+
+// VerifyMismatch 一项校验不一致
+type VerifyMismatch struct {
+	Database string
+	Item     string
+	Expected int64
+	Actual   int64
+}
+
+func (m VerifyMismatch) String() string {
+	return fmt.Sprintf("%s %s: 期望>=%d 实际=%d", m.Database, m.Item, m.Expected, m.Actual)
+}
+
+// VerificationResult 恢复后数据校验结果
+type VerificationResult struct {
+	Executed bool
+	// Reference 比对基准：源 Pod 或清单地址
+	Reference  string
+	Checks     int
+	Mismatches []VerifyMismatch
+	// Skipped 未执行校验的原因
+	Skipped string
+	Error   string
+}
+
+// collectDataStats 通过 client 统计受管数据库的序列数、设备数和抽样序列的 count/max_time
+func collectDataStats(ctx context.Context, client SQLClient, databases []config.DatabaseConfig) (*DataStats, error) {
+	stats := &DataStats{Databases: make(map[string]*DatabaseStats, len(databases))}
+
+	for _, db := range databases {
+		dbStats := &DatabaseStats{}
+
+		timeseries, err := queryCount(ctx, client, fmt.Sprintf("count timeseries %s.**", db.Name))
+		if err != nil {
+			return nil, fmt.Errorf("统计 %s 序列数失败: %w", db.Name, err)
+		}
+		dbStats.Timeseries = timeseries
+
+		devices, err := queryCount(ctx, client, fmt.Sprintf("count devices %s.**", db.Name))
+		if err != nil {
+			return nil, fmt.Errorf("统计 %s 设备数失败: %w", db.Name, err)
+		}
+		dbStats.Devices = devices
+
+		for _, series := range db.SampleSeries {
+			seriesStats, err := querySeriesStats(ctx, client, series)
+			if err != nil {
+				return nil, fmt.Errorf("统计抽样序列 %s 失败: %w", series, err)
+			}
+			if dbStats.Series == nil {
+				dbStats.Series = make(map[string]*SeriesStats, len(db.SampleSeries))
+			}
+			dbStats.Series[series] = seriesStats
+		}
+
+		stats.Databases[db.Name] = dbStats
+	}
+
+	return stats, nil
+}
+
+func queryCount(ctx context.Context, client SQLClient, sql string) (int64, error) {
+	res, err := client.Exec(ctx, sql)
+	if err != nil {
+		return 0, err
+	}
+	// 数据库下没有序列时 IoTDB 返回空结果
+	if len(res.Rows) == 0 {
+		return 0, nil
+	}
+	value, err := singleQueryValue(res.Rows)
+	if err != nil {
+		return 0, err
+	}
+	return parseStatValue(value)
+}
+
+func querySeriesStats(ctx context.Context, client SQLClient, series string) (*SeriesStats, error) {
+	device, measurement := splitSeriesPath(series)
+	res, err := client.Exec(ctx, fmt.Sprintf("select count(%s), max_time(%s) from %s", measurement, measurement, device))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &SeriesStats{}
+	if len(res.Rows) == 0 {
+		return stats, nil
+	}
+	for column, value := range res.Rows[0] {
+		column = strings.ToLower(strings.TrimSpace(column))
+		switch {
+		case strings.HasPrefix(column, "count("):
+			if stats.Count, err = parseStatValue(value); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(column, "max_time("):
+			if stats.MaxTime, err = parseStatValue(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+// parseStatValue 解析统计值，null 视为 0
+func parseStatValue(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "null") {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无法解析统计值 %q: %w", value, err)
+	}
+	return n, nil
+}
+
+// only 返回只包含指定数据库的统计
+func (s *DataStats) only(databases []string) *DataStats {
+	filtered := &DataStats{Databases: make(map[string]*DatabaseStats, len(databases))}
+	for _, db := range databases {
+		if stats, ok := s.Databases[db]; ok {
+			filtered.Databases[db] = stats
+		}
+	}
+	return filtered
+}
+
+// compareDataStats 逐项比对基准和恢复结果，返回检查项数和不一致项。
+// 基准采集后源端可能继续写入，目标端还包含 bootstrap/探测序列，因此只将目标少于基准视为不一致。
+func compareDataStats(expected, actual *DataStats) (int, []VerifyMismatch) {
+	checks := 0
+	var mismatches []VerifyMismatch
+	check := func(database, item string, want, got int64) {
+		checks++
+		if got < want {
+			mismatches = append(mismatches, VerifyMismatch{Database: database, Item: item, Expected: want, Actual: got})
+		}
+	}
+
+	databases := make([]string, 0, len(expected.Databases))
+	for db := range expected.Databases {
+		databases = append(databases, db)
+	}
+	sort.Strings(databases)
+
+	for _, db := range databases {
+		want := expected.Databases[db]
+		got := actual.Databases[db]
+		if got == nil {
+			got = &DatabaseStats{}
+		}
+		check(db, "timeseries", want.Timeseries, got.Timeseries)
+		check(db, "devices", want.Devices, got.Devices)
+
+		series := make([]string, 0, len(want.Series))
+		for s := range want.Series {
+			series = append(series, s)
+		}
+		sort.Strings(series)
+
+		for _, s := range series {
+			gotSeries := got.Series[s]
+			if gotSeries == nil {
+				gotSeries = &SeriesStats{}
+			}
+			check(db, fmt.Sprintf("count(%s)", s), want.Series[s].Count, gotSeries.Count)
+			check(db, fmt.Sprintf("max_time(%s)", s), want.Series[s].MaxTime, gotSeries.MaxTime)
+		}
+	}
+
+	return checks, mismatches
+}
+
+// captureSourceStats 在拉取源数据前统计源集群，作为直连恢复的校验基准
+func (r *IoTDBRestorer) captureSourceStats(ctx context.Context, source SQLClient) {
+	if !r.config.Verify.Enabled {
+		return
+	}
+	stats, err := collectDataStats(ctx, source, r.managedDatabases())
+	if err != nil {
+		logger.Warn("统计源集群数据失败，恢复后将无法比对", zap.Error(err))
+		return
+	}
+	r.sourceStats = stats
+}
+
+// verifyRestoredData 将恢复后的数据与源集群或备份清单比对
+func (r *IoTDBRestorer) verifyRestoredData(ctx context.Context) error {
+	logger.Info("步骤 5: 校验恢复后的数据")
+
+	verification := &VerificationResult{}
+	r.result.Verification = verification
+
+	expected, reference, err := r.verifyBaseline(ctx)
+	if err != nil {
+		verification.Error = err.Error()
+		return err
+	}
+	verification.Reference = reference
+	if expected == nil {
+		logger.Warn("缺少校验基准，跳过数据校验", zap.String("reason", verification.Skipped))
+		return nil
+	}
+
+	if r.partialRestore() {
+		verification.Skipped = "恢复范围只覆盖部分数据，无法与全量基准比对"
+		logger.Warn("选择性恢复，跳过数据校验", zap.String("filter", r.filter.String()))
+		return nil
+	}
+	expected = expected.only(r.databaseNames())
+
+	actual, err := collectDataStats(ctx, r.sql, r.managedDatabases())
+	if err != nil {
+		verification.Error = err.Error()
+		return fmt.Errorf("统计恢复后数据失败: %w", err)
+	}
+
+	verification.Executed = true
+	verification.Checks, verification.Mismatches = compareDataStats(expected, actual)
+	if len(verification.Mismatches) == 0 {
+		logger.Info("数据校验通过",
+			zap.String("reference", reference),
+			zap.Int("checks", verification.Checks),
+		)
+		return nil
+	}
+
+	for _, m := range verification.Mismatches {
+		logger.Warn("数据校验不一致", zap.String("mismatch", m.String()))
+	}
+	if r.config.Verify.FailOnMismatch {
+		return fmt.Errorf("数据校验发现 %d 项不一致", len(verification.Mismatches))
+	}
+	return nil
+}
+
+// verifyBaseline 返回比对基准；基准不可用时返回 nil 并记录跳过原因
+func (r *IoTDBRestorer) verifyBaseline(ctx context.Context) (*DataStats, string, error) {
+	verification := r.result.Verification
+
+	if r.config.Backup.UsesClusterStream() {
+		reference := fmt.Sprintf("%s/%s", r.config.Backup.SourceNamespace, r.config.Backup.SourcePodName)
+		if r.sourceStats == nil {
+			verification.Skipped = "未采集到源集群统计（续传或源端统计失败）"
+			return nil, reference, nil
+		}
+		return r.sourceStats, reference, nil
+	}
+
+	manifestURL := r.manifestURL()
+	d, err := downloader.NewDownloader(&r.config.Backup)
+	if err != nil {
+		return nil, manifestURL, err
+	}
+	data, err := d.Fetch(ctx, manifestURL)
+	if errors.Is(err, downloader.ErrNotFound) {
+		verification.Skipped = "备份清单不存在"
+		return nil, manifestURL, nil
+	}
+	if err != nil {
+		return nil, manifestURL, fmt.Errorf("下载备份清单失败: %w", err)
+	}
+
+	var manifest DataStats
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, manifestURL, fmt.Errorf("解析备份清单失败: %w", err)
+	}
+	if len(manifest.Databases) == 0 {
+		verification.Skipped = "备份清单为空"
+		return nil, manifestURL, nil
+	}
+	return &manifest, manifestURL, nil
+}
+
+// manifestURL 返回备份清单地址，manifest_url 中的 {file} 替换为备份文件名
+func (r *IoTDBRestorer) manifestURL() string {
+	if r.config.Verify.ManifestURL != "" {
+		return strings.ReplaceAll(r.config.Verify.ManifestURL, "{file}", r.result.BackupFile)
+	}
+	return fmt.Sprintf("%s/%s.manifest.json", strings.TrimSuffix(r.config.Backup.BaseURL, "/"), r.result.BackupFile)
+}
@@ -0,0 +1,151 @@
+package restorer
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/config"
+)
+
+func TestCompareDataStats(t *testing.T) {
+	expected := &DataStats{Databases: map[string]*DatabaseStats{
+		"root.energy": {
+			Timeseries: 10,
+			Devices:    2,
+			Series: map[string]*SeriesStats{
+				"root.energy.d1.s1": {Count: 100, MaxTime: 1770000000000},
+			},
+		},
+		"root.emsplus": {Timeseries: 5, Devices: 1},
+	}}
+
+	tests := []struct {
+		name   string
+		actual *DataStats
+		want   []string
+	}{
+		{
+			name: "target ahead of baseline",
+			actual: &DataStats{Databases: map[string]*DatabaseStats{
+				"root.energy": {
+					Timeseries: 12,
+					Devices:    3,
+					Series: map[string]*SeriesStats{
+						"root.energy.d1.s1": {Count: 100, MaxTime: 1770000000000},
+					},
+				},
+				"root.emsplus": {Timeseries: 5, Devices: 1},
+			}},
+		},
+		{
+			name: "missing data",
+			actual: &DataStats{Databases: map[string]*DatabaseStats{
+				"root.energy": {
+					Timeseries: 10,
+					Devices:    2,
+					Series: map[string]*SeriesStats{
+						"root.energy.d1.s1": {Count: 90, MaxTime: 1760000000000},
+					},
+				},
+			}},
+			want: []string{
+				"root.emsplus timeseries",
+				"root.emsplus devices",
+				"root.energy count(root.energy.d1.s1)",
+				"root.energy max_time(root.energy.d1.s1)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, mismatches := compareDataStats(expected, tt.actual)
+			if checks != 6 {
+				t.Fatalf("expected 6 checks, got %d", checks)
+			}
+			if len(mismatches) != len(tt.want) {
+				t.Fatalf("expected mismatches %v, got %v", tt.want, mismatches)
+			}
+			for i, m := range mismatches {
+				if got := m.Database + " " + m.Item; got != tt.want[i] {
+					t.Fatalf("mismatch %d: expected %q, got %q", i, tt.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+// newVerifyRestorer 返回只管理 root.energy 且开启数据校验的恢复器，Pod 中预置两条序列
+func newVerifyRestorer(t *testing.T, manifest string) (*IoTDBRestorer, *fakePod) {
+	t.Helper()
+
+	r, pod, _, url := newTestRestorerWithServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if manifest == "" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write([]byte(manifest))
+	})
+	r.config.Databases = []config.DatabaseConfig{{
+		Name:            "root.energy",
+		BootstrapSeries: "root.energy.__restore_bootstrap.status",
+		ProbeSeries:     "root.energy.__restore_probe.restore_check",
+		SampleSeries:    []string{"root.energy.d1.s1"},
+	}}
+	r.config.Verify = config.VerifyConfig{Enabled: true, ManifestURL: url + "/{file}.manifest.json"}
+
+	pod.series["root.energy.d1.s1"] = map[string]string{"100": "1", "200": "2"}
+	pod.series["root.energy.d1.s2"] = map[string]string{"100": "1"}
+	return r, pod
+}
+
+func TestRestoreVerifiesAgainstManifest(t *testing.T) {
+	r, _ := newVerifyRestorer(t, `{"databases":{"root.energy":{"timeseries":2,"devices":1,"series":{"root.energy.d1.s1":{"count":2,"max_time":200}}}}}`)
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp, SkipDelete: true})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	v := result.Verification
+	if v == nil || !v.Executed || v.Checks != 4 || len(v.Mismatches) != 0 {
+		t.Fatalf("unexpected verification: %+v", v)
+	}
+	if !strings.HasSuffix(v.Reference, "/"+result.BackupFile+".manifest.json") {
+		t.Fatalf("unexpected manifest reference: %s", v.Reference)
+	}
+}
+
+func TestRestoreVerifyMismatch(t *testing.T) {
+	manifest := `{"databases":{"root.energy":{"timeseries":2,"devices":1,"series":{"root.energy.d1.s1":{"count":5,"max_time":200}}}}}`
+
+	r, _ := newVerifyRestorer(t, manifest)
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp, SkipDelete: true})
+	if err != nil {
+		t.Fatalf("mismatch should only be reported by default: %v", err)
+	}
+	if v := result.Verification; len(v.Mismatches) != 1 || v.Mismatches[0].Expected != 5 || v.Mismatches[0].Actual != 2 {
+		t.Fatalf("unexpected verification: %+v", v)
+	}
+
+	r, _ = newVerifyRestorer(t, manifest)
+	r.config.Verify.FailOnMismatch = true
+	result, err = r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp, SkipDelete: true})
+	if err == nil || result.FailedPhase != PhaseVerify {
+		t.Fatalf("expected verify failure, got phase %q err %v", result.FailedPhase, err)
+	}
+}
+
+func TestRestoreVerifySkipsWithoutManifest(t *testing.T) {
+	r, _ := newVerifyRestorer(t, "")
+
+	result, err := r.Restore(context.Background(), RestoreOptions{Timestamp: testTimestamp, SkipDelete: true})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if v := result.Verification; v == nil || v.Executed || v.Skipped == "" {
+		t.Fatalf("expected verification to be skipped: %+v", v)
+	}
+}
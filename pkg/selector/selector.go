@@ -0,0 +1,290 @@
+// Package selector 按数据库、设备路径前缀和时间范围选择需要恢复的 tsfile
+package selector
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter 选择性恢复条件，零值表示恢复全部数据。
+// 时间范围为闭区间，Since/Until 为零值表示不限制该端。
+type Filter struct {
+	Databases    []string  `json:"databases,omitempty"`
+	PathPrefixes []string  `json:"path_prefixes,omitempty"`
+	Since        time.Time `json:"since"`
+	Until        time.Time `json:"until"`
+}
+
+// IsZero 是否未设置任何条件
+func (f Filter) IsZero() bool {
+	return len(f.Databases) == 0 && len(f.PathPrefixes) == 0 && f.Since.IsZero() && f.Until.IsZero()
+}
+
+// HasTimeRange 是否设置了时间范围
+func (f Filter) HasTimeRange() bool {
+	return !f.Since.IsZero() || !f.Until.IsZero()
+}
+
+// Validate 校验条件本身是否合法，不涉及受管数据库
+func (f Filter) Validate() error {
+	for _, prefix := range f.PathPrefixes {
+		if prefix != "root" && (!strings.HasPrefix(prefix, "root.") || strings.HasSuffix(prefix, ".")) {
+			return fmt.Errorf("路径前缀必须以 root 开头: %s", prefix)
+		}
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && f.Since.After(f.Until) {
+		return fmt.Errorf("起始时间 %s 晚于结束时间 %s", f.Since.Format(time.RFC3339), f.Until.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// String 返回便于记录日志和比对续传条件的描述
+func (f Filter) String() string {
+	if f.IsZero() {
+		return "全部"
+	}
+	var parts []string
+	if len(f.Databases) > 0 {
+		parts = append(parts, "database="+strings.Join(f.Databases, ","))
+	}
+	if len(f.PathPrefixes) > 0 {
+		parts = append(parts, "path_prefix="+strings.Join(f.PathPrefixes, ","))
+	}
+	if !f.Since.IsZero() {
+		parts = append(parts, "since="+f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		parts = append(parts, "until="+f.Until.Format(time.RFC3339))
+	}
+	return strings.Join(parts, " ")
+}
+
+// SelectDatabases 从受管数据库中选出需要恢复的数据库，保持受管数据库的顺序。
+// 指定的数据库不在受管列表中，或路径前缀不属于任何选中的数据库时返回错误。
+func (f Filter) SelectDatabases(managed []string) ([]string, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(managed))
+	for _, db := range managed {
+		known[db] = true
+	}
+	for _, db := range f.Databases {
+		if !known[db] {
+			return nil, fmt.Errorf("数据库 %s 不在 databases 配置中", db)
+		}
+	}
+
+	var selected []string
+	for _, db := range managed {
+		if len(f.Databases) > 0 && !contains(f.Databases, db) {
+			continue
+		}
+		if len(f.PathPrefixes) > 0 && !f.prefixRelated(db) {
+			continue
+		}
+		selected = append(selected, db)
+	}
+
+	for _, prefix := range f.PathPrefixes {
+		related := false
+		for _, db := range selected {
+			if covers(prefix, db) || covers(db, prefix) {
+				related = true
+				break
+			}
+		}
+		if !related {
+			return nil, fmt.Errorf("路径前缀 %s 不属于任何选中的数据库", prefix)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("没有符合条件的数据库")
+	}
+	return selected, nil
+}
+
+// Partial 选中的数据库是否只恢复部分数据：路径前缀深于数据库或设置了时间范围，
+// 此时删除整个数据库会丢失条件之外的数据
+func (f Filter) Partial(database string) bool {
+	return f.HasTimeRange() || (len(f.PathPrefixes) > 0 && !f.coversDatabase(database))
+}
+
+// coversDatabase 是否有路径前缀覆盖整个数据库
+func (f Filter) coversDatabase(database string) bool {
+	for _, prefix := range f.PathPrefixes {
+		if covers(prefix, database) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixRelated 数据库与任一路径前缀存在包含关系
+func (f Filter) prefixRelated(database string) bool {
+	for _, prefix := range f.PathPrefixes {
+		if covers(prefix, database) || covers(database, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Location tsfile 在数据目录中的位置：
+// <data>/{sequence|unsequence}/<database>/<region>/<time partition>/<time>-<version>-<inner>-<cross>.tsfile
+type Location struct {
+	Sequence  bool
+	Database  string
+	Region    int64
+	Partition int64
+	// Time 文件创建时间（毫秒），取自文件名
+	Time int64
+	// Version 文件版本号，取自文件名
+	Version int64
+}
+
+// ParsePath 按 IoTDB 数据目录结构解析 tsfile 路径
+func ParsePath(file string) (Location, error) {
+	parts := strings.Split(path.Clean(file), "/")
+	if len(parts) < 5 {
+		return Location{}, fmt.Errorf("tsfile 路径层级不足: %s", file)
+	}
+	parts = parts[len(parts)-5:]
+
+	var loc Location
+	switch parts[0] {
+	case "sequence":
+		loc.Sequence = true
+	case "unsequence":
+	default:
+		return Location{}, fmt.Errorf("tsfile 不在 sequence/unsequence 目录下: %s", file)
+	}
+	loc.Database = parts[1]
+
+	var err error
+	if loc.Region, err = strconv.ParseInt(parts[2], 10, 64); err != nil {
+		return Location{}, fmt.Errorf("无法解析 Region 目录 %q: %s", parts[2], file)
+	}
+	if loc.Partition, err = strconv.ParseInt(parts[3], 10, 64); err != nil {
+		return Location{}, fmt.Errorf("无法解析时间分区目录 %q: %s", parts[3], file)
+	}
+
+	fields := strings.Split(strings.TrimSuffix(parts[4], ".tsfile"), "-")
+	if !strings.HasSuffix(parts[4], ".tsfile") || len(fields) != 4 {
+		return Location{}, fmt.Errorf("tsfile 文件名格式不正确: %s", file)
+	}
+	if loc.Time, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		return Location{}, fmt.Errorf("无法解析 tsfile 时间: %s", file)
+	}
+	if loc.Version, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return Location{}, fmt.Errorf("无法解析 tsfile 版本号: %s", file)
+	}
+	return loc, nil
+}
+
+// PartitionRange 返回时间分区覆盖的时间范围 [start, end)，interval 为分区间隔（毫秒）
+func PartitionRange(partition, interval int64) (start, end time.Time) {
+	return time.UnixMilli(partition * interval), time.UnixMilli((partition + 1) * interval)
+}
+
+// MatchLocation 按目录结构判断 tsfile 是否可能包含符合条件的数据。
+// 路径前缀深于数据库或时间范围只覆盖分区的一部分时无法仅凭目录判断，按可能包含处理。
+func (f Filter) MatchLocation(loc Location, interval int64) bool {
+	if len(f.Databases) > 0 && !contains(f.Databases, loc.Database) {
+		return false
+	}
+	if len(f.PathPrefixes) > 0 && !f.prefixRelated(loc.Database) {
+		return false
+	}
+	if f.HasTimeRange() && interval > 0 {
+		start, end := PartitionRange(loc.Partition, interval)
+		if !f.Since.IsZero() && !end.After(f.Since) {
+			return false
+		}
+		if !f.Until.IsZero() && start.After(f.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// NeedsMetadata 仅凭目录结构无法确定 tsfile 是否完全符合条件，需要读取文件元数据：
+// 路径前缀深于数据库，或时间范围只覆盖时间分区的一部分
+func (f Filter) NeedsMetadata(loc Location, interval int64) bool {
+	if len(f.PathPrefixes) > 0 && !f.coversDatabase(loc.Database) {
+		return true
+	}
+	if f.HasTimeRange() && interval > 0 {
+		start, end := PartitionRange(loc.Partition, interval)
+		if !f.Since.IsZero() && start.Before(f.Since) {
+			return true
+		}
+		if !f.Until.IsZero() && end.Add(-time.Millisecond).After(f.Until) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchDevice 设备在 [start, end] 内的数据是否符合路径前缀和时间范围
+func (f Filter) MatchDevice(device string, start, end time.Time) bool {
+	if len(f.PathPrefixes) > 0 {
+		matched := false
+		for _, prefix := range f.PathPrefixes {
+			if covers(prefix, device) || covers(device, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && end.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && start.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Select 按目录结构筛选 tsfile，返回选中、跳过和无法解析路径的文件。
+// 无法解析路径的文件保守地保留，避免漏导数据。
+func (f Filter) Select(files []string, interval int64) (selected, skipped, unparsed []string) {
+	if f.IsZero() {
+		return files, nil, nil
+	}
+	for _, file := range files {
+		loc, err := ParsePath(file)
+		if err != nil {
+			unparsed = append(unparsed, file)
+			selected = append(selected, file)
+			continue
+		}
+		if f.MatchLocation(loc, interval) {
+			selected = append(selected, file)
+		} else {
+			skipped = append(skipped, file)
+		}
+	}
+	return selected, skipped, unparsed
+}
+
+// covers 路径 prefix 是否等于 path 或是其祖先节点
+func covers(prefix, p string) bool {
+	return p == prefix || strings.HasPrefix(p, prefix+".")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package selector
+
+import (
+	"testing"
+	"time"
+)
+
+// partitionInterval IoTDB 默认时间分区间隔（7 天）
+const partitionInterval = 604800000
+
+func TestParsePath(t *testing.T) {
+	loc, err := ParsePath("/iotdb/data/iotdb/data/datanode/data/unsequence/root.energy/3/2920/1770000000000-12-0-1.tsfile")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := Location{Database: "root.energy", Region: 3, Partition: 2920, Time: 1770000000000, Version: 12}
+	if loc != want {
+		t.Fatalf("expected %+v, got %+v", want, loc)
+	}
+
+	for _, file := range []string{
+		"root.energy/3/2920/1770000000000-12-0-1.tsfile",
+		"/data/other/root.energy/3/2920/1770000000000-12-0-1.tsfile",
+		"/data/sequence/root.energy/x/2920/1770000000000-12-0-1.tsfile",
+		"/data/sequence/root.energy/3/2920/1770000000000.tsfile",
+	} {
+		if _, err := ParsePath(file); err == nil {
+			t.Fatalf("expected error for %s", file)
+		}
+	}
+}
+
+func TestSelectDatabases(t *testing.T) {
+	managed := []string{"root.emsplus", "root.energy"}
+	tests := []struct {
+		name    string
+		filter  Filter
+		want    []string
+		wantErr bool
+	}{
+		{name: "all", filter: Filter{Since: time.UnixMilli(0)}, want: managed},
+		{name: "database", filter: Filter{Databases: []string{"root.energy"}}, want: []string{"root.energy"}},
+		{name: "device prefix", filter: Filter{PathPrefixes: []string{"root.energy.site42"}}, want: []string{"root.energy"}},
+		{name: "root prefix", filter: Filter{PathPrefixes: []string{"root"}}, want: managed},
+		{name: "prefix is not a node boundary", filter: Filter{PathPrefixes: []string{"root.ener"}}, wantErr: true},
+		{name: "unknown database", filter: Filter{Databases: []string{"root.unknown"}}, wantErr: true},
+		{name: "prefix outside database", filter: Filter{Databases: []string{"root.emsplus"}, PathPrefixes: []string{"root.energy.site42"}}, wantErr: true},
+		{name: "invalid prefix", filter: Filter{PathPrefixes: []string{"energy.site42"}}, wantErr: true},
+		{name: "reversed range", filter: Filter{Since: time.UnixMilli(2000), Until: time.UnixMilli(1000)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.SelectDatabases(managed)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("select: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterSelect(t *testing.T) {
+	files := []string{
+		"/data/sequence/root.energy/1/2919/1765000000000-1-0-0.tsfile",
+		"/data/sequence/root.energy/1/2920/1766100000000-2-0-0.tsfile",
+		"/data/unsequence/root.energy/1/2921/1766700000000-3-0-0.tsfile",
+		"/data/sequence/root.emsplus/2/2920/1766100000000-1-0-0.tsfile",
+		"/data/broken.tsfile",
+	}
+	// 分区 2920 覆盖 [1766016000000, 1766620800000)
+	partitionStart := time.UnixMilli(2920 * partitionInterval)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{name: "no filter", filter: Filter{}, want: 5},
+		{name: "database", filter: Filter{Databases: []string{"root.energy"}}, want: 4},
+		{name: "device prefix", filter: Filter{PathPrefixes: []string{"root.emsplus.site42"}}, want: 2},
+		{name: "since partition start", filter: Filter{Since: partitionStart}, want: 4},
+		{name: "since within partition", filter: Filter{Since: partitionStart.Add(time.Hour)}, want: 4},
+		{name: "until partition start", filter: Filter{Until: partitionStart}, want: 4},
+		{name: "until before partition", filter: Filter{Until: partitionStart.Add(-time.Millisecond)}, want: 2},
+		{name: "single partition", filter: Filter{Databases: []string{"root.energy"}, Since: partitionStart, Until: partitionStart.Add(24 * time.Hour)}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, skipped, unparsed := tt.filter.Select(files, partitionInterval)
+			if len(selected) != tt.want {
+				t.Fatalf("expected %d files, got %v", tt.want, selected)
+			}
+			if len(selected)+len(skipped) != len(files) {
+				t.Fatalf("selected %v and skipped %v do not cover all files", selected, skipped)
+			}
+			if !tt.filter.IsZero() && len(unparsed) != 1 {
+				t.Fatalf("expected broken path to be reported, got %v", unparsed)
+			}
+		})
+	}
+}
+
+func TestFilterPartial(t *testing.T) {
+	if (Filter{Databases: []string{"root.energy"}}).Partial("root.energy") {
+		t.Fatalf("database filter restores the whole database")
+	}
+	if (Filter{PathPrefixes: []string{"root"}}).Partial("root.energy") {
+		t.Fatalf("prefix above the database restores the whole database")
+	}
+	if !(Filter{PathPrefixes: []string{"root.energy.site42"}}).Partial("root.energy") {
+		t.Fatalf("device prefix restores part of the database")
+	}
+	if !(Filter{Since: time.UnixMilli(1)}).Partial("root.energy") {
+		t.Fatalf("time range restores part of the database")
+	}
+}
+
+func TestFilterNeedsMetadata(t *testing.T) {
+	loc := Location{Database: "root.energy", Partition: 2920}
+	partitionStart := time.UnixMilli(2920 * partitionInterval)
+	partitionEnd := time.UnixMilli(2921 * partitionInterval)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{name: "database", filter: Filter{Databases: []string{"root.energy"}}, want: false},
+		{name: "device prefix", filter: Filter{PathPrefixes: []string{"root.energy.site42"}}, want: true},
+		{name: "database prefix", filter: Filter{PathPrefixes: []string{"root.energy"}}, want: false},
+		{name: "range covers partition", filter: Filter{Since: partitionStart, Until: partitionEnd.Add(-time.Millisecond)}, want: false},
+		{name: "since within partition", filter: Filter{Since: partitionStart.Add(time.Hour)}, want: true},
+		{name: "until within partition", filter: Filter{Until: partitionEnd.Add(-time.Hour)}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.NeedsMetadata(loc, partitionInterval); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFilterMatchDevice(t *testing.T) {
+	start, end := time.UnixMilli(1000), time.UnixMilli(5000)
+	tests := []struct {
+		name   string
+		filter Filter
+		device string
+		want   bool
+	}{
+		{name: "prefix above device", filter: Filter{PathPrefixes: []string{"root.energy.site42"}}, device: "root.energy.site42.meter1", want: true},
+		{name: "prefix below device", filter: Filter{PathPrefixes: []string{"root.energy.site42.meter1.power"}}, device: "root.energy.site42.meter1", want: true},
+		{name: "other device", filter: Filter{PathPrefixes: []string{"root.energy.site42"}}, device: "root.energy.site43.meter1", want: false},
+		{name: "overlapping range", filter: Filter{Since: time.UnixMilli(5000)}, device: "root.energy.site42.meter1", want: true},
+		{name: "data before range", filter: Filter{Since: time.UnixMilli(5001)}, device: "root.energy.site42.meter1", want: false},
+		{name: "data after range", filter: Filter{Until: time.UnixMilli(999)}, device: "root.energy.site42.meter1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.MatchDevice(tt.device, start, end); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package tsfile
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// 元数据索引节点类型
+const (
+	nodeInternalDevice      byte = 0
+	nodeLeafDevice          byte = 1
+	nodeInternalMeasurement byte = 2
+	nodeLeafMeasurement     byte = 3
+)
+
+// 数据类型，决定 TimeseriesMetadata 中统计信息的长度
+const (
+	typeBoolean byte = 0
+	typeInt32   byte = 1
+	typeInt64   byte = 2
+	typeFloat   byte = 3
+	typeDouble  byte = 4
+	typeText    byte = 5
+	typeVector  byte = 6
+)
+
+const (
+	// maxStringSize 设备名、测点名的长度上限，超出视为损坏
+	maxStringSize = 1 << 20
+	// maxIndexDepth 索引树的深度上限，防止损坏文件中的环
+	maxIndexDepth = 32
+	// maxIndexChildren 单个索引节点的子节点数上限
+	maxIndexChildren = 1 << 16
+)
+
+type indexEntry struct {
+	name   string
+	offset int64
+}
+
+// indexNode MetadataIndexNode：子节点 i 的内容位于 [children[i].offset, children[i+1].offset)，
+// 最后一个子节点以 endOffset 结束
+type indexNode struct {
+	children  []indexEntry
+	endOffset int64
+	nodeType  byte
+}
+
+func (n *indexNode) childRange(i int) (int64, int64) {
+	end := n.endOffset
+	if i+1 < len(n.children) {
+		end = n.children[i+1].offset
+	}
+	return n.children[i].offset, end
+}
+
+// walker 遍历设备索引和测点索引，汇总每个设备的时间范围
+type walker struct {
+	meta    *section
+	devices []Device
+}
+
+func (w *walker) child(n *indexNode, i int) (*decoder, error) {
+	start, end := n.childRange(i)
+	data, ok := w.meta.slice(start, end)
+	if !ok {
+		return nil, corruptf("索引项 %q 的范围 [%d, %d) 超出元数据区", n.children[i].name, start, end)
+	}
+	return &decoder{buf: data, base: start}, nil
+}
+
+func (w *walker) deviceNode(n *indexNode, depth int) error {
+	if depth > maxIndexDepth {
+		return corruptf("设备索引深度超过 %d", maxIndexDepth)
+	}
+	for i, entry := range n.children {
+		d, err := w.child(n, i)
+		if err != nil {
+			return err
+		}
+		child := d.node()
+		if d.err != nil {
+			return d.err
+		}
+
+		switch n.nodeType {
+		case nodeInternalDevice:
+			if err := w.deviceNode(child, depth+1); err != nil {
+				return err
+			}
+		case nodeLeafDevice:
+			dev := &Device{ID: entry.name, StartTime: math.MaxInt64, EndTime: math.MinInt64}
+			if err := w.measurementNode(child, dev, depth+1); err != nil {
+				return err
+			}
+			if dev.StartTime > dev.EndTime {
+				dev.StartTime, dev.EndTime = 0, 0
+			}
+			w.devices = append(w.devices, *dev)
+		default:
+			return corruptf("设备索引节点类型 %d 不正确", n.nodeType)
+		}
+	}
+	return nil
+}
+
+func (w *walker) measurementNode(n *indexNode, dev *Device, depth int) error {
+	if depth > maxIndexDepth {
+		return corruptf("测点索引深度超过 %d", maxIndexDepth)
+	}
+	for i := range n.children {
+		d, err := w.child(n, i)
+		if err != nil {
+			return err
+		}
+
+		switch n.nodeType {
+		case nodeInternalMeasurement:
+			child := d.node()
+			if d.err != nil {
+				return d.err
+			}
+			if err := w.measurementNode(child, dev, depth+1); err != nil {
+				return err
+			}
+		case nodeLeafMeasurement:
+			// 叶子节点的每个索引项指向连续的若干个 TimeseriesMetadata
+			for !d.done() {
+				if err := d.timeseries(dev); err != nil {
+					return err
+				}
+			}
+			if d.err != nil {
+				return d.err
+			}
+		default:
+			return corruptf("设备 %s 的测点索引节点类型 %d 不正确", dev.ID, n.nodeType)
+		}
+	}
+	return nil
+}
+
+// decoder 按 TsFile 的序列化规则读取元数据：整数为大端序，
+// 长度和数量为 unsigned varint，字符串为 zigzag varint 长度加 UTF-8 内容
+type decoder struct {
+	buf  []byte
+	off  int
+	base int64
+	err  error
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = corruptf(format, args...)
+	}
+}
+
+func (d *decoder) done() bool {
+	return d.err != nil || d.off >= len(d.buf)
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf)-d.off {
+		d.fail("偏移 %d 处读取 %d 字节越界", d.base+int64(d.off), n)
+		return nil
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf[d.off:])
+	if n <= 0 {
+		d.fail("偏移 %d 处的 varint 不正确", d.base+int64(d.off))
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+func (d *decoder) string() string {
+	if d.err != nil {
+		return ""
+	}
+	n, k := binary.Varint(d.buf[d.off:])
+	if k <= 0 || n < 0 || n > maxStringSize {
+		d.fail("偏移 %d 处的字符串长度不正确", d.base+int64(d.off))
+		return ""
+	}
+	d.off += k
+	return string(d.next(int(n)))
+}
+
+// node 读取 MetadataIndexNode
+func (d *decoder) node() *indexNode {
+	count := d.uvarint()
+	if count > maxIndexChildren {
+		d.fail("索引节点的子节点数 %d 过多", count)
+		return nil
+	}
+	n := &indexNode{children: make([]indexEntry, 0, count)}
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		name := d.string()
+		offset := d.int64()
+		n.children = append(n.children, indexEntry{name: name, offset: offset})
+	}
+	n.endOffset = d.int64()
+	n.nodeType = d.byte()
+	return n
+}
+
+// timeseries 读取一个 TimeseriesMetadata，将其统计信息中的时间范围计入设备
+func (d *decoder) timeseries(dev *Device) error {
+	d.byte() // TimeseriesMetadata 类型：单 Chunk/多 Chunk 及对齐列标识
+	measurement := d.string()
+	dataType := d.byte()
+	chunkMetadataSize := d.uvarint()
+	d.uvarint() // 数据点数
+	start := d.int64()
+	end := d.int64()
+	if d.err != nil {
+		return d.err
+	}
+	if err := d.skipStatistics(dataType); err != nil {
+		return err
+	}
+	if chunkMetadataSize > uint64(len(d.buf)) {
+		d.fail("序列 %s.%s 的 ChunkMetadata 长度 %d 越界", dev.ID, measurement, chunkMetadataSize)
+	}
+	d.next(int(chunkMetadataSize))
+	if d.err != nil {
+		return d.err
+	}
+
+	if start > end {
+		return corruptf("序列 %s.%s 的时间范围 [%d, %d] 不正确", dev.ID, measurement, start, end)
+	}
+	dev.StartTime = min(dev.StartTime, start)
+	dev.EndTime = max(dev.EndTime, end)
+	if dataType != typeVector {
+		dev.Series++
+	}
+	return nil
+}
+
+// skipStatistics 跳过与数据类型相关的统计值（最值、首尾值、求和等）
+func (d *decoder) skipStatistics(dataType byte) error {
+	switch dataType {
+	case typeBoolean:
+		d.next(1 + 1 + 8)
+	case typeInt32, typeFloat:
+		d.next(4*4 + 8)
+	case typeInt64, typeDouble:
+		d.next(8*4 + 8)
+	case typeText:
+		// 首值、尾值，各为 int32 长度加内容
+		for i := 0; i < 2; i++ {
+			if n := d.int32(); n > 0 {
+				d.next(int(n))
+			}
+		}
+	case typeVector:
+		// 对齐设备的时间列只有时间范围
+	default:
+		return unsupportedf("数据类型 %d", dataType)
+	}
+	return d.err
+}
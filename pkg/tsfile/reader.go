@@ -0,0 +1,343 @@
+// Package tsfile 读取 TsFile（IoTDB 1.x 使用的 V3 格式）的文件头和尾部元数据索引，
+// 用于导入前校验文件完整性，并统计文件中的设备及其时间范围。
+//
+// 文件结构：
+//
+//	"TsFile" 版本号 | ChunkGroup... | 0x02 | TimeseriesMetadata... | MetadataIndexNode... | TsFileMetadata | 元数据长度(int32) | "TsFile"
+package tsfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Magic 文件头和文件尾的魔数
+const Magic = "TsFile"
+
+// Version3 IoTDB 1.x 写入的 TsFile 版本号
+const Version3 byte = 3
+
+const (
+	headerSize = len(Magic) + 1
+	tailSize   = 4 + len(Magic)
+	// tailWindow 首次从文件尾读取的长度，通常可以一次读到全部元数据
+	tailWindow = 64 << 10
+	// maxMetadataSize 元数据区上限，超出时不再检查
+	maxMetadataSize = 256 << 20
+)
+
+var (
+	// ErrCorrupt 文件结构损坏（截断、魔数错误、索引越界等），IoTDB 无法加载
+	ErrCorrupt = errors.New("tsfile 已损坏")
+	// ErrUnsupported 文件版本或数据类型超出本读取器的支持范围，不代表文件损坏
+	ErrUnsupported = errors.New("tsfile 格式不受支持")
+)
+
+// Device 设备在文件中的数据范围，时间与 IoTDB 的 timestamp_precision 一致（默认毫秒）
+type Device struct {
+	ID        string `json:"id"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+	// Series 序列数（不含对齐设备的时间列）
+	Series int `json:"series"`
+}
+
+// FileInfo tsfile 元数据摘要
+type FileInfo struct {
+	Version   byte     `json:"version"`
+	Size      int64    `json:"size"`
+	Devices   []Device `json:"devices"`
+	StartTime int64    `json:"start_time"`
+	EndTime   int64    `json:"end_time"`
+}
+
+func corruptf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, args...))
+}
+
+func unsupportedf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrUnsupported, fmt.Sprintf(format, args...))
+}
+
+// Read 从可随机读取的文件（本地文件或 Pod 内文件的区间读取）读取元数据，只读取文件头和尾部元数据区
+func Read(r io.ReaderAt, size int64) (*FileInfo, error) {
+	if size < int64(headerSize+1+tailSize) {
+		return nil, corruptf("文件过小（%d 字节）", size)
+	}
+	header := make([]byte, headerSize)
+	if err := readFull(r, header, 0); err != nil {
+		return nil, err
+	}
+	if err := checkHeader(header); err != nil {
+		return nil, err
+	}
+
+	info, err := readMetadata(r, size, int64(headerSize))
+	if err != nil {
+		return nil, err
+	}
+	info.Version = header[len(Magic)]
+	return info, nil
+}
+
+// ReadStream 顺序读取文件流（如从 Pod 内 cat 输出的文件），逐个校验数据区的 Chunk 结构，
+// 数据区的内容直接跳过，只在内存中保留尾部元数据区
+func ReadStream(r io.Reader) (*FileInfo, error) {
+	cr := &countingReader{r: bufio.NewReaderSize(r, tailWindow)}
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(cr, header); err != nil {
+		return nil, streamErr(err, "读取文件头失败")
+	}
+	if err := checkHeader(header); err != nil {
+		return nil, err
+	}
+
+	if err := skipChunkGroups(cr); err != nil {
+		return nil, err
+	}
+
+	// 分隔标记本身也放入元数据区，兼容 metaOffset 指向分隔标记的写法
+	separator := cr.n - 1
+	rest, err := io.ReadAll(io.LimitReader(cr, maxMetadataSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取 tsfile 失败: %w", err)
+	}
+	if len(rest) > maxMetadataSize {
+		return nil, unsupportedf("元数据区超过 %d 字节", maxMetadataSize)
+	}
+	data := append([]byte{markerSeparator}, rest...)
+	size := separator + int64(len(data))
+	if len(data) < tailSize+1 {
+		return nil, corruptf("缺少尾部元数据，文件可能被截断")
+	}
+
+	info, err := readMetadata(&section{base: separator, data: data}, size, separator)
+	if err != nil {
+		return nil, err
+	}
+	info.Version = header[len(Magic)]
+	return info, nil
+}
+
+func checkHeader(header []byte) error {
+	if string(header[:len(Magic)]) != Magic {
+		return corruptf("文件头魔数不正确")
+	}
+	if version := header[len(Magic)]; version != Version3 {
+		return unsupportedf("版本号 %d", version)
+	}
+	return nil
+}
+
+// Chunk 标记
+const (
+	markerChunkGroupHeader       byte = 0
+	markerChunkHeader            byte = 1
+	markerSeparator              byte = 2
+	markerOperationIndexRange    byte = 4
+	markerOnlyOnePageChunkHeader byte = 5
+	// 对齐序列的时间列和值列在 Chunk 标记的高位上标识
+	chunkColumnMask byte = 0xc0
+)
+
+// skipChunkGroups 跳过数据区直到元数据分隔标记
+func skipChunkGroups(cr *countingReader) error {
+	for {
+		offset := cr.n
+		marker, err := cr.ReadByte()
+		if err != nil {
+			return streamErr(err, "数据区未结束，文件可能被截断")
+		}
+
+		switch {
+		case marker == markerSeparator:
+			return nil
+		case marker == markerChunkGroupHeader:
+			if _, err := readStreamString(cr); err != nil {
+				return err
+			}
+		case marker == markerOperationIndexRange:
+			if err := skip(cr, 16); err != nil {
+				return err
+			}
+		case marker&^chunkColumnMask == markerChunkHeader || marker&^chunkColumnMask == markerOnlyOnePageChunkHeader:
+			if _, err := readStreamString(cr); err != nil {
+				return err
+			}
+			dataSize, err := binary.ReadUvarint(cr)
+			if err != nil {
+				return streamErr(err, "读取 Chunk 长度失败")
+			}
+			// 数据类型、压缩方式、编码方式各 1 字节
+			if err := skip(cr, 3+int64(dataSize)); err != nil {
+				return err
+			}
+		default:
+			return corruptf("偏移 %d 处的标记 %d 无法识别", offset, marker)
+		}
+	}
+}
+
+func readStreamString(cr *countingReader) (string, error) {
+	n, err := binary.ReadVarint(cr)
+	if err != nil {
+		return "", streamErr(err, "读取字符串长度失败")
+	}
+	if n < 0 || n > maxStringSize {
+		return "", corruptf("偏移 %d 处的字符串长度 %d 不正确", cr.n, n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(cr, buf); err != nil {
+		return "", streamErr(err, "读取字符串失败")
+	}
+	return string(buf), nil
+}
+
+func skip(cr *countingReader, n int64) error {
+	copied, err := io.CopyN(io.Discard, cr, n)
+	if err != nil {
+		return streamErr(err, fmt.Sprintf("跳过 %d 字节时只读到 %d 字节", n, copied))
+	}
+	return nil
+}
+
+// streamErr 流提前结束视为文件截断，其他读取错误原样返回
+func streamErr(err error, reason string) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return corruptf("%s", reason)
+	}
+	return fmt.Errorf("读取 tsfile 失败: %w", err)
+}
+
+// readMetadata 读取尾部元数据并遍历设备索引。lower 为元数据区可能的最小偏移
+func readMetadata(r io.ReaderAt, size, lower int64) (*FileInfo, error) {
+	window := size - lower
+	if window > tailWindow {
+		window = tailWindow
+	}
+	tail := &section{base: size - window, data: make([]byte, window)}
+	if err := readFull(r, tail.data, tail.base); err != nil {
+		return nil, err
+	}
+	if string(tail.data[len(tail.data)-len(Magic):]) != Magic {
+		return nil, corruptf("缺少尾部魔数，文件可能被截断或仍在写入")
+	}
+
+	metaSize := int64(int32(binary.BigEndian.Uint32(tail.data[len(tail.data)-tailSize:])))
+	metaStart := size - int64(tailSize) - metaSize
+	if metaSize <= 0 || metaStart < lower {
+		return nil, corruptf("元数据长度 %d 超出文件范围", metaSize)
+	}
+
+	fileMeta, err := readRange(r, tail, metaStart, size-int64(tailSize))
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{buf: fileMeta, base: metaStart}
+	root := d.node()
+	metaOffset := d.int64()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if metaOffset < lower || metaOffset > metaStart {
+		return nil, corruptf("元数据区偏移 %d 超出文件范围", metaOffset)
+	}
+	if metaStart-metaOffset > maxMetadataSize {
+		return nil, unsupportedf("元数据区超过 %d 字节", maxMetadataSize)
+	}
+
+	data, err := readRange(r, tail, metaOffset, metaStart)
+	if err != nil {
+		return nil, err
+	}
+	w := &walker{meta: &section{base: metaOffset, data: data}}
+	if err := w.deviceNode(root, 0); err != nil {
+		return nil, err
+	}
+
+	info := &FileInfo{Size: size, Devices: w.devices, StartTime: math.MaxInt64, EndTime: math.MinInt64}
+	for _, dev := range w.devices {
+		info.StartTime = min(info.StartTime, dev.StartTime)
+		info.EndTime = max(info.EndTime, dev.EndTime)
+	}
+	if len(w.devices) == 0 {
+		info.StartTime, info.EndTime = 0, 0
+	}
+	return info, nil
+}
+
+// readRange 读取 [off, end)，已在 cached 中的部分不再重复读取
+func readRange(r io.ReaderAt, cached *section, off, end int64) ([]byte, error) {
+	if data, ok := cached.slice(off, end); ok {
+		return data, nil
+	}
+	data := make([]byte, end-off)
+	if err := readFull(r, data, off); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func readFull(r io.ReaderAt, p []byte, off int64) error {
+	n, err := r.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		return corruptf("读取偏移 %d 的 %d 字节时文件提前结束", off, len(p))
+	}
+	return fmt.Errorf("读取 tsfile 失败: %w", err)
+}
+
+// section 文件中从 base 开始的一段内容
+type section struct {
+	base int64
+	data []byte
+}
+
+func (s *section) slice(off, end int64) ([]byte, bool) {
+	if off < s.base || end < off || end > s.base+int64(len(s.data)) {
+		return nil, false
+	}
+	return s.data[off-s.base : end-s.base], true
+}
+
+// ReadAt 实现 io.ReaderAt，只能读取已缓存的部分
+func (s *section) ReadAt(p []byte, off int64) (int, error) {
+	if off < s.base {
+		return 0, fmt.Errorf("偏移 %d 不在已读取的元数据区内", off)
+	}
+	if off >= s.base+int64(len(s.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, s.data[off-s.base:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// countingReader 记录已读取的字节数
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
@@ -0,0 +1,93 @@
+package tsfile_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/vnnox/iotdb-restore-tool/pkg/tsfile"
+	"github.com/vnnox/iotdb-restore-tool/pkg/tsfile/tsfiletest"
+)
+
+func testFile() []byte {
+	return tsfiletest.Build(
+		tsfiletest.Device{ID: "root.energy.site42.meter1", Int64: []string{"power", "energy"}, StartTime: 1000, EndTime: 5000},
+		tsfiletest.Device{ID: "root.energy.site43.meter1", Text: []string{"status"}, Aligned: true, StartTime: 3000, EndTime: 9000},
+	)
+}
+
+func TestRead(t *testing.T) {
+	data := testFile()
+
+	readers := map[string]func([]byte) (*tsfile.FileInfo, error){
+		"random access": func(b []byte) (*tsfile.FileInfo, error) { return tsfile.Read(bytes.NewReader(b), int64(len(b))) },
+		"stream":        func(b []byte) (*tsfile.FileInfo, error) { return tsfile.ReadStream(bytes.NewReader(b)) },
+	}
+	for name, read := range readers {
+		t.Run(name, func(t *testing.T) {
+			info, err := read(data)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if info.Version != tsfile.Version3 || info.Size != int64(len(data)) {
+				t.Fatalf("unexpected file info %+v", info)
+			}
+			if info.StartTime != 1000 || info.EndTime != 9000 {
+				t.Fatalf("unexpected time range [%d, %d]", info.StartTime, info.EndTime)
+			}
+			want := []tsfile.Device{
+				{ID: "root.energy.site42.meter1", StartTime: 1000, EndTime: 5000, Series: 2},
+				{ID: "root.energy.site43.meter1", StartTime: 3000, EndTime: 9000, Series: 1},
+			}
+			if len(info.Devices) != len(want) {
+				t.Fatalf("expected %d devices, got %+v", len(want), info.Devices)
+			}
+			for i := range want {
+				if info.Devices[i] != want[i] {
+					t.Fatalf("expected %+v, got %+v", want[i], info.Devices[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadRejectsBrokenFiles(t *testing.T) {
+	data := testFile()
+	// 数据区第一个 ChunkGroup 的标记位于文件头之后
+	badMarker := append([]byte(nil), data...)
+	badMarker[7] = 0x3f
+	badVersion := append([]byte(nil), data...)
+	badVersion[6] = 4
+	badIndex := append([]byte(nil), data...)
+	// 元数据长度指向文件头之前
+	copy(badIndex[len(badIndex)-10:], []byte{0x7f, 0xff, 0xff, 0xff})
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+		// streamOnly 只有顺序读取才会检查数据区
+		streamOnly bool
+	}{
+		{name: "truncated", data: data[:len(data)-20], want: tsfile.ErrCorrupt},
+		{name: "truncated in data section", data: data[:40], want: tsfile.ErrCorrupt},
+		{name: "not a tsfile", data: bytes.Repeat([]byte("x"), 64), want: tsfile.ErrCorrupt},
+		{name: "unsupported version", data: badVersion, want: tsfile.ErrUnsupported},
+		{name: "metadata size out of range", data: badIndex, want: tsfile.ErrCorrupt},
+		{name: "unknown chunk marker", data: badMarker, want: tsfile.ErrCorrupt, streamOnly: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tsfile.ReadStream(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Fatalf("stream: expected %v, got %v", tt.want, err)
+			}
+			if tt.streamOnly {
+				return
+			}
+			if _, err := tsfile.Read(bytes.NewReader(tt.data), int64(len(tt.data))); !errors.Is(err, tt.want) {
+				t.Fatalf("random access: expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
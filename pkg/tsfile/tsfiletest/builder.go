@@ -0,0 +1,150 @@
+// Package tsfiletest 构建测试用的最小 TsFile（V3）：每个序列一个单页 Chunk，
+// 元数据索引为单层设备叶子节点，结构与 IoTDB 1.x 写入的文件一致
+package tsfiletest
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Device 测试文件中的设备，所有序列共用同一时间范围
+type Device struct {
+	ID string
+	// Int64 INT64 类型的测点
+	Int64 []string
+	// Text TEXT 类型的测点
+	Text []string
+	// Aligned 对齐设备额外写入时间列
+	Aligned   bool
+	StartTime int64
+	EndTime   int64
+}
+
+const (
+	typeInt64  byte = 2
+	typeText   byte = 5
+	typeVector byte = 6
+)
+
+type series struct {
+	name     string
+	dataType byte
+	marker   byte
+}
+
+func (d Device) series() []series {
+	var all []series
+	valueMask := byte(0)
+	if d.Aligned {
+		all = append(all, series{name: "", dataType: typeVector, marker: 0x80 | 5})
+		valueMask = 0x40
+	}
+	for _, name := range d.Int64 {
+		all = append(all, series{name: name, dataType: typeInt64, marker: valueMask | 5})
+	}
+	for _, name := range d.Text {
+		all = append(all, series{name: name, dataType: typeText, marker: valueMask | 5})
+	}
+	return all
+}
+
+// Build 返回包含指定设备的 TsFile 内容
+func Build(devices ...Device) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("TsFile")
+	buf.WriteByte(3)
+
+	// 数据区：每个设备一个 ChunkGroup，每个序列一个 8 字节的 Chunk
+	for _, dev := range devices {
+		buf.WriteByte(0)
+		writeString(&buf, dev.ID)
+		for _, s := range dev.series() {
+			buf.WriteByte(s.marker)
+			writeString(&buf, s.name)
+			writeUvarint(&buf, 8)
+			buf.Write([]byte{s.dataType, 0, 0})
+			buf.Write(make([]byte, 8))
+		}
+	}
+
+	metaOffset := int64(buf.Len())
+	buf.WriteByte(2)
+
+	// TimeseriesMetadata 和每个设备的测点叶子节点
+	type leaf struct {
+		first  string
+		offset int64
+		end    int64
+	}
+	leaves := make([]leaf, len(devices))
+	for i, dev := range devices {
+		all := dev.series()
+		leaves[i] = leaf{first: all[0].name, offset: int64(buf.Len())}
+		for _, s := range all {
+			writeTimeseries(&buf, s, dev.StartTime, dev.EndTime)
+		}
+		leaves[i].end = int64(buf.Len())
+	}
+	deviceOffsets := make([]int64, len(devices))
+	for i, l := range leaves {
+		deviceOffsets[i] = int64(buf.Len())
+		writeUvarint(&buf, 1)
+		writeString(&buf, l.first)
+		writeInt64(&buf, l.offset)
+		writeInt64(&buf, l.end)
+		buf.WriteByte(3)
+	}
+	deviceEnd := int64(buf.Len())
+
+	// TsFileMetadata：设备叶子节点、元数据区偏移
+	var meta bytes.Buffer
+	writeUvarint(&meta, uint64(len(devices)))
+	for i, dev := range devices {
+		writeString(&meta, dev.ID)
+		writeInt64(&meta, deviceOffsets[i])
+	}
+	writeInt64(&meta, deviceEnd)
+	meta.WriteByte(1)
+	writeInt64(&meta, metaOffset)
+
+	buf.Write(meta.Bytes())
+	binary.Write(&buf, binary.BigEndian, int32(meta.Len()))
+	buf.WriteString("TsFile")
+	return buf.Bytes()
+}
+
+func writeTimeseries(buf *bytes.Buffer, s series, start, end int64) {
+	buf.WriteByte(0)
+	writeString(buf, s.name)
+	buf.WriteByte(s.dataType)
+	chunkMetadata := make([]byte, 16)
+	writeUvarint(buf, uint64(len(chunkMetadata)))
+	writeUvarint(buf, 1)
+	writeInt64(buf, start)
+	writeInt64(buf, end)
+	switch s.dataType {
+	case typeInt64:
+		buf.Write(make([]byte, 40))
+	case typeText:
+		for _, v := range []string{"first", "last"} {
+			binary.Write(buf, binary.BigEndian, int32(len(v)))
+			buf.WriteString(v)
+		}
+	}
+	buf.Write(chunkMetadata)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutVarint(tmp[:], int64(len(s)))])
+	buf.WriteString(s)
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+func writeInt64(buf *bytes.Buffer, v int64) {
+	binary.Write(buf, binary.BigEndian, v)
+}